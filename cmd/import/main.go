@@ -3,19 +3,25 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 
+	"github.com/comfortablynumb/pmp-mock-http/internal/har"
 	"github.com/comfortablynumb/pmp-mock-http/internal/models"
 	"github.com/comfortablynumb/pmp-mock-http/internal/openapi"
 )
 
 func main() {
 	// Define flags
-	input := flag.String("input", "", "Path or URL to OpenAPI/Swagger spec (required)")
+	input := flag.String("input", "", "Path or URL to OpenAPI/Swagger spec, Postman collection or HAR file (required)")
+	format := flag.String("format", "auto", "Input format: auto, openapi, postman or har")
 	output := flag.String("output", "mocks/imported.yaml", "Output path for generated mocks")
 	generateExamples := flag.Bool("generate-examples", false, "Generate example responses from schemas")
+	validateImported := flag.Bool("validate-imported", false, "Validate request bodies against the requestBody schemas of the spec")
+	harSequences := flag.Bool("har-sequences", false, "Group the responses recorded for the same request of a HAR file into a sequence")
 	flag.Parse()
 
 	// Validate input
@@ -25,22 +31,43 @@ func main() {
 		os.Exit(1)
 	}
 
-	log.Printf("PMP Mock HTTP - OpenAPI/Swagger Importer\n")
-	log.Printf("==========================================\n")
+	log.Printf("PMP Mock HTTP - Mock Importer\n")
+	log.Printf("=============================\n")
 
-	// Create parser
-	parser := openapi.NewParser(*generateExamples)
-
-	// Parse spec
-	var mockSpec *models.MockSpec
+	// Read spec
+	var data []byte
 	var err error
 
 	if isURL(*input) {
 		log.Printf("Fetching spec from URL: %s\n", *input)
-		mockSpec, err = parser.ParseURL(*input)
+		data, err = fetch(*input)
 	} else {
 		log.Printf("Reading spec from file: %s\n", *input)
-		mockSpec, err = parser.ParseFile(*input)
+		data, err = os.ReadFile(*input)
+	}
+
+	if err != nil {
+		log.Fatalf("Failed to read spec: %v\n", err)
+	}
+
+	if *format == "auto" {
+		*format = detectFormat(data)
+	}
+
+	// Parse spec
+	var mockSpec *models.MockSpec
+
+	switch *format {
+	case "openapi":
+		parser := openapi.NewParser(*generateExamples)
+		parser.SetValidateRequests(*validateImported)
+		mockSpec, err = parser.Parse(data, *input)
+	case "postman":
+		mockSpec, err = openapi.ParsePostmanCollection(data)
+	case "har":
+		mockSpec, err = har.ParseMocks(data, *harSequences)
+	default:
+		log.Fatalf("Unsupported format: %s (expected auto, openapi, postman or har)\n", *format)
 	}
 
 	if err != nil {
@@ -54,7 +81,7 @@ func main() {
 		log.Fatalf("Failed to save mocks: %v\n", err)
 	}
 
-	log.Printf("✓ Successfully imported OpenAPI spec\n")
+	log.Printf("✓ Successfully imported %s input\n", *format)
 	log.Printf("✓ Mocks saved to: %s\n", *output)
 	log.Printf("\nTo use these mocks, start the server with:\n")
 	log.Printf("  ./pmp-mock-http --mocks-dir %s\n", *output)
@@ -64,3 +91,29 @@ func main() {
 func isURL(input string) bool {
 	return strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://")
 }
+
+// fetch downloads the spec at the URL
+func fetch(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch spec: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // body is fully read
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch spec: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// detectFormat returns the format of the input: postman for Postman collections, har for
+// HAR files, else openapi
+func detectFormat(data []byte) string {
+	if openapi.IsPostmanCollection(data) {
+		return "postman"
+	}
+	if har.IsHAR(data) {
+		return "har"
+	}
+	return "openapi"
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -16,6 +20,8 @@ import (
 	"github.com/comfortablynumb/pmp-mock-http/internal/grpc"
 	"github.com/comfortablynumb/pmp-mock-http/internal/loader"
 	"github.com/comfortablynumb/pmp-mock-http/internal/management"
+	"github.com/comfortablynumb/pmp-mock-http/internal/matcher"
+	"github.com/comfortablynumb/pmp-mock-http/internal/oauth"
 	"github.com/comfortablynumb/pmp-mock-http/internal/observability"
 	"github.com/comfortablynumb/pmp-mock-http/internal/plugins"
 	"github.com/comfortablynumb/pmp-mock-http/internal/proxy"
@@ -37,6 +43,16 @@ func getEnvInt(key string, defaultVal int) int {
 	return defaultVal
 }
 
+// getEnvInt64 gets a 64-bit integer value from environment variable, or returns the default
+func getEnvInt64(key string, defaultVal int64) int64 {
+	if val := os.Getenv(key); val != "" {
+		if intVal, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return intVal
+		}
+	}
+	return defaultVal
+}
+
 // getEnvString gets a string value from environment variable, or returns the default
 func getEnvString(key string, defaultVal string) string {
 	if val := os.Getenv(key); val != "" {
@@ -60,20 +76,50 @@ var (
 	uiPort              = flag.Int("ui-port", getEnvInt("UI_PORT", 8081), "UI dashboard port")
 	mocksDir            = flag.String("mocks-dir", getEnvString("MOCKS_DIR", "mocks"), "Directory containing mock YAML files")
 	pluginsDir          = flag.String("plugins-dir", getEnvString("PLUGINS_DIR", "plugins"), "Directory to store plugin repositories")
-	pluginList          = flag.String("plugins", getEnvString("PLUGINS", ""), "Comma-separated list of git repository URLs to clone as plugins")
+	pluginList          = flag.String("plugins", getEnvString("PLUGINS", ""), "Comma-separated list of git repository URLs to clone as plugins, or local plugin directories (absolute paths or file:// URLs)")
 	pluginIncludeOnly   = flag.String("plugin-include-only", getEnvString("PLUGIN_INCLUDE_ONLY", ""), "Space-separated list of subdirectories from pmp-mock-http to include (e.g., 'openai stripe')")
+	trackerCapacity     = flag.Int("tracker-capacity", getEnvInt("TRACKER_CAPACITY", 1000), "Number of requests kept for the dashboard (0 = unbounded, up to 100000)")
+	reloadDebounce      = flag.Int("reload-debounce-ms", getEnvInt("RELOAD_DEBOUNCE_MS", 100), "Milliseconds to wait after the last change to a mock file before reloading")
+	watchExclude        = flag.String("watch-exclude", getEnvString("WATCH_EXCLUDE", strings.Join(watcher.DefaultExcludes, ",")), "Comma-separated glob patterns of file and directory names that don't trigger a reload (empty = none)")
+	pluginRefresh       = flag.Int("plugin-refresh-interval", getEnvInt("PLUGIN_REFRESH_INTERVAL", 0), "Seconds between automatic plugin updates (0 = only on startup and /__plugins/refresh)")
+	pluginGitUsername   = flag.String("plugin-git-username", getEnvString("PLUGIN_GIT_USERNAME", ""), "Username sent with --plugin-git-token (default: x-access-token)")
+	pluginGitToken      = flag.String("plugin-git-token", getEnvString("PLUGIN_GIT_TOKEN", ""), "Token for cloning private plugin repositories over HTTPS")
+	pluginSSHKey        = flag.String("plugin-ssh-key", getEnvString("PLUGIN_SSH_KEY", ""), "Private key for cloning private plugin repositories over SSH")
 	proxyTarget         = flag.String("proxy-target", getEnvString("PROXY_TARGET", ""), "Target URL for proxy passthrough (e.g., 'http://api.example.com')")
 	proxyPreserveHost   = flag.Bool("proxy-preserve-host", getEnvBool("PROXY_PRESERVE_HOST", false), "Preserve the original Host header when proxying")
 	proxyTimeout        = flag.Int("proxy-timeout", getEnvInt("PROXY_TIMEOUT", 30), "Proxy request timeout in seconds")
+	defaultResponse     = flag.String("default-response", getEnvString("DEFAULT_RESPONSE", ""), "Response for unmatched requests without a proxy: a YAML file (.yaml/.yml) with a response definition, or the name of a mock whose response is used")
+	adminAllowCIDR      = flag.String("admin-allow-cidr", getEnvString("ADMIN_ALLOW_CIDR", ""), "Comma-separated CIDRs or IPs allowed to call the control endpoints (/__*); other sources get a 403 (default: any source)")
+	adminOpenMethods    = flag.String("admin-open-methods", getEnvString("ADMIN_OPEN_METHODS", ""), "Comma-separated methods the control endpoints accept from any source when --admin-allow-cidr is set (e.g., 'GET')")
+	oauthConfig         = flag.String("oauth-config", getEnvString("OAUTH_CONFIG", ""), "YAML file of OAuth2 clients and users; serves the built-in OAuth2 provider under /oauth/ and /.well-known/jwks.json")
+	proxyStripHeaders   = flag.String("proxy-strip-headers", getEnvString("PROXY_STRIP_HEADERS", ""), "Comma-separated list of headers removed from proxied responses (e.g., 'Strict-Transport-Security')")
+	proxyAddHeaders     = flag.String("proxy-add-headers", getEnvString("PROXY_ADD_HEADERS", ""), "Comma-separated list of Name=value headers added to proxied responses")
 	tlsEnabled          = flag.Bool("tls", getEnvBool("TLS_ENABLED", false), "Enable TLS/HTTPS with HTTP/2")
 	tlsCertFile         = flag.String("tls-cert", getEnvString("TLS_CERT_FILE", ""), "Path to TLS certificate file")
 	tlsKeyFile          = flag.String("tls-key", getEnvString("TLS_KEY_FILE", ""), "Path to TLS private key file")
+	tlsClientCA         = flag.String("tls-client-ca", getEnvString("TLS_CLIENT_CA_FILE", ""), "Path to a PEM file of CAs verifying client certificates, for mocks matching on client_cert (requires TLS)")
 	http3Enabled        = flag.Bool("http3", getEnvBool("HTTP3_ENABLED", false), "Enable HTTP/3 with QUIC (requires TLS)")
 	dualStack           = flag.Bool("dual-stack", getEnvBool("DUAL_STACK", false), "Enable both HTTP/2 and HTTP/3 (requires TLS)")
+	autoTLSDetect       = flag.Bool("auto-tls-detect", getEnvBool("AUTO_TLS_DETECT", false), "Serve both HTTPS and plain HTTP on the same port by detecting TLS handshakes (requires TLS)")
 	enableCORS          = flag.Bool("enable-cors", getEnvBool("ENABLE_CORS", false), "Enable CORS support")
 	corsOrigins         = flag.String("cors-origins", getEnvString("CORS_ORIGINS", "*"), "CORS allowed origins")
 	corsMethods         = flag.String("cors-methods", getEnvString("CORS_METHODS", "GET,POST,PUT,DELETE,PATCH,OPTIONS"), "CORS allowed methods")
 	corsHeaders         = flag.String("cors-headers", getEnvString("CORS_HEADERS", "Content-Type,Authorization"), "CORS allowed headers")
+	decodeRequestBody   = flag.Bool("decode-request-body", getEnvBool("DECODE_REQUEST_BODY", false), "Decompress gzip, deflate and br request bodies before matching")
+	jsTimeoutMs         = flag.Int("js-timeout-ms", getEnvInt("JS_TIMEOUT_MS", int(matcher.DefaultJSTimeout/time.Millisecond)), "Max execution time of JavaScript matchers in milliseconds (0 = no limit)")
+	prettyJSON          = flag.Bool("pretty-json", getEnvBool("PRETTY_JSON", false), "Indent JSON response bodies of all mocks")
+	compress            = flag.Bool("compress", getEnvBool("COMPRESS", false), "Gzip response bodies for clients that send Accept-Encoding: gzip")
+	compressLevel       = flag.Int("compress-level", getEnvInt("COMPRESS_LEVEL", gzip.DefaultCompression), "Gzip compression level from 1 (fastest) to 9 (best), -1 = default")
+	compressMinBytes    = flag.Int("compress-min-bytes", getEnvInt("COMPRESS_MIN_BYTES", server.DefaultCompressMinBytes), "Smallest response body in bytes that gets compressed")
+	maintenance         = flag.Bool("maintenance", getEnvBool("MAINTENANCE", false), "Start in maintenance mode, returning 503 for all requests except the allowed paths (toggle via /__maintenance)")
+	maintenanceBody     = flag.String("maintenance-body", getEnvString("MAINTENANCE_BODY", ""), "Response body while in maintenance mode (default: JSON error)")
+	maintenanceAllow    = flag.String("maintenance-allow", getEnvString("MAINTENANCE_ALLOW", "/health,/ready,/live"), "Comma-separated paths served normally during maintenance")
+	shutdownTimeout     = flag.Int("shutdown-timeout", getEnvInt("SHUTDOWN_TIMEOUT", 10), "Seconds to wait for in-flight requests to finish on shutdown")
+	seed                = flag.Int64("seed", getEnvInt64("SEED", 0), "Seed for chaos injection, random latencies and weighted responses, making them reproducible (0 = random)")
+	fixedTime           = flag.String("fixed-time", getEnvString("FIXED_TIME", ""), "Fix the clock used by templates and time-based matching at this RFC 3339 time (e.g. 2024-01-01T00:00:00Z)")
+	maxHeaderBytes      = flag.Int("max-header-bytes", getEnvInt("MAX_HEADER_BYTES", 0), "Maximum request header size in bytes (0 = Go default of 1MB); larger headers get a 431")
+	mergeStrategy       = flag.String("merge-strategy", getEnvString("MERGE_STRATEGY", "keep-all"), "How to combine mocks with the same name across files (keep-all, error, last-wins, first-wins)")
+	dirPrecedence       = flag.String("dir-precedence", getEnvString("DIR_PRECEDENCE", "none"), "Which directory wins when the mocks directory and plugins define the same mock name (none, first, last)")
 	validateMocks       = flag.Bool("validate-mocks", getEnvBool("VALIDATE_MOCKS", true), "Validate mock configurations on startup")
 
 	// Observability flags
@@ -83,6 +129,10 @@ var (
 	otlpEndpoint        = flag.String("otlp-endpoint", getEnvString("OTLP_ENDPOINT", "localhost:4317"), "OTLP collector endpoint")
 	enableHealthCheck   = flag.Bool("enable-health", getEnvBool("ENABLE_HEALTH", true), "Enable health check endpoints")
 	healthPort          = flag.Int("health-port", getEnvInt("HEALTH_PORT", 8080), "Health check and metrics endpoints port")
+	metricsPath         = flag.String("metrics-path", getEnvString("METRICS_PATH", "/metrics"), "Path of the Prometheus metrics endpoint on the health port")
+	enablePprof         = flag.Bool("enable-pprof", getEnvBool("ENABLE_PPROF", false), "Serve runtime profiles (goroutine, heap, CPU) under /debug/pprof/ on the health port")
+	pprofToken          = flag.String("pprof-token", getEnvString("PPROF_TOKEN", ""), "Bearer token required to access /debug/pprof/ (required with --enable-pprof)")
+	metricsToken        = flag.String("metrics-token", getEnvString("METRICS_TOKEN", ""), "Bearer token required to scrape metrics (empty = no authentication)")
 
 	// Management API flags
 	enableManagementAPI = flag.Bool("enable-management", getEnvBool("ENABLE_MANAGEMENT", true), "Enable management API")
@@ -98,9 +148,180 @@ var (
 	grpcPort            = flag.Int("grpc-port", getEnvInt("GRPC_PORT", 9000), "gRPC server port")
 )
 
+// listenerPort describes a port used by one of the servers started by main
+type listenerPort struct {
+	flag    string
+	port    int
+	enabled bool
+}
+
+// parseHeaderPairs parses a comma-separated list of Name=value headers
+func parseHeaderPairs(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, headerValue, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid header %q (must be Name=value)", pair)
+		}
+		headers[name] = strings.TrimSpace(headerValue)
+	}
+	return headers, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// validateFlags checks flag combinations that would otherwise fail late or behave oddly during startup
+func validateFlags() error {
+	// HTTP/3 and dual-stack mode require TLS
+	if (*http3Enabled || *dualStack) && !*tlsEnabled {
+		return fmt.Errorf("--http3 and --dual-stack require TLS to be enabled (--tls)")
+	}
+
+	if *autoTLSDetect {
+		if !*tlsEnabled {
+			return fmt.Errorf("--auto-tls-detect requires TLS to be enabled (--tls)")
+		}
+		if *http3Enabled || *dualStack {
+			return fmt.Errorf("--auto-tls-detect can't be combined with --http3 or --dual-stack")
+		}
+	}
+
+	if *tlsEnabled && (*tlsCertFile == "" || *tlsKeyFile == "") {
+		return fmt.Errorf("--tls requires both --tls-cert and --tls-key")
+	}
+
+	if *tlsClientCA != "" {
+		if !*tlsEnabled {
+			return fmt.Errorf("--tls-client-ca requires TLS to be enabled (--tls)")
+		}
+		if *http3Enabled && !*dualStack {
+			return fmt.Errorf("--tls-client-ca isn't supported with --http3")
+		}
+	}
+
+	if *adminOpenMethods != "" && *adminAllowCIDR == "" {
+		return fmt.Errorf("--admin-open-methods requires --admin-allow-cidr")
+	}
+
+	if *shutdownTimeout < 0 {
+		return fmt.Errorf("--shutdown-timeout must be >= 0, got %d", *shutdownTimeout)
+	}
+
+	if *reloadDebounce <= 0 {
+		return fmt.Errorf("--reload-debounce-ms must be > 0, got %d", *reloadDebounce)
+	}
+
+	for _, pattern := range strings.Split(*watchExclude, ",") {
+		if _, err := filepath.Match(strings.TrimSpace(pattern), ""); err != nil {
+			return fmt.Errorf("--watch-exclude has an invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	if *trackerCapacity < 0 {
+		return fmt.Errorf("--tracker-capacity must be >= 0, got %d", *trackerCapacity)
+	}
+
+	if *pluginRefresh < 0 {
+		return fmt.Errorf("--plugin-refresh-interval must be >= 0, got %d", *pluginRefresh)
+	}
+
+	if *maxHeaderBytes < 0 {
+		return fmt.Errorf("--max-header-bytes must be >= 0, got %d", *maxHeaderBytes)
+	}
+
+	if *jsTimeoutMs < 0 {
+		return fmt.Errorf("--js-timeout-ms must be >= 0, got %d", *jsTimeoutMs)
+	}
+
+	if *compressLevel != gzip.DefaultCompression && (*compressLevel < gzip.BestSpeed || *compressLevel > gzip.BestCompression) {
+		return fmt.Errorf("--compress-level must be between 1 and 9 (or -1 for the default), got %d", *compressLevel)
+	}
+
+	if *compressMinBytes < 0 {
+		return fmt.Errorf("--compress-min-bytes must be >= 0, got %d", *compressMinBytes)
+	}
+
+	if *enableMetrics {
+		if !strings.HasPrefix(*metricsPath, "/") {
+			return fmt.Errorf("--metrics-path must start with '/', got %q", *metricsPath)
+		}
+		if *enableHealthCheck && (*metricsPath == "/health" || *metricsPath == "/ready" || *metricsPath == "/live") {
+			return fmt.Errorf("--metrics-path %s conflicts with a health endpoint", *metricsPath)
+		}
+		if *enablePprof && strings.HasPrefix(*metricsPath, observability.PprofPath) {
+			return fmt.Errorf("--metrics-path %s conflicts with the pprof endpoints", *metricsPath)
+		}
+	}
+
+	if *enablePprof && *pprofToken == "" {
+		return fmt.Errorf("--enable-pprof requires --pprof-token")
+	}
+
+	if _, err := parseHeaderPairs(*proxyAddHeaders); err != nil {
+		return fmt.Errorf("--proxy-add-headers: %w", err)
+	}
+
+	if _, err := loader.ParseMergeStrategy(*mergeStrategy); err != nil {
+		return fmt.Errorf("--merge-strategy: %w", err)
+	}
+
+	if _, err := loader.ParseDirPrecedence(*dirPrecedence); err != nil {
+		return fmt.Errorf("--dir-precedence: %w", err)
+	}
+
+	if *fixedTime != "" {
+		if _, err := time.Parse(time.RFC3339, *fixedTime); err != nil {
+			return fmt.Errorf("--fixed-time must be an RFC 3339 time (e.g. 2024-01-01T00:00:00Z): %w", err)
+		}
+	}
+
+	// Check for port collisions across all enabled servers
+	ports := []listenerPort{
+		{flag: "port", port: *port, enabled: true},
+		{flag: "ui-port", port: *uiPort, enabled: true},
+		{flag: "health-port", port: *healthPort, enabled: *enableHealthCheck || *enableMetrics || *enablePprof},
+		{flag: "management-port", port: *managementPort, enabled: *enableManagementAPI},
+		{flag: "graphql-port", port: *graphqlPort, enabled: *enableGraphQL},
+		{flag: "grpc-port", port: *grpcPort, enabled: *enableGRPC},
+	}
+
+	used := make(map[int]string)
+	for _, p := range ports {
+		if !p.enabled {
+			continue
+		}
+		if p.port < 1 || p.port > 65535 {
+			return fmt.Errorf("--%s must be between 1 and 65535, got %d", p.flag, p.port)
+		}
+		if other, exists := used[p.port]; exists {
+			return fmt.Errorf("--%s and --%s both use port %d", other, p.flag, p.port)
+		}
+		used[p.port] = p.flag
+	}
+
+	return nil
+}
+
 func main() {
 	flag.Parse()
 
+	if err := validateFlags(); err != nil {
+		log.Fatalf("Invalid configuration: %v\n", err)
+	}
+
 	// Initialize observability (structured logging)
 	isDevelopment := *logLevel == "debug"
 	if err := observability.InitLogger(*logLevel, isDevelopment); err != nil {
@@ -170,13 +391,21 @@ func main() {
 
 	// Set up plugins (clone/update repositories)
 	var pluginDirs []string
+	var pluginManager *plugins.Manager
+	if len(pluginIncludeFilter) > 0 {
+		pluginManager = plugins.NewManagerWithIncludeFilter(*pluginsDir, pluginRepos, pluginIncludeFilter)
+	} else {
+		pluginManager = plugins.NewManager(*pluginsDir, pluginRepos)
+	}
+	if *pluginGitToken != "" || *pluginSSHKey != "" {
+		pluginManager.SetCredentials(&plugins.Credentials{
+			Username:   *pluginGitUsername,
+			Token:      *pluginGitToken,
+			SSHKeyPath: *pluginSSHKey,
+		})
+		log.Println("Plugin git credentials configured")
+	}
 	if len(pluginRepos) > 0 {
-		var pluginManager *plugins.Manager
-		if len(pluginIncludeFilter) > 0 {
-			pluginManager = plugins.NewManagerWithIncludeFilter(*pluginsDir, pluginRepos, pluginIncludeFilter)
-		} else {
-			pluginManager = plugins.NewManager(*pluginsDir, pluginRepos)
-		}
 		var err error
 		pluginDirs, err = pluginManager.SetupPlugins()
 		if err != nil {
@@ -190,9 +419,16 @@ func main() {
 
 	// Create the loader with all directories
 	mockLoader := loader.NewLoader(loadDirs...)
+	strategy, _ := loader.ParseMergeStrategy(*mergeStrategy) // Already checked by validateFlags
+	mockLoader.SetMergeStrategy(strategy)
+	precedence, _ := loader.ParseDirPrecedence(*dirPrecedence) // Already checked by validateFlags
+	mockLoader.SetDirPrecedence(precedence)
 
 	// Load initial mocks
 	if err := mockLoader.LoadAll(); err != nil {
+		if errors.Is(err, loader.ErrDuplicateMocks) {
+			log.Fatalf("Failed to load mocks: %v\n", err)
+		}
 		log.Printf("Warning: failed to load mocks: %v\n", err)
 	}
 
@@ -209,7 +445,7 @@ func main() {
 	}
 
 	// Create request tracker for UI dashboard
-	requestTracker := tracker.NewTracker(1000) // Keep last 1000 requests
+	requestTracker := tracker.NewTracker(*trackerCapacity)
 
 	// Create proxy configuration if proxy target is specified
 	var proxyConfig *proxy.Config
@@ -219,6 +455,12 @@ func main() {
 			PreserveHost: *proxyPreserveHost,
 			Timeout:      time.Duration(*proxyTimeout) * time.Second,
 		}
+		for _, header := range strings.Split(*proxyStripHeaders, ",") {
+			if header = strings.TrimSpace(header); header != "" {
+				proxyConfig.StripHeaders = append(proxyConfig.StripHeaders, header)
+			}
+		}
+		proxyConfig.AddHeaders, _ = parseHeaderPairs(*proxyAddHeaders) // Validated in validateFlags
 	}
 
 	// Create CORS configuration if enabled
@@ -235,9 +477,118 @@ func main() {
 
 	// Create the mock server with tracker, proxy config, and CORS config
 	srv := server.NewServerWithTracker(*port, mockLoader.GetMocks(), requestTracker, proxyConfig, corsConfig)
+	srv.SetDecodeRequestBody(*decodeRequestBody)
+	srv.SetPrettyJSON(*prettyJSON)
+	srv.SetJSTimeout(time.Duration(*jsTimeoutMs) * time.Millisecond)
+	srv.SetCompression(*compress, *compressLevel, *compressMinBytes)
+	srv.SetSeed(*seed)
+	if *seed != 0 {
+		log.Printf("Random source seeded with %d\n", *seed)
+	}
+	var maintenancePaths []string
+	for _, path := range strings.Split(*maintenanceAllow, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			maintenancePaths = append(maintenancePaths, path)
+		}
+	}
+	srv.SetMaintenance(*maintenance, *maintenanceBody, maintenancePaths)
+	if *maintenance {
+		log.Printf("Maintenance mode enabled, allowed paths: %s\n", *maintenanceAllow)
+	}
+	if *fixedTime != "" {
+		t, _ := time.Parse(time.RFC3339, *fixedTime) // Validated in validateFlags
+		srv.Clock().Set(t)
+		log.Printf("Clock fixed at %s\n", t.Format(time.RFC3339))
+	}
+	srv.SetMaxHeaderBytes(*maxHeaderBytes)
+	if *defaultResponse != "" {
+		if ext := strings.ToLower(filepath.Ext(*defaultResponse)); ext == ".yaml" || ext == ".yml" {
+			response, err := loader.LoadResponse(*defaultResponse)
+			if err != nil {
+				log.Fatalf("Failed to load default response: %v\n", err)
+			}
+			srv.SetDefaultResponse(response)
+			log.Printf("Default response for unmatched requests loaded from %s\n", *defaultResponse)
+		} else {
+			srv.SetDefaultResponseMock(*defaultResponse)
+			log.Printf("Default response for unmatched requests: mock '%s'\n", *defaultResponse)
+		}
+	}
+	if err := srv.SetAdminAccess(splitList(*adminAllowCIDR), splitList(*adminOpenMethods)); err != nil {
+		log.Fatalf("Failed to configure admin access: %v\n", err)
+	}
+	if *adminAllowCIDR != "" {
+		log.Printf("Control endpoints restricted to %s\n", *adminAllowCIDR)
+	}
+	if *oauthConfig != "" {
+		scheme := "http"
+		if *tlsEnabled {
+			scheme = "https"
+		}
+		provider, err := oauth.NewOAuth2Provider(fmt.Sprintf("%s://localhost:%d", scheme, *port))
+		if err != nil {
+			log.Fatalf("Failed to create OAuth2 provider: %v\n", err)
+		}
+		config, err := oauth.LoadConfig(*oauthConfig)
+		if err != nil {
+			log.Fatalf("Failed to load OAuth2 config: %v\n", err)
+		}
+		if err := provider.ApplyConfig(config); err != nil {
+			log.Fatalf("Invalid OAuth2 config %s: %v\n", *oauthConfig, err)
+		}
+		srv.SetOAuthProvider(provider)
+		log.Printf("OAuth2 provider enabled with the clients and users of %s\n", *oauthConfig)
+	}
+	if *tlsClientCA != "" {
+		if err := srv.SetTLSClientCA(*tlsClientCA); err != nil {
+			log.Fatalf("Failed to configure client certificates: %v\n", err)
+		}
+		log.Printf("Client certificates verified with CAs from %s\n", *tlsClientCA)
+	}
+	// Create reload function for the watcher
+	reloadFn := func() error {
+		if err := mockLoader.LoadAll(); err != nil {
+			return err
+		}
+		srv.UpdateMocks(mockLoader.GetMocks())
+		return nil
+	}
+
+	// Create the file watchers, one per mock directory. They're started below; plugin
+	// refreshes add and remove the directories of their plugins.
+	watcherOptions := watcher.Options{
+		Debounce: time.Duration(*reloadDebounce) * time.Millisecond,
+		Exclude:  []string{},
+	}
+	for _, pattern := range strings.Split(*watchExclude, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			watcherOptions.Exclude = append(watcherOptions.Exclude, pattern)
+		}
+	}
+	watchers := watcher.NewGroup(reloadFn, watcherOptions)
+	defer watchers.Close()
+
+	srv.SetPluginManager(pluginManager, func() error {
+		dirs, err := pluginManager.SetupPlugins()
+		if err != nil {
+			return err
+		}
+		dirs = append([]string{*mocksDir}, dirs...)
+		mockLoader.SetDirectories(dirs...)
+		watchers.SetDirectories(dirs...)
+		return reloadFn()
+	})
 
 	// Create and start the UI server
 	uiServer := ui.NewServer(*uiPort, requestTracker)
+	// Replays go over TCP, so they're unavailable when the mock server only speaks HTTP/3
+	if !*http3Enabled || *dualStack {
+		scheme := "http"
+		if *tlsEnabled && !*autoTLSDetect {
+			scheme = "https"
+		}
+		uiServer.SetReplayTarget(fmt.Sprintf("%s://localhost:%d", scheme, *port))
+	}
 	go func() {
 		if err := uiServer.Start(); err != nil {
 			log.Fatalf("UI server error: %v\n", err)
@@ -248,6 +599,9 @@ func main() {
 	var mockManager *management.Manager
 	if *enableManagementAPI {
 		mockManager = management.NewManager()
+		mockManager.SetSequenceCounters(srv)
+		srv.SetManagedMocks(mockManager)
+		mockManager.SetChangeHandler(srv.RefreshManagedMocks)
 
 		// Load default templates if enabled
 		if *loadTemplates {
@@ -280,7 +634,7 @@ func main() {
 	}
 
 	// Initialize and start Health/Metrics server
-	if *enableHealthCheck || *enableMetrics {
+	if *enableHealthCheck || *enableMetrics || *enablePprof {
 		healthMux := http.NewServeMux()
 
 		if *enableHealthCheck {
@@ -290,7 +644,12 @@ func main() {
 		}
 
 		if *enableMetrics {
-			healthMux.Handle("/metrics", observability.MetricsHandler())
+			healthMux.Handle(*metricsPath, observability.ProtectedMetricsHandler(*metricsToken))
+		}
+
+		if *enablePprof {
+			healthMux.Handle(observability.PprofPath, observability.PprofHandler(*pprofToken))
+			log.Printf("Profiling endpoints enabled on port %d at %s\n", *healthPort, observability.PprofPath)
 		}
 
 		healthServer := &http.Server{
@@ -362,34 +721,27 @@ func main() {
 		}
 	}
 
-	// Create reload function for the watcher
-	reloadFn := func() error {
-		if err := mockLoader.LoadAll(); err != nil {
-			return err
-		}
-		srv.UpdateMocks(mockLoader.GetMocks())
-		return nil
-	}
-
-	// Create and start file watchers for all directories
-	var watchers []*watcher.Watcher
-	for _, dir := range loadDirs {
-		w, err := watcher.NewWatcher(dir, reloadFn)
-		if err != nil {
-			log.Printf("Warning: failed to create watcher for %s: %v\n", dir, err)
-			continue
-		}
-		defer w.Close() //nolint:errcheck // cleanup operation
-
-		if err := w.Start(); err != nil {
-			log.Printf("Warning: failed to start watcher for %s: %v\n", dir, err)
-			continue
-		}
-		watchers = append(watchers, w)
+	// Start file watchers for all directories
+	watched := watchers.SetDirectories(loadDirs...)
+
+	log.Printf("Watching %d directory(ies) for changes\n", watched)
+
+	// Periodically update the plugins. Pulled files also trigger the watchers; the reload
+	// here picks up plugins whose mock directories changed, and their directories are
+	// watched (or not anymore) from now on.
+	if *pluginRefresh > 0 && len(pluginRepos) > 0 {
+		pluginManager.StartAutoRefresh(time.Duration(*pluginRefresh)*time.Second, func(dirs []string) {
+			dirs = append([]string{*mocksDir}, dirs...)
+			mockLoader.SetDirectories(dirs...)
+			watchers.SetDirectories(dirs...)
+			if err := reloadFn(); err != nil {
+				log.Printf("Error reloading mocks after plugin refresh: %v\n", err)
+			}
+		})
+		defer pluginManager.StopAutoRefresh()
+		log.Printf("Plugins refreshed every %ds\n", *pluginRefresh)
 	}
 
-	log.Printf("Watching %d directory(ies) for changes\n", len(watchers))
-
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -398,20 +750,15 @@ func main() {
 	go func() {
 		var err error
 
-		// Validate TLS configuration for HTTP/3 and dual-stack
-		if (*http3Enabled || *dualStack) && !*tlsEnabled {
-			log.Fatalf("HTTP/3 and dual-stack mode require TLS to be enabled (--tls)\n")
-		}
-
-		if *tlsEnabled || *http3Enabled || *dualStack {
-			if *tlsCertFile == "" || *tlsKeyFile == "" {
-				log.Fatalf("TLS/HTTP3 enabled but certificate or key file not specified\n")
-			}
-
+		// TLS flag combinations have already been checked by validateFlags
+		if *tlsEnabled {
 			// Choose server mode
 			if *dualStack {
 				log.Println("Starting server in dual-stack mode (HTTP/1.1, HTTP/2, HTTP/3)")
 				err = srv.StartDualStack(*tlsCertFile, *tlsKeyFile)
+			} else if *autoTLSDetect {
+				log.Println("Starting server in TLS auto-detection mode (HTTP/1.1 with and without TLS)")
+				err = srv.StartAutoTLS(*tlsCertFile, *tlsKeyFile)
 			} else if *http3Enabled {
 				log.Println("Starting server in HTTP/3 mode")
 				err = srv.StartHTTP3(*tlsCertFile, *tlsKeyFile)
@@ -429,7 +776,12 @@ func main() {
 		}
 	}()
 
-	// Wait for shutdown signal
+	// Wait for shutdown signal, then let in-flight requests finish
 	<-sigChan
 	log.Println("\nShutting down gracefully...")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*shutdownTimeout)*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down server: %v\n", err)
+	}
 }
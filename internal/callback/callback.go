@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/comfortablynumb/pmp-mock-http/internal/clock"
 	"github.com/comfortablynumb/pmp-mock-http/internal/models"
 	"github.com/comfortablynumb/pmp-mock-http/internal/template"
 )
@@ -27,6 +28,16 @@ func NewExecutor() *Executor {
 	}
 }
 
+// SetClock sets the time source of the time functions in callback templates
+func (e *Executor) SetClock(c clock.Clock) {
+	e.renderer.SetClock(c)
+}
+
+// SetVariables sets the variables read by the env function in callback templates
+func (e *Executor) SetVariables(v *template.Variables) {
+	e.renderer.SetVariables(v)
+}
+
 // Execute executes a callback asynchronously
 func (e *Executor) Execute(callback *models.Callback, requestData *template.RequestData) {
 	if callback == nil || callback.URL == "" {
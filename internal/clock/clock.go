@@ -0,0 +1,87 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time. Components that depend on time take a Clock instead
+// of calling time.Now() so time-based mocks can be made reproducible.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock reads the system time
+type systemClock struct{}
+
+// Now returns the current system time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// System is the Clock that reads the system time
+var System Clock = systemClock{}
+
+// Virtual is a Clock that follows the system time until it's set to a fixed time.
+// A fixed virtual clock only moves when advanced.
+type Virtual struct {
+	fixed bool
+	now   time.Time
+	mu    sync.RWMutex
+}
+
+// NewVirtual creates a virtual clock that follows the system time
+func NewVirtual() *Virtual {
+	return &Virtual{}
+}
+
+// Now returns the fixed time, or the system time if the clock isn't fixed
+func (v *Virtual) Now() time.Time {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if !v.fixed {
+		return time.Now()
+	}
+	return v.now
+}
+
+// Set fixes the clock at the given time
+func (v *Virtual) Set(t time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.fixed = true
+	v.now = t
+}
+
+// Advance moves the clock forward (or backward for negative durations) and returns the new time.
+// A clock that follows the system time is fixed at the advanced system time.
+func (v *Virtual) Advance(d time.Duration) time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if !v.fixed {
+		v.fixed = true
+		v.now = time.Now()
+	}
+	v.now = v.now.Add(d)
+	return v.now
+}
+
+// Reset makes the clock follow the system time again
+func (v *Virtual) Reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.fixed = false
+	v.now = time.Time{}
+}
+
+// Fixed reports whether the clock is fixed instead of following the system time
+func (v *Virtual) Fixed() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.fixed
+}
@@ -0,0 +1,41 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVirtualClock(t *testing.T) {
+	c := NewVirtual()
+
+	if c.Fixed() {
+		t.Error("Expected a new clock to follow the system time")
+	}
+	if since := time.Since(c.Now()); since < 0 || since > time.Second {
+		t.Errorf("Expected the system time, got %v", c.Now())
+	}
+
+	fixed := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.Set(fixed)
+	if !c.Now().Equal(fixed) {
+		t.Errorf("Expected %v, got %v", fixed, c.Now())
+	}
+
+	if got := c.Advance(90 * time.Minute); !got.Equal(fixed.Add(90 * time.Minute)) {
+		t.Errorf("Expected advanced time %v, got %v", fixed.Add(90*time.Minute), got)
+	}
+
+	c.Reset()
+	if c.Fixed() {
+		t.Error("Expected the clock to follow the system time after reset")
+	}
+
+	// Advancing a clock that follows the system time fixes it
+	c.Advance(time.Hour)
+	if !c.Fixed() {
+		t.Error("Expected the clock to be fixed after advancing")
+	}
+	if until := time.Until(c.Now()); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("Expected about an hour ahead, got %v", until)
+	}
+}
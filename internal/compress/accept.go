@@ -0,0 +1,40 @@
+// Package compress holds helpers shared by the handlers that compress responses
+package compress
+
+import (
+	"strconv"
+	"strings"
+)
+
+// AcceptsGzip checks if an Accept-Encoding header allows gzip. An explicit gzip entry
+// takes precedence over "*".
+func AcceptsGzip(acceptEncoding string) bool {
+	gzipQuality, wildcardQuality := -1.0, -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "x-gzip" && coding != "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.EqualFold(strings.TrimSpace(key), "q") {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if coding == "*" {
+			wildcardQuality = quality
+		} else {
+			gzipQuality = quality
+		}
+	}
+
+	if gzipQuality >= 0 {
+		return gzipQuality > 0
+	}
+	return wildcardQuality > 0
+}
@@ -0,0 +1,31 @@
+package compress
+
+import "testing"
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		expected       bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"GZIP", true},
+		{"x-gzip", true},
+		{"deflate, br", false},
+		{"br, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"gzip;q=0.0", false},
+		{"*", true},
+		{"*;q=0", false},
+		{"gzip;q=0, *", false},
+		{"*;q=0, gzip", true},
+		{"gzip;level=1;q=0", false},
+		{"gzip; foo=bar; q=0.8", true},
+	}
+
+	for _, tt := range tests {
+		if got := AcceptsGzip(tt.acceptEncoding); got != tt.expected {
+			t.Errorf("AcceptsGzip(%q) = %v, expected %v", tt.acceptEncoding, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package grpc
+
+import (
+	"log"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataTemplateData is the data available to response metadata and trailer templates
+type MetadataTemplateData struct {
+	Method   string                 // Full method name (e.g. "/helloworld.Greeter/SayHello")
+	Service  string                 // Service name (e.g. "helloworld.Greeter")
+	Metadata map[string]string      // Request metadata (first value of each key, lowercase keys)
+	Fields   map[string]interface{} // Fields of the request message
+}
+
+// newMetadataTemplateData creates the template data of a call from its method name, request metadata and message
+func newMetadataTemplateData(fullMethod string, md metadata.MD, req *MockMessage) *MetadataTemplateData {
+	data := &MetadataTemplateData{
+		Method:   fullMethod,
+		Metadata: make(map[string]string),
+		Fields:   make(map[string]interface{}),
+	}
+	if parts := strings.Split(fullMethod, "/"); len(parts) == 3 {
+		data.Service = parts[1]
+	}
+	for key, values := range md {
+		if len(values) > 0 {
+			data.Metadata[key] = values[0]
+		}
+	}
+	if req != nil && req.Fields != nil {
+		data.Fields = req.Fields
+	}
+	return data
+}
+
+// renderMetadata builds response metadata, rendering each value as a template if enabled.
+// Values that fail to render are sent unchanged.
+func (s *Server) renderMetadata(values map[string]string, useTemplate bool, data *MetadataTemplateData) metadata.MD {
+	if !useTemplate {
+		return metadata.New(values)
+	}
+
+	rendered := make(map[string]string, len(values))
+	for key, value := range values {
+		renderedValue, err := s.templateRenderer.RenderData(value, data)
+		if err != nil {
+			log.Printf("Error rendering gRPC metadata template for '%s': %v\n", key, err)
+			rendered[key] = value // Fall back to original value
+		} else {
+			rendered[key] = renderedValue
+		}
+	}
+	return metadata.New(rendered)
+}
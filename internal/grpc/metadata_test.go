@@ -0,0 +1,136 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeTransportStream provides the method name of a call to grpc.MethodFromServerStream
+type fakeTransportStream struct {
+	method string
+}
+
+func (f *fakeTransportStream) Method() string               { return f.method }
+func (f *fakeTransportStream) SetHeader(metadata.MD) error  { return nil }
+func (f *fakeTransportStream) SendHeader(metadata.MD) error { return nil }
+func (f *fakeTransportStream) SetTrailer(metadata.MD) error { return nil }
+
+// fakeServerStream receives a single request and records what the handler sends
+type fakeServerStream struct {
+	ctx     context.Context
+	request map[string]interface{}
+	header  metadata.MD
+	trailer metadata.MD
+	sent    []*MockMessage
+}
+
+func newFakeServerStream(method string, md metadata.MD, request map[string]interface{}) *fakeServerStream {
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	ctx = grpc.NewContextWithServerTransportStream(ctx, &fakeTransportStream{method: method})
+	return &fakeServerStream{ctx: ctx, request: request}
+}
+
+func (f *fakeServerStream) SetHeader(md metadata.MD) error {
+	f.header = metadata.Join(f.header, md)
+	return nil
+}
+func (f *fakeServerStream) SendHeader(md metadata.MD) error {
+	f.header = metadata.Join(f.header, md)
+	return nil
+}
+func (f *fakeServerStream) SetTrailer(md metadata.MD) { f.trailer = metadata.Join(f.trailer, md) }
+func (f *fakeServerStream) Context() context.Context  { return f.ctx }
+
+func (f *fakeServerStream) SendMsg(m interface{}) error {
+	f.sent = append(f.sent, m.(*MockMessage))
+	return nil
+}
+
+func (f *fakeServerStream) RecvMsg(m interface{}) error {
+	m.(*MockMessage).Fields = f.request
+	return nil
+}
+
+func TestHandleUnaryTemplatedMetadata(t *testing.T) {
+	method := &MethodConfig{
+		Name: "SayHello",
+		Response: &ResponseConfig{
+			Body:     map[string]interface{}{"message": "hi"},
+			Template: true,
+			Metadata: map[string]string{
+				"x-request-id": `{{index .Metadata "x-request-id"}}`,
+				"x-greeted":    "{{index .Fields \"name\"}}",
+				"x-static":     "plain",
+				"x-broken":     "{{.Missing",
+			},
+			Trailers: map[string]string{
+				"x-method":  "{{.Method}}",
+				"x-service": "{{upper .Service}}",
+			},
+		},
+	}
+	srv, err := NewServer(&GRPCConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	stream := newFakeServerStream("/helloworld.Greeter/SayHello",
+		metadata.Pairs("x-request-id", "req-42"), map[string]interface{}{"name": "Ada"})
+	if err := srv.handleUnary(stream, method, metadata.Pairs("x-request-id", "req-42")); err != nil {
+		t.Fatalf("handleUnary failed: %v", err)
+	}
+
+	headers := map[string]string{
+		"x-request-id": "req-42",
+		"x-greeted":    "Ada",
+		"x-static":     "plain",
+		"x-broken":     "{{.Missing", // Templates that fail to render are sent unchanged
+	}
+	for key, expected := range headers {
+		if got := stream.header.Get(key); len(got) != 1 || got[0] != expected {
+			t.Errorf("Expected header %s=%q, got %v", key, expected, got)
+		}
+	}
+
+	trailers := map[string]string{
+		"x-method":  "/helloworld.Greeter/SayHello",
+		"x-service": "HELLOWORLD.GREETER",
+	}
+	for key, expected := range trailers {
+		if got := stream.trailer.Get(key); len(got) != 1 || got[0] != expected {
+			t.Errorf("Expected trailer %s=%q, got %v", key, expected, got)
+		}
+	}
+
+	if len(stream.sent) != 1 || stream.sent[0].Fields["message"] != "hi" {
+		t.Errorf("Expected the response body to be sent, got %+v", stream.sent)
+	}
+}
+
+func TestHandleUnaryMetadataWithoutTemplate(t *testing.T) {
+	method := &MethodConfig{
+		Name: "SayHello",
+		Response: &ResponseConfig{
+			Metadata: map[string]string{"x-raw": "{{.Method}}"},
+			Trailers: map[string]string{"x-raw-trailer": "{{.Service}}"},
+		},
+	}
+	srv, err := NewServer(&GRPCConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	stream := newFakeServerStream("/helloworld.Greeter/SayHello", metadata.MD{}, nil)
+	if err := srv.handleUnary(stream, method, metadata.MD{}); err != nil {
+		t.Fatalf("handleUnary failed: %v", err)
+	}
+	if got := stream.header.Get("x-raw"); len(got) != 1 || got[0] != "{{.Method}}" {
+		t.Errorf("Expected the metadata to be sent as is, got %v", got)
+	}
+	if got := stream.trailer.Get("x-raw-trailer"); len(got) != 1 || got[0] != "{{.Service}}" {
+		t.Errorf("Expected the trailer to be sent as is, got %v", got)
+	}
+}
@@ -31,6 +31,7 @@ type MethodConfig struct {
 	Request       *RequestMatcher        `yaml:"request"`        // Request matching
 	Response      *ResponseConfig        `yaml:"response"`       // Response configuration
 	Responses     []ResponseConfig       `yaml:"responses"`      // Multiple responses for streaming
+	Cases         []MethodCase           `yaml:"cases"`          // Request-specific responses (first match wins)
 	Metadata      map[string]string      `yaml:"metadata"`       // Expected metadata
 	StatusCode    int                    `yaml:"status_code"`    // gRPC status code (0 = OK)
 	StatusMessage string                 `yaml:"status_message"` // Status message
@@ -39,10 +40,18 @@ type MethodConfig struct {
 	JavaScript    string                 `yaml:"javascript"`     // JavaScript handler
 }
 
+// MethodCase pairs a request matcher with the response returned when it matches
+type MethodCase struct {
+	Request       *RequestMatcher `yaml:"request"`        // Request matching (nil matches any request)
+	Response      *ResponseConfig `yaml:"response"`       // Response configuration
+	StatusCode    int             `yaml:"status_code"`    // gRPC status code (0 = OK)
+	StatusMessage string          `yaml:"status_message"` // Status message
+}
+
 // RequestMatcher represents request matching configuration
 type RequestMatcher struct {
 	Body          map[string]interface{} `yaml:"body"`           // Expected request body
-	MatchMode     string                 `yaml:"match_mode"`     // exact, partial, regex
+	MatchMode     string                 `yaml:"match_mode"`     // exact, partial, jsonpath
 	JSONPath      []JSONPathMatcher      `yaml:"json_path"`      // JSON path matchers
 	BodyContains  string                 `yaml:"body_contains"`  // Body contains string
 }
@@ -62,7 +71,7 @@ type ResponseConfig struct {
 	Delay         int                    `yaml:"delay"`     // Delay before sending
 	StreamDelay   int                    `yaml:"stream_delay"` // Delay between stream messages
 	StreamCount   int                    `yaml:"stream_count"` // Number of stream messages
-	Template      bool                   `yaml:"template"`  // Use Go templates
+	Template      bool                   `yaml:"template"`  // Render metadata and trailer values as Go templates (see MetadataTemplateData)
 }
 
 // TLSConfig represents TLS configuration
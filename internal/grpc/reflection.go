@@ -0,0 +1,137 @@
+package grpc
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// registerReflection registers a reflection service that also advertises the services
+// configured in YAML. Services without compiled proto descriptors get a synthesized
+// descriptor so tools like grpcurl can list and describe their methods.
+func (s *Server) registerReflection() error {
+	files, err := s.buildMockDescriptors()
+	if err != nil {
+		return err
+	}
+
+	opts := reflection.ServerOptions{
+		Services:           &mockServiceInfoProvider{server: s},
+		DescriptorResolver: &mockDescriptorResolver{files: files},
+	}
+
+	grpc_reflection_v1.RegisterServerReflectionServer(s.grpcServer, reflection.NewServerV1(opts))
+	grpc_reflection_v1alpha.RegisterServerReflectionServer(s.grpcServer, reflection.NewServer(opts))
+	return nil
+}
+
+// buildMockDescriptors synthesizes a file descriptor for each configured service that
+// isn't already known from compiled protos. Every method uses an empty placeholder message.
+func (s *Server) buildMockDescriptors() (*protoregistry.Files, error) {
+	files := new(protoregistry.Files)
+
+	for _, service := range s.config.Services {
+		if _, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service.Name)); err == nil {
+			continue
+		}
+
+		pkg, name := "", service.Name
+		if idx := strings.LastIndex(service.Name, "."); idx >= 0 {
+			pkg, name = service.Name[:idx], service.Name[idx+1:]
+		}
+
+		messageName := name + "MockMessage"
+		messageType := "." + messageName
+		if pkg != "" {
+			messageType = "." + pkg + "." + messageName
+		}
+
+		serviceProto := &descriptorpb.ServiceDescriptorProto{Name: proto.String(name)}
+		for _, method := range service.Methods {
+			serviceProto.Method = append(serviceProto.Method, &descriptorpb.MethodDescriptorProto{
+				Name:            proto.String(method.Name),
+				InputType:       proto.String(messageType),
+				OutputType:      proto.String(messageType),
+				ClientStreaming: proto.Bool(method.StreamType == string(StreamTypeClientStream) || method.StreamType == string(StreamTypeBidirectional)),
+				ServerStreaming: proto.Bool(method.StreamType == string(StreamTypeServerStream) || method.StreamType == string(StreamTypeBidirectional)),
+			})
+		}
+
+		fileProto := &descriptorpb.FileDescriptorProto{
+			Name:        proto.String("pmp-mock/" + service.Name + ".proto"),
+			Syntax:      proto.String("proto3"),
+			MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String(messageName)}},
+			Service:     []*descriptorpb.ServiceDescriptorProto{serviceProto},
+		}
+		if pkg != "" {
+			fileProto.Package = proto.String(pkg)
+		}
+
+		file, err := protodesc.NewFile(fileProto, files)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build descriptor for service %s: %w", service.Name, err)
+		}
+		if err := files.RegisterFile(file); err != nil {
+			return nil, fmt.Errorf("failed to register descriptor for service %s: %w", service.Name, err)
+		}
+	}
+
+	return files, nil
+}
+
+// mockServiceInfoProvider lists the services registered on the gRPC server plus the configured mock services
+type mockServiceInfoProvider struct {
+	server *Server
+}
+
+// GetServiceInfo implements reflection.ServiceInfoProvider
+func (p *mockServiceInfoProvider) GetServiceInfo() map[string]grpc.ServiceInfo {
+	info := p.server.grpcServer.GetServiceInfo()
+
+	for _, service := range p.server.config.Services {
+		if _, exists := info[service.Name]; exists {
+			continue
+		}
+		methods := make([]grpc.MethodInfo, 0, len(service.Methods))
+		for _, method := range service.Methods {
+			methods = append(methods, grpc.MethodInfo{
+				Name:           method.Name,
+				IsClientStream: method.StreamType == string(StreamTypeClientStream) || method.StreamType == string(StreamTypeBidirectional),
+				IsServerStream: method.StreamType == string(StreamTypeServerStream) || method.StreamType == string(StreamTypeBidirectional),
+			})
+		}
+		info[service.Name] = grpc.ServiceInfo{Methods: methods}
+	}
+
+	return info
+}
+
+// mockDescriptorResolver resolves synthesized mock descriptors first, then compiled protos
+type mockDescriptorResolver struct {
+	files *protoregistry.Files
+}
+
+// FindFileByPath implements protodesc.Resolver
+func (r *mockDescriptorResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if file, err := r.files.FindFileByPath(path); err == nil {
+		return file, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+// FindDescriptorByName implements protodesc.Resolver
+func (r *mockDescriptorResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if desc, err := r.files.FindDescriptorByName(name); err == nil {
+		return desc, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
@@ -0,0 +1,127 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestReflectionListsMockServices(t *testing.T) {
+	srv, err := NewServer(&GRPCConfig{
+		Reflection: true,
+		Services: []ServiceConfig{
+			{
+				Name: "helloworld.Greeter",
+				Methods: []MethodConfig{
+					{Name: "SayHello", StreamType: string(StreamTypeUnary)},
+					{Name: "Chat", StreamType: string(StreamTypeBidirectional)},
+				},
+			},
+			{
+				Name:    "Unpackaged",
+				Methods: []MethodConfig{{Name: "Watch", StreamType: string(StreamTypeServerStream)}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go srv.grpcServer.Serve(listener) //nolint:errcheck // stopped below
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck // test connection
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := grpc_reflection_v1.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open reflection stream: %v", err)
+	}
+	ask := func(req *grpc_reflection_v1.ServerReflectionRequest) *grpc_reflection_v1.ServerReflectionResponse {
+		t.Helper()
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Failed to send reflection request: %v", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Failed to receive reflection response: %v", err)
+		}
+		return resp
+	}
+
+	listed := ask(&grpc_reflection_v1.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_ListServices{},
+	}).GetListServicesResponse()
+	names := make(map[string]bool)
+	for _, service := range listed.GetService() {
+		names[service.GetName()] = true
+	}
+	for _, expected := range []string{"helloworld.Greeter", "Unpackaged"} {
+		if !names[expected] {
+			t.Errorf("Expected %s to be listed, got %v", expected, names)
+		}
+	}
+
+	tests := []struct {
+		symbol  string
+		pkg     string
+		service string
+		methods map[string][2]bool // Method name -> client streaming, server streaming
+	}{
+		{"helloworld.Greeter", "helloworld", "Greeter", map[string][2]bool{"SayHello": {false, false}, "Chat": {true, true}}},
+		{"Unpackaged", "", "Unpackaged", map[string][2]bool{"Watch": {false, true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			resp := ask(&grpc_reflection_v1.ServerReflectionRequest{
+				MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: tt.symbol},
+			})
+			if errResp := resp.GetErrorResponse(); errResp != nil {
+				t.Fatalf("Failed to resolve %s: %s", tt.symbol, errResp.GetErrorMessage())
+			}
+			encoded := resp.GetFileDescriptorResponse().GetFileDescriptorProto()
+			if len(encoded) == 0 {
+				t.Fatalf("Expected a file descriptor for %s", tt.symbol)
+			}
+			var file descriptorpb.FileDescriptorProto
+			if err := proto.Unmarshal(encoded[0], &file); err != nil {
+				t.Fatalf("Failed to decode file descriptor: %v", err)
+			}
+			if file.GetPackage() != tt.pkg || len(file.GetService()) != 1 || file.GetService()[0].GetName() != tt.service {
+				t.Fatalf("Unexpected file descriptor: %v", &file)
+			}
+
+			methods := file.GetService()[0].GetMethod()
+			if len(methods) != len(tt.methods) {
+				t.Errorf("Expected %d methods, got %d", len(tt.methods), len(methods))
+			}
+			for _, method := range methods {
+				streaming, exists := tt.methods[method.GetName()]
+				if !exists {
+					t.Errorf("Unexpected method %s", method.GetName())
+					continue
+				}
+				if method.GetClientStreaming() != streaming[0] || method.GetServerStreaming() != streaming[1] {
+					t.Errorf("Method %s: expected streaming %v, got client %v server %v",
+						method.GetName(), streaming, method.GetClientStreaming(), method.GetServerStreaming())
+				}
+			}
+		})
+	}
+}
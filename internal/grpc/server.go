@@ -6,34 +6,39 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/comfortablynumb/pmp-mock-http/internal/template"
+	"github.com/tidwall/gjson"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 )
 
 // Server represents a gRPC mock server
 type Server struct {
-	config   *GRPCConfig
-	grpcServer *grpc.Server
-	listener net.Listener
-	services map[string]*ServiceConfig
-	mu       sync.RWMutex
+	config           *GRPCConfig
+	grpcServer       *grpc.Server
+	listener         net.Listener
+	services         map[string]*ServiceConfig
+	templateRenderer *template.Renderer        // Renders templated response metadata and trailers
+	regexes          map[string]*regexp.Regexp // Compiled JSON path regex patterns, keyed by pattern
+	mu               sync.RWMutex
 }
 
 // NewServer creates a new gRPC mock server
 func NewServer(config *GRPCConfig) (*Server, error) {
 	s := &Server{
-		config:   config,
-		services: make(map[string]*ServiceConfig),
+		config:           config,
+		services:         make(map[string]*ServiceConfig),
+		templateRenderer: template.NewRenderer(),
 	}
 
 	// Index services by name
@@ -41,6 +46,12 @@ func NewServer(config *GRPCConfig) (*Server, error) {
 		s.services[config.Services[i].Name] = &config.Services[i]
 	}
 
+	regexes, err := compileRegexes(config.Services)
+	if err != nil {
+		return nil, err
+	}
+	s.regexes = regexes
+
 	// Create gRPC server options
 	opts := []grpc.ServerOption{
 		grpc.UnknownServiceHandler(s.handleUnknownService),
@@ -70,7 +81,9 @@ func NewServer(config *GRPCConfig) (*Server, error) {
 
 	// Register reflection service if enabled
 	if config.Reflection {
-		reflection.Register(s.grpcServer)
+		if err := s.registerReflection(); err != nil {
+			return nil, fmt.Errorf("failed to register reflection: %w", err)
+		}
 	}
 
 	// Register health check service if enabled
@@ -170,8 +183,9 @@ func (s *Server) handleUnary(stream grpc.ServerStream, method *MethodConfig, md
 		return err
 	}
 
-	// Check if request matches
-	if method.Request != nil && !s.matchesRequest(&req, method.Request) {
+	// Pick the response for this request
+	response, statusCode, statusMessage, ok := s.selectUnaryResponse(&req, method)
+	if !ok {
 		return status.Error(codes.InvalidArgument, "request does not match expected pattern")
 	}
 
@@ -180,16 +194,21 @@ func (s *Server) handleUnary(stream grpc.ServerStream, method *MethodConfig, md
 		time.Sleep(time.Duration(method.Delay) * time.Millisecond)
 	}
 
+	// Metadata and trailers can be templates rendered with the request
+	fullMethod, _ := grpc.MethodFromServerStream(stream)
+	templateData := newMetadataTemplateData(fullMethod, md, &req)
+	useTemplate := method.Template || (response != nil && response.Template)
+
 	// Send metadata if configured
-	if method.Response != nil && len(method.Response.Metadata) > 0 {
-		respMd := metadata.New(method.Response.Metadata)
+	if response != nil && len(response.Metadata) > 0 {
+		respMd := s.renderMetadata(response.Metadata, useTemplate, templateData)
 		_ = stream.SendHeader(respMd)
 	}
 
 	// Send response
-	if method.Response != nil {
+	if response != nil {
 		resp := &MockMessage{
-			Fields: method.Response.Body,
+			Fields: response.Body,
 		}
 		if err := stream.SendMsg(resp); err != nil {
 			return err
@@ -197,19 +216,37 @@ func (s *Server) handleUnary(stream grpc.ServerStream, method *MethodConfig, md
 	}
 
 	// Send trailers if configured
-	if method.Response != nil && len(method.Response.Trailers) > 0 {
-		trailerMd := metadata.New(method.Response.Trailers)
+	if response != nil && len(response.Trailers) > 0 {
+		trailerMd := s.renderMetadata(response.Trailers, useTemplate, templateData)
 		stream.SetTrailer(trailerMd)
 	}
 
 	// Return status
-	if method.StatusCode != 0 {
-		return status.Error(codes.Code(method.StatusCode), method.StatusMessage)
+	if statusCode != 0 {
+		return status.Error(codes.Code(statusCode), statusMessage)
 	}
 
 	return nil
 }
 
+// selectUnaryResponse evaluates the method cases in order and returns the response of
+// the first one matching the request, falling back to the method's default response.
+// The boolean result is false when neither a case nor the default request matcher matches.
+func (s *Server) selectUnaryResponse(req *MockMessage, method *MethodConfig) (*ResponseConfig, int, string, bool) {
+	for i := range method.Cases {
+		c := &method.Cases[i]
+		if c.Request == nil || s.matchesRequest(req, c.Request) {
+			return c.Response, c.StatusCode, c.StatusMessage, true
+		}
+	}
+
+	if method.Request != nil && !s.matchesRequest(req, method.Request) {
+		return nil, 0, "", false
+	}
+
+	return method.Response, method.StatusCode, method.StatusMessage, true
+}
+
 // handleServerStream handles server streaming RPC calls
 func (s *Server) handleServerStream(stream grpc.ServerStream, method *MethodConfig, md metadata.MD) error {
 	// Receive request
@@ -225,7 +262,9 @@ func (s *Server) handleServerStream(stream grpc.ServerStream, method *MethodConf
 
 	// Send metadata if configured
 	if len(method.Responses) > 0 && len(method.Responses[0].Metadata) > 0 {
-		respMd := metadata.New(method.Responses[0].Metadata)
+		fullMethod, _ := grpc.MethodFromServerStream(stream)
+		useTemplate := method.Template || method.Responses[0].Template
+		respMd := s.renderMetadata(method.Responses[0].Metadata, useTemplate, newMetadataTemplateData(fullMethod, md, &req))
 		_ = stream.SendHeader(respMd)
 	}
 
@@ -318,14 +357,19 @@ func (s *Server) handleBidirectional(stream grpc.ServerStream, method *MethodCon
 	}
 }
 
-// matchesRequest checks if a request matches the expected pattern
+// matchesRequest checks if a request matches the expected pattern. JSON path matchers apply
+// in every match mode; in "jsonpath" mode they're the only condition.
 func (s *Server) matchesRequest(req *MockMessage, matcher *RequestMatcher) bool {
-	if matcher.Body == nil {
+	// Convert request to JSON for matching
+	reqJSON, _ := json.Marshal(req.Fields)
+
+	if !s.matchJSONPath(reqJSON, matcher.JSONPath) {
+		return false
+	}
+	if matcher.MatchMode == "jsonpath" || matcher.Body == nil {
 		return true
 	}
 
-	// Convert request to JSON for matching
-	reqJSON, _ := json.Marshal(req.Fields)
 	expectedJSON, _ := json.Marshal(matcher.Body)
 
 	switch matcher.MatchMode {
@@ -338,6 +382,67 @@ func (s *Server) matchesRequest(req *MockMessage, matcher *RequestMatcher) bool
 	}
 }
 
+// matchJSONPath checks every GJSON path matcher against the JSON-encoded request
+func (s *Server) matchJSONPath(reqJSON []byte, matchers []JSONPathMatcher) bool {
+	for _, matcher := range matchers {
+		result := gjson.GetBytes(reqJSON, matcher.Path)
+		if !result.Exists() {
+			return false
+		}
+
+		expected := fmt.Sprintf("%v", matcher.Value)
+		if matcher.Regex {
+			if !s.regexes[expected].MatchString(result.String()) {
+				return false
+			}
+		} else if result.String() != expected {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compileRegexes compiles the regex patterns of the JSON path matchers of every method and
+// case, so they aren't compiled per request. Invalid patterns are rejected.
+func compileRegexes(services []ServiceConfig) (map[string]*regexp.Regexp, error) {
+	regexes := make(map[string]*regexp.Regexp)
+	compile := func(service, method string, matcher *RequestMatcher) error {
+		if matcher == nil {
+			return nil
+		}
+		for _, jm := range matcher.JSONPath {
+			if !jm.Regex {
+				continue
+			}
+			pattern := fmt.Sprintf("%v", jm.Value)
+			if _, exists := regexes[pattern]; exists {
+				continue
+			}
+			compiled, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("method %s/%s has an invalid regex for %s: %w", service, method, jm.Path, err)
+			}
+			regexes[pattern] = compiled
+		}
+		return nil
+	}
+
+	for _, service := range services {
+		for _, method := range service.Methods {
+			if err := compile(service.Name, method.Name, method.Request); err != nil {
+				return nil, err
+			}
+			for i := range method.Cases {
+				if err := compile(service.Name, method.Name, method.Cases[i].Request); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return regexes, nil
+}
+
 // MockMessage implements proto.Message interface methods
 func (m *MockMessage) Reset()         { m.Fields = nil }
 func (m *MockMessage) String() string { return fmt.Sprintf("%v", m.Fields) }
@@ -0,0 +1,87 @@
+package grpc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectUnaryResponse(t *testing.T) {
+	method := MethodConfig{
+		Name: "GetUser",
+		Cases: []MethodCase{
+			{
+				Request:  &RequestMatcher{MatchMode: "jsonpath", JSONPath: []JSONPathMatcher{{Path: "id", Value: 1}}},
+				Response: &ResponseConfig{Body: map[string]interface{}{"name": "first"}},
+			},
+			{
+				Request:  &RequestMatcher{MatchMode: "jsonpath", JSONPath: []JSONPathMatcher{{Path: "email", Value: `^[a-z]+@admin\.example\.com$`, Regex: true}}},
+				Response: &ResponseConfig{Body: map[string]interface{}{"name": "admin"}},
+			},
+			{
+				// JSON path matchers apply without match_mode: jsonpath too
+				Request:       &RequestMatcher{JSONPath: []JSONPathMatcher{{Path: "role", Value: "banned"}}},
+				StatusCode:    StatusPermissionDenied,
+				StatusMessage: "banned",
+			},
+			{
+				Request:       &RequestMatcher{MatchMode: "exact", Body: map[string]interface{}{"id": 404}},
+				StatusCode:    StatusNotFound,
+				StatusMessage: "user not found",
+			},
+		},
+		Request:  &RequestMatcher{MatchMode: "jsonpath", JSONPath: []JSONPathMatcher{{Path: "id", Value: `^\d+$`, Regex: true}}},
+		Response: &ResponseConfig{Body: map[string]interface{}{"name": "default"}},
+	}
+	srv, err := NewServer(&GRPCConfig{Services: []ServiceConfig{{Name: "users.Users", Methods: []MethodConfig{method}}}})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		fields     map[string]interface{}
+		matched    bool
+		body       string // Expected response name ("" = no response)
+		statusCode int
+	}{
+		{"jsonpath value", map[string]interface{}{"id": 1}, true, "first", 0},
+		{"jsonpath regex", map[string]interface{}{"id": 2, "email": "root@admin.example.com"}, true, "admin", 0},
+		{"regex mismatch falls through", map[string]interface{}{"id": 2, "email": "root@example.com"}, true, "default", 0},
+		{"jsonpath without match mode", map[string]interface{}{"id": 3, "role": "banned"}, true, "", StatusPermissionDenied},
+		{"jsonpath without match mode mismatch", map[string]interface{}{"id": 3, "role": "user"}, true, "default", 0},
+		{"exact body", map[string]interface{}{"id": 404}, true, "", StatusNotFound},
+		{"default response", map[string]interface{}{"id": 7}, true, "default", 0},
+		{"default request mismatch", map[string]interface{}{"name": "no id"}, false, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, statusCode, _, matched := srv.selectUnaryResponse(&MockMessage{Fields: tt.fields}, &method)
+			if matched != tt.matched {
+				t.Fatalf("Expected matched %v, got %v", tt.matched, matched)
+			}
+			body := ""
+			if response != nil {
+				body, _ = response.Body["name"].(string)
+			}
+			if body != tt.body || statusCode != tt.statusCode {
+				t.Errorf("Expected response %q with status %d, got %q with status %d", tt.body, tt.statusCode, body, statusCode)
+			}
+		})
+	}
+}
+
+func TestNewServerRejectsInvalidRegex(t *testing.T) {
+	_, err := NewServer(&GRPCConfig{Services: []ServiceConfig{{
+		Name: "users.Users",
+		Methods: []MethodConfig{{
+			Name: "GetUser",
+			Cases: []MethodCase{{
+				Request: &RequestMatcher{JSONPath: []JSONPathMatcher{{Path: "email", Value: "[invalid", Regex: true}}},
+			}},
+		}},
+	}}})
+	if err == nil || !strings.Contains(err.Error(), "users.Users/GetUser") {
+		t.Errorf("Expected an invalid regex error naming the method, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package har
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/tracker"
+)
+
+// Creator of the archives exported from the request log
+var exportCreator = Creator{Name: "pmp-mock-http", Version: "1.0.0"}
+
+// FromRequestLogs converts request logs to an HTTP Archive, in chronological order. The
+// request log doesn't record timings or header sizes, so timings are 0 and header sizes unknown (-1).
+func FromRequestLogs(logs []tracker.RequestLog) *HAR {
+	entries := make([]Entry, 0, len(logs))
+	for i := range logs {
+		entries = append(entries, logEntry(&logs[i]))
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime < entries[j].StartedDateTime
+	})
+
+	return &HAR{Log: Log{Version: "1.2", Creator: exportCreator, Entries: entries}}
+}
+
+// logEntry converts a request log to a HAR entry
+func logEntry(entry *tracker.RequestLog) Entry {
+	requestURL := entry.URL
+	if requestURL == "" {
+		requestURL = "http://localhost" + entry.URI
+	}
+
+	request := Request{
+		Method:      entry.Method,
+		URL:         requestURL,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []NameValue{},
+		Headers:     requestHeaders(entry.Headers),
+		QueryString: queryString(requestURL),
+		HeadersSize: -1,
+		BodySize:    len(entry.Body),
+	}
+	if entry.Body != "" {
+		text, encoding := encodeBody(entry.Body)
+		request.PostData = &PostData{MimeType: entry.Headers["Content-Type"], Text: text, Encoding: encoding}
+	}
+
+	content := Content{
+		Size:     len(entry.Response),
+		MimeType: http.Header(entry.ResponseHeaders).Get("Content-Type"),
+	}
+	content.Text, content.Encoding = encodeBody(entry.Response)
+
+	return Entry{
+		StartedDateTime: entry.Timestamp.UTC().Format(time.RFC3339Nano),
+		Time:            0,
+		Request:         request,
+		Response: Response{
+			Status:      entry.StatusCode,
+			StatusText:  http.StatusText(entry.StatusCode),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []NameValue{},
+			Headers:     multiHeaders(entry.ResponseHeaders),
+			Content:     content,
+			RedirectURL: http.Header(entry.ResponseHeaders).Get("Location"),
+			HeadersSize: -1,
+			BodySize:    len(entry.Response),
+		},
+		Cache:   Cache{},
+		Timings: Timings{},
+	}
+}
+
+// encodeBody returns the body as HAR text, base64-encoded if it isn't valid UTF-8
+func encodeBody(body string) (string, string) {
+	if utf8.ValidString(body) {
+		return body, ""
+	}
+	return base64.StdEncoding.EncodeToString([]byte(body)), "base64"
+}
+
+// requestHeaders converts the logged request headers, sorted by name
+func requestHeaders(headers map[string]string) []NameValue {
+	converted := make([]NameValue, 0, len(headers))
+	for name, value := range headers {
+		converted = append(converted, NameValue{Name: name, Value: value})
+	}
+	sort.Slice(converted, func(i, j int) bool {
+		return converted[i].Name < converted[j].Name
+	})
+	return converted
+}
+
+// multiHeaders converts response headers, sorted by name, with one entry per value
+func multiHeaders(headers map[string][]string) []NameValue {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	converted := make([]NameValue, 0, len(headers))
+	for _, name := range names {
+		for _, value := range headers[name] {
+			converted = append(converted, NameValue{Name: name, Value: value})
+		}
+	}
+	return converted
+}
+
+// queryString lists the query parameters of the URL in their original order
+func queryString(requestURL string) []NameValue {
+	params := make([]NameValue, 0)
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return params
+	}
+
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		params = append(params, NameValue{Name: name, Value: value})
+	}
+	return params
+}
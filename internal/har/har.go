@@ -0,0 +1,267 @@
+package har
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+)
+
+// HAR represents an HTTP Archive (HAR 1.2)
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+// Log contains the recorded entries
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+}
+
+// Creator describes the application that created the archive
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry is a recorded request and its response
+type Entry struct {
+	StartedDateTime string   `json:"startedDateTime"`
+	Time            float64  `json:"time"` // Total time of the request in milliseconds
+	Request         Request  `json:"request"`
+	Response        Response `json:"response"`
+	Cache           Cache    `json:"cache"`
+	Timings         Timings  `json:"timings"`
+}
+
+// Cache describes the cache state of an entry; the mock server doesn't cache
+type Cache struct{}
+
+// Timings break down the time of an entry in milliseconds
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// Request describes a recorded request
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// Response describes a recorded response
+type Response struct {
+	Status      int         `json:"status"` // 0 for requests that got no response (e.g. blocked or aborted)
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// NameValue is a header, cookie or query string parameter
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PostData is the body of a recorded request
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"` // "base64" for binary bodies (custom field, HAR only defines it for responses)
+}
+
+// Content is the body of a recorded response
+type Content struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"` // "base64" for binary bodies
+}
+
+// IsHAR reports whether the data is an HTTP Archive
+func IsHAR(data []byte) bool {
+	var archive struct {
+		Log *struct {
+			Entries []json.RawMessage `json:"entries"`
+		} `json:"log"`
+	}
+	return json.Unmarshal(data, &archive) == nil && archive.Log != nil && archive.Log.Entries != nil
+}
+
+// ParseMocks converts the entries of a HAR file to mocks, one per distinct request and
+// response, with decreasing priorities in recorded order. Identical entries (same method,
+// URL, request body, status and response body) become a single mock. With sequences, the
+// different responses recorded for the same method and URL are grouped into one mock that
+// returns them in order (sequence_mode "once", so the last one is repeated).
+func ParseMocks(data []byte, sequences bool) (*models.MockSpec, error) {
+	var archive HAR
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, fmt.Errorf("failed to parse HAR file: %w", err)
+	}
+
+	log.Printf("Converting HAR file with %d entries\n", len(archive.Log.Entries))
+
+	mockSpec := &models.MockSpec{
+		Mocks: []models.Mock{},
+	}
+	seen := make(map[string]bool)
+	routes := make(map[string]int) // Route key to the position of its mock (sequences only)
+	routeNames := make(map[string]int)
+
+	for i := range archive.Log.Entries {
+		entry := &archive.Log.Entries[i]
+		if entry.Response.Status == 0 {
+			log.Printf("Skipping entry %d (%s %s): no response was recorded\n", i, entry.Request.Method, entry.Request.URL)
+			continue
+		}
+
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			log.Printf("Skipping entry %d: invalid URL %q: %v\n", i, entry.Request.URL, err)
+			continue
+		}
+		method := strings.ToUpper(entry.Request.Method)
+		route := method + " " + u.RequestURI()
+
+		body, err := decodeBody(entry.Response.Content.Text, entry.Response.Content.Encoding)
+		if err != nil {
+			log.Printf("Skipping entry %d (%s): %v\n", i, route, err)
+			continue
+		}
+
+		requestBody := ""
+		if postData := entry.Request.PostData; postData != nil {
+			requestBody, err = decodeBody(postData.Text, postData.Encoding)
+			if err != nil {
+				log.Printf("Skipping entry %d (%s): request body: %v\n", i, route, err)
+				continue
+			}
+		}
+		key := fmt.Sprintf("%s\x00%s\x00%d\x00%s", route, requestBody, entry.Response.Status, body)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		headers, headersMulti := responseHeaders(entry.Response.Headers)
+
+		if position, exists := routes[route]; exists && sequences {
+			mock := &mockSpec.Mocks[position]
+			if len(mock.Response.Sequence) == 0 {
+				mock.Response.Sequence = []models.ResponseItem{{
+					StatusCode: mock.Response.StatusCode,
+					Headers:    mock.Response.Headers,
+					Body:       mock.Response.Body,
+				}}
+				mock.Response.SequenceMode = "once"
+				mock.Response.HeadersMulti = nil
+			}
+			mock.Response.Sequence = append(mock.Response.Sequence, models.ResponseItem{
+				StatusCode: entry.Response.Status,
+				Headers:    headers,
+				Body:       body,
+			})
+			continue
+		}
+
+		name := route
+		routeNames[route]++
+		if routeNames[route] > 1 {
+			name = fmt.Sprintf("%s #%d", route, routeNames[route])
+		}
+
+		mock := models.Mock{
+			Name:     name,
+			Priority: 100 - len(mockSpec.Mocks),
+			Request: models.Request{
+				URI:    u.Path,
+				Method: method,
+			},
+			Response: models.Response{
+				StatusCode:   entry.Response.Status,
+				Headers:      headers,
+				HeadersMulti: headersMulti,
+				Body:         body,
+			},
+		}
+		if mock.Request.URI == "" {
+			mock.Request.URI = "/"
+		}
+		query := u.Query()
+		keys := make([]string, 0, len(query))
+		for key := range query {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			for _, value := range query[key] {
+				mock.Request.QueryParams = append(mock.Request.QueryParams, models.QueryParamMatcher{Key: key, Value: value})
+			}
+		}
+
+		routes[route] = len(mockSpec.Mocks)
+		mockSpec.Mocks = append(mockSpec.Mocks, mock)
+	}
+
+	log.Printf("Generated %d mocks from HAR file\n", len(mockSpec.Mocks))
+	return mockSpec, nil
+}
+
+// decodeBody returns a recorded body, decoding it if it's base64-encoded
+func decodeBody(text, encoding string) (string, error) {
+	if encoding != "base64" {
+		return text, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid base64 content: %w", err)
+	}
+	return string(decoded), nil
+}
+
+// responseHeaders converts recorded headers. Repeated headers (e.g. Set-Cookie) keep their
+// first value in headers and the others in headers_multi. HTTP/2 pseudo-headers and headers
+// that depend on how the body was transferred are left out.
+func responseHeaders(recorded []NameValue) (map[string]string, map[string][]string) {
+	headers := make(map[string]string)
+	var headersMulti map[string][]string
+
+	for _, header := range recorded {
+		if strings.HasPrefix(header.Name, ":") {
+			continue
+		}
+		switch strings.ToLower(header.Name) {
+		case "content-length", "transfer-encoding", "content-encoding", "connection", "keep-alive":
+			continue
+		}
+
+		if _, exists := headers[header.Name]; !exists {
+			headers[header.Name] = header.Value
+			continue
+		}
+		if headersMulti == nil {
+			headersMulti = make(map[string][]string)
+		}
+		headersMulti[header.Name] = append(headersMulti[header.Name], header.Value)
+	}
+	return headers, headersMulti
+}
@@ -0,0 +1,157 @@
+package har
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/tracker"
+)
+
+func TestParseMocks(t *testing.T) {
+	data, err := os.ReadFile("testdata/traffic.har")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	if !IsHAR(data) {
+		t.Fatal("Expected the fixture to be detected as a HAR file")
+	}
+	if IsHAR([]byte(`{"openapi": "3.0.3"}`)) {
+		t.Error("Expected an OpenAPI spec not to be detected as a HAR file")
+	}
+
+	spec, err := ParseMocks(data, false)
+	if err != nil {
+		t.Fatalf("Failed to parse HAR file: %v", err)
+	}
+
+	// The duplicate entry is dropped and the entry without a response skipped
+	if len(spec.Mocks) != 3 {
+		t.Fatalf("Expected 3 mocks, got %d: %+v", len(spec.Mocks), spec.Mocks)
+	}
+
+	pending := spec.Mocks[0]
+	if pending.Name != "GET /jobs/7?verbose=true" || pending.Request.Method != "GET" || pending.Request.URI != "/jobs/7" {
+		t.Errorf("Unexpected request of the first mock: %s %+v", pending.Name, pending.Request)
+	}
+	if len(pending.Request.QueryParams) != 1 || pending.Request.QueryParams[0].Key != "verbose" || pending.Request.QueryParams[0].Value != "true" {
+		t.Errorf("Expected the recorded query string to be matched, got %+v", pending.Request.QueryParams)
+	}
+	if pending.Response.StatusCode != 200 || pending.Response.Body != `{"status":"pending"}` {
+		t.Errorf("Unexpected response of the first mock: %d %s", pending.Response.StatusCode, pending.Response.Body)
+	}
+	if _, exists := pending.Response.Headers["content-length"]; exists {
+		t.Error("Expected Content-Length to be left out")
+	}
+	if pending.Response.Headers["set-cookie"] != "a=1" || len(pending.Response.HeadersMulti["set-cookie"]) != 1 || pending.Response.HeadersMulti["set-cookie"][0] != "b=2" {
+		t.Errorf("Expected repeated headers to keep every value, got %v and %v", pending.Response.Headers, pending.Response.HeadersMulti)
+	}
+
+	done := spec.Mocks[1]
+	if done.Name != "GET /jobs/7?verbose=true #2" || done.Response.Body != `{"status":"done"}` || done.Priority >= pending.Priority {
+		t.Errorf("Expected a second, lower-priority mock for the other response, got %s (priority %d) %s", done.Name, done.Priority, done.Response.Body)
+	}
+
+	if logo := spec.Mocks[2]; logo.Response.Body != "\x89PNG" {
+		t.Errorf("Expected the base64 body to be decoded, got %q", logo.Response.Body)
+	}
+}
+
+func TestParseMocksSequences(t *testing.T) {
+	data, err := os.ReadFile("testdata/traffic.har")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	spec, err := ParseMocks(data, true)
+	if err != nil {
+		t.Fatalf("Failed to parse HAR file: %v", err)
+	}
+	if len(spec.Mocks) != 2 {
+		t.Fatalf("Expected 2 mocks, got %d", len(spec.Mocks))
+	}
+
+	job := spec.Mocks[0]
+	if job.Response.SequenceMode != "once" || len(job.Response.Sequence) != 2 {
+		t.Fatalf("Expected a sequence of the 2 different responses, got %+v", job.Response)
+	}
+	if job.Response.Sequence[0].Body != `{"status":"pending"}` || job.Response.Sequence[1].Body != `{"status":"done"}` {
+		t.Errorf("Expected the responses in recorded order, got %+v", job.Response.Sequence)
+	}
+	if len(spec.Mocks[1].Response.Sequence) != 0 {
+		t.Error("Expected no sequence for a URL recorded once")
+	}
+}
+
+func TestFromRequestLogs(t *testing.T) {
+	started := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	logs := []tracker.RequestLog{
+		{
+			ID: 2, Timestamp: started.Add(time.Second), Method: "GET", URI: "/image.png",
+			URL: "http://localhost:8083/image.png", StatusCode: 200, Response: "\x89PNG\xff",
+			ResponseHeaders: map[string][]string{"Content-Type": {"image/png"}},
+		},
+		{
+			ID: 1, Timestamp: started, Method: "POST", URI: "/api/users?role=admin&q=a%20b",
+			URL:     "https://api.example.com/api/users?role=admin&q=a%20b",
+			Headers: map[string]string{"Content-Type": "application/json", "Accept": "*/*"},
+			Body:    `{"name":"Ada"}`, StatusCode: 201, Response: `{"id":1}`,
+			ResponseHeaders: map[string][]string{"Content-Type": {"application/json"}, "Set-Cookie": {"a=1", "b=2"}},
+		},
+	}
+
+	archive := FromRequestLogs(logs)
+	if archive.Log.Version != "1.2" || archive.Log.Creator.Name != "pmp-mock-http" {
+		t.Errorf("Unexpected archive header: %+v", archive.Log)
+	}
+	if len(archive.Log.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(archive.Log.Entries))
+	}
+
+	// Entries are in chronological order
+	post := archive.Log.Entries[0]
+	if post.StartedDateTime != "2024-03-01T12:00:00Z" || post.Request.Method != "POST" {
+		t.Errorf("Expected the oldest request first, got %+v", post)
+	}
+	if post.Request.URL != "https://api.example.com/api/users?role=admin&q=a%20b" {
+		t.Errorf("Unexpected URL: %s", post.Request.URL)
+	}
+	if len(post.Request.QueryString) != 2 || post.Request.QueryString[1] != (NameValue{Name: "q", Value: "a b"}) {
+		t.Errorf("Unexpected query string: %+v", post.Request.QueryString)
+	}
+	if len(post.Request.Headers) != 2 || post.Request.Headers[0].Name != "Accept" {
+		t.Errorf("Expected sorted request headers, got %+v", post.Request.Headers)
+	}
+	if post.Request.PostData == nil || post.Request.PostData.Text != `{"name":"Ada"}` || post.Request.PostData.MimeType != "application/json" {
+		t.Errorf("Unexpected post data: %+v", post.Request.PostData)
+	}
+	if post.Response.Status != 201 || post.Response.StatusText != "Created" || post.Response.Content.Text != `{"id":1}` || post.Response.Content.Encoding != "" {
+		t.Errorf("Unexpected response: %+v", post.Response)
+	}
+	if len(post.Response.Headers) != 3 || post.Response.Headers[1] != (NameValue{Name: "Set-Cookie", Value: "a=1"}) || post.Response.Headers[2] != (NameValue{Name: "Set-Cookie", Value: "b=2"}) {
+		t.Errorf("Expected one header per value, got %+v", post.Response.Headers)
+	}
+
+	// Bodies that aren't valid UTF-8 are base64-encoded
+	image := archive.Log.Entries[1]
+	if image.Response.Content.Encoding != "base64" || image.Response.Content.Text != "iVBOR/8=" || image.Response.Content.MimeType != "image/png" {
+		t.Errorf("Expected a base64-encoded body, got %+v", image.Response.Content)
+	}
+
+	// The export can be imported again
+	data, err := json.Marshal(archive)
+	if err != nil {
+		t.Fatalf("Failed to marshal archive: %v", err)
+	}
+	if !IsHAR(data) {
+		t.Fatal("Expected the export to be detected as a HAR file")
+	}
+	spec, err := ParseMocks(data, false)
+	if err != nil {
+		t.Fatalf("Failed to parse the export: %v", err)
+	}
+	if len(spec.Mocks) != 2 || spec.Mocks[1].Response.Body != "\x89PNG\xff" {
+		t.Errorf("Expected the exported bodies to round-trip, got %+v", spec.Mocks)
+	}
+}
@@ -1,6 +1,9 @@
 package loader
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,31 +14,140 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// MergeStrategy defines how mocks sharing the same name across files are combined
+type MergeStrategy string
+
+const (
+	// MergeStrategyKeepAll keeps every definition (default)
+	MergeStrategyKeepAll MergeStrategy = "keep-all"
+	// MergeStrategyError fails the load, listing the conflicting files
+	MergeStrategyError MergeStrategy = "error"
+	// MergeStrategyLastWins keeps the definition loaded last
+	MergeStrategyLastWins MergeStrategy = "last-wins"
+	// MergeStrategyFirstWins keeps the definition loaded first
+	MergeStrategyFirstWins MergeStrategy = "first-wins"
+)
+
+// ErrDuplicateMocks is returned by LoadAll when the error merge strategy finds duplicate mock names
+var ErrDuplicateMocks = errors.New("duplicate mock names")
+
+// ParseMergeStrategy parses a merge strategy name
+func ParseMergeStrategy(name string) (MergeStrategy, error) {
+	switch strategy := MergeStrategy(strings.ToLower(name)); strategy {
+	case "", MergeStrategyKeepAll:
+		return MergeStrategyKeepAll, nil
+	case MergeStrategyError, MergeStrategyLastWins, MergeStrategyFirstWins:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("invalid merge strategy '%s' (must be: keep-all, error, last-wins or first-wins)", name)
+	}
+}
+
+// DirPrecedence defines which directory wins when directories define mocks with the same name
+type DirPrecedence string
+
+const (
+	// DirPrecedenceNone doesn't resolve conflicts between directories; the merge strategy applies (default)
+	DirPrecedenceNone DirPrecedence = "none"
+	// DirPrecedenceFirst lets earlier directories (the mocks directory) shadow later ones (plugins)
+	DirPrecedenceFirst DirPrecedence = "first"
+	// DirPrecedenceLast lets later directories shadow earlier ones
+	DirPrecedenceLast DirPrecedence = "last"
+)
+
+// ParseDirPrecedence parses a directory precedence name
+func ParseDirPrecedence(name string) (DirPrecedence, error) {
+	switch precedence := DirPrecedence(strings.ToLower(name)); precedence {
+	case "", DirPrecedenceNone:
+		return DirPrecedenceNone, nil
+	case DirPrecedenceFirst, DirPrecedenceLast:
+		return precedence, nil
+	default:
+		return "", fmt.Errorf("invalid directory precedence '%s' (must be: none, first or last)", name)
+	}
+}
+
+// ShadowedMock describes a mock dropped because a directory with higher precedence defines the same name
+type ShadowedMock struct {
+	Name       string
+	File       string // File of the dropped definition
+	ShadowedBy string // Directory whose definition is used instead
+}
+
+// DuplicateMock describes a mock name defined more than once
+type DuplicateMock struct {
+	Name  string
+	Files []string // Files defining the mock, in load order
+	Kept  string   // File whose definition was kept (empty when all definitions are kept)
+}
+
+// LoadResult summarizes the outcome of the last load
+type LoadResult struct {
+	MockCount  int
+	FileCount  int
+	Duplicates []DuplicateMock
+	Shadowed   []ShadowedMock
+}
+
 // Loader manages loading mock specifications from YAML files
 type Loader struct {
-	mocksDirs []string
-	mocks     []models.Mock
-	mu        sync.RWMutex
+	mocksDirs     []string
+	mocks         []models.Mock
+	mergeStrategy MergeStrategy
+	dirPrecedence DirPrecedence
+	lastResult    LoadResult
+	mu            sync.RWMutex
+}
+
+// loadedMock is a mock together with the file it was loaded from
+type loadedMock struct {
+	mock models.Mock
+	file string
+	dir  int // Index of the directory in load order
 }
 
 // NewLoader creates a new mock loader with one or more directories
 func NewLoader(mocksDirs ...string) *Loader {
 	return &Loader{
-		mocksDirs: mocksDirs,
-		mocks:     make([]models.Mock, 0),
+		mocksDirs:     mocksDirs,
+		mocks:         make([]models.Mock, 0),
+		mergeStrategy: MergeStrategyKeepAll,
+		dirPrecedence: DirPrecedenceNone,
 	}
 }
 
+// SetMergeStrategy sets how mocks with duplicate names are combined
+func (l *Loader) SetMergeStrategy(strategy MergeStrategy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.mergeStrategy = strategy
+}
+
+// SetDirPrecedence sets which directory wins when several directories define the same mock name.
+// Conflicts within the winning directory are still combined with the merge strategy.
+func (l *Loader) SetDirPrecedence(precedence DirPrecedence) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.dirPrecedence = precedence
+}
+
+// SetDirectories replaces the directories mocks are loaded from (takes effect on the next LoadAll)
+func (l *Loader) SetDirectories(mocksDirs ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.mocksDirs = mocksDirs
+}
+
 // LoadAll loads all mock files from all configured directories and subdirectories
 func (l *Loader) LoadAll() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	// Clear existing mocks
-	l.mocks = make([]models.Mock, 0)
+	loaded := make([]loadedMock, 0)
+	fileCount := 0
 
 	// Walk through each configured directory
-	for _, mocksDir := range l.mocksDirs {
+	for dirIndex, mocksDir := range l.mocksDirs {
 		err := filepath.Walk(mocksDir, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				// If the directory doesn't exist, just return (it will be created later)
@@ -50,18 +162,23 @@ func (l *Loader) LoadAll() error {
 				return nil
 			}
 
-			// Only process YAML files
-			if !isYAMLFile(path) {
+			// Only process YAML files and JSON files holding mocks
+			if !IsMockFile(path) {
 				return nil
 			}
 
 			// Load the mock file
-			if err := l.loadFile(path); err != nil {
+			mocks, err := l.loadFile(path)
+			if err != nil {
 				fmt.Printf("Warning: failed to load mock file %s: %v\n", path, err)
 				// Continue processing other files even if one fails
 				return nil
 			}
 
+			fileCount++
+			for _, mock := range mocks {
+				loaded = append(loaded, loadedMock{mock: mock, file: path, dir: dirIndex})
+			}
 			return nil
 		})
 
@@ -70,33 +187,189 @@ func (l *Loader) LoadAll() error {
 		}
 	}
 
+	loaded, shadowed := shadowMocks(loaded, l.mocksDirs, l.dirPrecedence)
+	for _, shadow := range shadowed {
+		fmt.Printf("Mock '%s' in %s is shadowed by %s\n", shadow.Name, shadow.File, shadow.ShadowedBy)
+	}
+
+	mocks, duplicates := mergeMocks(loaded, l.mergeStrategy)
+	for _, dup := range duplicates {
+		if dup.Kept != "" {
+			fmt.Printf("Duplicate mock name '%s' in %s (kept definition from %s)\n", dup.Name, strings.Join(dup.Files, ", "), dup.Kept)
+		} else {
+			fmt.Printf("Duplicate mock name '%s' in %s\n", dup.Name, strings.Join(dup.Files, ", "))
+		}
+	}
+
+	if l.mergeStrategy == MergeStrategyError && len(duplicates) > 0 {
+		conflicts := make([]string, 0, len(duplicates))
+		for _, dup := range duplicates {
+			conflicts = append(conflicts, fmt.Sprintf("'%s' (%s)", dup.Name, strings.Join(dup.Files, ", ")))
+		}
+		// Keep the previously loaded mocks so a bad reload doesn't wipe them out
+		return fmt.Errorf("%w: %s", ErrDuplicateMocks, strings.Join(conflicts, "; "))
+	}
+
+	l.mocks = mocks
+	l.lastResult = LoadResult{
+		MockCount:  len(mocks),
+		FileCount:  fileCount,
+		Duplicates: duplicates,
+		Shadowed:   shadowed,
+	}
+
 	fmt.Printf("Loaded %d total mock(s) from %d directory(ies)\n", len(l.mocks), len(l.mocksDirs))
 	return nil
 }
 
+// shadowMocks drops the mocks whose name is also defined in a directory with higher precedence.
+// Mocks without a name are never shadowed.
+func shadowMocks(loaded []loadedMock, dirs []string, precedence DirPrecedence) ([]loadedMock, []ShadowedMock) {
+	shadowed := make([]ShadowedMock, 0)
+	if precedence != DirPrecedenceFirst && precedence != DirPrecedenceLast {
+		return loaded, shadowed
+	}
+
+	// Find the winning directory for each name
+	winner := make(map[string]int)
+	for _, entry := range loaded {
+		if entry.mock.Name == "" {
+			continue
+		}
+		dir, exists := winner[entry.mock.Name]
+		if !exists || (precedence == DirPrecedenceFirst && entry.dir < dir) || (precedence == DirPrecedenceLast && entry.dir > dir) {
+			winner[entry.mock.Name] = entry.dir
+		}
+	}
+
+	kept := make([]loadedMock, 0, len(loaded))
+	for _, entry := range loaded {
+		if dir, exists := winner[entry.mock.Name]; exists && dir != entry.dir {
+			shadowed = append(shadowed, ShadowedMock{Name: entry.mock.Name, File: entry.file, ShadowedBy: dirs[dir]})
+			continue
+		}
+		kept = append(kept, entry)
+	}
+
+	return kept, shadowed
+}
+
+// mergeMocks combines loaded mocks according to the merge strategy and reports duplicate names.
+// Mocks without a name are never considered duplicates.
+func mergeMocks(loaded []loadedMock, strategy MergeStrategy) ([]models.Mock, []DuplicateMock) {
+	// Find the files defining each name, in load order
+	filesByName := make(map[string][]string)
+	names := make([]string, 0)
+	for _, entry := range loaded {
+		if entry.mock.Name == "" {
+			continue
+		}
+		if _, exists := filesByName[entry.mock.Name]; !exists {
+			names = append(names, entry.mock.Name)
+		}
+		filesByName[entry.mock.Name] = append(filesByName[entry.mock.Name], entry.file)
+	}
+
+	// Pick the entry to keep for each duplicate name
+	keptIndex := make(map[string]int)
+	for i, entry := range loaded {
+		if len(filesByName[entry.mock.Name]) < 2 {
+			continue
+		}
+		if _, exists := keptIndex[entry.mock.Name]; !exists || strategy == MergeStrategyLastWins {
+			keptIndex[entry.mock.Name] = i
+		}
+	}
+
+	duplicates := make([]DuplicateMock, 0)
+	for _, name := range names {
+		files := filesByName[name]
+		if len(files) < 2 {
+			continue
+		}
+		dup := DuplicateMock{Name: name, Files: files}
+		if strategy == MergeStrategyFirstWins || strategy == MergeStrategyLastWins {
+			dup.Kept = loaded[keptIndex[name]].file
+		}
+		duplicates = append(duplicates, dup)
+	}
+
+	mocks := make([]models.Mock, 0, len(loaded))
+	for i, entry := range loaded {
+		if idx, isDuplicate := keptIndex[entry.mock.Name]; isDuplicate &&
+			(strategy == MergeStrategyFirstWins || strategy == MergeStrategyLastWins) && idx != i {
+			continue
+		}
+		mocks = append(mocks, entry.mock)
+	}
+
+	return mocks, duplicates
+}
+
 // loadFile loads a single YAML mock file
-func (l *Loader) loadFile(path string) error {
+func (l *Loader) loadFile(path string) ([]models.Mock, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
 	var spec models.MockSpec
+	if isJSONFile(path) {
+		// JSON is valid YAML, so the YAML field names and decoding apply; decoding it as JSON
+		// first reports syntax errors with JSON positions.
+		var document interface{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+	}
 	if err := yaml.Unmarshal(data, &spec); err != nil {
-		return fmt.Errorf("failed to parse YAML: %w", err)
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
 
 	// Add all mocks from this file
+	mocks := make([]models.Mock, 0, len(spec.Mocks))
 	for _, mock := range spec.Mocks {
+		// Resolve references to named responses
+		if mock.ResponseRef != "" {
+			response, exists := spec.Responses[mock.ResponseRef]
+			if !exists {
+				return nil, fmt.Errorf("mock %q references unknown response %q", mock.Name, mock.ResponseRef)
+			}
+			mock.Response = response
+		}
+
 		// Set default values if not specified
 		if mock.Response.StatusCode == 0 {
 			mock.Response.StatusCode = 200
 		}
-		l.mocks = append(l.mocks, mock)
+		resolveBodyFile(&mock.Response, path)
+		mocks = append(mocks, mock)
 	}
 
 	fmt.Printf("Loaded %d mock(s) from %s\n", len(spec.Mocks), path)
-	return nil
+	return mocks, nil
+}
+
+// LoadResponse loads a single response definition (status_code, headers, body, ...) from a YAML file
+func LoadResponse(path string) (*models.Response, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var response models.Response
+	if err := yaml.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	resolveBodyFile(&response, path)
+	return &response, nil
+}
+
+// resolveBodyFile makes a relative body file path relative to the file the response was loaded from
+func resolveBodyFile(response *models.Response, path string) {
+	if response.BodyFile != "" && !filepath.IsAbs(response.BodyFile) {
+		response.BodyFile = filepath.Join(filepath.Dir(path), response.BodyFile)
+	}
 }
 
 // GetMocks returns a copy of all loaded mocks
@@ -110,8 +383,51 @@ func (l *Loader) GetMocks() []models.Mock {
 	return mocks
 }
 
-// isYAMLFile checks if a file has a YAML extension
-func isYAMLFile(path string) bool {
+// GetLoadResult returns a summary of the last successful load
+func (l *Loader) GetLoadResult() LoadResult {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.lastResult
+}
+
+// IsMockFile checks if a file is a mock file: a YAML file, or a JSON file whose top level has
+// a "mocks" key. Other JSON files, like response bodies read with body_file, aren't.
+func IsMockFile(path string) bool {
 	ext := strings.ToLower(filepath.Ext(path))
-	return ext == ".yaml" || ext == ".yml"
+	if ext == ".yaml" || ext == ".yml" {
+		return true
+	}
+	if !isJSONFile(path) {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	return err == nil && hasMocksKey(data)
+}
+
+// hasMocksKey checks if JSON data is an object with a top-level "mocks" key. It only reads up
+// to that key, so mock files with syntax errors further on are still recognized (and reported).
+func hasMocksKey(data []byte) bool {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return false
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if token == "mocks" {
+			return true
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// isJSONFile checks if a file has a JSON extension
+func isJSONFile(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".json"
 }
@@ -1,8 +1,10 @@
 package loader
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -17,14 +19,15 @@ func TestLoaderLoadAll(t *testing.T) {
 
 	mocks := loader.GetMocks()
 
-	// We expect 4 mocks:
+	// We expect 5 mocks:
 	// - 2 from valid-mock.yaml
 	// - 1 from subdir/nested-mock.yaml
 	// - 1 from defaults.yaml
-	// (invalid.yaml should fail to parse but not stop loading)
+	// - 1 from json-mock.json
+	// (invalid.yaml and invalid.json should fail to parse but not stop loading)
 	// (readme.txt should be ignored)
-	if len(mocks) != 4 {
-		t.Errorf("Expected 4 mocks, got %d", len(mocks))
+	if len(mocks) != 5 {
+		t.Errorf("Expected 5 mocks, got %d", len(mocks))
 	}
 
 	// Verify mock names
@@ -33,7 +36,7 @@ func TestLoaderLoadAll(t *testing.T) {
 		mockNames[mock.Name] = true
 	}
 
-	expectedNames := []string{"Test Mock 1", "Test Mock 2", "Nested Mock", "Mock with defaults"}
+	expectedNames := []string{"Test Mock 1", "Test Mock 2", "Nested Mock", "Mock with defaults", "JSON Mock"}
 	for _, name := range expectedNames {
 		if !mockNames[name] {
 			t.Errorf("Expected mock '%s' not found", name)
@@ -213,6 +216,63 @@ func TestLoaderReload(t *testing.T) {
 	}
 }
 
+func TestLoaderJSONFile(t *testing.T) {
+	mocks, err := NewLoader("testdata").loadFile(filepath.Join("testdata", "json-mock.json"))
+	if err != nil {
+		t.Fatalf("Failed to load JSON mock file: %v", err)
+	}
+	if len(mocks) != 1 {
+		t.Fatalf("Expected 1 mock, got %d", len(mocks))
+	}
+
+	mock := mocks[0]
+	if mock.Name != "JSON Mock" || mock.Priority != 7 || mock.Request.URI != "/api/json" || mock.Request.Headers["Accept"] != "application/json" {
+		t.Errorf("Unexpected request: %+v", mock)
+	}
+	if mock.Response.StatusCode != 202 || mock.Response.Body != `{"source": "json"}` || mock.Response.Headers["Content-Type"] != "application/json" {
+		t.Errorf("Unexpected response: %+v", mock.Response)
+	}
+
+	_, err = NewLoader("testdata").loadFile(filepath.Join("testdata", "invalid.json"))
+	if err == nil || !strings.Contains(err.Error(), "failed to parse JSON") {
+		t.Errorf("Expected a JSON parse error, got %v", err)
+	}
+}
+
+func TestIsMockFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{filepath.Join("testdata", "valid-mock.yaml"), true},
+		{filepath.Join("testdata", "json-mock.json"), true},
+		{filepath.Join("testdata", "invalid.json"), true}, // Has a "mocks" key, so its syntax error is reported
+		{filepath.Join("testdata", "users-body.json"), false},
+		{filepath.Join("testdata", "readme.txt"), false},
+		{write("mocks-later.json", `{"description": {"nested": {"mocks": 1}}, "mocks": []}`), true},
+		{write("nested-mocks.json", `{"data": {"mocks": []}}`), false},
+		{write("array.json", `[{"mocks": []}]`), false},
+		{write("upper.JSON", `{"mocks": []}`), true},
+		{write("empty.json", ``), false},
+		{filepath.Join(dir, "missing.json"), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsMockFile(tt.path); got != tt.expected {
+			t.Errorf("IsMockFile(%s) = %v, expected %v", tt.path, got, tt.expected)
+		}
+	}
+}
+
 func TestLoaderIgnoresNonYAMLFiles(t *testing.T) {
 	testDir := "testdata"
 	loader := NewLoader(testDir)
@@ -392,3 +452,312 @@ func TestLoaderEmptyDirectoryList(t *testing.T) {
 		t.Errorf("Expected 0 mocks with empty directory list, got %d", len(mocks))
 	}
 }
+
+// writeDuplicateMockFiles creates two directories that both define a mock named "Shared Mock"
+func writeDuplicateMockFiles(t *testing.T) (string, string) {
+	t.Helper()
+
+	dir1 := t.TempDir()
+	dir2 := t.TempDir()
+
+	content1 := `mocks:
+  - name: "Shared Mock"
+    request:
+      uri: "/api/shared"
+      method: "GET"
+    response:
+      body: "from dir1"
+  - name: "Unique Mock"
+    request:
+      uri: "/api/unique"
+      method: "GET"
+`
+	content2 := `mocks:
+  - name: "Shared Mock"
+    request:
+      uri: "/api/shared"
+      method: "GET"
+    response:
+      body: "from dir2"
+`
+	if err := os.WriteFile(filepath.Join(dir1, "mocks.yaml"), []byte(content1), 0644); err != nil {
+		t.Fatalf("Failed to write mock file 1: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir2, "mocks.yaml"), []byte(content2), 0644); err != nil {
+		t.Fatalf("Failed to write mock file 2: %v", err)
+	}
+
+	return dir1, dir2
+}
+
+func TestLoaderMergeStrategies(t *testing.T) {
+	tests := []struct {
+		strategy     MergeStrategy
+		expectedLen  int
+		expectedBody string
+	}{
+		{MergeStrategyKeepAll, 3, ""},
+		{MergeStrategyFirstWins, 2, "from dir1"},
+		{MergeStrategyLastWins, 2, "from dir2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			dir1, dir2 := writeDuplicateMockFiles(t)
+
+			loader := NewLoader(dir1, dir2)
+			loader.SetMergeStrategy(tt.strategy)
+			if err := loader.LoadAll(); err != nil {
+				t.Fatalf("LoadAll failed: %v", err)
+			}
+
+			mocks := loader.GetMocks()
+			if len(mocks) != tt.expectedLen {
+				t.Fatalf("Expected %d mocks, got %d", tt.expectedLen, len(mocks))
+			}
+
+			if tt.expectedBody != "" {
+				for _, mock := range mocks {
+					if mock.Name == "Shared Mock" && mock.Response.Body != tt.expectedBody {
+						t.Errorf("Expected body '%s', got '%s'", tt.expectedBody, mock.Response.Body)
+					}
+				}
+			}
+
+			result := loader.GetLoadResult()
+			if len(result.Duplicates) != 1 || result.Duplicates[0].Name != "Shared Mock" {
+				t.Fatalf("Expected one duplicate 'Shared Mock', got %+v", result.Duplicates)
+			}
+			if len(result.Duplicates[0].Files) != 2 {
+				t.Errorf("Expected 2 conflicting files, got %v", result.Duplicates[0].Files)
+			}
+		})
+	}
+}
+
+func TestLoaderMergeStrategyError(t *testing.T) {
+	dir1, dir2 := writeDuplicateMockFiles(t)
+
+	loader := NewLoader(dir1, dir2)
+	loader.SetMergeStrategy(MergeStrategyError)
+
+	err := loader.LoadAll()
+	if !errors.Is(err, ErrDuplicateMocks) {
+		t.Fatalf("Expected ErrDuplicateMocks, got %v", err)
+	}
+	if !strings.Contains(err.Error(), filepath.Join(dir1, "mocks.yaml")) || !strings.Contains(err.Error(), filepath.Join(dir2, "mocks.yaml")) {
+		t.Errorf("Expected error to list both conflicting files, got: %v", err)
+	}
+	if len(loader.GetMocks()) != 0 {
+		t.Errorf("Expected no mocks to be loaded on conflict, got %d", len(loader.GetMocks()))
+	}
+}
+
+func TestParseMergeStrategy(t *testing.T) {
+	if strategy, err := ParseMergeStrategy(""); err != nil || strategy != MergeStrategyKeepAll {
+		t.Errorf("Expected empty strategy to default to keep-all, got %s (%v)", strategy, err)
+	}
+	if strategy, err := ParseMergeStrategy("Last-Wins"); err != nil || strategy != MergeStrategyLastWins {
+		t.Errorf("Expected last-wins, got %s (%v)", strategy, err)
+	}
+	if _, err := ParseMergeStrategy("random"); err == nil {
+		t.Error("Expected error for invalid strategy")
+	}
+}
+
+func TestLoaderDirPrecedence(t *testing.T) {
+	tests := []struct {
+		precedence   DirPrecedence
+		expectedBody string
+		shadowedDir  int
+	}{
+		{DirPrecedenceFirst, "from dir1", 1},
+		{DirPrecedenceLast, "from dir2", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.precedence), func(t *testing.T) {
+			dir1, dir2 := writeDuplicateMockFiles(t)
+			dirs := []string{dir1, dir2}
+
+			loader := NewLoader(dirs...)
+			loader.SetMergeStrategy(MergeStrategyError) // Shadowed mocks aren't duplicates
+			loader.SetDirPrecedence(tt.precedence)
+			if err := loader.LoadAll(); err != nil {
+				t.Fatalf("LoadAll failed: %v", err)
+			}
+
+			mocks := loader.GetMocks()
+			if len(mocks) != 2 {
+				t.Fatalf("Expected 2 mocks, got %d", len(mocks))
+			}
+			for _, mock := range mocks {
+				if mock.Name == "Shared Mock" && mock.Response.Body != tt.expectedBody {
+					t.Errorf("Expected body '%s', got '%s'", tt.expectedBody, mock.Response.Body)
+				}
+			}
+
+			result := loader.GetLoadResult()
+			if len(result.Duplicates) != 0 {
+				t.Errorf("Expected no duplicates, got %+v", result.Duplicates)
+			}
+			if len(result.Shadowed) != 1 {
+				t.Fatalf("Expected one shadowed mock, got %+v", result.Shadowed)
+			}
+			shadow := result.Shadowed[0]
+			if shadow.Name != "Shared Mock" || filepath.Dir(shadow.File) != dirs[tt.shadowedDir] || shadow.ShadowedBy != dirs[1-tt.shadowedDir] {
+				t.Errorf("Unexpected shadowed mock: %+v", shadow)
+			}
+		})
+	}
+}
+
+func TestParseDirPrecedence(t *testing.T) {
+	if precedence, err := ParseDirPrecedence(""); err != nil || precedence != DirPrecedenceNone {
+		t.Errorf("Expected none for empty precedence, got %q (%v)", precedence, err)
+	}
+	if precedence, err := ParseDirPrecedence("FIRST"); err != nil || precedence != DirPrecedenceFirst {
+		t.Errorf("Expected first, got %q (%v)", precedence, err)
+	}
+	if _, err := ParseDirPrecedence("middle"); err == nil {
+		t.Error("Expected error for invalid precedence")
+	}
+}
+
+func TestLoaderResponseRefs(t *testing.T) {
+	tempDir := t.TempDir()
+
+	content := `responses:
+  notFound:
+    status_code: 404
+    headers:
+      Content-Type: "application/json"
+    body: '{"error": "not found"}'
+
+mocks:
+  - name: "Missing User"
+    request:
+      uri: "/api/users/999"
+      method: "GET"
+    response_ref: notFound
+  - name: "Missing Order"
+    request:
+      uri: "/api/orders/999"
+      method: "GET"
+    response_ref: notFound
+`
+	if err := os.WriteFile(filepath.Join(tempDir, "mocks.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write mock file: %v", err)
+	}
+
+	loader := NewLoader(tempDir)
+	if err := loader.LoadAll(); err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+
+	mocks := loader.GetMocks()
+	if len(mocks) != 2 {
+		t.Fatalf("Expected 2 mocks, got %d", len(mocks))
+	}
+	for _, mock := range mocks {
+		if mock.Response.StatusCode != 404 {
+			t.Errorf("%s: expected status 404, got %d", mock.Name, mock.Response.StatusCode)
+		}
+		if mock.Response.Body != `{"error": "not found"}` {
+			t.Errorf("%s: unexpected body %s", mock.Name, mock.Response.Body)
+		}
+		if mock.Response.Headers["Content-Type"] != "application/json" {
+			t.Errorf("%s: expected Content-Type header, got %v", mock.Name, mock.Response.Headers)
+		}
+	}
+}
+
+func TestLoaderUnknownResponseRef(t *testing.T) {
+	tempDir := t.TempDir()
+
+	content := `mocks:
+  - name: "Broken Ref"
+    request:
+      uri: "/api/broken"
+    response_ref: missing
+`
+	if err := os.WriteFile(filepath.Join(tempDir, "mocks.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write mock file: %v", err)
+	}
+
+	loader := NewLoader(tempDir)
+	if err := loader.LoadAll(); err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+
+	// The file with the unknown reference is skipped
+	if len(loader.GetMocks()) != 0 {
+		t.Errorf("Expected no mocks to be loaded, got %d", len(loader.GetMocks()))
+	}
+}
+
+func TestLoadResponse(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "default.yaml")
+
+	content := `status_code: 404
+headers:
+  Content-Type: "application/json"
+body: '{"error": "no mock for {{.Path}}"}'
+template: true
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write response file: %v", err)
+	}
+
+	response, err := LoadResponse(path)
+	if err != nil {
+		t.Fatalf("LoadResponse failed: %v", err)
+	}
+	if response.StatusCode != 404 || !response.Template {
+		t.Errorf("Expected status 404 with template enabled, got %+v", response)
+	}
+	if response.Headers["Content-Type"] != "application/json" {
+		t.Errorf("Expected Content-Type header, got %v", response.Headers)
+	}
+
+	if _, err := LoadResponse(filepath.Join(tempDir, "missing.yaml")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestLoaderBodyFile(t *testing.T) {
+	tempDir := t.TempDir()
+	absolute := filepath.Join(t.TempDir(), "absolute.bin")
+
+	content := `mocks:
+  - name: "Relative"
+    request:
+      uri: "/relative"
+    response:
+      body_file: "files/video.mp4"
+  - name: "Absolute"
+    request:
+      uri: "/absolute"
+    response:
+      body_file: "` + absolute + `"
+`
+	if err := os.WriteFile(filepath.Join(tempDir, "mocks.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write mock file: %v", err)
+	}
+
+	loader := NewLoader(tempDir)
+	if err := loader.LoadAll(); err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"Relative": filepath.Join(tempDir, "files", "video.mp4"),
+		"Absolute": absolute,
+	}
+	for _, mock := range loader.GetMocks() {
+		if mock.Response.BodyFile != expected[mock.Name] {
+			t.Errorf("Expected body file %s for %s, got %s", expected[mock.Name], mock.Name, mock.Response.BodyFile)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package management
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/comfortablynumb/pmp-mock-http/internal/observability"
 	"go.uber.org/zap"
@@ -31,6 +33,15 @@ func (h *APIHandler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/v1/mocks/{id}/versions", h.handleVersions)
 	mux.HandleFunc("/api/v1/mocks/{id}/versions/{version}", h.handleVersion)
 	mux.HandleFunc("/api/v1/mocks/{id}/rollback", h.handleRollback)
+	mux.HandleFunc("/api/v1/mocks/{id}/diff", h.handleDiff)
+
+	// Enabling and disabling
+	mux.HandleFunc("/api/v1/mocks/{id}/enable", h.handleEnable)
+	mux.HandleFunc("/api/v1/mocks/{id}/disable", h.handleEnable)
+
+	// Sequence counters
+	mux.HandleFunc("/api/v1/mocks/{id}/counter", h.handleCounter)
+	mux.HandleFunc("/api/v1/mocks/{id}/counter/reset", h.handleCounterReset)
 
 	// Templates
 	mux.HandleFunc("/api/v1/templates", h.handleTemplates)
@@ -87,8 +98,19 @@ func (h *APIHandler) listMocks(w http.ResponseWriter, r *http.Request) {
 	if search := r.URL.Query().Get("search"); search != "" {
 		filter.Search = search
 	}
+	if path := r.URL.Query().Get("path"); path != "" {
+		filter.Path = path
+		filter.PathRegex = r.URL.Query().Get("path_regex") == "true"
+	}
+	if method := r.URL.Query().Get("method"); method != "" {
+		filter.Method = method
+	}
 
 	mocks, err := h.manager.ListMocks(&filter)
+	if errors.Is(err, ErrInvalidFilter) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		observability.Error("Failed to list mocks", zap.Error(err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -234,6 +256,115 @@ func (h *APIHandler) handleRollback(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(mock)
 }
 
+// handleDiff handles comparing two versions of a mock
+func (h *APIHandler) handleDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid from version", http.StatusBadRequest)
+		return
+	}
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid to version", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := h.manager.DiffVersions(r.PathValue("id"), from, to)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrVersionNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(diff)
+}
+
+// handleEnable handles enabling and disabling a mock
+func (h *APIHandler) handleEnable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	enabled := strings.HasSuffix(r.URL.Path, "/enable")
+	mock, err := h.manager.SetEnabled(r.PathValue("id"), enabled)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(mock)
+}
+
+// handleCounter handles reading a mock's sequence counters
+func (h *APIHandler) handleCounter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	counter, err := h.manager.GetCounter(id)
+	if err != nil {
+		writeCounterError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(counter)
+}
+
+// handleCounterReset handles resetting a mock's sequence counters
+func (h *APIHandler) handleCounterReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	if err := h.manager.ResetCounter(id); err != nil {
+		writeCounterError(w, err)
+		return
+	}
+
+	counter, err := h.manager.GetCounter(id)
+	if err != nil {
+		writeCounterError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(counter)
+}
+
+// writeCounterError maps sequence counter errors to status codes
+func writeCounterError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrCountersUnavailable):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // handleTemplates handles template listing and creation
 func (h *APIHandler) handleTemplates(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -359,7 +490,7 @@ func (h *APIHandler) handleExport(w http.ResponseWriter, r *http.Request) {
 
 	// Set content type based on format
 	switch req.Format {
-	case ExportFormatYAML:
+	case ExportFormatYAML, ExportFormatOpenAPI:
 		w.Header().Set("Content-Type", "application/x-yaml")
 	case ExportFormatJSON:
 		w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,96 @@
+package management
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// DiffVersions compares two stored versions of a mock field by field. Fields are named
+// after the mock configuration (YAML) keys, so the diff reads like the mock files.
+func (m *Manager) DiffVersions(id string, from, to int) (*VersionDiff, error) {
+	fromVersion, err := m.GetVersion(id, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := m.GetVersion(id, to)
+	if err != nil {
+		return nil, err
+	}
+
+	fromFields, err := mockFields(&fromVersion.Mock)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := mockFields(&toVersion.Mock)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &VersionDiff{ID: id, From: from, To: to, Changes: []FieldChange{}}
+	diffValues("", fromFields, toFields, &diff.Changes)
+	sort.Slice(diff.Changes, func(i, j int) bool {
+		return diff.Changes[i].Field < diff.Changes[j].Field
+	})
+	return diff, nil
+}
+
+// mockFields converts a mock to its generic YAML representation
+func mockFields(mock *models.Mock) (interface{}, error) {
+	data, err := yaml.Marshal(mock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal mock: %w", err)
+	}
+
+	var fields interface{}
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mock: %w", err)
+	}
+	return fields, nil
+}
+
+// diffValues appends the changes between two generic values, recursing into maps and lists
+func diffValues(field string, from, to interface{}, changes *[]FieldChange) {
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap {
+		keys := make(map[string]bool)
+		for key := range fromMap {
+			keys[key] = true
+		}
+		for key := range toMap {
+			keys[key] = true
+		}
+		for key := range keys {
+			child := key
+			if field != "" {
+				child = field + "." + key
+			}
+			diffValues(child, fromMap[key], toMap[key], changes)
+		}
+		return
+	}
+
+	fromList, fromIsList := from.([]interface{})
+	toList, toIsList := to.([]interface{})
+	if fromIsList && toIsList {
+		for i := 0; i < len(fromList) || i < len(toList); i++ {
+			var fromItem, toItem interface{}
+			if i < len(fromList) {
+				fromItem = fromList[i]
+			}
+			if i < len(toList) {
+				toItem = toList[i]
+			}
+			diffValues(fmt.Sprintf("%s[%d]", field, i), fromItem, toItem, changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, FieldChange{Field: field, From: from, To: to})
+	}
+}
@@ -2,20 +2,44 @@ package management
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+	"github.com/comfortablynumb/pmp-mock-http/internal/openapi"
 	"gopkg.in/yaml.v3"
 )
 
+// ErrInvalidFilter is returned when a mock filter can't be applied (e.g. an invalid regex)
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// ErrNotFound is returned when a mock doesn't exist
+var ErrNotFound = errors.New("mock not found")
+
+// ErrVersionNotFound is returned when a mock doesn't have the requested version
+var ErrVersionNotFound = errors.New("version not found")
+
+// ErrCountersUnavailable is returned when the manager isn't connected to a live server
+var ErrCountersUnavailable = errors.New("sequence counters not available")
+
+// SequenceCounters gives access to the sequence counters of the live mock server
+type SequenceCounters interface {
+	GetCallCounts(mockName string) map[string]int
+	ResetCallCounts(mockName string)
+}
+
 // Manager handles mock lifecycle and versioning
 type Manager struct {
 	mocks     map[string]*ManagedMock
 	versions  map[string][]MockVersion
 	templates map[string]*MockTemplate
+	counters  SequenceCounters // Live sequence counters, looked up by mock name
+	onChange  func()           // Called after the mocks changed (nil = no-op)
 	mu        sync.RWMutex
 	nextID    int
 }
@@ -31,10 +55,16 @@ func NewManager() *Manager {
 }
 
 // CreateMock creates a new managed mock
-func (m *Manager) CreateMock(req CreateMockRequest) (*ManagedMock, error) {
+func (m *Manager) CreateMock(req CreateMockRequest) (_ *ManagedMock, err error) {
+	defer m.notifyChange(&err)
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	return m.createMock(req), nil
+}
+
+// createMock adds a new managed mock. The caller must hold m.mu.
+func (m *Manager) createMock(req CreateMockRequest) *ManagedMock {
 	id := m.generateID()
 
 	metadata := MockMetadata{
@@ -49,6 +79,7 @@ func (m *Manager) CreateMock(req CreateMockRequest) (*ManagedMock, error) {
 		UpdatedAt:   time.Now(),
 		Source:      "api",
 		Template:    req.Template,
+		Enabled:     req.Enabled == nil || *req.Enabled,
 	}
 
 	managed := &ManagedMock{
@@ -69,7 +100,7 @@ func (m *Manager) CreateMock(req CreateMockRequest) (*ManagedMock, error) {
 		},
 	}
 
-	return managed, nil
+	return managed
 }
 
 // GetMock retrieves a mock by ID
@@ -79,14 +110,92 @@ func (m *Manager) GetMock(id string) (*ManagedMock, error) {
 
 	mock, exists := m.mocks[id]
 	if !exists {
-		return nil, fmt.Errorf("mock not found: %s", id)
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
 	}
 
 	return mock, nil
 }
 
+// SetSequenceCounters connects the manager to the sequence counters of the live server
+func (m *Manager) SetSequenceCounters(counters SequenceCounters) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.counters = counters
+}
+
+// SetChangeHandler sets the function called after a mock is created, updated, deleted or
+// rolled back, e.g. to update the mocks served by the live server. It runs without the
+// manager's lock held, so it may read the mocks.
+func (m *Manager) SetChangeHandler(onChange func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.onChange = onChange
+}
+
+// notifyChange calls the change handler unless the mutation failed. Mutations defer it
+// before taking the lock, so it runs once the lock is released.
+func (m *Manager) notifyChange(err *error) {
+	if *err != nil {
+		return
+	}
+
+	m.mu.RLock()
+	onChange := m.onChange
+	m.mu.RUnlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// GetCounter returns the live sequence counters of a mock
+func (m *Manager) GetCounter(id string) (*MockCounter, error) {
+	mock, counters, err := m.counterTarget(id)
+	if err != nil {
+		return nil, err
+	}
+
+	counter := &MockCounter{ID: id, Name: mock.Mock.Name, Clients: make(map[string]int)}
+	for client, count := range counters.GetCallCounts(mock.Mock.Name) {
+		if client == "" {
+			counter.Count = count
+		} else {
+			counter.Clients[client] = count
+		}
+	}
+	return counter, nil
+}
+
+// ResetCounter resets the live sequence counters of a mock so its sequence starts over
+func (m *Manager) ResetCounter(id string) error {
+	mock, counters, err := m.counterTarget(id)
+	if err != nil {
+		return err
+	}
+
+	counters.ResetCallCounts(mock.Mock.Name)
+	return nil
+}
+
+// counterTarget returns the mock and the sequence counters to read or reset
+func (m *Manager) counterTarget(id string) (*ManagedMock, SequenceCounters, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.counters == nil {
+		return nil, nil, ErrCountersUnavailable
+	}
+	mock, exists := m.mocks[id]
+	if !exists {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return mock, m.counters, nil
+}
+
 // UpdateMock updates an existing mock
-func (m *Manager) UpdateMock(id string, req UpdateMockRequest) (*ManagedMock, error) {
+func (m *Manager) UpdateMock(id string, req UpdateMockRequest) (_ *ManagedMock, err error) {
+	defer m.notifyChange(&err)
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -134,8 +243,25 @@ func (m *Manager) UpdateMock(id string, req UpdateMockRequest) (*ManagedMock, er
 	return managed, nil
 }
 
+// SetEnabled enables or disables a mock. Disabled mocks keep their definition and history
+// but aren't served; toggling doesn't create a new version.
+func (m *Manager) SetEnabled(id string, enabled bool) (_ *ManagedMock, err error) {
+	defer m.notifyChange(&err)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	managed, exists := m.mocks[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	managed.Metadata.Enabled = enabled
+	return managed, nil
+}
+
 // DeleteMock deletes a mock
-func (m *Manager) DeleteMock(id string) error {
+func (m *Manager) DeleteMock(id string) (err error) {
+	defer m.notifyChange(&err)
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -154,10 +280,19 @@ func (m *Manager) ListMocks(filter *MockFilter) ([]*ManagedMock, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	var pathRegex *regexp.Regexp
+	if filter != nil && filter.PathRegex && filter.Path != "" {
+		var err error
+		pathRegex, err = regexp.Compile(filter.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+	}
+
 	var result []*ManagedMock
 
 	for _, mock := range m.mocks {
-		if filter == nil || m.matchesFilter(mock, filter) {
+		if filter == nil || (m.matchesFilter(mock, filter) && matchesRequestFilter(&mock.Mock, filter, pathRegex)) {
 			result = append(result, mock)
 		}
 	}
@@ -165,6 +300,41 @@ func (m *Manager) ListMocks(filter *MockFilter) ([]*ManagedMock, error) {
 	return result, nil
 }
 
+// matchesRequestFilter checks if a mock handles the path and method in the filter.
+// A plain path matches mocks with that exact URI or whose URI regex matches the path;
+// a regex path (pathRegex != nil) is matched against the mock URI itself.
+func matchesRequestFilter(mock *models.Mock, filter *MockFilter, pathRegex *regexp.Regexp) bool {
+	if filter.Path != "" {
+		if pathRegex != nil {
+			if !pathRegex.MatchString(mock.Request.URI) {
+				return false
+			}
+		} else if !matchesPattern(filter.Path, mock.Request.URI, mock.Request.IsRegex.URI) {
+			return false
+		}
+	}
+
+	if filter.Method != "" && !matchesPattern(filter.Method, mock.Request.Method, mock.Request.IsRegex.Method) {
+		return false
+	}
+
+	return true
+}
+
+// matchesPattern checks a concrete value against a mock pattern, the same way the matcher does
+func matchesPattern(value, pattern string, useRegex bool) bool {
+	if pattern == "" {
+		return true // Empty pattern matches anything
+	}
+
+	if useRegex {
+		matched, err := regexp.MatchString(pattern, value)
+		return err == nil && matched
+	}
+
+	return strings.EqualFold(value, pattern)
+}
+
 // GetVersionHistory retrieves version history for a mock
 func (m *Manager) GetVersionHistory(id string) ([]MockVersion, error) {
 	m.mu.RLock()
@@ -185,7 +355,7 @@ func (m *Manager) GetVersion(id string, version int) (*MockVersion, error) {
 
 	versions, exists := m.versions[id]
 	if !exists {
-		return nil, fmt.Errorf("mock not found: %s", id)
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
 	}
 
 	for _, v := range versions {
@@ -194,11 +364,12 @@ func (m *Manager) GetVersion(id string, version int) (*MockVersion, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("version not found: %d", version)
+	return nil, fmt.Errorf("%w: %d", ErrVersionNotFound, version)
 }
 
 // RollbackToVersion rolls back a mock to a specific version
-func (m *Manager) RollbackToVersion(id string, version int, author string) (*ManagedMock, error) {
+func (m *Manager) RollbackToVersion(id string, version int, author string) (_ *ManagedMock, err error) {
+	defer m.notifyChange(&err)
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -361,8 +532,12 @@ func (m *Manager) Export(req ExportRequest) (string, error) {
 		return string(data), err
 
 	case ExportFormatOpenAPI:
-		// TODO: Convert to OpenAPI format
-		return "", fmt.Errorf("OpenAPI export not yet implemented")
+		plain := make([]models.Mock, 0, len(mocks))
+		for _, mock := range mocks {
+			plain = append(plain, mock.Mock)
+		}
+		data, err := yaml.Marshal(openapi.GenerateSpec("PMP Mock HTTP mocks", plain))
+		return string(data), err
 
 	default:
 		return "", fmt.Errorf("unsupported export format: %s", req.Format)
@@ -370,44 +545,63 @@ func (m *Manager) Export(req ExportRequest) (string, error) {
 }
 
 // Import imports mocks from the specified format
-func (m *Manager) Import(req ImportRequest) (int, error) {
-	var mocks []ManagedMock
-
+func (m *Manager) Import(req ImportRequest) (count int, err error) {
+	var unmarshal func([]byte, interface{}) error
 	switch req.Format {
 	case ExportFormatYAML:
-		if err := yaml.Unmarshal([]byte(req.Data), &mocks); err != nil {
-			return 0, err
-		}
-
+		unmarshal = yaml.Unmarshal
 	case ExportFormatJSON:
-		if err := json.Unmarshal([]byte(req.Data), &mocks); err != nil {
-			return 0, err
-		}
-
+		unmarshal = json.Unmarshal
 	default:
 		return 0, fmt.Errorf("unsupported import format: %s", req.Format)
 	}
 
-	count := 0
-	for _, mock := range mocks {
+	var mocks []ManagedMock
+	if err := unmarshal([]byte(req.Data), &mocks); err != nil {
+		return 0, err
+	}
+
+	// Read the enabled flags separately, so mocks without one (e.g. written by hand) are
+	// imported enabled while exported disabled mocks stay disabled
+	var states []importedState
+	if err := unmarshal([]byte(req.Data), &states); err != nil {
+		return 0, err
+	}
+
+	// Create all mocks under one lock and notify once, so the served mocks are refreshed
+	// once per import rather than once per mock
+	m.mu.Lock()
+	for i, mock := range mocks {
 		createReq := CreateMockRequest{
-			Mock:   mock.Mock,
-			Tags:   append(mock.Metadata.Tags, req.Tags...),
-			Labels: mock.Metadata.Labels,
+			Mock:    mock.Mock,
+			Tags:    append(mock.Metadata.Tags, req.Tags...),
+			Labels:  mock.Metadata.Labels,
+			Enabled: states[i].Metadata.Enabled,
 		}
 
 		if req.Source != "" {
 			createReq.Labels["source"] = req.Source
 		}
 
-		if _, err := m.CreateMock(createReq); err == nil {
-			count++
-		}
+		m.createMock(createReq)
+		count++
 	}
+	m.mu.Unlock()
 
+	if count > 0 {
+		m.notifyChange(&err)
+	}
 	return count, nil
 }
 
+// importedState holds the metadata of an imported mock that can't be told apart from
+// its zero value in ManagedMock
+type importedState struct {
+	Metadata struct {
+		Enabled *bool `json:"enabled" yaml:"enabled"`
+	} `json:"metadata" yaml:"metadata"`
+}
+
 // matchesFilter checks if a mock matches the filter criteria
 func (m *Manager) matchesFilter(mock *ManagedMock, filter *MockFilter) bool {
 	// Filter by tags
@@ -483,13 +677,33 @@ func (m *Manager) generateID() string {
 	return id
 }
 
-// GetAllMocks returns all mocks for integration with the server
+// GetAllMocks returns the enabled mocks for integration with the server
 func (m *Manager) GetAllMocks() []models.Mock {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	result := make([]models.Mock, 0, len(m.mocks))
+	enabled := make([]*ManagedMock, 0, len(m.mocks))
 	for _, managed := range m.mocks {
+		if managed.Metadata.Enabled {
+			enabled = append(enabled, managed)
+		}
+	}
+
+	// Keep a stable order, so mocks of equal priority always match in the same order
+	sort.Slice(enabled, func(i, j int) bool {
+		a, b := enabled[i].Metadata, enabled[j].Metadata
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		// IDs are "mock-N": shorter ones have lower numbers
+		if len(a.ID) != len(b.ID) {
+			return len(a.ID) < len(b.ID)
+		}
+		return a.ID < b.ID
+	})
+
+	result := make([]models.Mock, 0, len(enabled))
+	for _, managed := range enabled {
 		result = append(result, managed.Mock)
 	}
 	return result
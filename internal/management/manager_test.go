@@ -0,0 +1,470 @@
+package management
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+	"github.com/comfortablynumb/pmp-mock-http/internal/openapi"
+	"gopkg.in/yaml.v3"
+)
+
+func TestListMocksRequestFilter(t *testing.T) {
+	manager := NewManager()
+
+	mocks := []models.Mock{
+		{Name: "create-order", Request: models.Request{URI: "/orders", Method: "POST"}},
+		{Name: "list-orders", Request: models.Request{URI: "/orders", Method: "GET"}},
+		{Name: "get-order", Request: models.Request{URI: "^/orders/[0-9]+$", Method: "GET", IsRegex: models.RegexConfig{URI: true}}},
+		{Name: "any-user", Request: models.Request{URI: "/users"}},
+	}
+	for _, mock := range mocks {
+		if _, err := manager.CreateMock(CreateMockRequest{Mock: mock}); err != nil {
+			t.Fatalf("Failed to create mock: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   MockFilter
+		expected []string
+	}{
+		{"path and method", MockFilter{Path: "/orders", Method: "post"}, []string{"create-order"}},
+		{"path only", MockFilter{Path: "/orders"}, []string{"create-order", "list-orders"}},
+		{"regex mock URI", MockFilter{Path: "/orders/42", Method: "GET"}, []string{"get-order"}},
+		{"empty mock method matches any", MockFilter{Path: "/users", Method: "DELETE"}, []string{"any-user"}},
+		{"path regex filter", MockFilter{Path: "/orders", PathRegex: true, Method: "GET"}, []string{"list-orders", "get-order"}},
+		{"no match", MockFilter{Path: "/missing"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := manager.ListMocks(&tt.filter)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			names := make(map[string]bool)
+			for _, mock := range result {
+				names[mock.Mock.Name] = true
+			}
+			if len(names) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, names)
+			}
+			for _, name := range tt.expected {
+				if !names[name] {
+					t.Errorf("Expected %s in result, got %v", name, names)
+				}
+			}
+		})
+	}
+}
+
+func TestListMocksInvalidPathRegex(t *testing.T) {
+	manager := NewManager()
+
+	_, err := manager.ListMocks(&MockFilter{Path: "(", PathRegex: true})
+	if !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("Expected ErrInvalidFilter, got %v", err)
+	}
+}
+
+// fakeCounters is an in-memory SequenceCounters
+type fakeCounters map[string]map[string]int
+
+func (f fakeCounters) GetCallCounts(mockName string) map[string]int {
+	return f[mockName]
+}
+
+func (f fakeCounters) ResetCallCounts(mockName string) {
+	delete(f, mockName)
+}
+
+func TestCounterEndpoints(t *testing.T) {
+	manager := NewManager()
+	managed, err := manager.CreateMock(CreateMockRequest{Mock: models.Mock{Name: "checkout"}})
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	NewAPIHandler(manager).RegisterRoutes(mux)
+
+	// Not connected to a live server
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/mocks/"+managed.Metadata.ID+"/counter", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 without counters, got %d", w.Code)
+	}
+
+	manager.SetSequenceCounters(fakeCounters{"checkout": {"": 3, "10.0.0.1": 2}})
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/mocks/"+managed.Metadata.ID+"/counter", nil))
+	var counter MockCounter
+	if err := json.Unmarshal(w.Body.Bytes(), &counter); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if counter.Name != "checkout" || counter.Count != 3 || counter.Clients["10.0.0.1"] != 2 {
+		t.Errorf("Unexpected counter: %+v", counter)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/mocks/"+managed.Metadata.ID+"/counter/reset", nil))
+	counter = MockCounter{}
+	if err := json.Unmarshal(w.Body.Bytes(), &counter); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if counter.Count != 0 || len(counter.Clients) != 0 {
+		t.Errorf("Expected reset counter, got %+v", counter)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/mocks/missing/counter", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown mock, got %d", w.Code)
+	}
+}
+
+func TestExportOpenAPI(t *testing.T) {
+	spec := `openapi: 3.0.3
+info:
+  title: Users
+  version: 1.0.0
+paths:
+  /users:
+    get:
+      operationId: listUsers
+      responses:
+        "200":
+          description: The users
+          content:
+            application/json:
+              example: [{"id": 1}]
+    post:
+      operationId: createUser
+      responses:
+        "201":
+          description: Created
+        "400":
+          description: Invalid user
+  /users/{id}/posts/{post.id}:
+    delete:
+      operationId: deletePost
+      responses:
+        "204":
+          description: Deleted
+`
+
+	imported, err := openapi.NewParser(false).Parse([]byte(spec), "spec.yaml")
+	if err != nil {
+		t.Fatalf("Failed to import spec: %v", err)
+	}
+
+	manager := NewManager()
+	for _, mock := range imported.Mocks {
+		if _, err := manager.CreateMock(CreateMockRequest{Mock: mock}); err != nil {
+			t.Fatalf("Failed to create mock: %v", err)
+		}
+	}
+	notExportable := models.Mock{Name: "any-order", Request: models.Request{URI: "^/orders/[0-9]+$", Method: "GET", IsRegex: models.RegexConfig{URI: true}}}
+	if _, err := manager.CreateMock(CreateMockRequest{Mock: notExportable}); err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+
+	exported, err := manager.Export(ExportRequest{Format: ExportFormatOpenAPI})
+	if err != nil {
+		t.Fatalf("Failed to export mocks: %v", err)
+	}
+	reimported, err := openapi.NewParser(false).Parse([]byte(exported), "export.yaml")
+	if err != nil {
+		t.Fatalf("Failed to import the exported spec: %v\n%s", err, exported)
+	}
+
+	// Paths, methods and statuses survive the round trip; the plain regex mock is skipped
+	routes := func(spec *models.MockSpec) map[string]bool {
+		result := make(map[string]bool)
+		for _, mock := range spec.Mocks {
+			result[fmt.Sprintf("%s %s %d", mock.Request.Method, mock.Request.URI, mock.Response.StatusCode)] = true
+		}
+		return result
+	}
+	expected, got := routes(imported), routes(reimported)
+	if len(expected) != 4 || len(got) != len(expected) {
+		t.Fatalf("Expected the routes %v, got %v", expected, got)
+	}
+	for route := range expected {
+		if !got[route] {
+			t.Errorf("Route %s missing from the exported spec, got %v", route, got)
+		}
+	}
+
+	var document map[string]interface{}
+	if err := yaml.Unmarshal([]byte(exported), &document); err != nil {
+		t.Fatalf("Failed to parse the exported spec: %v", err)
+	}
+	if document["openapi"] != "3.0.3" {
+		t.Errorf("Expected an OpenAPI 3.0 document, got version %v", document["openapi"])
+	}
+	paths, _ := document["paths"].(map[string]interface{})
+	if _, ok := paths["/users/{id}/posts/{post_id}"]; !ok {
+		t.Errorf("Expected the path template to be restored, got paths %v", paths)
+	}
+	if listUsers := findMockByName(reimported, "listUsers"); listUsers == nil || listUsers.Response.Body != `[{"id":1}]` {
+		t.Errorf("Expected the JSON body to be exported as example, got %+v", listUsers)
+	}
+}
+
+// findMockByName returns the mock with the given name, or nil
+func findMockByName(spec *models.MockSpec, name string) *models.Mock {
+	for i := range spec.Mocks {
+		if spec.Mocks[i].Name == name {
+			return &spec.Mocks[i]
+		}
+	}
+	return nil
+}
+
+func TestEnableDisableEndpoints(t *testing.T) {
+	manager := NewManager()
+	managed, err := manager.CreateMock(CreateMockRequest{Mock: models.Mock{Name: "checkout", Request: models.Request{URI: "/checkout"}}})
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	if !managed.Metadata.Enabled {
+		t.Fatal("Expected new mocks to be enabled")
+	}
+
+	changes := 0
+	manager.SetChangeHandler(func() { changes++ })
+
+	mux := http.NewServeMux()
+	NewAPIHandler(manager).RegisterRoutes(mux)
+
+	toggle := func(action string) *ManagedMock {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/mocks/"+managed.Metadata.ID+"/"+action, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d: %s", action, w.Code, w.Body.String())
+		}
+		var result ManagedMock
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return &result
+	}
+
+	if disabled := toggle("disable"); disabled.Metadata.Enabled {
+		t.Error("Expected the mock to be disabled")
+	}
+	if len(manager.GetAllMocks()) != 0 {
+		t.Error("Expected disabled mocks not to be served")
+	}
+	mocks, _ := manager.ListMocks(nil)
+	if len(mocks) != 1 || mocks[0].Metadata.Enabled {
+		t.Errorf("Expected disabled mocks to be listed as disabled, got %+v", mocks)
+	}
+
+	if enabled := toggle("enable"); !enabled.Metadata.Enabled || enabled.Metadata.Version != 1 {
+		t.Errorf("Expected the mock to be enabled again without a new version, got %+v", enabled.Metadata)
+	}
+	if len(manager.GetAllMocks()) != 1 {
+		t.Error("Expected the enabled mock to be served")
+	}
+	if versions, _ := manager.GetVersionHistory(managed.Metadata.ID); len(versions) != 1 {
+		t.Errorf("Expected toggling not to add versions, got %d", len(versions))
+	}
+	if changes != 2 {
+		t.Errorf("Expected a change notification per toggle, got %d", changes)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/mocks/missing/disable", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown mock, got %d", w.Code)
+	}
+	if changes != 2 {
+		t.Error("Expected no change notification for a failed toggle")
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/mocks/"+managed.Metadata.ID+"/enable", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", w.Code)
+	}
+}
+
+func TestDiffVersions(t *testing.T) {
+	manager := NewManager()
+	managed, err := manager.CreateMock(CreateMockRequest{Mock: models.Mock{
+		Name:     "user",
+		Request:  models.Request{URI: "/users/1", Method: "GET"},
+		Response: models.Response{StatusCode: 200, Headers: map[string]string{"Content-Type": "application/json"}, Body: `{"id":1}`},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	id := managed.Metadata.ID
+
+	updated := managed.Mock
+	updated.Request.Method = "POST"
+	updated.Response.StatusCode = 201
+	updated.Response.Headers = map[string]string{"Content-Type": "application/json", "Location": "/users/1"}
+	if _, err := manager.UpdateMock(id, UpdateMockRequest{Mock: &updated}); err != nil {
+		t.Fatalf("Failed to update mock: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	NewAPIHandler(manager).RegisterRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/mocks/"+id+"/diff?from=1&to=2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var diff VersionDiff
+	if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if diff.ID != id || diff.From != 1 || diff.To != 2 {
+		t.Errorf("Unexpected diff header: %+v", diff)
+	}
+
+	expected := []FieldChange{
+		{Field: "request.method", From: "GET", To: "POST"},
+		{Field: "response.headers.Location", To: "/users/1"},
+		{Field: "response.status_code", From: float64(200), To: float64(201)},
+	}
+	if len(diff.Changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %+v", len(expected), diff.Changes)
+	}
+	for i, change := range expected {
+		if diff.Changes[i] != change {
+			t.Errorf("Expected change %+v, got %+v", change, diff.Changes[i])
+		}
+	}
+
+	if same, err := manager.DiffVersions(id, 2, 2); err != nil || len(same.Changes) != 0 {
+		t.Errorf("Expected no changes between equal versions, got %+v (%v)", same, err)
+	}
+
+	for _, url := range []string{"/api/v1/mocks/" + id + "/diff?from=1&to=3", "/api/v1/mocks/missing/diff?from=1&to=2"} {
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for %s, got %d", url, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/mocks/"+id+"/diff?from=1", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a to version, got %d", w.Code)
+	}
+}
+
+func TestGetAllMocksOrder(t *testing.T) {
+	manager := NewManager()
+	var expected []string
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("mock-%d", i)
+		if _, err := manager.CreateMock(CreateMockRequest{Mock: models.Mock{Name: name}}); err != nil {
+			t.Fatalf("Failed to create mock: %v", err)
+		}
+		expected = append(expected, name)
+	}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		mocks := manager.GetAllMocks()
+		for i, mock := range mocks {
+			if mock.Name != expected[i] {
+				t.Fatalf("Expected the mocks in creation order %v, got %s at %d", expected, mock.Name, i)
+			}
+		}
+	}
+}
+
+func TestImportNotifiesOnce(t *testing.T) {
+	manager := NewManager()
+	changes := 0
+	manager.SetChangeHandler(func() {
+		changes++
+		// The handler runs without the lock held, so it can read the mocks
+		_ = manager.GetAllMocks()
+	})
+
+	data := `[{"mock":{"name":"one"}},{"mock":{"name":"two"}},{"mock":{"name":"three"}}]`
+	count, err := manager.Import(ImportRequest{Format: ExportFormatJSON, Data: data})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if count != 3 || len(manager.GetAllMocks()) != 3 {
+		t.Errorf("Expected 3 imported mocks, got count %d and %d mocks", count, len(manager.GetAllMocks()))
+	}
+	if changes != 1 {
+		t.Errorf("Expected one change notification per import, got %d", changes)
+	}
+
+	if _, err := manager.Import(ImportRequest{Format: ExportFormatJSON, Data: "[]"}); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if changes != 1 {
+		t.Errorf("Expected no change notification for an empty import, got %d", changes)
+	}
+}
+
+func TestImportKeepsDisabledMocks(t *testing.T) {
+	for _, format := range []ExportFormat{ExportFormatJSON, ExportFormatYAML} {
+		t.Run(string(format), func(t *testing.T) {
+			source := NewManager()
+			live, err := source.CreateMock(CreateMockRequest{Mock: models.Mock{Name: "live", Request: models.Request{URI: "/live"}}})
+			if err != nil {
+				t.Fatalf("Failed to create mock: %v", err)
+			}
+			silenced, err := source.CreateMock(CreateMockRequest{Mock: models.Mock{Name: "silenced", Request: models.Request{URI: "/silenced"}}})
+			if err != nil {
+				t.Fatalf("Failed to create mock: %v", err)
+			}
+			if _, err := source.SetEnabled(silenced.Metadata.ID, false); err != nil {
+				t.Fatalf("Failed to disable mock: %v", err)
+			}
+
+			data, err := source.Export(ExportRequest{Format: format})
+			if err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+
+			target := NewManager()
+			if count, err := target.Import(ImportRequest{Format: format, Data: data}); err != nil || count != 2 {
+				t.Fatalf("Expected 2 imported mocks, got %d (%v)", count, err)
+			}
+
+			enabled := make(map[string]bool)
+			mocks, _ := target.ListMocks(nil)
+			for _, mock := range mocks {
+				enabled[mock.Mock.Name] = mock.Metadata.Enabled
+			}
+			if !enabled[live.Mock.Name] || enabled[silenced.Mock.Name] {
+				t.Errorf("Expected only the live mock to be enabled after the round trip, got %v", enabled)
+			}
+			if served := target.GetAllMocks(); len(served) != 1 || served[0].Name != "live" {
+				t.Errorf("Expected only the live mock to be served, got %v", served)
+			}
+		})
+	}
+
+	// Mocks without an enabled flag are imported enabled
+	manager := NewManager()
+	if _, err := manager.Import(ImportRequest{Format: ExportFormatJSON, Data: `[{"mock":{"name":"plain"}}]`}); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(manager.GetAllMocks()) != 1 {
+		t.Error("Expected a mock without an enabled flag to be served")
+	}
+}
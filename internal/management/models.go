@@ -19,6 +19,7 @@ type MockMetadata struct {
 	UpdatedAt   time.Time         `json:"updated_at" yaml:"updated_at"`
 	Source      string            `json:"source" yaml:"source"` // file, api, template
 	Template    string            `json:"template,omitempty" yaml:"template,omitempty"`
+	Enabled     bool              `json:"enabled" yaml:"enabled"` // Disabled mocks are kept but not served
 }
 
 // ManagedMock represents a mock with management metadata
@@ -66,12 +67,38 @@ type MockFilter struct {
 	Source     string            `json:"source,omitempty"`
 	Template   string            `json:"template,omitempty"`
 	Search     string            `json:"search,omitempty"` // Search in name, description
+	Path       string            `json:"path,omitempty"`       // Request path the mock must handle
+	PathRegex  bool              `json:"path_regex,omitempty"` // If true, Path is a regex matched against the mock URI
+	Method     string            `json:"method,omitempty"`     // Request method the mock must handle
 	CreatedAfter  *time.Time     `json:"created_after,omitempty"`
 	CreatedBefore *time.Time     `json:"created_before,omitempty"`
 	UpdatedAfter  *time.Time     `json:"updated_after,omitempty"`
 	UpdatedBefore *time.Time     `json:"updated_before,omitempty"`
 }
 
+// MockCounter represents the live sequence counters of a mock
+type MockCounter struct {
+	ID      string         `json:"id"`
+	Name    string         `json:"name"`
+	Count   int            `json:"count"`             // Calls counted by the global sequence
+	Clients map[string]int `json:"clients,omitempty"` // Calls counted per client for client-scoped sequences
+}
+
+// VersionDiff represents the changes between two versions of a mock
+type VersionDiff struct {
+	ID      string        `json:"id"`
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Changes []FieldChange `json:"changes"` // Sorted by field; empty if the versions are equal
+}
+
+// FieldChange is a field of the mock configuration that differs between two versions
+type FieldChange struct {
+	Field string      `json:"field"`          // Path of the field in the mock configuration, e.g. response.headers.Content-Type or request.query_params[0].value
+	From  interface{} `json:"from,omitempty"` // Value in the older version (nil = not set)
+	To    interface{} `json:"to,omitempty"`   // Value in the newer version (nil = not set)
+}
+
 // MockStats represents statistics about mocks
 type MockStats struct {
 	TotalMocks      int                `json:"total_mocks"`
@@ -90,6 +117,7 @@ type CreateMockRequest struct {
 	Description string            `json:"description,omitempty"`
 	Author      string            `json:"author,omitempty"`
 	Template    string            `json:"template,omitempty"`
+	Enabled     *bool             `json:"enabled,omitempty"` // Whether the mock is served (nil = enabled)
 }
 
 // UpdateMockRequest represents a request to update a mock
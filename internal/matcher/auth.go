@@ -0,0 +1,61 @@
+package matcher
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+)
+
+// Auth types of models.AuthConfig
+const (
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+)
+
+// AuthError is returned by FindMatch when a mock requiring credentials matched the request
+// but its credentials are missing or wrong
+type AuthError struct {
+	Mock    string             // Name of the mock that rejected the request
+	Auth    *models.AuthConfig // Credentials the mock requires
+	Missing bool               // No credentials of the required type were sent
+}
+
+func (e *AuthError) Error() string {
+	if e.Missing {
+		return fmt.Sprintf("mock '%s' requires %s credentials", e.Mock, strings.ToLower(e.Auth.Type))
+	}
+	return fmt.Sprintf("invalid %s credentials for mock '%s'", strings.ToLower(e.Auth.Type), e.Mock)
+}
+
+// checkAuth checks the request's Authorization header against the required credentials.
+// Returns whether credentials of the required type were missing, and whether they're valid.
+func checkAuth(r *http.Request, auth *models.AuthConfig) (bool, bool) {
+	switch strings.ToLower(auth.Type) {
+	case AuthBasic:
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			return true, false
+		}
+		return false, secureEqual(username, auth.Username) && secureEqual(password, auth.Password)
+	case AuthBearer:
+		header := r.Header.Get("Authorization")
+		if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+			return true, false
+		}
+		token := strings.TrimSpace(header[len("Bearer "):])
+		if token == "" {
+			return true, false
+		}
+		return false, secureEqual(token, auth.Token)
+	default:
+		return false, false // Unknown types never authorize (reported by the validator)
+	}
+}
+
+// secureEqual compares credentials in constant time
+func secureEqual(actual, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) == 1
+}
@@ -0,0 +1,128 @@
+package matcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+	"github.com/google/cel-go/cel"
+)
+
+// celCostLimit bounds the work a single CEL expression may do per request
+const celCostLimit = 1_000_000
+
+// celEnv declares the request variables available to CEL expressions
+var celEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("uri", cel.StringType),
+		cel.Variable("method", cel.StringType),
+		cel.Variable("host", cel.StringType),
+		cel.Variable("headers", cel.MapType(cel.StringType, cel.StringType)), // Lower-case names, first value
+		cel.Variable("query", cel.MapType(cel.StringType, cel.StringType)),   // First value of each parameter
+		cel.Variable("body", cel.StringType),
+		cel.Variable("json", cel.DynType), // Parsed JSON body (null if the body isn't JSON)
+	)
+})
+
+// CompileCEL compiles a CEL matcher expression. The expression must evaluate to a boolean.
+func CompileCEL(expr string) (cel.Program, error) {
+	env, err := celEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if out := ast.OutputType(); !out.IsExactType(cel.BoolType) && !out.IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression must return a bool, got %s", out)
+	}
+
+	return env.Program(ast, cel.CostLimit(celCostLimit))
+}
+
+// compileCELPrograms compiles the CEL expressions of the mocks. Invalid expressions are
+// logged once and cached as nil, so they never match without being recompiled per request.
+func compileCELPrograms(mocks []models.Mock) map[string]cel.Program {
+	cache := make(map[string]cel.Program)
+	for i := range mocks {
+		for req := &mocks[i].Request; req != nil; req = req.Not {
+			if req.CEL == "" {
+				continue
+			}
+			if _, exists := cache[req.CEL]; exists {
+				continue
+			}
+			program, err := CompileCEL(req.CEL)
+			if err != nil {
+				log.Printf("Warning: mock '%s' has an invalid CEL expression (it will never match): %v\n", mocks[i].Name, err)
+			}
+			cache[req.CEL] = program
+		}
+	}
+	return cache
+}
+
+// celProgram returns the compiled expression, or nil if it's invalid. Expressions that
+// weren't precompiled are compiled and cached on first use.
+func (m *Matcher) celProgram(expr string) cel.Program {
+	m.celMu.RLock()
+	program, exists := m.celPrograms[expr]
+	m.celMu.RUnlock()
+	if exists {
+		return program
+	}
+
+	program, _ = CompileCEL(expr)
+
+	m.celMu.Lock()
+	m.celPrograms[expr] = program
+	m.celMu.Unlock()
+	return program
+}
+
+// matchCEL evaluates a CEL expression against the request. Invalid expressions and
+// evaluation errors (e.g. a missing map key) don't match.
+func (m *Matcher) matchCEL(r *http.Request, body, expr string) bool {
+	program := m.celProgram(expr)
+	if program == nil {
+		return false
+	}
+
+	headers := make(map[string]string, len(r.Header))
+	for key, values := range r.Header {
+		if len(values) > 0 {
+			headers[strings.ToLower(key)] = values[0]
+		}
+	}
+	query := make(map[string]string)
+	for key, values := range r.URL.Query() {
+		if len(values) > 0 {
+			query[key] = values[0]
+		}
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(body), &parsed); err != nil {
+		parsed = nil
+	}
+
+	out, _, err := program.Eval(map[string]interface{}{
+		"uri":     r.URL.Path,
+		"method":  r.Method,
+		"host":    requestHost(r),
+		"headers": headers,
+		"query":   query,
+		"body":    body,
+		"json":    parsed,
+	})
+	if err != nil {
+		return false
+	}
+	matched, ok := out.Value().(bool)
+	return ok && matched
+}
@@ -0,0 +1,31 @@
+package matcher
+
+import (
+	"net/http"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+)
+
+// FindCORS returns the CORS configuration of the first mock in the active scenario whose
+// URI, method and host match the request, ignoring its other conditions. CORS preflights
+// use it with the method they ask for, since they carry no body or credentials. Has no
+// side effects. Returns nil if no such mock defines CORS.
+func (m *Matcher) FindCORS(r *http.Request) *models.CORSConfig {
+	m.scenarioMu.RLock()
+	activeScenario := m.activeScenario
+	m.scenarioMu.RUnlock()
+
+	for _, i := range m.index.candidates(r) {
+		mock := &m.mocks[i]
+		if mock.CORS == nil || !m.belongsToScenario(mock, activeScenario) {
+			continue
+		}
+		req := &mock.Request
+		if m.matchString(r.URL.Path, req.URI, req.IsRegex.URI) &&
+			m.matchString(r.Method, req.Method, req.IsRegex.Method) &&
+			m.matchString(requestHost(r), req.Host, req.IsRegex.Host) {
+			return mock.CORS
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package matcher
+
+import (
+	"io"
+	"net/http"
+)
+
+// ConditionResult is the outcome of a single condition of a mock
+type ConditionResult struct {
+	Condition string `json:"condition"` // e.g. "uri", "method", "header X-Api-Key" or "json_path user.id"
+	Passed    bool   `json:"passed"`
+}
+
+// MatchExplanation describes why a mock matched a request or not
+type MatchExplanation struct {
+	Mock       string            `json:"mock"`
+	Priority   int               `json:"priority"`
+	Matched    bool              `json:"matched"`
+	Fallback   bool              `json:"fallback,omitempty"` // Fallback mocks only apply when no other mock matched
+	Reason     string            `json:"reason,omitempty"`   // Why the conditions weren't (fully) checked
+	Conditions []ConditionResult `json:"conditions"`
+}
+
+// record adds the outcome of a condition
+func (e *MatchExplanation) record(condition string, passed bool) {
+	e.Conditions = append(e.Conditions, ConditionResult{Condition: condition, Passed: passed})
+	e.Matched = e.Matched && passed
+}
+
+// ExplainMatch checks the request against every mock, in priority order, recording the
+// outcome of each condition instead of stopping at the first failure. It has no side
+// effects: sequences, counters and scenario states are left untouched and JavaScript
+// matchers aren't evaluated.
+func (m *Matcher) ExplainMatch(r *http.Request) ([]MatchExplanation, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	bodyStr := string(body)
+
+	m.scenarioMu.RLock()
+	activeScenario := m.activeScenario
+	m.scenarioMu.RUnlock()
+
+	explanations := make([]MatchExplanation, 0, len(m.mocks))
+	for i := range m.mocks {
+		mock := &m.mocks[i]
+		exp := MatchExplanation{
+			Mock:       mock.Name,
+			Priority:   mock.Priority,
+			Matched:    true,
+			Fallback:   mock.Fallback,
+			Conditions: make([]ConditionResult, 0),
+		}
+
+		switch {
+		case !m.belongsToScenario(mock, activeScenario):
+			exp.Matched = false
+			exp.Reason = "not part of the active scenario '" + activeScenario + "'"
+		case !m.inRequiredState(mock):
+			exp.Matched = false
+			exp.Reason = "requires scenario state '" + mock.RequiredState + "'"
+		case mock.Request.JavaScript != "":
+			exp.Matched = false
+			exp.Reason = "JavaScript matchers are not evaluated"
+		default:
+			if mock.Request.Auth != nil {
+				_, authorized := checkAuth(r, mock.Request.Auth)
+				exp.record("auth", authorized)
+			}
+			m.evaluateRequest(r, bodyStr, &mock.Request, &exp)
+			if len(mock.Request.Produces) > 0 {
+				exp.record("produces", m.acceptable(r, mock))
+			}
+			if mock.Fallback {
+				exp.record("fallback navigation", isNavigation(r))
+			}
+		}
+
+		explanations = append(explanations, exp)
+	}
+	return explanations, nil
+}
+
+// ExplainedMatch returns the name of the mock FindMatch would pick from the explanations
+// of a request, or "" if none matched
+func ExplainedMatch(explanations []MatchExplanation) string {
+	fallback := ""
+	for _, exp := range explanations {
+		if !exp.Matched {
+			continue
+		}
+		if !exp.Fallback {
+			return exp.Mock
+		}
+		if fallback == "" {
+			fallback = exp.Mock
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,74 @@
+package matcher
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+)
+
+// mockIndex narrows down the mocks that can match a request. Mocks with an exact URI are
+// bucketed by method and path; all others (regex or empty URI, JavaScript) are always candidates.
+// Buckets hold positions in the priority-sorted mock list, so merging them keeps the priority order.
+type mockIndex struct {
+	buckets  map[string][]int // Key: upper-case method (empty = any method) + " " + lower-case path
+	fallback []int            // Mocks that must always be evaluated
+}
+
+// buildIndex indexes the priority-sorted mocks
+func buildIndex(mocks []models.Mock) *mockIndex {
+	index := &mockIndex{buckets: make(map[string][]int)}
+
+	for i := range mocks {
+		req := &mocks[i].Request
+		if req.JavaScript != "" || req.URI == "" || req.IsRegex.URI {
+			index.fallback = append(index.fallback, i)
+			continue
+		}
+
+		method := strings.ToUpper(req.Method)
+		if req.IsRegex.Method {
+			method = ""
+		}
+		key := indexKey(method, req.URI)
+		index.buckets[key] = append(index.buckets[key], i)
+	}
+
+	return index
+}
+
+// indexKey builds a bucket key. Exact URI and method matching is case-insensitive.
+func indexKey(method, path string) string {
+	return method + " " + strings.ToLower(path)
+}
+
+// candidates returns the positions of the mocks that can match the request, in priority order
+func (idx *mockIndex) candidates(r *http.Request) []int {
+	exact := idx.buckets[indexKey(strings.ToUpper(r.Method), r.URL.Path)]
+	anyMethod := idx.buckets[indexKey("", r.URL.Path)]
+	return mergeSorted(mergeSorted(exact, anyMethod), idx.fallback)
+}
+
+// mergeSorted merges two ascending lists of positions
+func mergeSorted(a, b []int) []int {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+
+	merged := make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] < b[j] {
+			merged = append(merged, a[i])
+			i++
+		} else {
+			merged = append(merged, b[j])
+			j++
+		}
+	}
+	merged = append(merged, a[i:]...)
+	return append(merged, b[j:]...)
+}
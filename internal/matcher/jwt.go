@@ -0,0 +1,296 @@
+package matcher
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksCacheTTL is how long fetched JWKS keys are reused before fetching them again
+const jwksCacheTTL = 5 * time.Minute
+
+// jwksRefetchCooldown is the minimum time between two fetches of a JWKS, e.g. for tokens
+// with an unknown key ID or while the JWKS endpoint is failing
+const jwksRefetchCooldown = 10 * time.Second
+
+// ErrMissingToken is returned by VerifyJWT when the request has no Bearer token
+var ErrMissingToken = errors.New("missing bearer token")
+
+// jwtKeys caches parsed PEM keys and fetched JWKS key sets
+type jwtKeys struct {
+	pemKeys  map[string]interface{}
+	jwks     map[string]*jwksEntry
+	client   *http.Client
+	cooldown time.Duration // Minimum time between two fetches of a JWKS
+	mu       sync.Mutex
+	fetchMu  sync.Mutex // Serializes JWKS fetches, so concurrent requests fetch once
+}
+
+// jwksEntry is a fetched JWKS key set indexed by key ID
+type jwksEntry struct {
+	keys        map[string]interface{}
+	fetchedAt   time.Time // When the keys were fetched (zero = never)
+	attemptedAt time.Time // When the last fetch was attempted, successful or not
+	err         error     // Error of the last fetch if no keys were ever fetched
+}
+
+func newJWTKeys() *jwtKeys {
+	return &jwtKeys{
+		pemKeys:  make(map[string]interface{}),
+		jwks:     make(map[string]*jwksEntry),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		cooldown: jwksRefetchCooldown,
+	}
+}
+
+// VerifyJWT validates the request's Bearer token against the JWT matcher and returns its claims
+func (m *Matcher) VerifyJWT(r *http.Request, config *models.JWTMatcher) (map[string]interface{}, error) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < len("Bearer ") || !strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return nil, ErrMissingToken
+	}
+	tokenString := strings.TrimSpace(auth[len("Bearer "):])
+	if tokenString == "" {
+		return nil, ErrMissingToken
+	}
+
+	validMethods := []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+	if config.Secret != "" {
+		validMethods = []string{"HS256", "HS384", "HS512"}
+	}
+
+	options := []jwt.ParserOption{jwt.WithValidMethods(validMethods), jwt.WithTimeFunc(m.clock.Now)}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return m.jwtKeys.keyFor(token, config)
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, expected := range config.Claims {
+		if !claimMatches(name, claims[name], expected) {
+			return nil, fmt.Errorf("claim %q does not match", name)
+		}
+	}
+
+	return claims, nil
+}
+
+// claimMatches compares a claim value with the expected value. Array claims and the
+// space-separated "scope" claim must contain it.
+func claimMatches(name string, value interface{}, expected string) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		if name == "scope" {
+			for _, scope := range strings.Fields(v) {
+				if scope == expected {
+					return true
+				}
+			}
+			return false
+		}
+		return v == expected
+	case []interface{}:
+		for _, item := range v {
+			if fmt.Sprintf("%v", item) == expected {
+				return true
+			}
+		}
+		return false
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64) == expected
+	default:
+		return fmt.Sprintf("%v", v) == expected
+	}
+}
+
+// keyFor returns the key to verify the token with
+func (k *jwtKeys) keyFor(token *jwt.Token, config *models.JWTMatcher) (interface{}, error) {
+	switch {
+	case config.Secret != "":
+		return []byte(config.Secret), nil
+	case config.PublicKey != "":
+		return k.pemKey(config.PublicKey)
+	case config.JWKSURL != "":
+		kid, _ := token.Header["kid"].(string)
+		return k.jwksKey(config.JWKSURL, kid)
+	default:
+		return nil, errors.New("no public_key, jwks_url or secret configured")
+	}
+}
+
+// pemKey parses (and caches) a PEM-encoded RSA or ECDSA public key
+func (k *jwtKeys) pemKey(pemData string) (interface{}, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if key, exists := k.pemKeys[pemData]; exists {
+		return key, nil
+	}
+
+	if rsaKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(pemData)); err == nil {
+		k.pemKeys[pemData] = rsaKey
+		return rsaKey, nil
+	}
+
+	ecKey, err := jwt.ParseECPublicKeyFromPEM([]byte(pemData))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	k.pemKeys[pemData] = ecKey
+	return ecKey, nil
+}
+
+// jwksKey returns the key with the given ID from the JWKS, fetching it if needed.
+// Without a key ID the set must contain a single key. A set that expired or lacks the
+// key is fetched again at most once per cooldown, so tokens with unknown key IDs can't
+// make every request fetch it; until then the key is looked up in the cached set, which
+// is also kept when fetching it again fails.
+func (k *jwtKeys) jwksKey(url, kid string) (interface{}, error) {
+	entry := k.cachedJWKS(url)
+	if key, ok := lookupJWK(entry, kid); ok && time.Since(entry.fetchedAt) <= jwksCacheTTL {
+		return key, nil
+	}
+
+	k.fetchMu.Lock()
+	// Another request may have fetched the set while this one waited
+	entry = k.cachedJWKS(url)
+	if entry == nil || time.Since(entry.attemptedAt) >= k.cooldown {
+		fetched, err := k.fetchJWKS(url)
+		switch {
+		case err == nil:
+			entry = fetched
+		case entry == nil || entry.fetchedAt.IsZero():
+			entry = &jwksEntry{keys: make(map[string]interface{}), err: err}
+		default:
+			log.Printf("JWT: Failed to fetch JWKS %s, using the cached keys: %v\n", url, err)
+			cached := *entry
+			entry = &cached
+		}
+		entry.attemptedAt = time.Now()
+
+		k.mu.Lock()
+		k.jwks[url] = entry
+		k.mu.Unlock()
+	}
+	k.fetchMu.Unlock()
+
+	key, ok := lookupJWK(entry, kid)
+	if !ok {
+		if entry.err != nil {
+			return nil, entry.err
+		}
+		return nil, fmt.Errorf("key %q not found in JWKS", kid)
+	}
+	return key, nil
+}
+
+// cachedJWKS returns the cached key set of the URL, or nil if it was never fetched
+func (k *jwtKeys) cachedJWKS(url string) *jwksEntry {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.jwks[url]
+}
+
+// lookupJWK finds a key by ID in a fetched key set
+func lookupJWK(entry *jwksEntry, kid string) (interface{}, bool) {
+	if entry == nil {
+		return nil, false
+	}
+	if kid == "" {
+		if len(entry.keys) != 1 {
+			return nil, false
+		}
+		for _, key := range entry.keys {
+			return key, true
+		}
+	}
+	key, ok := entry.keys[kid]
+	return key, ok
+}
+
+// fetchJWKS downloads and parses a JWKS document
+func (k *jwtKeys) fetchJWKS(url string) (*jwksEntry, error) {
+	resp, err := k.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // response body is read-only
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var document struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	entry := &jwksEntry{keys: make(map[string]interface{}), fetchedAt: time.Now()}
+	for _, jwk := range document.Keys {
+		switch jwk.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			entry.keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch jwk.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			entry.keys[jwk.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+
+	return entry, nil
+}
@@ -2,15 +2,26 @@ package matcher
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
+	"log"
+	"math/rand"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
+	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/comfortablynumb/pmp-mock-http/internal/clock"
 	"github.com/comfortablynumb/pmp-mock-http/internal/models"
 	"github.com/dop251/goja"
+	"github.com/google/cel-go/cel"
 	"github.com/tidwall/gjson"
 	"github.com/xeipuuv/gojsonschema"
 )
@@ -18,15 +29,34 @@ import (
 // Matcher handles matching incoming requests to mock specifications
 type Matcher struct {
 	mocks          []models.Mock
-	globalVM       *goja.Runtime         // Persistent JS runtime for global state
-	globalState    map[string]interface{} // Global state shared across JavaScript evaluations
-	stateMu        sync.RWMutex           // Mutex to protect global state
-	callCounts     map[string]int         // Track call counts for sequence responses
-	countMu        sync.Mutex             // Mutex to protect call counts
-	activeScenario string                 // Currently active scenario (empty means all mocks)
-	scenarioMu     sync.RWMutex           // Mutex to protect scenario state
+	index          *mockIndex                // Lookup of the mocks that can match a request
+	globalVM       *goja.Runtime             // Persistent JS runtime for global state
+	globalState    map[string]interface{}    // Global state shared across JavaScript evaluations
+	stateMu        sync.RWMutex              // Mutex to protect global state
+	callCounts     map[string]int            // Track call counts for sequence responses
+	countMu        sync.Mutex                // Mutex to protect call counts
+	hits           map[string]int            // Number of matches of each mock, by name
+	hitsMu         sync.Mutex                // Mutex to protect hits
+	rngs           map[string]*rand.Rand     // Seeded random sources for probabilistic responses
+	rng            *rand.Rand                // Source for mocks without their own seed (see SetSeed)
+	seed           int64                     // Seed of rng (0 = random)
+	rngMu          sync.Mutex                // Mutex to protect random sources
+	activeScenario string                    // Currently active scenario (empty means all mocks)
+	scenarioMu     sync.RWMutex              // Mutex to protect scenario state
+	states         map[string]string         // Current state of each scenario used by stateful mocks
+	statesMu       sync.Mutex                // Mutex to protect scenario states
+	jwtKeys        *jwtKeys                  // Cached keys for JWT validation
+	clock          clock.Clock               // Time source for time-based checks (e.g. JWT expiry)
+	regexes        map[string]*regexp.Regexp // Compiled regex patterns of the mocks (nil = invalid pattern)
+	regexMu        sync.RWMutex              // Mutex to protect compiled regexes
+	celPrograms    map[string]cel.Program    // Compiled CEL expressions of the mocks (nil = invalid expression)
+	celMu          sync.RWMutex              // Mutex to protect compiled CEL expressions
+	jsTimeout      time.Duration             // Max execution time of a JavaScript matcher (0 = no limit)
 }
 
+// DefaultJSTimeout is how long a JavaScript matcher may run before it's interrupted
+const DefaultJSTimeout = time.Second
+
 // NewMatcher creates a new request matcher
 func NewMatcher(mocks []models.Mock) *Matcher {
 	// Sort mocks by priority (higher priority first)
@@ -46,12 +76,35 @@ func NewMatcher(mocks []models.Mock) *Matcher {
 
 	return &Matcher{
 		mocks:       sortedMocks,
+		index:       buildIndex(sortedMocks),
 		globalVM:    globalVM,
 		globalState: make(map[string]interface{}),
 		callCounts:  make(map[string]int),
+		hits:        make(map[string]int),
+		rngs:        make(map[string]*rand.Rand),
+		rng:         newRand(0),
+		states:      make(map[string]string),
+		jwtKeys:     newJWTKeys(),
+		clock:       clock.System,
+		jsTimeout:   DefaultJSTimeout,
+		regexes:     compileRegexes(sortedMocks),
+		celPrograms: compileCELPrograms(sortedMocks),
 	}
 }
 
+// SetClock sets the time source used for time-based checks such as JWT expiry
+func (m *Matcher) SetClock(c clock.Clock) {
+	m.clock = c
+}
+
+// SetJSTimeout sets how long a JavaScript matcher may run. Scripts exceeding it are
+// interrupted and don't match. Zero disables the limit.
+func (m *Matcher) SetJSTimeout(timeout time.Duration) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	m.jsTimeout = timeout
+}
+
 // FindMatch finds the first mock that matches the given request
 func (m *Matcher) FindMatch(r *http.Request) (*models.Mock, error) {
 	// Read the request body
@@ -66,83 +119,300 @@ func (m *Matcher) FindMatch(r *http.Request) (*models.Mock, error) {
 	activeScenario := m.activeScenario
 	m.scenarioMu.RUnlock()
 
-	// Try to match each mock in priority order
-	for _, mock := range m.mocks {
-		// Skip mocks that don't belong to the active scenario
-		if !m.belongsToScenario(&mock, activeScenario) {
+	// Set when a mock matched but rejected the request: ErrNotAcceptable if it can't produce
+	// a representation the client accepts, an *AuthError if the credentials are missing or wrong
+	var rejected error
+
+	// Try to match each candidate mock in priority order. Fallback mocks are kept for last.
+	var fallbacks []int
+	for _, i := range m.index.candidates(r) {
+		if m.mocks[i].Fallback {
+			fallbacks = append(fallbacks, i)
 			continue
 		}
+		match, rejection := m.tryMatch(r, bodyStr, m.mocks[i], activeScenario)
+		if match != nil {
+			m.recordHit(match.Name)
+			return match, nil
+		}
+		rejected = firstRejection(rejected, rejection)
+	}
 
-		// For JavaScript evaluation, we need special handling
-		if mock.Request.JavaScript != "" {
-			matches, customResponse := m.evaluateJavaScript(r, bodyStr, mock.Request.JavaScript)
-			if matches {
-				// Create a copy of the mock
-				matchedMock := mock
-				// If JavaScript returned a custom response, use it
-				if customResponse != nil {
-					matchedMock.Response = *customResponse
-				} else {
-					// Use sequential response if defined
-					matchedMock.Response = m.getSequentialResponse(&mock)
-				}
-				return &matchedMock, nil
+	// Fallback mocks only serve page navigations, so API calls still get a 404 or the proxy
+	if rejected == nil && len(fallbacks) > 0 && isNavigation(r) {
+		for _, i := range fallbacks {
+			if match, _ := m.tryMatch(r, bodyStr, m.mocks[i], activeScenario); match != nil {
+				m.recordHit(match.Name)
+				return match, nil
 			}
-			continue
 		}
+	}
 
-		// Standard matching
-		if m.matches(r, bodyStr, &mock) {
-			// Create a copy of the mock
-			matchedMock := mock
-			// Get sequential response if defined
-			matchedMock.Response = m.getSequentialResponse(&mock)
-			return &matchedMock, nil
-		}
+	if rejected != nil {
+		return nil, rejected
 	}
 
 	return nil, nil // No match found
 }
 
+// firstRejection keeps the first rejection of a request, except that authentication
+// failures take precedence over content negotiation ones
+func firstRejection(current, next error) error {
+	var authErr *AuthError
+	if current == nil || (next != nil && !errors.As(current, &authErr) && errors.As(next, &authErr)) {
+		return next
+	}
+	return current
+}
+
+// tryMatch checks a single mock against the request. It returns the matched mock, with its
+// response selected, or the reason the mock matched but rejected the request (ErrNotAcceptable
+// or an *AuthError).
+func (m *Matcher) tryMatch(r *http.Request, bodyStr string, mock models.Mock, activeScenario string) (*models.Mock, error) {
+	// Skip mocks that don't belong to the active scenario or aren't in their required state
+	if !m.belongsToScenario(&mock, activeScenario) || !m.inRequiredState(&mock) {
+		return nil, nil
+	}
+
+	// For JavaScript evaluation, we need special handling
+	if mock.Request.JavaScript != "" {
+		matches, customResponse := m.evaluateJavaScript(r, bodyStr, mock.Request.JavaScript)
+		if !matches {
+			return nil, nil
+		}
+		if !m.acceptable(r, &mock) {
+			return nil, ErrNotAcceptable
+		}
+		if !m.transitionState(&mock) {
+			return nil, nil
+		}
+		// Create a copy of the mock
+		matchedMock := mock
+		// If JavaScript returned a custom response, use it
+		if customResponse != nil {
+			matchedMock.Response = *customResponse
+		} else {
+			// Use sequential or probabilistic response if defined
+			matchedMock.Response = m.selectResponse(r, &mock)
+		}
+		return &matchedMock, nil
+	}
+
+	// Credentials are checked before the body: requests for the mock that aren't authorized
+	// are rejected whatever their body
+	if auth := mock.Request.Auth; auth != nil {
+		if missing, ok := checkAuth(r, auth); !ok {
+			if m.matchRequest(r, "", withoutBodyConditions(&mock.Request)) {
+				return nil, &AuthError{Mock: mock.Name, Auth: auth, Missing: missing}
+			}
+			return nil, nil
+		}
+	}
+
+	// Standard matching
+	if !m.matches(r, bodyStr, &mock) {
+		return nil, nil
+	}
+	if !m.acceptable(r, &mock) {
+		return nil, ErrNotAcceptable
+	}
+	// Another request changed the scenario state since the check above
+	if !m.transitionState(&mock) {
+		return nil, nil
+	}
+	// Create a copy of the mock
+	matchedMock := mock
+	// Get sequential or probabilistic response if defined
+	matchedMock.Response = m.selectResponse(r, &mock)
+	return &matchedMock, nil
+}
+
+// FindExpectContinue returns the 100-continue configuration for a request sent with
+// "Expect: 100-continue". The body hasn't been sent yet, so the first mock whose
+// conditions other than the body ones match decides. It has no side effects on
+// sequences or counters; JavaScript mocks are skipped.
+func (m *Matcher) FindExpectContinue(r *http.Request) *models.Expect100Config {
+	m.scenarioMu.RLock()
+	activeScenario := m.activeScenario
+	m.scenarioMu.RUnlock()
+
+	for _, mock := range m.mocks {
+		if !m.belongsToScenario(&mock, activeScenario) || !m.inRequiredState(&mock) || mock.Request.JavaScript != "" {
+			continue
+		}
+		if m.matchRequest(r, "", withoutBodyConditions(&mock.Request)) {
+			return mock.Response.Expect100
+		}
+	}
+	return nil
+}
+
+// withoutBodyConditions returns a copy of a request spec without the conditions on the body.
+// Negated conditions are dropped as they may depend on the body.
+func withoutBodyConditions(req *models.Request) *models.Request {
+	spec := *req
+	spec.Body = ""
+	spec.CanonicalJSON = false
+	spec.BodyMatchMode = ""
+	spec.FormParams = nil
+	spec.Multipart = nil
+	spec.JSONPath = nil
+	spec.ValidateSchema = nil
+	spec.Not = nil
+	return &spec
+}
+
+// acceptable checks if the client accepts one of the media types the mock produces
+func (m *Matcher) acceptable(r *http.Request, mock *models.Mock) bool {
+	if len(mock.Request.Produces) == 0 {
+		return true
+	}
+	return NegotiateContentType(r.Header.Get("Accept"), mock.Request.Produces) != ""
+}
+
 // matches checks if a request matches a mock specification
 func (m *Matcher) matches(r *http.Request, body string, mock *models.Mock) bool {
+	return m.matchRequest(r, body, &mock.Request)
+}
+
+// matchRequest checks the declarative conditions of a request spec, including its negated spec
+func (m *Matcher) matchRequest(r *http.Request, body string, req *models.Request) bool {
+	return m.evaluateRequest(r, body, req, nil)
+}
+
+// evaluateRequest checks the conditions of a request spec. Without an explanation it stops at
+// the first failing condition; with one it checks every condition and records each outcome.
+func (m *Matcher) evaluateRequest(r *http.Request, body string, req *models.Request, exp *MatchExplanation) bool {
+	check := func(condition string, passed bool) bool {
+		if exp == nil {
+			return passed
+		}
+		exp.record(condition, passed)
+		return true
+	}
+
+	// Negated conditions: a request matching the inner spec never matches
+	if req.Not != nil && !check("not", !m.matchRequest(r, body, req.Not)) {
+		return false
+	}
+
 	// Match URI
-	if !m.matchString(r.URL.Path, mock.Request.URI, mock.Request.IsRegex.URI) {
+	if req.URI != "" && !check("uri", m.matchString(r.URL.Path, req.URI, req.IsRegex.URI)) {
 		return false
 	}
 
 	// Match method
-	if !m.matchString(r.Method, mock.Request.Method, mock.Request.IsRegex.Method) {
+	if req.Method != "" && !check("method", m.matchString(r.Method, req.Method, req.IsRegex.Method)) {
 		return false
 	}
 
-	// Match headers
-	if !m.matchHeaders(r.Header, mock.Request.Headers, mock.Request.IsRegex.Headers) {
+	// Match host (virtual hosts)
+	if req.Host != "" && !check("host", m.matchString(requestHost(r), req.Host, req.IsRegex.Host)) {
+		return false
+	}
+
+	// Match the TLS client certificate (mTLS)
+	if req.ClientCert != nil && !check("client_cert", m.matchClientCert(r, req.ClientCert)) {
 		return false
 	}
 
+	// Match headers
+	if exp == nil {
+		if !m.matchHeaders(r.Header, req.Headers, req.IsRegex.Headers) {
+			return false
+		}
+	} else {
+		for key, value := range req.Headers {
+			check("header "+key, m.matchHeaders(r.Header, map[string]string{key: value}, req.IsRegex.Headers))
+		}
+	}
+
+	// Match query parameters
+	if exp == nil {
+		if !m.matchQueryParams(r.URL.Query(), req.QueryParams, req.IsRegex.QueryParams) {
+			return false
+		}
+	} else {
+		for _, qm := range req.QueryParams {
+			check("query "+qm.Key, m.matchQueryParams(r.URL.Query(), []models.QueryParamMatcher{qm}, req.IsRegex.QueryParams))
+		}
+	}
+
 	// Match body (if specified)
-	if mock.Request.Body != "" {
-		if !m.matchString(body, mock.Request.Body, mock.Request.IsRegex.Body) {
+	if req.Body != "" {
+		switch bodyMatchMode(req) {
+		case models.BodyMatchJSON:
+			if !check("body", jsonEqual(body, req.Body, false)) {
+				return false
+			}
+		case models.BodyMatchJSONIgnoreOrder:
+			if !check("body", jsonEqual(body, req.Body, true)) {
+				return false
+			}
+		default:
+			if !check("body", m.matchString(body, req.Body, req.IsRegex.Body)) {
+				return false
+			}
+		}
+	}
+
+	// Match form fields (if specified)
+	if len(req.FormParams) > 0 {
+		if !check("form_params", m.matchFormParams(r, body, req.FormParams)) {
+			return false
+		}
+	}
+
+	// Match multipart parts (if specified)
+	if len(req.Multipart) > 0 {
+		if !check("multipart", m.matchMultipart(r, body, req.Multipart)) {
 			return false
 		}
 	}
 
 	// Match JSON path (if specified)
-	if len(mock.Request.JSONPath) > 0 {
-		if !m.matchJSONPath(body, mock.Request.JSONPath) {
+	if exp == nil {
+		if len(req.JSONPath) > 0 && !m.matchJSONPath(body, req.JSONPath) {
 			return false
 		}
+	} else {
+		for _, jm := range req.JSONPath {
+			check("json_path "+jm.Path, m.matchJSONPath(body, []models.JSONPathMatcher{jm}))
+		}
 	}
 
 	// Validate JSON schema (if specified)
-	if len(mock.Request.ValidateSchema) > 0 {
-		if !m.validateSchema(body, mock.Request.ValidateSchema) {
+	if len(req.ValidateSchema) > 0 {
+		if !check("validate_schema", m.validateSchema(body, req.ValidateSchema)) {
 			return false
 		}
 	}
 
-	return true
+	// Evaluate the CEL expression (if specified)
+	if req.CEL != "" && !check("cel", m.matchCEL(r, body, req.CEL)) {
+		return false
+	}
+
+	return exp == nil || exp.Matched
+}
+
+// requestHost returns the hostname the request was sent to, without the port
+func requestHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.Host); err == nil {
+		return host
+	}
+	return r.Host
+}
+
+// matchClientCert matches the leaf TLS client certificate of the request. Requests without
+// a client certificate never match.
+func (m *Matcher) matchClientCert(r *http.Request, cm *models.ClientCertMatcher) bool {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return false
+	}
+	cert := r.TLS.PeerCertificates[0]
+	return m.matchString(cert.Subject.CommonName, cm.CommonName, cm.Regex) &&
+		m.matchString(cert.Issuer.CommonName, cm.Issuer, cm.Regex)
 }
 
 // matchString matches a value against a pattern (exact or regex)
@@ -152,18 +422,78 @@ func (m *Matcher) matchString(value, pattern string, useRegex bool) bool {
 	}
 
 	if useRegex {
-		matched, err := regexp.MatchString(pattern, value)
-		if err != nil {
-			// If regex is invalid, treat as no match
-			return false
-		}
-		return matched
+		// Invalid regexes never match
+		return m.matchRegex(pattern, value)
 	}
 
 	// Exact match (case-insensitive for methods)
 	return strings.EqualFold(value, pattern)
 }
 
+// bodyMatchMode returns how the body of a request spec is compared
+func bodyMatchMode(req *models.Request) string {
+	if req.BodyMatchMode == "" && req.CanonicalJSON {
+		return models.BodyMatchJSON
+	}
+	return req.BodyMatchMode
+}
+
+// jsonEqual compares two JSON documents semantically, ignoring whitespace and object key order,
+// and optionally the order of array elements. Invalid JSON never matches.
+func jsonEqual(actual, expected string, ignoreArrayOrder bool) bool {
+	var actualValue, expectedValue interface{}
+	if err := json.Unmarshal([]byte(actual), &actualValue); err != nil {
+		return false
+	}
+	if err := json.Unmarshal([]byte(expected), &expectedValue); err != nil {
+		return false
+	}
+	if ignoreArrayOrder {
+		return jsonValuesEqualUnordered(actualValue, expectedValue)
+	}
+	return reflect.DeepEqual(actualValue, expectedValue)
+}
+
+// jsonValuesEqualUnordered compares decoded JSON values, treating arrays as multisets
+func jsonValuesEqualUnordered(actual, expected interface{}) bool {
+	switch expectedValue := expected.(type) {
+	case map[string]interface{}:
+		actualValue, ok := actual.(map[string]interface{})
+		if !ok || len(actualValue) != len(expectedValue) {
+			return false
+		}
+		for key, value := range expectedValue {
+			other, exists := actualValue[key]
+			if !exists || !jsonValuesEqualUnordered(other, value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		actualValue, ok := actual.([]interface{})
+		if !ok || len(actualValue) != len(expectedValue) {
+			return false
+		}
+		// Pair every expected element with a distinct equal actual element
+		used := make([]bool, len(actualValue))
+		for _, value := range expectedValue {
+			found := false
+			for i, other := range actualValue {
+				if !used[i] && jsonValuesEqualUnordered(other, value) {
+					used[i], found = true, true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	default:
+		return actual == expected
+	}
+}
+
 // matchHeaders matches request headers against mock header specifications
 func (m *Matcher) matchHeaders(requestHeaders http.Header, mockHeaders map[string]string, useRegex bool) bool {
 	if len(mockHeaders) == 0 {
@@ -177,15 +507,13 @@ func (m *Matcher) matchHeaders(requestHeaders http.Header, mockHeaders map[strin
 			// Regex mode: match both header name and value using regex
 			for reqKey, reqValues := range requestHeaders {
 				// Try to match header key
-				keyMatched, err := regexp.MatchString(mockKey, reqKey)
-				if err != nil || !keyMatched {
+				if !m.matchRegex(mockKey, reqKey) {
 					continue
 				}
 
 				// Try to match header value
 				for _, reqValue := range reqValues {
-					valueMatched, err := regexp.MatchString(mockValue, reqValue)
-					if err == nil && valueMatched {
+					if m.matchRegex(mockValue, reqValue) {
 						matched = true
 						break
 					}
@@ -214,27 +542,175 @@ func (m *Matcher) matchHeaders(requestHeaders http.Header, mockHeaders map[strin
 	return true
 }
 
+// matchQueryParams matches the request query string against the mock's query parameter matchers.
+// Every parameter must be present; a matcher without a value only checks presence.
+func (m *Matcher) matchQueryParams(query url.Values, matchers []models.QueryParamMatcher, useRegex bool) bool {
+	for _, qm := range matchers {
+		matched := false
+
+		if useRegex {
+			// Regex mode: match both parameter name and value using regex
+			for key, values := range query {
+				if !m.matchRegex(qm.Key, key) {
+					continue
+				}
+				if qm.Value == "" {
+					matched = true
+					break
+				}
+				for _, value := range values {
+					if m.matchRegex(qm.Value, value) {
+						matched = true
+						break
+					}
+				}
+				if matched {
+					break
+				}
+			}
+		} else {
+			// Exact match mode
+			values, exists := query[qm.Key]
+			if exists && qm.Value == "" {
+				matched = true
+			}
+			for _, value := range values {
+				if value == qm.Value {
+					matched = true
+					break
+				}
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchFormParams matches the fields of a form-encoded body. The request must have an
+// application/x-www-form-urlencoded content type and a parseable body.
+func (m *Matcher) matchFormParams(r *http.Request, body string, matchers []models.FormParamMatcher) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/x-www-form-urlencoded" {
+		return false
+	}
+
+	form, err := url.ParseQuery(body)
+	if err != nil {
+		return false
+	}
+
+	for _, fm := range matchers {
+		values, exists := form[fm.Key]
+		if !exists {
+			return false
+		}
+		if fm.Value == "" {
+			continue // Presence is enough
+		}
+
+		matched := false
+		for _, value := range values {
+			if fm.Regex {
+				if m.matchRegex(fm.Value, value) {
+					matched = true
+					break
+				}
+			} else if value == fm.Value {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
 // UpdateMocks updates the matcher with new mocks
 // Note: This preserves the global state across mock reloads
 func (m *Matcher) UpdateMocks(mocks []models.Mock) {
-	// Sort mocks by priority (higher priority first)
-	sortedMocks := make([]models.Mock, len(mocks))
-	copy(sortedMocks, mocks)
-	sort.Slice(sortedMocks, func(i, j int) bool {
-		return sortedMocks[i].Priority > sortedMocks[j].Priority
-	})
-
-	m.mocks = sortedMocks
+	m.setMocks(mocks)
 
 	// Reset call counts when mocks are updated
 	m.countMu.Lock()
 	m.callCounts = make(map[string]int)
 	m.countMu.Unlock()
 
+	// Reset seeded random sources so selections are reproducible after a reload
+	m.rngMu.Lock()
+	m.rngs = make(map[string]*rand.Rand)
+	m.rng = newRand(m.seed)
+	m.rngMu.Unlock()
+
 	// Note: We intentionally do NOT reset globalState here
 	// This allows state to persist across mock file reloads
 }
 
+// SwapMocks updates the matcher with new mocks like UpdateMocks, but keeps the call counts
+// and seeded random sources of the mocks that didn't change. Only the state of removed or
+// changed mocks is dropped, so adding a mock doesn't restart the sequences of the others.
+func (m *Matcher) SwapMocks(mocks []models.Mock) {
+	previous := make(map[string]models.Mock, len(m.mocks))
+	for _, mock := range m.mocks {
+		previous[mock.Name] = mock
+	}
+
+	m.setMocks(mocks)
+
+	current := make(map[string]models.Mock, len(m.mocks))
+	for _, mock := range m.mocks {
+		current[mock.Name] = mock
+	}
+
+	var stale []string
+	for name, mock := range previous {
+		if updated, exists := current[name]; !exists || !reflect.DeepEqual(mock, updated) {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	for _, name := range stale {
+		m.ResetCallCounts(name)
+	}
+	m.rngMu.Lock()
+	for _, name := range stale {
+		delete(m.rngs, name)
+	}
+	m.rngMu.Unlock()
+}
+
+// setMocks sorts the mocks and replaces the served mocks, their index and compiled patterns
+func (m *Matcher) setMocks(mocks []models.Mock) {
+	// Sort mocks by priority (higher priority first)
+	sortedMocks := make([]models.Mock, len(mocks))
+	copy(sortedMocks, mocks)
+	sort.Slice(sortedMocks, func(i, j int) bool {
+		return sortedMocks[i].Priority > sortedMocks[j].Priority
+	})
+
+	m.mocks = sortedMocks
+	m.index = buildIndex(sortedMocks)
+
+	// Compile the patterns of the new mocks
+	regexes := compileRegexes(sortedMocks)
+	m.regexMu.Lock()
+	m.regexes = regexes
+	m.regexMu.Unlock()
+	celPrograms := compileCELPrograms(sortedMocks)
+	m.celMu.Lock()
+	m.celPrograms = celPrograms
+	m.celMu.Unlock()
+}
+
 // matchJSONPath matches request body against GJSON path matchers
 func (m *Matcher) matchJSONPath(body string, matchers []models.JSONPathMatcher) bool {
 	// Validate that the body is valid JSON
@@ -249,11 +725,17 @@ func (m *Matcher) matchJSONPath(body string, matchers []models.JSONPathMatcher)
 			return false
 		}
 
+		if matcher.Operator != "" {
+			if !m.compareJSONValue(result, matcher) {
+				return false
+			}
+			continue
+		}
+
 		resultStr := result.String()
 		if matcher.Regex {
 			// Use regex matching
-			matched, err := regexp.MatchString(matcher.Value, resultStr)
-			if err != nil || !matched {
+			if !m.matchRegex(matcher.Value, resultStr) {
 				return false
 			}
 		} else {
@@ -267,6 +749,46 @@ func (m *Matcher) matchJSONPath(body string, matchers []models.JSONPathMatcher)
 	return true
 }
 
+// compareJSONValue applies a JSON path matcher's comparison operator. Ordering operators
+// compare numerically and never match non-numeric values; eq and ne compare numbers by
+// value and anything else as strings (or by regex if enabled).
+func (m *Matcher) compareJSONValue(result gjson.Result, matcher models.JSONPathMatcher) bool {
+	operator := strings.ToLower(matcher.Operator)
+	expected, expectedErr := strconv.ParseFloat(matcher.Value, 64)
+	numeric := result.Type == gjson.Number && expectedErr == nil
+
+	switch operator {
+	case "eq", "ne":
+		var equal bool
+		switch {
+		case matcher.Regex:
+			equal = m.matchRegex(matcher.Value, result.String())
+		case numeric:
+			equal = result.Float() == expected
+		default:
+			equal = result.String() == matcher.Value
+		}
+		return equal == (operator == "eq")
+	case "gt", "gte", "lt", "lte":
+		if !numeric {
+			return false
+		}
+		actual := result.Float()
+		switch operator {
+		case "gt":
+			return actual > expected
+		case "gte":
+			return actual >= expected
+		case "lt":
+			return actual < expected
+		default:
+			return actual <= expected
+		}
+	default:
+		return false // Unknown operator
+	}
+}
+
 // validateSchema validates request body against a JSON schema
 func (m *Matcher) validateSchema(body string, schema map[string]interface{}) bool {
 	// Validate that the body is valid JSON
@@ -323,10 +845,36 @@ func (m *Matcher) evaluateJavaScript(r *http.Request, body string, script string
 		return false, nil
 	}
 
+	// Interrupt scripts that run too long (e.g. an infinite loop) so they can't hang the server
+	if m.jsTimeout > 0 {
+		done := make(chan struct{})
+		var watchdog sync.WaitGroup
+		watchdog.Add(1)
+		go func() {
+			defer watchdog.Done()
+			timer := time.NewTimer(m.jsTimeout)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				m.globalVM.Interrupt("timeout")
+			case <-done:
+			}
+		}()
+		defer func() {
+			close(done)
+			watchdog.Wait()
+			m.globalVM.ClearInterrupt()
+		}()
+	}
+
 	// Execute the JavaScript code in the global VM
 	// This allows the script to access and modify the persistent global object
 	result, err := m.globalVM.RunString(script)
 	if err != nil {
+		var interrupted *goja.InterruptedError
+		if errors.As(err, &interrupted) {
+			log.Printf("Warning: JavaScript matcher interrupted after exceeding the %v timeout\n", m.jsTimeout)
+		}
 		return false, nil
 	}
 
@@ -379,19 +927,142 @@ func (m *Matcher) evaluateJavaScript(r *http.Request, body string, script string
 	return false, nil
 }
 
+// selectResponse returns the response to use for a matched mock
+func (m *Matcher) selectResponse(r *http.Request, mock *models.Mock) models.Response {
+	if len(mock.Response.AfterCount) > 0 {
+		if response, ok := m.getCountResponse(mock); ok {
+			return response
+		}
+	}
+	if len(mock.Response.Probabilistic) > 0 {
+		return m.getProbabilisticResponse(mock)
+	}
+	return m.getSequentialResponse(r, mock)
+}
+
+// getCountResponse counts the call and returns the response of the highest after_count
+// threshold the mock has been called more than. Returns false below the first threshold.
+func (m *Matcher) getCountResponse(mock *models.Mock) (models.Response, bool) {
+	counterKey := mock.Name + "|after_count"
+	m.countMu.Lock()
+	callCount := m.callCounts[counterKey]
+	m.callCounts[counterKey] = callCount + 1
+	m.countMu.Unlock()
+
+	var chosen *models.CountResponse
+	for i := range mock.Response.AfterCount {
+		threshold := &mock.Response.AfterCount[i]
+		if callCount >= threshold.After && (chosen == nil || threshold.After > chosen.After) {
+			chosen = threshold
+		}
+	}
+	if chosen == nil {
+		return models.Response{}, false
+	}
+
+	response := chosen.Response
+	if response.StatusCode == 0 {
+		response.StatusCode = 200
+	}
+	return response, true
+}
+
+// getProbabilisticResponse picks one of the weighted responses at random
+func (m *Matcher) getProbabilisticResponse(mock *models.Mock) models.Response {
+	total := 0.0
+	for _, weighted := range mock.Response.Probabilistic {
+		if weighted.Probability > 0 {
+			total += weighted.Probability
+		}
+	}
+	if total <= 0 {
+		return mock.Response
+	}
+
+	roll := m.randomFloat(mock) * total
+
+	// Default to the last entry in case of floating point rounding
+	chosen := mock.Response.Probabilistic[len(mock.Response.Probabilistic)-1].Response
+	for _, weighted := range mock.Response.Probabilistic {
+		if weighted.Probability <= 0 {
+			continue
+		}
+		if roll < weighted.Probability {
+			chosen = weighted.Response
+			break
+		}
+		roll -= weighted.Probability
+	}
+
+	if chosen.StatusCode == 0 {
+		chosen.StatusCode = 200
+	}
+	return chosen
+}
+
+// SetSeed seeds the random source of weighted selections for mocks without their own
+// probabilistic_seed, making them reproducible. Zero uses a random seed.
+func (m *Matcher) SetSeed(seed int64) {
+	m.rngMu.Lock()
+	defer m.rngMu.Unlock()
+	m.seed = seed
+	m.rng = newRand(seed)
+}
+
+// newRand creates a random source from the seed, or from the current time if it's zero
+func newRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// randomFloat returns a random number in [0.0, 1.0) for the mock,
+// using a per-mock seeded source when a seed is configured
+func (m *Matcher) randomFloat(mock *models.Mock) float64 {
+	m.rngMu.Lock()
+	defer m.rngMu.Unlock()
+
+	if mock.Response.ProbabilisticSeed == 0 {
+		return m.rng.Float64()
+	}
+
+	rng, exists := m.rngs[mock.Name]
+	if !exists {
+		rng = rand.New(rand.NewSource(mock.Response.ProbabilisticSeed))
+		m.rngs[mock.Name] = rng
+	}
+	return rng.Float64()
+}
+
 // getSequentialResponse returns the appropriate response based on the sequence and call count
-func (m *Matcher) getSequentialResponse(mock *models.Mock) models.Response {
+func (m *Matcher) getSequentialResponse(r *http.Request, mock *models.Mock) models.Response {
 	// If no sequence is defined, return the default response
 	if len(mock.Response.Sequence) == 0 {
 		return mock.Response
 	}
 
 	// Get and increment call count
+	counterKey := m.sequenceCounterKey(r, mock)
+	advanceOn := mock.Response.SequenceAdvanceOn
 	m.countMu.Lock()
-	callCount := m.callCounts[mock.Name]
-	m.callCounts[mock.Name] = callCount + 1
+	callCount := m.callCounts[counterKey]
+	if advanceOn != nil && advanceOn.Header != "" {
+		// Only matching requests are counted, and they advance before the response is selected
+		if advancesSequence(r, advanceOn) {
+			callCount++
+			m.callCounts[counterKey] = callCount
+		}
+	} else {
+		m.callCounts[counterKey] = callCount + 1
+	}
 	m.countMu.Unlock()
 
+	// Each response is returned "every" times before advancing
+	if advanceOn != nil && advanceOn.Every > 1 {
+		callCount /= advanceOn.Every
+	}
+
 	// Determine which response to return
 	sequenceLen := len(mock.Response.Sequence)
 	var responseIndex int
@@ -416,12 +1087,100 @@ func (m *Matcher) getSequentialResponse(mock *models.Mock) models.Response {
 	// Build the response from the sequence item
 	item := mock.Response.Sequence[responseIndex]
 	return models.Response{
-		StatusCode: item.StatusCode,
-		Headers:    item.Headers,
-		Body:       item.Body,
-		Delay:      item.Delay,
-		Template:   item.Template,
-		Callback:   item.Callback,
+		StatusCode:     item.StatusCode,
+		Headers:        item.Headers,
+		Body:           item.Body,
+		Delay:          item.Delay,
+		Template:       item.Template,
+		TemplateDelims: mock.Response.TemplateDelims,
+		Callback:       item.Callback,
+	}
+}
+
+// GetMock returns a copy of the mock with the given name, or nil if there's none
+func (m *Matcher) GetMock(name string) *models.Mock {
+	for i := range m.mocks {
+		if m.mocks[i].Name == name {
+			mock := m.mocks[i]
+			return &mock
+		}
+	}
+	return nil
+}
+
+// GetCallCounts returns the sequence call counts of a mock, keyed by client identifier.
+// The global counter (and unscoped sequences) use the empty key.
+func (m *Matcher) GetCallCounts(mockName string) map[string]int {
+	m.countMu.Lock()
+	defer m.countMu.Unlock()
+
+	counts := make(map[string]int)
+	for key, count := range m.callCounts {
+		if key == mockName {
+			counts[""] = count
+		} else if client, ok := strings.CutPrefix(key, mockName+"|client:"); ok {
+			counts[client] = count
+		}
+	}
+	return counts
+}
+
+// ResetCallCounts resets all call counters of a mock (sequences and after_count), so they start over
+func (m *Matcher) ResetCallCounts(mockName string) {
+	m.countMu.Lock()
+	defer m.countMu.Unlock()
+
+	for key := range m.callCounts {
+		if key == mockName || strings.HasPrefix(key, mockName+"|") {
+			delete(m.callCounts, key)
+		}
+	}
+}
+
+// advancesSequence checks if the request has the header that advances the sequence
+func advancesSequence(r *http.Request, advanceOn *models.SequenceAdvanceOn) bool {
+	values := r.Header.Values(advanceOn.Header)
+	if len(values) == 0 {
+		return false
+	}
+	if advanceOn.Value == "" {
+		return true
+	}
+	for _, value := range values {
+		if value == advanceOn.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// sequenceCounterKey returns the call count key for a mock's sequence.
+// Client-scoped sequences get a separate counter per client identifier.
+func (m *Matcher) sequenceCounterKey(r *http.Request, mock *models.Mock) string {
+	if !strings.EqualFold(mock.Response.SequenceScope, "client") {
+		return mock.Name
+	}
+	return mock.Name + "|client:" + clientIdentifier(r, mock.Response.SequenceClientKey)
+}
+
+// clientIdentifier extracts the client identifier from the request.
+// The key is "ip" (default), "header:<name>" or "cookie:<name>".
+func clientIdentifier(r *http.Request, key string) string {
+	switch {
+	case len(key) > len("header:") && strings.EqualFold(key[:len("header:")], "header:"):
+		return r.Header.Get(key[len("header:"):])
+	case len(key) > len("cookie:") && strings.EqualFold(key[:len("cookie:")], "cookie:"):
+		cookie, err := r.Cookie(key[len("cookie:"):])
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	default:
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
 	}
 }
 
@@ -2,13 +2,29 @@ package matcher
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"math/big"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 func TestMatcherExactURIMatch(t *testing.T) {
@@ -416,6 +432,36 @@ func TestMatcherUpdateMocks(t *testing.T) {
 	}
 }
 
+func TestMatcherSwapMocks(t *testing.T) {
+	sequence := []models.ResponseItem{{StatusCode: 200, Body: "first"}, {StatusCode: 200, Body: "second"}}
+	kept := models.Mock{Name: "kept", Request: models.Request{URI: "/kept"}, Response: models.Response{Sequence: sequence}}
+	changed := models.Mock{Name: "changed", Request: models.Request{URI: "/changed"}, Response: models.Response{Sequence: sequence}}
+	removed := models.Mock{Name: "removed", Request: models.Request{URI: "/removed"}, Response: models.Response{Sequence: sequence}}
+	matcher := NewMatcher([]models.Mock{kept, changed, removed})
+
+	for _, uri := range []string{"/kept", "/changed", "/removed"} {
+		if _, err := matcher.FindMatch(createRequest("GET", uri, nil, nil)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	changed.Priority = 5
+	added := models.Mock{Name: "added", Request: models.Request{URI: "/added"}}
+	matcher.SwapMocks([]models.Mock{kept, changed, added})
+
+	match, _ := matcher.FindMatch(createRequest("GET", "/kept", nil, nil))
+	if match == nil || match.Response.Body != "second" {
+		t.Errorf("Expected the unchanged mock's sequence to continue, got %v", match)
+	}
+	match, _ = matcher.FindMatch(createRequest("GET", "/changed", nil, nil))
+	if match == nil || match.Response.Body != "first" {
+		t.Errorf("Expected the changed mock's sequence to start over, got %v", match)
+	}
+	if counts := matcher.GetCallCounts("removed"); len(counts) != 0 {
+		t.Errorf("Expected the removed mock's counters to be dropped, got %v", counts)
+	}
+}
+
 func TestMatcherEmptyPattern(t *testing.T) {
 	mocks := []models.Mock{
 		{
@@ -696,6 +742,47 @@ func TestMatcherJavaScriptCustomResponse(t *testing.T) {
 	}
 }
 
+func TestMatcherJavaScriptTimeout(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:    "Slow Script",
+			Request: models.Request{URI: "/slow", JavaScript: "while (true) {}"},
+		},
+		{
+			Name:    "Fallback",
+			Request: models.Request{URI: "/slow"},
+		},
+	}
+
+	matcher := NewMatcher(mocks)
+	matcher.SetJSTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	match, err := matcher.FindMatch(createRequest("GET", "/slow", nil, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Expected the script to be interrupted, took %v", elapsed)
+	}
+	if match == nil || match.Name != "Fallback" {
+		t.Fatalf("Expected the timed out script not to match, got %v", match)
+	}
+
+	// The runtime keeps working after an interrupt
+	matcher.UpdateMocks([]models.Mock{{
+		Name:    "Fast Script",
+		Request: models.Request{URI: "/fast", JavaScript: "({matches: true})"},
+	}})
+	match, err = matcher.FindMatch(createRequest("GET", "/fast", nil, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if match == nil {
+		t.Error("Expected a match after a previous script was interrupted")
+	}
+}
+
 func TestMatcherJavaScriptRequestObject(t *testing.T) {
 	mocks := []models.Mock{
 		{
@@ -1117,6 +1204,75 @@ func TestSequentialResponsesOnce(t *testing.T) {
 	}
 }
 
+func TestSequentialResponsesClientScope(t *testing.T) {
+	sequence := []models.ResponseItem{
+		{StatusCode: 200, Body: "first"},
+		{StatusCode: 200, Body: "second"},
+	}
+
+	tests := []struct {
+		name      string
+		clientKey string
+		setClient func(r *http.Request, client string)
+	}{
+		{
+			name:      "ip",
+			clientKey: "",
+			setClient: func(r *http.Request, client string) { r.RemoteAddr = client + ":1234" },
+		},
+		{
+			name:      "header",
+			clientKey: "header:X-Client-Id",
+			setClient: func(r *http.Request, client string) { r.Header.Set("X-Client-Id", client) },
+		},
+		{
+			name:      "cookie",
+			clientKey: "cookie:session",
+			setClient: func(r *http.Request, client string) { r.AddCookie(&http.Cookie{Name: "session", Value: client}) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher := NewMatcher([]models.Mock{
+				{
+					Name:    "Client Sequence",
+					Request: models.Request{URI: "/api/seq", Method: "GET"},
+					Response: models.Response{
+						Sequence:          sequence,
+						SequenceScope:     "client",
+						SequenceClientKey: tt.clientKey,
+					},
+				},
+			})
+
+			call := func(client string) string {
+				req := httptest.NewRequest("GET", "/api/seq", nil)
+				tt.setClient(req, client)
+				mock, err := matcher.FindMatch(req)
+				if err != nil || mock == nil {
+					t.Fatalf("Expected match, got mock=%v err=%v", mock, err)
+				}
+				return mock.Response.Body
+			}
+
+			// Each client gets its own progression
+			if body := call("10.0.0.1"); body != "first" {
+				t.Errorf("Client A call 1: expected 'first', got '%s'", body)
+			}
+			if body := call("10.0.0.2"); body != "first" {
+				t.Errorf("Client B call 1: expected 'first', got '%s'", body)
+			}
+			if body := call("10.0.0.1"); body != "second" {
+				t.Errorf("Client A call 2: expected 'second', got '%s'", body)
+			}
+			if body := call("10.0.0.2"); body != "second" {
+				t.Errorf("Client B call 2: expected 'second', got '%s'", body)
+			}
+		})
+	}
+}
+
 func TestSequentialResponsesWithHeaders(t *testing.T) {
 	mocks := []models.Mock{
 		{
@@ -1372,6 +1528,119 @@ func TestScenarioMultipleTags(t *testing.T) {
 	}
 }
 
+func TestMatcherScenarioStates(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:          "Profile (logged out)",
+			Scenarios:     []string{"auth"},
+			RequiredState: StartedState,
+			Request:       models.Request{URI: "/profile", Method: "GET"},
+		},
+		{
+			Name:      "Login",
+			Scenarios: []string{"auth"},
+			NewState:  "LoggedIn",
+			Request:   models.Request{URI: "/login", Method: "POST"},
+		},
+		{
+			Name:          "Profile (logged in)",
+			Scenarios:     []string{"auth"},
+			RequiredState: "LoggedIn",
+			Request:       models.Request{URI: "/profile", Method: "GET"},
+		},
+		{
+			Name:          "Logout",
+			Scenarios:     []string{"auth"},
+			RequiredState: "LoggedIn",
+			NewState:      StartedState,
+			Request:       models.Request{URI: "/logout", Method: "POST"},
+		},
+		{
+			Name:     "Unscoped",
+			NewState: "Touched",
+			Request:  models.Request{URI: "/touch"},
+		},
+	}
+
+	matcher := NewMatcher(mocks)
+
+	expectMatch := func(method, uri, expected string) {
+		t.Helper()
+		match, err := matcher.FindMatch(createRequest(method, uri, nil, nil))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		name := ""
+		if match != nil {
+			name = match.Name
+		}
+		if name != expected {
+			t.Errorf("%s %s: expected match %q, got %q", method, uri, expected, name)
+		}
+	}
+
+	expectMatch("GET", "/profile", "Profile (logged out)")
+	expectMatch("POST", "/logout", "")
+	expectMatch("POST", "/login", "Login")
+	expectMatch("GET", "/profile", "Profile (logged in)")
+
+	states := matcher.ScenarioStates()
+	if states["auth"] != "LoggedIn" {
+		t.Errorf("Expected auth scenario in state LoggedIn, got %q", states["auth"])
+	}
+	if states[DefaultStateScenario] != StartedState {
+		t.Errorf("Expected default scenario in state %s, got %q", StartedState, states[DefaultStateScenario])
+	}
+
+	expectMatch("POST", "/logout", "Logout")
+	expectMatch("GET", "/profile", "Profile (logged out)")
+
+	expectMatch("GET", "/touch", "Unscoped")
+	if state := matcher.ScenarioStates()[DefaultStateScenario]; state != "Touched" {
+		t.Errorf("Expected default scenario in state Touched, got %q", state)
+	}
+
+	matcher.ResetScenarioStates()
+	if state := matcher.ScenarioStates()[DefaultStateScenario]; state != StartedState {
+		t.Errorf("Expected default scenario reset to %s, got %q", StartedState, state)
+	}
+}
+
+func TestMatcherScenarioStateConcurrentTransition(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:          "Claim",
+			Scenarios:     []string{"coupon"},
+			RequiredState: StartedState,
+			NewState:      "Claimed",
+			Request:       models.Request{URI: "/claim"},
+		},
+	}
+
+	matcher := NewMatcher(mocks)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	claimed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			match, err := matcher.FindMatch(createRequest("POST", "/claim", nil, nil))
+			if err == nil && match != nil {
+				mu.Lock()
+				claimed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if claimed != 1 {
+		t.Errorf("Expected exactly one request to take the transition, got %d", claimed)
+	}
+}
+
 func TestGetAvailableScenarios(t *testing.T) {
 	mocks := []models.Mock{
 		{
@@ -1533,3 +1802,1352 @@ func TestValidateSchemaWithTypes(t *testing.T) {
 		t.Error("Expected no match for out of range value")
 	}
 }
+
+func TestProbabilisticResponses(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name: "Flaky Endpoint",
+			Request: models.Request{
+				URI:    "/api/flaky",
+				Method: "GET",
+			},
+			Response: models.Response{
+				Probabilistic: []models.WeightedResponse{
+					{Probability: 0.9, Response: models.Response{StatusCode: 200, Body: "ok"}},
+					{Probability: 0.1, Response: models.Response{StatusCode: 500, Body: "error"}},
+					{Probability: 0, Response: models.Response{StatusCode: 418, Body: "never"}},
+				},
+				ProbabilisticSeed: 42,
+			},
+		},
+	}
+
+	collect := func(m *Matcher, n int) []int {
+		codes := make([]int, 0, n)
+		for i := 0; i < n; i++ {
+			match, err := m.FindMatch(httptest.NewRequest("GET", "/api/flaky", nil))
+			if err != nil {
+				t.Fatalf("FindMatch error: %v", err)
+			}
+			if match == nil {
+				t.Fatal("Expected mock to match")
+			}
+			codes = append(codes, match.Response.StatusCode)
+		}
+		return codes
+	}
+
+	first := collect(NewMatcher(mocks), 1000)
+	second := collect(NewMatcher(mocks), 1000)
+
+	counts := make(map[int]int)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Expected identical selections with the same seed, differ at call %d", i)
+		}
+		counts[first[i]]++
+	}
+
+	if counts[418] != 0 {
+		t.Errorf("Expected zero-probability response never to be picked, got %d", counts[418])
+	}
+	if counts[200] < 850 || counts[200] > 950 {
+		t.Errorf("Expected roughly 90%% of responses to be 200, got %d/1000", counts[200])
+	}
+	if counts[500] == 0 {
+		t.Error("Expected some 500 responses")
+	}
+}
+
+func TestMatcherSetSeed(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:    "Unseeded",
+			Request: models.Request{URI: "/api/flaky"},
+			Response: models.Response{
+				Probabilistic: []models.WeightedResponse{
+					{Probability: 0.5, Response: models.Response{StatusCode: 200}},
+					{Probability: 0.5, Response: models.Response{StatusCode: 500}},
+				},
+			},
+		},
+	}
+
+	collect := func(m *Matcher) []int {
+		codes := make([]int, 0, 100)
+		for i := 0; i < 100; i++ {
+			match, err := m.FindMatch(httptest.NewRequest("GET", "/api/flaky", nil))
+			if err != nil || match == nil {
+				t.Fatalf("Expected mock to match, got %v, %v", match, err)
+			}
+			codes = append(codes, match.Response.StatusCode)
+		}
+		return codes
+	}
+
+	first := NewMatcher(mocks)
+	first.SetSeed(7)
+	second := NewMatcher(mocks)
+	second.SetSeed(7)
+
+	a, b := collect(first), collect(second)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Expected identical selections with the same matcher seed, differ at call %d", i)
+		}
+	}
+
+	// Reloading restarts the sequence
+	first.UpdateMocks(mocks)
+	c := collect(first)
+	for i := range a {
+		if a[i] != c[i] {
+			t.Fatalf("Expected the sequence to restart after a reload, differ at call %d", i)
+		}
+	}
+}
+
+func TestVerifyJWT(t *testing.T) {
+	matcher := NewMatcher(nil)
+	secret := "test-secret"
+
+	sign := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return token
+	}
+
+	config := &models.JWTMatcher{
+		Secret: secret,
+		Issuer: "https://issuer.example.com",
+		Claims: map[string]string{"scope": "read", "tenant": "42"},
+	}
+
+	valid := jwt.MapClaims{
+		"iss":    "https://issuer.example.com",
+		"sub":    "user-1",
+		"scope":  []string{"read", "write"},
+		"tenant": 42,
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+
+	tests := []struct {
+		name    string
+		auth    string
+		wantErr bool
+	}{
+		{"valid token", "Bearer " + sign(valid), false},
+		{"missing token", "", true},
+		{"expired token", "Bearer " + sign(jwt.MapClaims{"iss": "https://issuer.example.com", "scope": "read", "tenant": 42, "exp": time.Now().Add(-time.Hour).Unix()}), true},
+		{"wrong issuer", "Bearer " + sign(jwt.MapClaims{"iss": "https://other.example.com", "scope": "read", "tenant": 42}), true},
+		{"missing claim", "Bearer " + sign(jwt.MapClaims{"iss": "https://issuer.example.com", "scope": "read"}), true},
+		{"garbage token", "Bearer not-a-jwt", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/protected", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			claims, err := matcher.VerifyJWT(req, config)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if claims["sub"] != "user-1" {
+				t.Errorf("Expected sub claim 'user-1', got %v", claims["sub"])
+			}
+		})
+	}
+}
+
+func TestVerifyJWTWithJWKS(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"n":   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+			}},
+		})
+	}))
+	defer jwks.Close()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user-2", "aud": "my-api"})
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(privateKey)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	matcher := NewMatcher(nil)
+	req := httptest.NewRequest("GET", "/api/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+
+	claims, err := matcher.VerifyJWT(req, &models.JWTMatcher{JWKSURL: jwks.URL, Audience: "my-api"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if claims["sub"] != "user-2" {
+		t.Errorf("Expected sub claim 'user-2', got %v", claims["sub"])
+	}
+
+	// A token signed with an HMAC secret is rejected for a JWKS-configured matcher
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-2"}).SignedString([]byte("secret"))
+	req.Header.Set("Authorization", "Bearer "+hmacToken)
+	if _, err := matcher.VerifyJWT(req, &models.JWTMatcher{JWKSURL: jwks.URL}); err == nil {
+		t.Error("Expected HMAC token to be rejected")
+	}
+}
+
+func TestJWKSRefetchCooldown(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	var fetches atomic.Int32
+	var failing atomic.Bool
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		key := map[string]string{
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+		}
+		first, second := map[string]string{"kid": "test-key"}, map[string]string{"kid": "other-key"}
+		for name, value := range key {
+			first[name], second[name] = value, value
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{first, second}})
+	}))
+	defer jwks.Close()
+
+	matcher := NewMatcher(nil)
+	config := &models.JWTMatcher{JWKSURL: jwks.URL}
+	verify := func(kid string) error {
+		t.Helper()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user-3"})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(privateKey)
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		req := httptest.NewRequest("GET", "/api/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+signed)
+		_, err = matcher.VerifyJWT(req, config)
+		return err
+	}
+
+	if err := verify("test-key"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Unknown and missing key IDs don't refetch the set before the cooldown passes
+	for _, kid := range []string{"unknown-1", "unknown-2", "", "unknown-1", ""} {
+		if err := verify(kid); err == nil {
+			t.Errorf("Expected a token with key ID %q to be rejected", kid)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("Expected a single JWKS fetch, got %d", got)
+	}
+
+	// A failed refetch keeps the cached set
+	matcher.jwtKeys.cooldown = 0
+	failing.Store(true)
+	if err := verify("unknown-3"); err == nil {
+		t.Error("Expected a token with an unknown key ID to be rejected")
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("Expected the unknown key ID to refetch the set after the cooldown, got %d fetches", got)
+	}
+	if err := verify("test-key"); err != nil {
+		t.Errorf("Expected the cached set to be used after a failed refetch: %v", err)
+	}
+
+	// A failing endpoint without a cached set isn't fetched on every request either
+	matcher = NewMatcher(nil)
+	for i := 0; i < 3; i++ {
+		if err := verify("test-key"); err == nil {
+			t.Error("Expected verification to fail without keys")
+		}
+	}
+	if got := fetches.Load() - 2; got != 1 {
+		t.Errorf("Expected a single fetch of the failing endpoint, got %d", got)
+	}
+}
+
+func TestProducesContentNegotiation(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "report",
+			Priority: 10,
+			Request:  models.Request{URI: "/report", Method: "GET", Produces: []string{"application/json", "text/csv"}},
+		},
+		{
+			Name:    "image",
+			Request: models.Request{URI: "/image", Method: "GET", Produces: []string{"image/png"}},
+		},
+		{
+			Name:    "image-fallback",
+			Request: models.Request{URI: "/image", Method: "GET", Headers: map[string]string{"X-Fallback": "yes"}},
+		},
+	}
+	matcher := NewMatcher(mocks)
+
+	tests := []struct {
+		name     string
+		uri      string
+		accept   string
+		fallback bool
+		expected string
+		err      error
+	}{
+		{"no accept header", "/report", "", false, "report", nil},
+		{"exact type", "/report", "text/csv", false, "report", nil},
+		{"wildcard subtype", "/report", "application/*", false, "report", nil},
+		{"any type", "/report", "*/*", false, "report", nil},
+		{"unsupported type", "/report", "application/xml", false, "", ErrNotAcceptable},
+		{"explicitly refused", "/report", "application/json;q=0, text/csv;q=0, */*", false, "", ErrNotAcceptable},
+		{"other mock still matches", "/image", "text/html", true, "image-fallback", nil},
+		{"unmatched path is not a 406", "/missing", "text/html", false, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.uri, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if tt.fallback {
+				req.Header.Set("X-Fallback", "yes")
+			}
+
+			mock, err := matcher.FindMatch(req)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			name := ""
+			if mock != nil {
+				name = mock.Name
+			}
+			if name != tt.expected {
+				t.Errorf("Expected mock '%s', got '%s'", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	produces := []string{"application/json", "text/csv"}
+
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"", "application/json"},
+		{"text/csv", "text/csv"},
+		{"application/json;q=0.5, text/csv", "text/csv"},
+		{"text/*;q=0.9, application/json;q=0.1", "text/csv"},
+		{"*/*;q=0.1, application/json;q=0", "text/csv"},
+		{"image/png", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NegotiateContentType(tt.accept, produces); got != tt.expected {
+			t.Errorf("Accept '%s': expected '%s', got '%s'", tt.accept, tt.expected, got)
+		}
+	}
+}
+
+func TestMatcherBodyCanonicalJSON(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name: "Canonical Body Mock",
+			Request: models.Request{
+				URI:           "/api/orders",
+				Method:        "POST",
+				Body:          `{"customer": {"id": 1, "name": "Jane"}, "items": [1, 2]}`,
+				CanonicalJSON: true,
+			},
+		},
+	}
+
+	matcher := NewMatcher(mocks)
+
+	tests := []struct {
+		name     string
+		body     string
+		expected bool
+	}{
+		{"identical", `{"customer": {"id": 1, "name": "Jane"}, "items": [1, 2]}`, true},
+		{"whitespace and key order", "{\n  \"items\":[1,2],\n  \"customer\":{\"name\":\"Jane\",\"id\":1.0}\n}", true},
+		{"array order matters", `{"customer": {"id": 1, "name": "Jane"}, "items": [2, 1]}`, false},
+		{"different value", `{"customer": {"id": 2, "name": "Jane"}, "items": [1, 2]}`, false},
+		{"extra field", `{"customer": {"id": 1, "name": "Jane"}, "items": [1, 2], "note": ""}`, false},
+		{"invalid JSON", `{"customer":`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := matcher.FindMatch(createRequest("POST", "/api/orders", nil, []byte(tt.body)))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (match != nil) != tt.expected {
+				t.Errorf("Expected match=%v, got %v", tt.expected, match != nil)
+			}
+		})
+	}
+}
+
+func TestMatcherBodyMatchMode(t *testing.T) {
+	const expected = `{"tags": ["a", "b", {"id": 1}], "user": {"name": "Jane"}}`
+	newMatcher := func(mode string) *Matcher {
+		return NewMatcher([]models.Mock{
+			{
+				Name: "Body Mock",
+				Request: models.Request{
+					URI:           "/api/tags",
+					Body:          expected,
+					BodyMatchMode: mode,
+				},
+			},
+		})
+	}
+
+	tests := []struct {
+		name     string
+		mode     string
+		body     string
+		expected bool
+	}{
+		{"exact identical", models.BodyMatchExact, expected, true},
+		{"exact whitespace", models.BodyMatchExact, `{"tags":["a","b",{"id":1}],"user":{"name":"Jane"}}`, false},
+		{"default is exact", "", `{"user": {"name": "Jane"}, "tags": ["a", "b", {"id": 1}]}`, false},
+		{"json key order", models.BodyMatchJSON, `{"user":{"name":"Jane"},"tags":["a","b",{"id":1}]}`, true},
+		{"json array order", models.BodyMatchJSON, `{"tags": ["b", "a", {"id": 1}], "user": {"name": "Jane"}}`, false},
+		{"ignore order arrays", models.BodyMatchJSONIgnoreOrder, `{"tags": [{"id": 1.0}, "b", "a"], "user": {"name": "Jane"}}`, true},
+		{"ignore order duplicates", models.BodyMatchJSONIgnoreOrder, `{"tags": ["a", "a", {"id": 1}], "user": {"name": "Jane"}}`, false},
+		{"ignore order missing element", models.BodyMatchJSONIgnoreOrder, `{"tags": ["a", {"id": 1}], "user": {"name": "Jane"}}`, false},
+		{"ignore order invalid JSON", models.BodyMatchJSONIgnoreOrder, `{"tags": [`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := newMatcher(tt.mode).FindMatch(createRequest("POST", "/api/tags", nil, []byte(tt.body)))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (match != nil) != tt.expected {
+				t.Errorf("Expected match=%v, got %v", tt.expected, match != nil)
+			}
+		})
+	}
+}
+
+func TestMatcherQueryParams(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name: "search-users",
+			Request: models.Request{
+				URI:         "/search",
+				Method:      "GET",
+				QueryParams: []models.QueryParamMatcher{{Key: "type", Value: "user"}},
+			},
+		},
+		{
+			Name: "search-orders",
+			Request: models.Request{
+				URI:         "/search",
+				Method:      "GET",
+				QueryParams: []models.QueryParamMatcher{{Key: "type", Value: "order"}, {Key: "page"}},
+			},
+		},
+		{
+			Name: "search-regex",
+			Request: models.Request{
+				URI:         "/search",
+				Method:      "GET",
+				QueryParams: []models.QueryParamMatcher{{Key: "^filter\\[.+\\]$", Value: "^[0-9]+$"}},
+				IsRegex:     models.RegexConfig{QueryParams: true},
+			},
+		},
+	}
+
+	matcher := NewMatcher(mocks)
+
+	tests := []struct {
+		name     string
+		uri      string
+		expected string
+	}{
+		{"exact value", "/search?type=user", "search-users"},
+		{"one of repeated values", "/search?type=admin&type=user", "search-users"},
+		{"value and presence", "/search?type=order&page=", "search-orders"},
+		{"missing param", "/search?type=order", ""},
+		{"different value", "/search?type=product", ""},
+		{"regex key and value", "/search?filter%5Bage%5D=42", "search-regex"},
+		{"regex value mismatch", "/search?filter%5Bage%5D=old", ""},
+		{"no params", "/search", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := matcher.FindMatch(createRequest("GET", tt.uri, nil, nil))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			name := ""
+			if match != nil {
+				name = match.Name
+			}
+			if name != tt.expected {
+				t.Errorf("Expected mock '%s', got '%s'", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestCallCountsAccessors(t *testing.T) {
+	sequence := []models.ResponseItem{{StatusCode: 200, Body: "first"}, {StatusCode: 200, Body: "second"}}
+	matcher := NewMatcher([]models.Mock{
+		{Name: "global", Request: models.Request{URI: "/global"}, Response: models.Response{Sequence: sequence}},
+		{Name: "client", Request: models.Request{URI: "/client"}, Response: models.Response{Sequence: sequence, SequenceScope: "client"}},
+	})
+
+	for _, uri := range []string{"/global", "/global", "/client"} {
+		req := createRequest("GET", uri, nil, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if _, err := matcher.FindMatch(req); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if counts := matcher.GetCallCounts("global"); counts[""] != 2 || len(counts) != 1 {
+		t.Errorf("Expected 2 global calls, got %v", counts)
+	}
+	if counts := matcher.GetCallCounts("client"); len(counts) != 1 || counts["10.0.0.1"] != 1 {
+		t.Errorf("Expected a single client counter, got %v", counts)
+	}
+
+	matcher.ResetCallCounts("global")
+	if counts := matcher.GetCallCounts("global"); len(counts) != 0 {
+		t.Errorf("Expected no counters after reset, got %v", counts)
+	}
+	match, _ := matcher.FindMatch(createRequest("GET", "/global", nil, nil))
+	if match == nil || match.Response.Body != "first" {
+		t.Errorf("Expected the sequence to start over after reset, got %v", match)
+	}
+	if counts := matcher.GetCallCounts("client"); len(counts) != 1 {
+		t.Errorf("Expected other mocks' counters to be kept, got %v", counts)
+	}
+}
+
+func TestSequenceAdvanceOn(t *testing.T) {
+	sequence := []models.ResponseItem{
+		{StatusCode: 500, Body: "fail-1"},
+		{StatusCode: 500, Body: "fail-2"},
+		{StatusCode: 200, Body: "ok"},
+	}
+
+	t.Run("retry header", func(t *testing.T) {
+		matcher := NewMatcher([]models.Mock{{
+			Name:    "flaky",
+			Request: models.Request{URI: "/flaky"},
+			Response: models.Response{
+				Sequence:          sequence,
+				SequenceMode:      "once",
+				SequenceAdvanceOn: &models.SequenceAdvanceOn{Header: "X-Retry-Attempt"},
+			},
+		}})
+
+		calls := []struct {
+			retry    string
+			expected string
+		}{
+			{"", "fail-1"},
+			{"", "fail-1"}, // Not a retry, doesn't advance
+			{"1", "fail-2"},
+			{"2", "ok"},
+			{"3", "ok"},
+		}
+		for i, call := range calls {
+			headers := map[string]string{}
+			if call.retry != "" {
+				headers["X-Retry-Attempt"] = call.retry
+			}
+			match, _ := matcher.FindMatch(createRequest("GET", "/flaky", headers, nil))
+			if match == nil || match.Response.Body != call.expected {
+				t.Errorf("Call %d: expected '%s', got %v", i+1, call.expected, match)
+			}
+		}
+	})
+
+	t.Run("header value", func(t *testing.T) {
+		matcher := NewMatcher([]models.Mock{{
+			Name:    "flaky",
+			Request: models.Request{URI: "/flaky"},
+			Response: models.Response{
+				Sequence:          sequence,
+				SequenceAdvanceOn: &models.SequenceAdvanceOn{Header: "X-Retry", Value: "true"},
+			},
+		}})
+
+		match, _ := matcher.FindMatch(createRequest("GET", "/flaky", map[string]string{"X-Retry": "false"}, nil))
+		if match == nil || match.Response.Body != "fail-1" {
+			t.Errorf("Expected a different header value not to advance, got %v", match)
+		}
+		match, _ = matcher.FindMatch(createRequest("GET", "/flaky", map[string]string{"X-Retry": "true"}, nil))
+		if match == nil || match.Response.Body != "fail-2" {
+			t.Errorf("Expected the matching header value to advance, got %v", match)
+		}
+	})
+
+	t.Run("every", func(t *testing.T) {
+		matcher := NewMatcher([]models.Mock{{
+			Name:    "flaky",
+			Request: models.Request{URI: "/flaky"},
+			Response: models.Response{
+				Sequence:          sequence,
+				SequenceAdvanceOn: &models.SequenceAdvanceOn{Every: 2},
+			},
+		}})
+
+		for i, expected := range []string{"fail-1", "fail-1", "fail-2", "fail-2", "ok", "ok", "fail-1"} {
+			match, _ := matcher.FindMatch(createRequest("GET", "/flaky", nil, nil))
+			if match == nil || match.Response.Body != expected {
+				t.Errorf("Call %d: expected '%s', got %v", i+1, expected, match)
+			}
+		}
+	})
+}
+
+func TestMatcherFormParams(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name: "client-credentials",
+			Request: models.Request{
+				URI:    "/oauth/token",
+				Method: "POST",
+				FormParams: []models.FormParamMatcher{
+					{Key: "grant_type", Value: "client_credentials"},
+					{Key: "client_id"},
+					{Key: "scope", Value: "^(read|write)$", Regex: true},
+				},
+			},
+		},
+	}
+
+	matcher := NewMatcher(mocks)
+	formHeaders := map[string]string{"Content-Type": "application/x-www-form-urlencoded; charset=utf-8"}
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		body     string
+		expected bool
+	}{
+		{"all fields", formHeaders, "grant_type=client_credentials&client_id=abc&scope=read", true},
+		{"field order", formHeaders, "scope=write&client_id=&grant_type=client_credentials", true},
+		{"repeated keys", formHeaders, "grant_type=password&grant_type=client_credentials&client_id=abc&scope=admin&scope=read", true},
+		{"wrong value", formHeaders, "grant_type=password&client_id=abc&scope=read", false},
+		{"regex mismatch", formHeaders, "grant_type=client_credentials&client_id=abc&scope=admin", false},
+		{"missing field", formHeaders, "grant_type=client_credentials&scope=read", false},
+		{"unparseable body", formHeaders, "grant_type=client_credentials&client_id=abc&scope=read&bad=%zz", false},
+		{"not form encoded", map[string]string{"Content-Type": "application/json"}, "grant_type=client_credentials&client_id=abc&scope=read", false},
+		{"no content type", nil, "grant_type=client_credentials&client_id=abc&scope=read", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := matcher.FindMatch(createRequest("POST", "/oauth/token", tt.headers, []byte(tt.body)))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (match != nil) != tt.expected {
+				t.Errorf("Expected match=%v, got %v", tt.expected, match != nil)
+			}
+		})
+	}
+}
+
+func TestMatcherHost(t *testing.T) {
+	mocks := []models.Mock{
+		{Name: "foo", Request: models.Request{URI: "/api/users", Host: "api.foo.test"}},
+		{Name: "bar", Request: models.Request{URI: "/api/users", Host: "API.BAR.TEST"}},
+		{Name: "tenants", Request: models.Request{URI: "/api/users", Host: `^[a-z]+\.tenants\.test$`, IsRegex: models.RegexConfig{Host: true}}},
+		{Name: "any", Request: models.Request{URI: "/api/users"}},
+	}
+
+	matcher := NewMatcher(mocks)
+
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"api.foo.test", "foo"},
+		{"api.foo.test:8083", "foo"},
+		{"api.bar.test", "bar"},
+		{"acme.tenants.test:443", "tenants"},
+		{"acme.tenants.test.evil", "any"},
+		{"localhost:8083", "any"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			req := createRequest("GET", "/api/users", nil, nil)
+			req.Host = tt.host
+
+			match, err := matcher.FindMatch(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if match == nil || match.Name != tt.expected {
+				t.Errorf("Expected match %q, got %v", tt.expected, match)
+			}
+		})
+	}
+}
+
+func TestMatcherMultipart(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name: "avatar-upload",
+			Request: models.Request{
+				URI:    "/upload",
+				Method: "POST",
+				// The raw body stays available to the other matchers
+				Body:    `name="user_id"`,
+				IsRegex: models.RegexConfig{Body: true},
+				Multipart: []models.MultipartMatcher{
+					{Name: "user_id", Value: "^[0-9]+$", Regex: true},
+					{Name: "file", Filename: `\.png$`, ContentType: "image/png"},
+				},
+			},
+		},
+	}
+
+	matcher := NewMatcher(mocks)
+
+	buildBody := func(userID, filename, contentType string) (string, []byte) {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		if userID != "" {
+			if err := writer.WriteField("user_id", userID); err != nil {
+				t.Fatalf("Failed to write field: %v", err)
+			}
+		}
+		if filename != "" {
+			header := make(textproto.MIMEHeader)
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, filename))
+			header.Set("Content-Type", contentType)
+			part, err := writer.CreatePart(header)
+			if err != nil {
+				t.Fatalf("Failed to create part: %v", err)
+			}
+			part.Write([]byte("\x89PNG")) //nolint:errcheck // in-memory buffer
+		}
+		writer.Close() //nolint:errcheck // in-memory buffer
+		return writer.FormDataContentType(), buf.Bytes()
+	}
+
+	tests := []struct {
+		name        string
+		userID      string
+		filename    string
+		contentType string
+		expected    bool
+	}{
+		{"field and file", "42", "avatar.png", "image/png", true},
+		{"field regex mismatch", "abc", "avatar.png", "image/png", false},
+		{"missing field", "", "avatar.png", "image/png", false},
+		{"missing file", "42", "", "", false},
+		{"filename mismatch", "42", "avatar.gif", "image/png", false},
+		{"content type mismatch", "42", "avatar.png", "image/gif", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType, body := buildBody(tt.userID, tt.filename, tt.contentType)
+			match, err := matcher.FindMatch(createRequest("POST", "/upload", map[string]string{"Content-Type": contentType}, body))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (match != nil) != tt.expected {
+				t.Errorf("Expected match=%v, got %v", tt.expected, match != nil)
+			}
+		})
+	}
+
+	t.Run("not multipart", func(t *testing.T) {
+		_, body := buildBody("42", "avatar.png", "image/png")
+		match, err := matcher.FindMatch(createRequest("POST", "/upload", map[string]string{"Content-Type": "application/octet-stream"}, body))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if match != nil {
+			t.Error("Expected non-multipart requests not to match")
+		}
+	})
+}
+
+func TestMatcherFallback(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Admin SPA",
+			Fallback: true,
+			Priority: 10,
+			Request:  models.Request{URI: "^/admin(/|$)", IsRegex: models.RegexConfig{URI: true}},
+		},
+		{
+			Name:     "SPA everything",
+			Fallback: true,
+		},
+		{
+			Name:    "Users API",
+			Request: models.Request{URI: "/api/users", Method: "GET"},
+		},
+		{
+			Name:    "Dashboard page",
+			Request: models.Request{URI: "/dashboard", Method: "GET"},
+		},
+	}
+
+	matcher := NewMatcher(mocks)
+
+	const browserAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+	tests := []struct {
+		name     string
+		method   string
+		uri      string
+		accept   string
+		expected string
+	}{
+		{"regular mocks win over fallbacks", "GET", "/dashboard", browserAccept, "Dashboard page"},
+		{"api mock", "GET", "/api/users", "application/json", "Users API"},
+		{"navigation to client-side route", "GET", "/settings/profile", browserAccept, "SPA everything"},
+		{"fallbacks follow priority", "GET", "/admin/users", browserAccept, "Admin SPA"},
+		{"head navigation", "HEAD", "/settings", "text/html", "SPA everything"},
+		{"api call without text/html", "GET", "/api/unknown", "application/json", ""},
+		{"wildcard accept is not a navigation", "GET", "/settings", "*/*", ""},
+		{"no accept header", "GET", "/settings", "", ""},
+		{"text/html refused", "GET", "/settings", "text/html;q=0, */*", ""},
+		{"post", "POST", "/settings", browserAccept, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{}
+			if tt.accept != "" {
+				headers["Accept"] = tt.accept
+			}
+			match, err := matcher.FindMatch(createRequest(tt.method, tt.uri, headers, nil))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			name := ""
+			if match != nil {
+				name = match.Name
+			}
+			if name != tt.expected {
+				t.Errorf("Expected match %q, got %q", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestMatcherExplainMatch(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Create user",
+			Priority: 10,
+			Request: models.Request{
+				URI:     "/api/users",
+				Method:  "POST",
+				Headers: map[string]string{"X-Api-Key": "secret", "Content-Type": "application/json"},
+				JSONPath: []models.JSONPathMatcher{
+					{Path: "name", Value: "John"},
+					{Path: "age", Value: "30"},
+				},
+			},
+		},
+		{
+			Name:    "Any users call",
+			Request: models.Request{URI: "/api/users"},
+		},
+		{
+			Name:    "Scripted",
+			Request: models.Request{JavaScript: "true"},
+		},
+	}
+
+	matcher := NewMatcher(mocks)
+
+	req := createRequest("POST", "/api/users", map[string]string{"Content-Type": "application/json"}, []byte(`{"name": "John", "age": 31}`))
+	explanations, err := matcher.ExplainMatch(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(explanations) != 3 {
+		t.Fatalf("Expected 3 explanations, got %d", len(explanations))
+	}
+
+	create := explanations[0]
+	if create.Mock != "Create user" || create.Matched {
+		t.Errorf("Expected 'Create user' first and not matched, got %+v", create)
+	}
+	expected := map[string]bool{
+		"uri":                 true,
+		"method":              true,
+		"header X-Api-Key":    false,
+		"header Content-Type": true,
+		"json_path name":      true,
+		"json_path age":       false,
+	}
+	if len(create.Conditions) != len(expected) {
+		t.Errorf("Expected every condition to be checked, got %+v", create.Conditions)
+	}
+	for _, c := range create.Conditions {
+		if passed, exists := expected[c.Condition]; !exists || passed != c.Passed {
+			t.Errorf("Unexpected condition result %+v", c)
+		}
+	}
+
+	if !explanations[1].Matched {
+		t.Errorf("Expected 'Any users call' to match, got %+v", explanations[1])
+	}
+	if explanations[2].Matched || explanations[2].Reason == "" {
+		t.Errorf("Expected the JavaScript mock to be skipped with a reason, got %+v", explanations[2])
+	}
+
+	if name := ExplainedMatch(explanations); name != "Any users call" {
+		t.Errorf("Expected explained match 'Any users call', got %q", name)
+	}
+}
+
+func TestMatcherClientCert(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Admin client",
+			Priority: 10,
+			Request: models.Request{
+				URI:        "/api/admin",
+				ClientCert: &models.ClientCertMatcher{CommonName: "admin.example.com", Issuer: "Example CA"},
+			},
+		},
+		{
+			Name: "Service clients",
+			Request: models.Request{
+				URI:        "/api/admin",
+				ClientCert: &models.ClientCertMatcher{CommonName: `^svc-[a-z]+\.internal$`, Regex: true},
+			},
+		},
+	}
+
+	matcher := NewMatcher(mocks)
+
+	withCert := func(commonName, issuer string) *http.Request {
+		req := createRequest("GET", "/api/admin", nil, nil)
+		if commonName != "" {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{
+				Subject: pkix.Name{CommonName: commonName},
+				Issuer:  pkix.Name{CommonName: issuer},
+			}}}
+		}
+		return req
+	}
+
+	tests := []struct {
+		name       string
+		commonName string
+		issuer     string
+		expected   string
+	}{
+		{"exact subject and issuer", "admin.example.com", "Example CA", "Admin client"},
+		{"wrong issuer", "admin.example.com", "Other CA", ""},
+		{"regex subject", "svc-billing.internal", "Example CA", "Service clients"},
+		{"regex subject mismatch", "svc-billing.example.com", "Example CA", ""},
+		{"no client certificate", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := matcher.FindMatch(withCert(tt.commonName, tt.issuer))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			name := ""
+			if match != nil {
+				name = match.Name
+			}
+			if name != tt.expected {
+				t.Errorf("Expected match %q, got %q", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestMatcherCEL(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name: "Adult users",
+			Request: models.Request{
+				URI: "/api/users",
+				CEL: `method == "POST" && json.age >= 18 && headers["x-tenant"].startsWith("acme")`,
+			},
+			Response: models.Response{StatusCode: 201},
+		},
+		{
+			Name: "Search",
+			Request: models.Request{
+				URI: "/api/search",
+				CEL: `"q" in query && size(query["q"]) > 2`,
+			},
+			Response: models.Response{StatusCode: 200},
+		},
+		{
+			Name:     "Invalid",
+			Request:  models.Request{URI: "/api/invalid", CEL: `method ==`},
+			Response: models.Response{StatusCode: 200},
+		},
+	}
+
+	m := NewMatcher(mocks)
+
+	tests := []struct {
+		name     string
+		method   string
+		uri      string
+		headers  map[string]string
+		body     string
+		expected string
+	}{
+		{"All conditions hold", "POST", "/api/users", map[string]string{"X-Tenant": "acme-eu"}, `{"age": 30}`, "Adult users"},
+		{"JSON field fails", "POST", "/api/users", map[string]string{"X-Tenant": "acme-eu"}, `{"age": 12}`, ""},
+		{"Missing header", "POST", "/api/users", nil, `{"age": 30}`, ""},
+		{"Body isn't JSON", "POST", "/api/users", map[string]string{"X-Tenant": "acme-eu"}, `age=30`, ""},
+		{"Query parameter", "GET", "/api/search?q=mock", nil, "", "Search"},
+		{"Short query parameter", "GET", "/api/search?q=mo", nil, "", ""},
+		{"Missing query parameter", "GET", "/api/search", nil, "", ""},
+		{"Invalid expression never matches", "GET", "/api/invalid", nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := createRequest(tt.method, tt.uri, tt.headers, []byte(tt.body))
+			match, err := m.FindMatch(req)
+			if err != nil {
+				t.Fatalf("FindMatch failed: %v", err)
+			}
+			got := ""
+			if match != nil {
+				got = match.Name
+			}
+			if got != tt.expected {
+				t.Errorf("Expected match %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestMatcherNotConditions(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name: "api-without-debug",
+			Request: models.Request{
+				URI:     "^/api/",
+				IsRegex: models.RegexConfig{URI: true},
+				Not: &models.Request{
+					Headers: map[string]string{"X-Debug": "true"},
+				},
+			},
+		},
+		{
+			Name: "orders-except-drafts",
+			Request: models.Request{
+				URI:    "/orders",
+				Method: "POST",
+				Not: &models.Request{
+					JSONPath: []models.JSONPathMatcher{{Path: "status", Value: "draft"}},
+					Not: &models.Request{
+						Headers: map[string]string{"X-Force": "1"},
+					},
+				},
+			},
+		},
+	}
+
+	matcher := NewMatcher(mocks)
+
+	tests := []struct {
+		name     string
+		method   string
+		uri      string
+		headers  map[string]string
+		body     string
+		expected string
+	}{
+		{"outer match without header", "GET", "/api/users", nil, "", "api-without-debug"},
+		{"negated header", "GET", "/api/users", map[string]string{"X-Debug": "true"}, "", ""},
+		{"other header value", "GET", "/api/users", map[string]string{"X-Debug": "false"}, "", "api-without-debug"},
+		{"outer mismatch", "GET", "/health", nil, "", ""},
+		{"negated body", "POST", "/orders", nil, `{"status": "draft"}`, ""},
+		{"body not negated", "POST", "/orders", nil, `{"status": "placed"}`, "orders-except-drafts"},
+		{"nested not", "POST", "/orders", map[string]string{"X-Force": "1"}, `{"status": "draft"}`, "orders-except-drafts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := matcher.FindMatch(createRequest(tt.method, tt.uri, tt.headers, []byte(tt.body)))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			name := ""
+			if match != nil {
+				name = match.Name
+			}
+			if name != tt.expected {
+				t.Errorf("Expected match %q, got %q", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestMatcherJSONPathOperators(t *testing.T) {
+	body := `{"user": {"name": "Jane", "age": 30, "score": 7.5, "zip": "01234"}}`
+
+	tests := []struct {
+		name     string
+		matcher  models.JSONPathMatcher
+		expected bool
+	}{
+		{"gt", models.JSONPathMatcher{Path: "user.age", Operator: "gt", Value: "18"}, true},
+		{"gt equal", models.JSONPathMatcher{Path: "user.age", Operator: "gt", Value: "30"}, false},
+		{"gte", models.JSONPathMatcher{Path: "user.age", Operator: "gte", Value: "30"}, true},
+		{"lt float", models.JSONPathMatcher{Path: "user.score", Operator: "lt", Value: "7.6"}, true},
+		{"lte", models.JSONPathMatcher{Path: "user.score", Operator: "lte", Value: "7"}, false},
+		{"eq numeric", models.JSONPathMatcher{Path: "user.age", Operator: "eq", Value: "30.0"}, true},
+		{"eq string", models.JSONPathMatcher{Path: "user.name", Operator: "eq", Value: "Jane"}, true},
+		{"eq numeric string compares as string", models.JSONPathMatcher{Path: "user.zip", Operator: "eq", Value: "1234"}, false},
+		{"ne", models.JSONPathMatcher{Path: "user.name", Operator: "ne", Value: "John"}, true},
+		{"ne equal", models.JSONPathMatcher{Path: "user.age", Operator: "ne", Value: "30"}, false},
+		{"ordering on string", models.JSONPathMatcher{Path: "user.zip", Operator: "gt", Value: "100"}, false},
+		{"ordering with non-numeric value", models.JSONPathMatcher{Path: "user.age", Operator: "gt", Value: "old"}, false},
+		{"missing path", models.JSONPathMatcher{Path: "user.height", Operator: "ne", Value: "1"}, false},
+		{"no operator keeps exact match", models.JSONPathMatcher{Path: "user.age", Value: "30"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher := NewMatcher([]models.Mock{{
+				Name:    "operators",
+				Request: models.Request{URI: "/users", JSONPath: []models.JSONPathMatcher{tt.matcher}},
+			}})
+
+			match, err := matcher.FindMatch(createRequest("POST", "/users", nil, []byte(body)))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (match != nil) != tt.expected {
+				t.Errorf("Expected match=%v, got %v", tt.expected, match != nil)
+			}
+		})
+	}
+}
+
+func TestAfterCountResponses(t *testing.T) {
+	matcher := NewMatcher([]models.Mock{{
+		Name:    "quota",
+		Request: models.Request{URI: "/quota"},
+		Response: models.Response{
+			StatusCode: 200,
+			Body:       "ok",
+			AfterCount: []models.CountResponse{
+				{After: 5, Response: models.Response{StatusCode: 503, Body: "gone"}},
+				{After: 2, Response: models.Response{StatusCode: 429, Body: "quota exceeded"}},
+			},
+		},
+	}})
+
+	expected := []int{200, 200, 429, 429, 429, 503, 503}
+	for i, status := range expected {
+		match, err := matcher.FindMatch(createRequest("GET", "/quota", nil, nil))
+		if err != nil || match == nil {
+			t.Fatalf("Call %d: expected a match, got %v (%v)", i+1, match, err)
+		}
+		if match.Response.StatusCode != status {
+			t.Errorf("Call %d: expected %d, got %d", i+1, status, match.Response.StatusCode)
+		}
+	}
+
+	// Resetting the counters restores the base response
+	matcher.ResetCallCounts("quota")
+	match, _ := matcher.FindMatch(createRequest("GET", "/quota", nil, nil))
+	if match == nil || match.Response.StatusCode != 200 {
+		t.Errorf("Expected 200 after reset, got %v", match)
+	}
+}
+
+func TestMatcherIndexPriority(t *testing.T) {
+	mocks := []models.Mock{
+		{Name: "exact-get", Priority: 1, Request: models.Request{URI: "/api/items", Method: "GET"}},
+		{Name: "regex", Priority: 5, Request: models.Request{URI: "^/api/items$", IsRegex: models.RegexConfig{URI: true}, Headers: map[string]string{"X-Regex": "1"}}},
+		{Name: "any-method", Priority: 3, Request: models.Request{URI: "/api/items", Headers: map[string]string{"X-Any": "1"}}},
+		{Name: "regex-method", Priority: 2, Request: models.Request{URI: "/api/items", Method: "^(PUT|PATCH)$", IsRegex: models.RegexConfig{Method: true}}},
+	}
+
+	matcher := NewMatcher(mocks)
+
+	tests := []struct {
+		name     string
+		method   string
+		uri      string
+		headers  map[string]string
+		expected string
+	}{
+		{"fallback mock with higher priority wins", "GET", "/api/items", map[string]string{"X-Regex": "1", "X-Any": "1"}, "regex"},
+		{"any-method bucket before lower priority exact", "GET", "/api/items", map[string]string{"X-Any": "1"}, "any-method"},
+		{"exact bucket", "GET", "/api/items", nil, "exact-get"},
+		{"case-insensitive lookup", "get", "/API/Items", nil, "exact-get"},
+		{"regex method", "PATCH", "/api/items", nil, "regex-method"},
+		{"no bucket", "GET", "/api/other", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := matcher.FindMatch(createRequest(tt.method, tt.uri, tt.headers, nil))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			name := ""
+			if match != nil {
+				name = match.Name
+			}
+			if name != tt.expected {
+				t.Errorf("Expected match %q, got %q", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestMatcherRegexCache(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:    "invalid",
+			Request: models.Request{URI: "^/items/(", IsRegex: models.RegexConfig{URI: true}},
+		},
+		{
+			Name:    "items",
+			Request: models.Request{URI: "^/items/[0-9]+$", IsRegex: models.RegexConfig{URI: true}},
+		},
+	}
+
+	matcher := NewMatcher(mocks)
+	if compiled, exists := matcher.regexes["^/items/[0-9]+$"]; !exists || compiled == nil {
+		t.Error("Expected the URI pattern to be precompiled")
+	}
+	if compiled, exists := matcher.regexes["^/items/("]; !exists || compiled != nil {
+		t.Error("Expected the invalid pattern to be cached as invalid")
+	}
+
+	match, err := matcher.FindMatch(createRequest("GET", "/items/42", nil, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if match == nil || match.Name != "items" {
+		t.Fatalf("Expected the valid regex mock to match, got %v", match)
+	}
+
+	// Reloading replaces the compiled patterns
+	matcher.UpdateMocks([]models.Mock{{
+		Name:    "orders",
+		Request: models.Request{URI: "^/orders/[0-9]+$", IsRegex: models.RegexConfig{URI: true}},
+	}})
+	if _, exists := matcher.regexes["^/items/[0-9]+$"]; exists {
+		t.Error("Expected patterns of removed mocks to be dropped")
+	}
+	match, err = matcher.FindMatch(createRequest("GET", "/orders/7", nil, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if match == nil || match.Name != "orders" {
+		t.Errorf("Expected the reloaded mock to match, got %v", match)
+	}
+}
+
+// newRegexBenchmarkMocks creates regex mocks where only the last one matches the benchmark request
+func newRegexBenchmarkMocks(count int) []models.Mock {
+	mocks := make([]models.Mock, 0, count)
+	for i := 0; i < count; i++ {
+		mocks = append(mocks, models.Mock{
+			Name: fmt.Sprintf("regex-%d", i),
+			Request: models.Request{
+				URI:     fmt.Sprintf("^/api/v1/resource-%d/[0-9]+$", i),
+				Method:  "^(GET|POST)$",
+				Headers: map[string]string{"^X-Tenant$": "^tenant-[a-z]+$"},
+				IsRegex: models.RegexConfig{URI: true, Method: true, Headers: true},
+			},
+		})
+	}
+	return mocks
+}
+
+func BenchmarkFindMatchRegexMocks(b *testing.B) {
+	const mockCount = 500
+	matcher := NewMatcher(newRegexBenchmarkMocks(mockCount))
+	req := createRequest("GET", fmt.Sprintf("/api/v1/resource-%d/42", mockCount-1), map[string]string{"X-Tenant": "tenant-acme"}, nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		match, err := matcher.FindMatch(req)
+		if err != nil || match == nil {
+			b.Fatalf("Expected a match, got %v (%v)", match, err)
+		}
+	}
+}
+
+func BenchmarkFindMatchLargeMockSet(b *testing.B) {
+	const mockCount = 5000
+	mocks := make([]models.Mock, 0, mockCount)
+	for i := 0; i < mockCount; i++ {
+		mocks = append(mocks, models.Mock{
+			Name:    fmt.Sprintf("mock-%d", i),
+			Request: models.Request{URI: fmt.Sprintf("/api/v1/resource-%d", i), Method: "GET"},
+		})
+	}
+	matcher := NewMatcher(mocks)
+	req := createRequest("GET", fmt.Sprintf("/api/v1/resource-%d", mockCount-1), nil, nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		match, err := matcher.FindMatch(req)
+		if err != nil || match == nil {
+			b.Fatalf("Expected a match, got %v (%v)", match, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package matcher
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+)
+
+// multipartPart is a parsed part of a multipart/form-data body
+type multipartPart struct {
+	name        string
+	filename    string
+	contentType string
+	content     string
+}
+
+// matchMultipart matches the parts of a multipart/form-data body. The body is parsed from
+// the buffered copy, so the other matchers still see it. Requests that aren't multipart or
+// can't be parsed never match.
+func (m *Matcher) matchMultipart(r *http.Request, body string, matchers []models.MultipartMatcher) bool {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return false
+	}
+
+	parts, err := parseMultipart(body, params["boundary"])
+	if err != nil {
+		return false
+	}
+
+	for _, mm := range matchers {
+		matched := false
+		for _, part := range parts {
+			if m.matchPart(part, mm) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchPart checks a single part against a multipart matcher
+func (m *Matcher) matchPart(part multipartPart, mm models.MultipartMatcher) bool {
+	if part.name != mm.Name {
+		return false
+	}
+	if mm.Filename != "" && (part.filename == "" || !m.matchRegex(mm.Filename, part.filename)) {
+		return false
+	}
+	if mm.ContentType != "" {
+		mediaType, _, err := mime.ParseMediaType(part.contentType)
+		if err != nil || !strings.EqualFold(mediaType, mm.ContentType) {
+			return false
+		}
+	}
+	if mm.Value != "" {
+		if mm.Regex {
+			return m.matchRegex(mm.Value, part.content)
+		}
+		return part.content == mm.Value
+	}
+	return true
+}
+
+// parseMultipart reads every part of a multipart body
+func parseMultipart(body, boundary string) ([]multipartPart, error) {
+	reader := multipart.NewReader(strings.NewReader(body), boundary)
+
+	parts := make([]multipartPart, 0)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, multipartPart{
+			name:        part.FormName(),
+			filename:    part.FileName(),
+			contentType: part.Header.Get("Content-Type"),
+			content:     string(content),
+		})
+	}
+}
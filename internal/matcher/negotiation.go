@@ -0,0 +1,103 @@
+package matcher
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrNotAcceptable is returned by FindMatch when a mock matched the request but none of
+// the media types it produces is acceptable to the client, and no other mock matched
+var ErrNotAcceptable = errors.New("no acceptable representation")
+
+// acceptRange is a media range from an Accept header
+type acceptRange struct {
+	mediaType string // e.g. "application/json", "text/*" or "*/*"
+	quality   float64
+}
+
+// parseAccept parses an Accept header into its media ranges. Malformed ranges are ignored.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil || !strings.Contains(mediaType, "/") {
+			continue
+		}
+		quality := 1.0
+		if q, exists := params["q"]; exists {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality})
+	}
+	return ranges
+}
+
+// mediaTypeQuality returns the quality the client assigns to a media type. The most specific
+// matching range wins ("type/subtype" over "type/*" over "*/*"); 0 means not acceptable.
+func mediaTypeQuality(ranges []acceptRange, mediaType string) float64 {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+
+	quality, specificity := 0.0, -1
+	for _, ar := range ranges {
+		rangeSpecificity := -1
+		switch {
+		case ar.mediaType == mediaType:
+			rangeSpecificity = 2
+		case ar.mediaType == mainType+"/*":
+			rangeSpecificity = 1
+		case ar.mediaType == "*/*":
+			rangeSpecificity = 0
+		}
+		if rangeSpecificity > specificity {
+			quality, specificity = ar.quality, rangeSpecificity
+		}
+	}
+	return quality
+}
+
+// NegotiateContentType returns the media type from produces the client prefers according to
+// its Accept header, or "" if none is acceptable. Without an Accept header the first one is used.
+func NegotiateContentType(accept string, produces []string) string {
+	if len(produces) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return produces[0]
+	}
+
+	ranges := parseAccept(accept)
+	best, bestQuality := "", 0.0
+	for _, produced := range produces {
+		mediaType, _, err := mime.ParseMediaType(produced)
+		if err != nil {
+			continue
+		}
+		if quality := mediaTypeQuality(ranges, mediaType); quality > bestQuality {
+			best, bestQuality = produced, quality
+		}
+	}
+	return best
+}
+
+// isNavigation checks if the request looks like a browser page load: a GET or HEAD whose
+// Accept header explicitly lists text/html. Wildcards don't count, so API calls never qualify.
+func isNavigation(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	for _, ar := range parseAccept(r.Header.Get("Accept")) {
+		if ar.mediaType == "text/html" && ar.quality > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package matcher
+
+import (
+	"log"
+	"regexp"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+)
+
+// compileRegexes compiles every regex pattern used by the mocks. Invalid patterns are
+// logged once and cached as nil, so they never match without being recompiled per request.
+func compileRegexes(mocks []models.Mock) map[string]*regexp.Regexp {
+	cache := make(map[string]*regexp.Regexp)
+	for i := range mocks {
+		for _, pattern := range requestPatterns(&mocks[i].Request) {
+			if _, exists := cache[pattern]; exists {
+				continue
+			}
+			compiled, err := regexp.Compile(pattern)
+			if err != nil {
+				log.Printf("Warning: mock '%s' has an invalid regex %q (it will never match): %v\n", mocks[i].Name, pattern, err)
+			}
+			cache[pattern] = compiled
+		}
+	}
+	return cache
+}
+
+// requestPatterns lists the regex patterns of a request spec, including its negated spec
+func requestPatterns(req *models.Request) []string {
+	patterns := make([]string, 0)
+	add := func(pattern string, useRegex bool) {
+		if useRegex && pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	add(req.URI, req.IsRegex.URI)
+	add(req.Method, req.IsRegex.Method)
+	add(req.Host, req.IsRegex.Host)
+	if req.ClientCert != nil {
+		add(req.ClientCert.CommonName, req.ClientCert.Regex)
+		add(req.ClientCert.Issuer, req.ClientCert.Regex)
+	}
+	add(req.Body, req.IsRegex.Body && (bodyMatchMode(req) == "" || bodyMatchMode(req) == models.BodyMatchExact))
+	for key, value := range req.Headers {
+		add(key, req.IsRegex.Headers)
+		add(value, req.IsRegex.Headers)
+	}
+	for _, qm := range req.QueryParams {
+		add(qm.Key, req.IsRegex.QueryParams)
+		add(qm.Value, req.IsRegex.QueryParams)
+	}
+	for _, fm := range req.FormParams {
+		add(fm.Value, fm.Regex)
+	}
+	for _, mm := range req.Multipart {
+		add(mm.Value, mm.Regex)
+		add(mm.Filename, true)
+	}
+	for _, jm := range req.JSONPath {
+		add(jm.Value, jm.Regex)
+	}
+
+	if req.Not != nil {
+		patterns = append(patterns, requestPatterns(req.Not)...)
+	}
+	return patterns
+}
+
+// regex returns the compiled pattern, or nil if it's invalid. Patterns that weren't
+// precompiled are compiled and cached on first use.
+func (m *Matcher) regex(pattern string) *regexp.Regexp {
+	m.regexMu.RLock()
+	compiled, exists := m.regexes[pattern]
+	m.regexMu.RUnlock()
+	if exists {
+		return compiled
+	}
+
+	compiled, _ = regexp.Compile(pattern)
+
+	m.regexMu.Lock()
+	m.regexes[pattern] = compiled
+	m.regexMu.Unlock()
+	return compiled
+}
+
+// matchRegex reports whether the value matches the pattern. Invalid patterns never match.
+func (m *Matcher) matchRegex(pattern, value string) bool {
+	compiled := m.regex(pattern)
+	return compiled != nil && compiled.MatchString(value)
+}
@@ -0,0 +1,101 @@
+package matcher
+
+import (
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+)
+
+// StartedState is the state every scenario is in until a mock transitions it
+const StartedState = "Started"
+
+// DefaultStateScenario holds the state of stateful mocks that don't belong to any scenario
+const DefaultStateScenario = "default"
+
+// stateScenarios returns the scenarios whose state a mock depends on or changes
+func stateScenarios(mock *models.Mock) []string {
+	if len(mock.Scenarios) == 0 {
+		return []string{DefaultStateScenario}
+	}
+	return mock.Scenarios
+}
+
+// currentState returns the state of a scenario. Callers must hold statesMu.
+func (m *Matcher) currentState(scenario string) string {
+	if state, exists := m.states[scenario]; exists {
+		return state
+	}
+	return StartedState
+}
+
+// inRequiredState checks if all the mock's scenarios are in its required state
+func (m *Matcher) inRequiredState(mock *models.Mock) bool {
+	if mock.RequiredState == "" {
+		return true
+	}
+
+	m.statesMu.Lock()
+	defer m.statesMu.Unlock()
+	return m.inRequiredStateLocked(mock)
+}
+
+func (m *Matcher) inRequiredStateLocked(mock *models.Mock) bool {
+	if mock.RequiredState == "" {
+		return true
+	}
+	for _, scenario := range stateScenarios(mock) {
+		if m.currentState(scenario) != mock.RequiredState {
+			return false
+		}
+	}
+	return true
+}
+
+// transitionState moves the mock's scenarios to its new state. The required state is checked
+// again under the same lock, so of two concurrent requests only one can take a transition;
+// it returns false if the state changed since the mock was matched.
+func (m *Matcher) transitionState(mock *models.Mock) bool {
+	if mock.RequiredState == "" && mock.NewState == "" {
+		return true
+	}
+
+	m.statesMu.Lock()
+	defer m.statesMu.Unlock()
+
+	if !m.inRequiredStateLocked(mock) {
+		return false
+	}
+	if mock.NewState != "" {
+		for _, scenario := range stateScenarios(mock) {
+			m.states[scenario] = mock.NewState
+		}
+	}
+	return true
+}
+
+// ScenarioStates returns the current state of every scenario used by stateful mocks
+func (m *Matcher) ScenarioStates() map[string]string {
+	m.statesMu.Lock()
+	defer m.statesMu.Unlock()
+
+	states := make(map[string]string)
+	for i := range m.mocks {
+		mock := &m.mocks[i]
+		if mock.RequiredState == "" && mock.NewState == "" {
+			continue
+		}
+		for _, scenario := range stateScenarios(mock) {
+			states[scenario] = m.currentState(scenario)
+		}
+	}
+	// Keep scenarios transitioned by mocks that were removed since
+	for scenario, state := range m.states {
+		states[scenario] = state
+	}
+	return states
+}
+
+// ResetScenarioStates moves every scenario back to the started state
+func (m *Matcher) ResetScenarioStates() {
+	m.statesMu.Lock()
+	defer m.statesMu.Unlock()
+	m.states = make(map[string]string)
+}
@@ -0,0 +1,31 @@
+package matcher
+
+// recordHit counts a match of the mock
+func (m *Matcher) recordHit(name string) {
+	m.hitsMu.Lock()
+	m.hits[name]++
+	m.hitsMu.Unlock()
+}
+
+// MockStats returns how many times each mock matched, keyed by mock name. Every loaded
+// mock is listed, with zero if it never matched. Counts survive mock reloads.
+func (m *Matcher) MockStats() map[string]int {
+	m.hitsMu.Lock()
+	defer m.hitsMu.Unlock()
+
+	stats := make(map[string]int, len(m.mocks)+len(m.hits))
+	for i := range m.mocks {
+		stats[m.mocks[i].Name] = 0
+	}
+	for name, count := range m.hits {
+		stats[name] = count
+	}
+	return stats
+}
+
+// ResetMockStats zeroes the match counts of all mocks
+func (m *Matcher) ResetMockStats() {
+	m.hitsMu.Lock()
+	defer m.hitsMu.Unlock()
+	m.hits = make(map[string]int)
+}
@@ -1,62 +1,207 @@
 package models
 
+import "gopkg.in/yaml.v3"
+
 // MockSpec represents a complete mock specification loaded from a YAML file
 type MockSpec struct {
-	Mocks []Mock `yaml:"mocks"`
+	Mocks     []Mock              `yaml:"mocks"`
+	Responses map[string]Response `yaml:"responses"` // Named responses that mocks in this file can reference via response_ref
 }
 
 // Mock represents a single mock endpoint definition
 type Mock struct {
 	Name        string            `yaml:"name"`
 	Scenarios   []string          `yaml:"scenarios"`  // Scenarios this mock belongs to (empty means all scenarios)
+	RequiredState string          `yaml:"required_state"` // State the mock's scenarios must be in to match (empty means any state)
+	NewState    string            `yaml:"new_state"`  // State the mock's scenarios transition to when it matches
 	Protocol    string            `yaml:"protocol"`   // Protocol type: "http" (default), "websocket", "sse"
 	Request     Request           `yaml:"request"`
 	Response    Response          `yaml:"response"`
+	ResponseRef string            `yaml:"response_ref"` // Name of a response defined in the file's responses section
 	WebSocket   *WebSocketConfig  `yaml:"websocket"`  // WebSocket-specific configuration
 	SSE         *SSEConfig        `yaml:"sse"`        // Server-Sent Events configuration
 	Priority    int               `yaml:"priority"`   // Higher priority mocks are matched first
+	Fallback    bool              `yaml:"fallback"`   // Only match page navigations (GET/HEAD accepting text/html) that no other mock matched, e.g. a SPA's index.html
+	LogLevel    string            `yaml:"log_level"`  // Per-request logging: "silent", "info" (default) or "debug"
+	CORS        *CORSConfig       `yaml:"cors"`       // CORS headers of this mock, overriding the server-wide configuration
+}
+
+// CORSConfig defines the CORS headers of a mock's responses and preflights
+type CORSConfig struct {
+	Origins     string `yaml:"origins"`     // Comma-separated allowed origins, or "*" (empty = server-wide setting)
+	Methods     string `yaml:"methods"`     // Allowed methods (empty = server-wide setting)
+	Headers     string `yaml:"headers"`     // Allowed request headers (empty = server-wide setting)
+	Credentials bool   `yaml:"credentials"` // Send Access-Control-Allow-Credentials: true
+	MaxAge      int    `yaml:"max_age"`     // Seconds browsers may cache preflights (0 = 86400)
 }
 
+// Body match modes of Request.BodyMatchMode
+const (
+	BodyMatchExact           = "exact"           // Compare the raw text (or regex)
+	BodyMatchJSON            = "json"            // Compare as JSON, ignoring whitespace and object key order
+	BodyMatchJSONIgnoreOrder = "jsonIgnoreOrder" // Compare as JSON, also ignoring the order of array elements
+)
+
 // Request defines the matching criteria for incoming requests
 type Request struct {
 	URI            string                 `yaml:"uri"`             // Can be exact match or regex
 	Method         string                 `yaml:"method"`          // Can be exact match or regex
+	Host           string                 `yaml:"host"`            // Hostname of the Host header without the port, exact or regex (empty = any host)
 	Headers        map[string]string      `yaml:"headers"`         // Can be exact match or regex (both key and value)
+	QueryParams    []QueryParamMatcher    `yaml:"query_params"`    // Query string parameters that must be present, exact or regex (both key and value)
 	Body           string                 `yaml:"body"`            // Can be exact match or regex
+	CanonicalJSON  bool                   `yaml:"canonical_json"`  // Compare the body as JSON, ignoring whitespace and key order (same as body_match_mode "json")
+	BodyMatchMode  string                 `yaml:"body_match_mode"` // How the body is compared: "exact" (default), "json" or "jsonIgnoreOrder"
+	FormParams     []FormParamMatcher     `yaml:"form_params"`     // Fields of an application/x-www-form-urlencoded body
+	Multipart      []MultipartMatcher     `yaml:"multipart"`       // Parts of a multipart/form-data body (fields and file uploads)
 	IsRegex        RegexConfig            `yaml:"regex"`           // Specify which fields use regex
 	JSONPath       []JSONPathMatcher      `yaml:"json_path"`       // GJSON path matchers for JSON bodies
 	JavaScript     string                 `yaml:"javascript"`      // JavaScript code for custom matching logic
+	CEL            string                 `yaml:"cel"`             // CEL expression returning a bool, evaluated against the request
 	ValidateSchema map[string]interface{} `yaml:"validate_schema"` // JSON Schema for request body validation
+	JWT            *JWTMatcher            `yaml:"jwt"`             // Require a valid Bearer JWT (invalid tokens get a 401)
+	Auth           *AuthConfig            `yaml:"auth"`            // Require Basic or Bearer credentials (missing or wrong ones get a 401)
+	ClientCert     *ClientCertMatcher     `yaml:"client_cert"`     // Require a TLS client certificate (requests without one don't match)
+	Produces       []string               `yaml:"produces"`        // Media types the mock can return; the Accept header must allow one (else 406)
+	Not            *Request               `yaml:"not"`             // The mock doesn't match requests matching this spec (javascript, jwt, auth and produces are ignored)
+}
+
+// ClientCertMatcher matches the TLS client certificate of the request (mTLS)
+type ClientCertMatcher struct {
+	CommonName string `yaml:"common_name"` // Common name of the certificate subject (empty = any)
+	Issuer     string `yaml:"issuer"`      // Common name of the certificate issuer (empty = any)
+	Regex      bool   `yaml:"regex"`       // Treat common_name and issuer as regex patterns
+}
+
+// AuthConfig defines the credentials a mock requires. Requests for the mock without
+// them get a 401 with a WWW-Authenticate challenge, whatever their body.
+type AuthConfig struct {
+	Type     string `yaml:"type"`     // "basic" or "bearer"
+	Username string `yaml:"username"` // Basic auth user name
+	Password string `yaml:"password"` // Basic auth password
+	Token    string `yaml:"token"`    // Expected Bearer token
+	Realm    string `yaml:"realm"`    // Realm of the WWW-Authenticate challenge (default "pmp-mock-http")
+}
+
+// JWTMatcher defines how the Bearer token of a matched request is validated
+type JWTMatcher struct {
+	PublicKey string            `yaml:"public_key"` // PEM-encoded RSA or ECDSA public key
+	JWKSURL   string            `yaml:"jwks_url"`   // URL of a JWKS to fetch the signing keys from
+	Secret    string            `yaml:"secret"`     // Shared secret for HMAC-signed tokens
+	Issuer    string            `yaml:"issuer"`     // Required "iss" claim (optional)
+	Audience  string            `yaml:"audience"`   // Required "aud" claim (optional)
+	Claims    map[string]string `yaml:"claims"`     // Required claim values (array claims must contain the value)
 }
 
 // RegexConfig specifies which request fields should use regex matching
 type RegexConfig struct {
-	URI     bool `yaml:"uri"`
-	Method  bool `yaml:"method"`
-	Headers bool `yaml:"headers"` // If true, both header names and values are treated as regex
-	Body    bool `yaml:"body"`
+	URI         bool `yaml:"uri"`
+	Method      bool `yaml:"method"`
+	Host        bool `yaml:"host"`
+	Headers     bool `yaml:"headers"`      // If true, both header names and values are treated as regex
+	QueryParams bool `yaml:"query_params"` // If true, both query parameter names and values are treated as regex
+	Body        bool `yaml:"body"`
 }
 
 // JSONPathMatcher defines a GJSON path-based matcher for JSON bodies
 type JSONPathMatcher struct {
-	Path  string `yaml:"path"`  // GJSON path expression
-	Value string `yaml:"value"` // Expected value (supports exact match or regex)
+	Path     string `yaml:"path"`     // GJSON path expression
+	Value    string `yaml:"value"`    // Expected value (supports exact match or regex)
+	Regex    bool   `yaml:"regex"`    // If true, value is treated as regex
+	Operator string `yaml:"operator"` // Comparison: "eq", "ne", "gt", "gte", "lt" or "lte" (default: exact or regex match)
+}
+
+// QueryParamMatcher defines a query string parameter the request must have
+type QueryParamMatcher struct {
+	Key   string `yaml:"key"`   // Parameter name
+	Value string `yaml:"value"` // Expected value (empty = the parameter only has to be present)
+}
+
+// MultipartMatcher defines a part a multipart/form-data body must have
+type MultipartMatcher struct {
+	Name        string `yaml:"name"`         // Form field name of the part
+	Value       string `yaml:"value"`        // Expected content of the part (empty = any)
+	Regex       bool   `yaml:"regex"`        // If true, value is treated as regex
+	Filename    string `yaml:"filename"`     // Regex the uploaded file name must match (empty = any; set to require a file)
+	ContentType string `yaml:"content_type"` // Expected media type of the part, e.g. image/png (empty = any)
+}
+
+// FormParamMatcher defines a field an application/x-www-form-urlencoded body must have
+type FormParamMatcher struct {
+	Key   string `yaml:"key"`   // Field name
+	Value string `yaml:"value"` // Expected value (empty = the field only has to be present)
 	Regex bool   `yaml:"regex"` // If true, value is treated as regex
 }
 
 // Response defines what to return when a request matches
 type Response struct {
 	StatusCode      int               `yaml:"status_code"`
+	StatusCodeTemplate string         `yaml:"status_code_template"` // Go template rendered to the status code, overriding status_code (falls back to it on errors)
 	Headers         map[string]string `yaml:"headers"`
+	HeadersMulti    map[string][]string `yaml:"headers_multi"` // Headers sent once per value (e.g. several Link headers), added after headers
 	Body            string            `yaml:"body"`
+	Cookies         []ResponseCookie  `yaml:"cookies"`         // Cookies to set, one Set-Cookie header each (values are templates if template is enabled)
+	BodyFile        string            `yaml:"body_file"`       // File served as the body instead of body, with Range support (relative to the mock file)
 	Delay           int               `yaml:"delay"`           // Response delay in milliseconds (fixed)
 	Template        bool              `yaml:"template"`        // If true, body is a Go template
 	HeaderTemplates bool              `yaml:"header_templates"` // If true, headers support Go templates
+	TemplateDelims  [2]string         `yaml:"template_delims"` // Alternative template delimiters, e.g. ["<<", ">>"] (default "{{" and "}}")
 	Callback        *Callback         `yaml:"callback"`        // Optional callback to trigger
 	Sequence        []ResponseItem    `yaml:"sequence"`        // Sequential responses
 	SequenceMode    string            `yaml:"sequence_mode"`   // "cycle" or "once" (default: cycle)
+	SequenceScope   string            `yaml:"sequence_scope"`  // "global" or "client" (default: global)
+	SequenceClientKey string          `yaml:"sequence_client_key"` // Client identifier for client scope: "ip", "header:<name>" or "cookie:<name>" (default: ip)
+	SequenceAdvanceOn *SequenceAdvanceOn `yaml:"sequence_advance_on"` // Only advance the sequence under this condition (default: on every call)
 	Chaos           *ChaosConfig      `yaml:"chaos"`           // Chaos engineering configuration
 	Latency         *LatencyConfig    `yaml:"latency"`         // Advanced latency simulation
+	Probabilistic   []WeightedResponse `yaml:"probabilistic"`   // Weighted random responses (one is picked per request)
+	ProbabilisticSeed int64           `yaml:"probabilistic_seed"` // Seed for deterministic weighted selection (0 = random)
+	AfterCount      []CountResponse   `yaml:"after_count"`     // Responses returned once the mock has been called more than a number of times
+	OmitContentLength bool            `yaml:"omit_content_length"` // Send the body chunked without a Content-Length header
+	FakeContentLength int             `yaml:"fake_content_length"` // Send this (wrong) Content-Length and close the connection (0 = disabled, HTTP/1.x only)
+	AllowHeadBody   bool              `yaml:"allow_head_body"` // Send the body on HEAD requests too (protocol violation, HTTP/1.x only)
+	SimulateGatewayTimeout int       `yaml:"simulate_gateway_timeout"` // Gateway timeout in ms: if the (simulated) upstream delay exceeds it, or there is no delay, wait this long and return 504
+	PrettyJSON      bool              `yaml:"pretty_json"` // Indent the body if it is valid JSON
+	ETag            string            `yaml:"etag"`        // ETag header value, or "auto" for a hash of the body; a matching If-None-Match gets a 304
+	Expect100       *Expect100Config  `yaml:"expect_100"`  // How requests sent with "Expect: 100-continue" are answered
+	Stream          *StreamConfig     `yaml:"stream"`      // Write the body in chunks with a delay between them
+	ThrottleBytesPerSec int           `yaml:"throttle_bytes_per_sec"` // Cap the body transfer rate (0 = unlimited)
+}
+
+// ResponseCookie defines a cookie set by a response
+type ResponseCookie struct {
+	Name     string `yaml:"name"`
+	Value    string `yaml:"value"`
+	Path     string `yaml:"path"`
+	Domain   string `yaml:"domain"`
+	MaxAge   int    `yaml:"max_age"`   // Seconds until the cookie expires (0 = session cookie, negative = delete it)
+	Secure   bool   `yaml:"secure"`
+	HTTPOnly bool   `yaml:"http_only"`
+	SameSite string `yaml:"same_site"` // "lax", "strict" or "none" (default: not set)
+}
+
+// StreamConfig defines how a response body is streamed in chunks
+type StreamConfig struct {
+	ChunkSize    int `yaml:"chunk_size"`     // Bytes per chunk (default 1024)
+	ChunkDelayMs int `yaml:"chunk_delay_ms"` // Milliseconds to wait between chunks
+}
+
+// Expect100Config defines how the server answers requests with an "Expect: 100-continue" header
+type Expect100Config struct {
+	Action string `yaml:"action"` // "continue" (send 100 Continue, default), "withhold" (answer without reading the body) or "reject" (417)
+	Delay  int    `yaml:"delay"`  // Milliseconds to wait before sending 100 Continue
+}
+
+// WeightedResponse is a response picked at random according to its probability
+type WeightedResponse struct {
+	Probability float64  `yaml:"probability"` // Relative weight of this response (e.g. 0.9 for 90%)
+	Response    Response `yaml:"response"`    // Response returned when this entry is picked
+}
+
+// CountResponse is a response returned once a mock has been called more than After times
+type CountResponse struct {
+	After    int      `yaml:"after"`    // Number of calls after which this response is returned (e.g. 100 = from the 101st call on)
+	Response Response `yaml:"response"` // Response returned past the threshold
 }
 
 // ChaosConfig defines chaos engineering behavior
@@ -66,6 +211,7 @@ type ChaosConfig struct {
 	ErrorCodes  []int   `yaml:"error_codes"`  // Status codes to randomly return on failure
 	LatencyMin  int     `yaml:"latency_min"`  // Minimum latency to inject (ms)
 	LatencyMax  int     `yaml:"latency_max"`  // Maximum latency to inject (ms)
+	ConnectionReset float64 `yaml:"connection_reset"` // Probability of closing the connection without a response (0.0 to 1.0, HTTP/1.x only)
 }
 
 // LatencyConfig defines advanced latency simulation
@@ -78,6 +224,13 @@ type LatencyConfig struct {
 	P99  int    `yaml:"p99"`  // 99th percentile latency (ms)
 }
 
+// SequenceAdvanceOn defines when a sequence moves on to its next response
+type SequenceAdvanceOn struct {
+	Header string `yaml:"header"` // Only requests with this header (e.g. a retry counter) advance the sequence, before the response is selected
+	Value  string `yaml:"value"`  // Required header value (empty = any value)
+	Every  int    `yaml:"every"`  // Advance only every N counted calls, so each response is returned N times
+}
+
 // ResponseItem represents a single response in a sequence
 type ResponseItem struct {
 	StatusCode      int               `yaml:"status_code"`
@@ -118,14 +271,51 @@ type WebSocketConfig struct {
 	OnDisconnect   string              `yaml:"on_disconnect"`    // Action on disconnect
 	Template       bool                `yaml:"template"`         // Enable templates in messages
 	MaxConnections int                 `yaml:"max_connections"`  // Max concurrent connections (0 = unlimited)
+	AllowedOrigins []string            `yaml:"allowed_origins"`  // Origins allowed to connect (empty = allow all, "*" = any)
+	EnableCompression bool             `yaml:"enable_compression"` // Negotiate permessage-deflate with clients that support it
 }
 
 // WebSocketMessage represents a message in a WebSocket sequence
 type WebSocketMessage struct {
-	Type     string `yaml:"type"`     // "text" or "binary"
-	Data     string `yaml:"data"`     // Message data
-	Delay    int    `yaml:"delay"`    // Delay before sending this message (ms)
-	Template bool   `yaml:"template"` // Enable template in this message
+	Type     string      `yaml:"type"`     // "text", "binary", "auto" (text if valid UTF-8, binary otherwise) or "json"
+	Data     string      `yaml:"data"`     // Message data
+	DataJSON interface{} `yaml:"-"`        // Structured data for "json" messages (set when data is a YAML object or list)
+	Delay    int         `yaml:"delay"`    // Delay before sending this message (ms)
+	Template bool        `yaml:"template"` // Enable template in this message
+}
+
+// UnmarshalYAML allows data to be a plain string or a YAML object/list, which is
+// kept in DataJSON and serialized to JSON when the message is sent
+func (m *WebSocketMessage) UnmarshalYAML(value *yaml.Node) error {
+	type plain WebSocketMessage
+
+	node := value
+	var dataNode *yaml.Node
+	if value.Kind == yaml.MappingNode {
+		node = &yaml.Node{Kind: yaml.MappingNode, Tag: value.Tag, Line: value.Line, Column: value.Column}
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			key, val := value.Content[i], value.Content[i+1]
+			if key.Value == "data" && (val.Kind == yaml.MappingNode || val.Kind == yaml.SequenceNode) {
+				dataNode = val
+				continue
+			}
+			node.Content = append(node.Content, key, val)
+		}
+	}
+
+	var msg plain
+	if err := node.Decode(&msg); err != nil {
+		return err
+	}
+
+	if dataNode != nil {
+		if err := dataNode.Decode(&msg.DataJSON); err != nil {
+			return err
+		}
+	}
+
+	*m = WebSocketMessage(msg)
+	return nil
 }
 
 // SSEConfig defines Server-Sent Events behavior
@@ -138,6 +328,7 @@ type SSEConfig struct {
 	CloseAfter   int        `yaml:"close_after"`   // Close after N events (0 = keep open)
 	Template     bool       `yaml:"template"`      // Enable templates in event data
 	JavaScript   string     `yaml:"javascript"`    // JavaScript for dynamic event generation
+	EnableCompression bool  `yaml:"enable_compression"` // Gzip the stream for clients sending Accept-Encoding: gzip
 }
 
 // SSEEvent represents a single Server-Sent Event
@@ -159,3 +159,46 @@ mocks:
 		t.Errorf("Expected default delay 0, got %d", mock.Response.Delay)
 	}
 }
+
+func TestWebSocketMessageUnmarshal(t *testing.T) {
+	yamlData := `
+messages:
+  - type: "text"
+    data: "hello"
+    delay: 10
+  - type: "json"
+    data:
+      id: 1
+      tags: ["a", "b"]
+    template: true
+`
+
+	var config WebSocketConfig
+	if err := yaml.Unmarshal([]byte(yamlData), &config); err != nil {
+		t.Fatalf("Failed to unmarshal YAML: %v", err)
+	}
+
+	if len(config.Messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(config.Messages))
+	}
+
+	text := config.Messages[0]
+	if text.Data != "hello" || text.DataJSON != nil || text.Delay != 10 {
+		t.Errorf("Unexpected text message: %+v", text)
+	}
+
+	jsonMsg := config.Messages[1]
+	if jsonMsg.Type != "json" || !jsonMsg.Template || jsonMsg.Data != "" {
+		t.Errorf("Unexpected json message: %+v", jsonMsg)
+	}
+	data, ok := jsonMsg.DataJSON.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected object data, got %T", jsonMsg.DataJSON)
+	}
+	if data["id"] != 1 {
+		t.Errorf("Expected id 1, got %v", data["id"])
+	}
+	if tags, ok := data["tags"].([]interface{}); !ok || len(tags) != 2 {
+		t.Errorf("Expected 2 tags, got %v", data["tags"])
+	}
+}
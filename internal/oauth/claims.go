@@ -0,0 +1,54 @@
+package oauth
+
+import "sort"
+
+// reservedClaims are set by the provider and can't be overridden by custom claims
+var reservedClaims = map[string]bool{
+	"iss":       true,
+	"sub":       true,
+	"aud":       true,
+	"exp":       true,
+	"nbf":       true,
+	"iat":       true,
+	"jti":       true,
+	"scope":     true,
+	"client_id": true,
+}
+
+// addCustomClaims merges the claims of the client and then those of the user into token
+// claims, so user claims win over client claims. Reserved claims are never overridden.
+func (p *OAuth2Provider) addCustomClaims(claims map[string]interface{}, clientID, userID string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if client, exists := p.clients[clientID]; exists {
+		mergeClaims(claims, client.Claims)
+	}
+	if user := p.userBySubject(userID); user != nil {
+		mergeClaims(claims, user.Claims)
+	}
+}
+
+// mergeClaims copies the custom claims into the claims, skipping reserved claims
+func mergeClaims(claims, custom map[string]interface{}) {
+	for key, value := range custom {
+		if !reservedClaims[key] {
+			claims[key] = value
+		}
+	}
+}
+
+// reservedClaim returns the first reserved claim (in alphabetical order) of the custom claims, or ""
+func reservedClaim(custom map[string]interface{}) string {
+	keys := make([]string, 0, len(custom))
+	for key := range custom {
+		if reservedClaims[key] {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	return keys[0]
+}
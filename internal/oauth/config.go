@@ -0,0 +1,190 @@
+package oauth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config defines the clients and users of the provider, usually loaded from a YAML file
+type Config struct {
+	Issuer  string   `yaml:"issuer"`  // Issuer of the tokens (empty = keep the provider's issuer)
+	Clients []Client `yaml:"clients"` // Replace the default client if set
+	Users   []User   `yaml:"users"`   // Replace the default user if set
+}
+
+// User represents a resource owner that can sign in
+type User struct {
+	Username string                 `yaml:"username"`
+	Password string                 `yaml:"password"`
+	Subject  string                 `yaml:"subject"` // sub claim of the user's tokens (default: "user-" + username)
+	Claims   map[string]interface{} `yaml:"claims"`  // Claims of the user's tokens and the userinfo endpoint, e.g. name, email or roles
+}
+
+// LoadConfig loads a provider configuration from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	return &config, nil
+}
+
+// ApplyConfig sets the issuer, clients and users of the configuration. Configured clients
+// and users replace the defaults; if the configuration has none, the defaults are kept.
+func (p *OAuth2Provider) ApplyConfig(config *Config) error {
+	clientIDs := make(map[string]bool)
+	for i, client := range config.Clients {
+		if client.ClientID == "" {
+			return fmt.Errorf("client %d has no client_id", i)
+		}
+		if clientIDs[client.ClientID] {
+			return fmt.Errorf("duplicate client %q", client.ClientID)
+		}
+		if claim := reservedClaim(client.Claims); claim != "" {
+			return fmt.Errorf("client %q overrides the reserved claim %q", client.ClientID, claim)
+		}
+		clientIDs[client.ClientID] = true
+	}
+	usernames := make(map[string]bool)
+	for i, user := range config.Users {
+		if user.Username == "" {
+			return fmt.Errorf("user %d has no username", i)
+		}
+		if usernames[user.Username] {
+			return fmt.Errorf("duplicate user %q", user.Username)
+		}
+		if claim := reservedClaim(user.Claims); claim != "" {
+			return fmt.Errorf("user %q overrides the reserved claim %q", user.Username, claim)
+		}
+		usernames[user.Username] = true
+	}
+
+	p.mu.Lock()
+	if config.Issuer != "" {
+		p.issuer = config.Issuer
+	}
+	if len(config.Clients) > 0 {
+		p.clients = make(map[string]*Client)
+	}
+	if len(config.Users) > 0 {
+		p.users = make(map[string]*User)
+		p.userOrder = nil
+	}
+	p.mu.Unlock()
+
+	for i := range config.Clients {
+		client := config.Clients[i]
+		p.RegisterClient(&client)
+	}
+	for i := range config.Users {
+		user := config.Users[i]
+		p.RegisterUser(&user)
+	}
+	return nil
+}
+
+// RegisterUser registers a user, replacing any user with the same username
+func (p *OAuth2Provider) RegisterUser(user *User) {
+	if user.Subject == "" {
+		user.Subject = "user-" + user.Username
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.users[user.Username]; !exists {
+		p.userOrder = append(p.userOrder, user.Username)
+	}
+	p.users[user.Username] = user
+	log.Printf("OAuth2: Registered user %s\n", user.Username)
+}
+
+// signInUser returns the user with the given username, or the first registered user if it's empty
+func (p *OAuth2Provider) signInUser(username string) *User {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if username == "" {
+		if len(p.userOrder) == 0 {
+			return nil
+		}
+		username = p.userOrder[0]
+	}
+	return p.users[username]
+}
+
+// authenticateUser returns the user with the given credentials, or nil if they're invalid
+func (p *OAuth2Provider) authenticateUser(username, password string) *User {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	user, exists := p.users[username]
+	if !exists || username == "" || subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) != 1 {
+		return nil
+	}
+	return user
+}
+
+// userClaims returns the claims of the user with the given subject, including sub.
+// Subjects without a registered user (e.g. of client credentials tokens) only get sub.
+func (p *OAuth2Provider) userClaims(subject string) map[string]interface{} {
+	claims := map[string]interface{}{"sub": subject}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if user := p.userBySubject(subject); user != nil {
+		mergeClaims(claims, user.Claims)
+	}
+	return claims
+}
+
+// userBySubject returns the user with the given subject, or nil. The caller must hold p.mu.
+func (p *OAuth2Provider) userBySubject(subject string) *User {
+	if subject == "" {
+		return nil
+	}
+	for _, user := range p.users {
+		if user.Subject == subject {
+			return user
+		}
+	}
+	return nil
+}
+
+// clientAllowsScope reports whether the client may request the space-separated scopes
+func (p *OAuth2Provider) clientAllowsScope(clientID, scope string) bool {
+	p.mu.RLock()
+	client, exists := p.clients[clientID]
+	p.mu.RUnlock()
+	return exists && client.allowsScope(scope)
+}
+
+// allowsScope reports whether every requested scope is one of the client's scopes.
+// Clients without scopes may request any scope.
+func (c *Client) allowsScope(scope string) bool {
+	if len(c.Scopes) == 0 {
+		return true
+	}
+	for _, requested := range strings.Fields(scope) {
+		allowed := false
+		for _, s := range c.Scopes {
+			if s == requested {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
@@ -3,6 +3,8 @@ package oauth
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -24,6 +26,8 @@ type OAuth2Provider struct {
 	authCodes        map[string]*AuthorizationCode
 	tokens           map[string]*TokenInfo
 	clients          map[string]*Client
+	users            map[string]*User // Key: username
+	userOrder        []string         // Usernames in registration order; the first one signs in by default
 	mu               sync.RWMutex
 	tokenExpiry      time.Duration
 	refreshExpiry    time.Duration
@@ -31,10 +35,11 @@ type OAuth2Provider struct {
 
 // Client represents an OAuth2 client application
 type Client struct {
-	ClientID     string
-	ClientSecret string
-	RedirectURIs []string
-	Scopes       []string
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURIs []string `yaml:"redirect_uris"`
+	Scopes       []string `yaml:"scopes"` // Scopes the client may request (empty = any)
+	Claims       map[string]interface{} `yaml:"claims"` // Extra claims of the client's access and ID tokens (reserved claims are ignored)
 }
 
 // AuthorizationCode represents an authorization code
@@ -84,6 +89,7 @@ func NewOAuth2Provider(issuer string) (*OAuth2Provider, error) {
 		authCodes:     make(map[string]*AuthorizationCode),
 		tokens:        make(map[string]*TokenInfo),
 		clients:       make(map[string]*Client),
+		users:         make(map[string]*User),
 		tokenExpiry:   time.Hour,        // 1 hour
 		refreshExpiry: time.Hour * 24 * 30, // 30 days
 	}
@@ -96,6 +102,14 @@ func NewOAuth2Provider(issuer string) (*OAuth2Provider, error) {
 		Scopes:       []string{"openid", "profile", "email"},
 	})
 
+	// Register a default user
+	provider.RegisterUser(&User{
+		Username: "user",
+		Password: "password",
+		Subject:  "mock-user-id",
+		Claims:   map[string]interface{}{"name": "Mock User", "email": "user@example.com", "email_verified": true},
+	})
+
 	return provider, nil
 }
 
@@ -142,6 +156,23 @@ func (p *OAuth2Provider) HandleAuthorize(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if codeChallenge != "" && codeChallengeMethod != "" && codeChallengeMethod != "plain" && codeChallengeMethod != "S256" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	if !client.allowsScope(scope) {
+		http.Error(w, "invalid_scope", http.StatusBadRequest)
+		return
+	}
+
+	// There is no login page: login_hint picks the user, the first registered user signs in otherwise
+	user := p.signInUser(query.Get("login_hint"))
+	if user == nil {
+		http.Error(w, "access_denied", http.StatusBadRequest)
+		return
+	}
+
 	switch responseType {
 	case "code":
 		// Authorization Code Flow
@@ -154,7 +185,7 @@ func (p *OAuth2Provider) HandleAuthorize(w http.ResponseWriter, r *http.Request)
 			CodeChallenge: codeChallenge,
 			CodeChallengeMethod: codeChallengeMethod,
 			ExpiresAt:    time.Now().Add(10 * time.Minute),
-			UserID:       "mock-user-id",
+			UserID:       user.Subject,
 		}
 
 		p.mu.Lock()
@@ -174,7 +205,7 @@ func (p *OAuth2Provider) HandleAuthorize(w http.ResponseWriter, r *http.Request)
 
 	case "token":
 		// Implicit Flow
-		accessToken := p.generateAccessToken(clientID, scope, "mock-user-id")
+		accessToken := p.generateAccessToken(clientID, scope, user.Subject)
 
 		// Redirect with access token in fragment
 		redirectURL, _ := url.Parse(redirectURI)
@@ -281,7 +312,7 @@ func (p *OAuth2Provider) handleAuthorizationCodeGrant(w http.ResponseWriter, r *
 	p.tokens[accessToken] = tokenInfo
 	p.mu.Unlock()
 
-	p.sendTokenResponse(w, accessToken, refreshToken, authCode.Scope, authCode.UserID)
+	p.sendTokenResponse(w, clientID, accessToken, refreshToken, authCode.Scope, authCode.UserID)
 }
 
 // handleClientCredentialsGrant handles client credentials grant
@@ -295,6 +326,10 @@ func (p *OAuth2Provider) handleClientCredentialsGrant(w http.ResponseWriter, r *
 		p.sendError(w, "invalid_client", http.StatusUnauthorized)
 		return
 	}
+	if !p.clientAllowsScope(clientID, scope) {
+		p.sendError(w, "invalid_scope", http.StatusBadRequest)
+		return
+	}
 
 	// Generate access token (no refresh token for client credentials)
 	accessToken := p.generateAccessToken(clientID, scope, "")
@@ -310,7 +345,7 @@ func (p *OAuth2Provider) handleClientCredentialsGrant(w http.ResponseWriter, r *
 	p.tokens[accessToken] = tokenInfo
 	p.mu.Unlock()
 
-	p.sendTokenResponse(w, accessToken, "", scope, "")
+	p.sendTokenResponse(w, clientID, accessToken, "", scope, "")
 }
 
 // handleRefreshTokenGrant handles refresh token grant
@@ -357,7 +392,7 @@ func (p *OAuth2Provider) handleRefreshTokenGrant(w http.ResponseWriter, r *http.
 	p.tokens[newAccessToken] = newTokenInfo
 	p.mu.Unlock()
 
-	p.sendTokenResponse(w, newAccessToken, refreshToken, tokenInfo.Scope, tokenInfo.UserID)
+	p.sendTokenResponse(w, clientID, newAccessToken, refreshToken, tokenInfo.Scope, tokenInfo.UserID)
 }
 
 // handlePasswordGrant handles resource owner password credentials grant
@@ -374,13 +409,19 @@ func (p *OAuth2Provider) handlePasswordGrant(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Mock password validation (always succeeds for demo)
-	if username == "" || password == "" {
+	if !p.clientAllowsScope(clientID, scope) {
+		p.sendError(w, "invalid_scope", http.StatusBadRequest)
+		return
+	}
+
+	// Validate the user's credentials
+	user := p.authenticateUser(username, password)
+	if user == nil {
 		p.sendError(w, "invalid_grant", http.StatusBadRequest)
 		return
 	}
 
-	userID := "user-" + username
+	userID := user.Subject
 
 	// Generate tokens
 	accessToken := p.generateAccessToken(clientID, scope, userID)
@@ -399,7 +440,7 @@ func (p *OAuth2Provider) handlePasswordGrant(w http.ResponseWriter, r *http.Requ
 	p.tokens[accessToken] = tokenInfo
 	p.mu.Unlock()
 
-	p.sendTokenResponse(w, accessToken, refreshToken, scope, userID)
+	p.sendTokenResponse(w, clientID, accessToken, refreshToken, scope, userID)
 }
 
 // HandleUserInfo handles the userinfo endpoint
@@ -423,13 +464,8 @@ func (p *OAuth2Provider) HandleUserInfo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Return user info
-	userInfo := map[string]interface{}{
-		"sub":   tokenInfo.UserID,
-		"name":  "Mock User",
-		"email": "user@example.com",
-		"email_verified": true,
-	}
+	// Return the claims of the user
+	userInfo := p.userClaims(tokenInfo.UserID)
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(userInfo); err != nil {
@@ -471,6 +507,7 @@ func (p *OAuth2Provider) generateAccessToken(clientID, scope, userID string) str
 		"scope":     scope,
 		"client_id": clientID,
 	}
+	p.addCustomClaims(claims, clientID, userID)
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
 	tokenString, err := token.SignedString(p.privateKey)
@@ -513,19 +550,27 @@ func (p *OAuth2Provider) validateClient(clientID, clientSecret string) bool {
 	return client.ClientSecret == clientSecret
 }
 
-// validatePKCE validates PKCE code verifier
+// validatePKCE validates a PKCE code verifier against the code challenge (RFC 7636).
+// An empty method means plain; unknown methods are rejected.
 func (p *OAuth2Provider) validatePKCE(verifier, challenge, method string) bool {
-	if method == "S256" {
-		// SHA-256 validation would go here
-		// For simplicity, we'll just check if verifier is provided
-		return verifier != ""
+	if verifier == "" {
+		return false
+	}
+
+	switch method {
+	case "S256":
+		sum := sha256.Sum256([]byte(verifier))
+		computed := base64.RawURLEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+	case "plain", "":
+		return subtle.ConstantTimeCompare([]byte(verifier), []byte(challenge)) == 1
+	default:
+		return false
 	}
-	// Plain method
-	return verifier == challenge
 }
 
 // sendTokenResponse sends a token response
-func (p *OAuth2Provider) sendTokenResponse(w http.ResponseWriter, accessToken, refreshToken, scope, userID string) {
+func (p *OAuth2Provider) sendTokenResponse(w http.ResponseWriter, clientID, accessToken, refreshToken, scope, userID string) {
 	response := TokenResponse{
 		AccessToken:  accessToken,
 		TokenType:    "Bearer",
@@ -536,7 +581,7 @@ func (p *OAuth2Provider) sendTokenResponse(w http.ResponseWriter, accessToken, r
 
 	// Generate ID token if openid scope is present
 	if strings.Contains(scope, "openid") && userID != "" {
-		response.IDToken = p.generateIDToken(userID, scope)
+		response.IDToken = p.generateIDToken(clientID, userID)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -548,18 +593,17 @@ func (p *OAuth2Provider) sendTokenResponse(w http.ResponseWriter, accessToken, r
 	}
 }
 
-// generateIDToken generates an OpenID Connect ID token
-func (p *OAuth2Provider) generateIDToken(userID, scope string) string {
+// generateIDToken generates an OpenID Connect ID token for the client, with the claims of the user
+func (p *OAuth2Provider) generateIDToken(clientID, userID string) string {
 	now := time.Now()
 	claims := jwt.MapClaims{
-		"iss":   p.issuer,
-		"sub":   userID,
-		"aud":   "default-client",
-		"exp":   now.Add(p.tokenExpiry).Unix(),
-		"iat":   now.Unix(),
-		"name":  "Mock User",
-		"email": "user@example.com",
+		"iss": p.issuer,
+		"sub": userID,
+		"aud": clientID,
+		"exp": now.Add(p.tokenExpiry).Unix(),
+		"iat": now.Unix(),
 	}
+	p.addCustomClaims(claims, clientID, userID)
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
 	tokenString, err := token.SignedString(p.privateKey)
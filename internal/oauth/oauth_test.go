@@ -0,0 +1,123 @@
+package oauth
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestValidatePKCE(t *testing.T) {
+	provider := &OAuth2Provider{}
+
+	// Example from RFC 7636, Appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		method    string
+		expected  bool
+	}{
+		{"S256 valid", verifier, challenge, "S256", true},
+		{"S256 wrong verifier", "wrong-verifier", challenge, "S256", false},
+		{"S256 verifier sent as challenge", challenge, challenge, "S256", false},
+		{"S256 empty verifier", "", challenge, "S256", false},
+		{"Plain valid", verifier, verifier, "plain", true},
+		{"Plain wrong verifier", "wrong-verifier", verifier, "plain", false},
+		{"Empty method is plain", verifier, verifier, "", true},
+		{"Unknown method", verifier, verifier, "S512", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := provider.validatePKCE(tt.verifier, tt.challenge, tt.method); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCustomClaims(t *testing.T) {
+	provider, err := NewOAuth2Provider("http://localhost:8083")
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	provider.RegisterClient(&Client{
+		ClientID:     "tenant-app",
+		ClientSecret: "secret",
+		Claims:       map[string]interface{}{"tenant_id": "acme", "roles": []interface{}{"reader"}, "iss": "spoofed"},
+	})
+	provider.RegisterUser(&User{
+		Username: "alice",
+		Password: "wonderland",
+		Subject:  "user-1",
+		Claims:   map[string]interface{}{"roles": []interface{}{"admin"}, "exp": 0},
+	})
+
+	parse := func(tokenString string) jwt.MapClaims {
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+			return provider.publicKey, nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to parse token: %v", err)
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		userID string
+	}{
+		{"Access token", provider.generateAccessToken("tenant-app", "openid", "user-1"), "user-1"},
+		{"ID token", provider.generateIDToken("tenant-app", "user-1"), "user-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := parse(tt.token)
+			if claims["tenant_id"] != "acme" {
+				t.Errorf("Expected the client's tenant_id claim, got %v", claims["tenant_id"])
+			}
+			if roles, _ := claims["roles"].([]interface{}); len(roles) != 1 || roles[0] != "admin" {
+				t.Errorf("Expected the user's roles to win over the client's, got %v", claims["roles"])
+			}
+			if claims["iss"] != "http://localhost:8083" || claims["sub"] != tt.userID {
+				t.Errorf("Expected reserved claims to be kept, got iss=%v sub=%v", claims["iss"], claims["sub"])
+			}
+		})
+	}
+
+	// Client credentials tokens only get the client's claims
+	claims := parse(provider.generateAccessToken("tenant-app", "", ""))
+	if roles, _ := claims["roles"].([]interface{}); len(roles) != 1 || roles[0] != "reader" {
+		t.Errorf("Expected the client's roles, got %v", claims["roles"])
+	}
+}
+
+func TestApplyConfigReservedClaims(t *testing.T) {
+	provider, err := NewOAuth2Provider("http://localhost:8083")
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"Client", Config{Clients: []Client{{ClientID: "app", Claims: map[string]interface{}{"aud": "other"}}}}},
+		{"User", Config{Users: []User{{Username: "alice", Claims: map[string]interface{}{"sub": "admin"}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := provider.ApplyConfig(&tt.config); err == nil {
+				t.Error("Expected an error for a reserved claim")
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package observability
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"strconv"
 	"time"
@@ -205,3 +206,22 @@ func RecordRecordedRequest() {
 func MetricsHandler() http.Handler {
 	return promhttp.Handler()
 }
+
+// ProtectedMetricsHandler returns the Prometheus metrics HTTP handler requiring
+// an "Authorization: Bearer <token>" header. An empty token disables the check.
+func ProtectedMetricsHandler(token string) http.Handler {
+	handler := MetricsHandler()
+	if token == "" {
+		return handler
+	}
+
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,54 @@
+package observability
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProtectedMetricsHandler(t *testing.T) {
+	handler := ProtectedMetricsHandler("s3cret")
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		contains      string
+	}{
+		{"missing token", "", http.StatusUnauthorized, "Unauthorized"},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized, "Unauthorized"},
+		{"token without scheme", "s3cret", http.StatusUnauthorized, "Unauthorized"},
+		{"correct token", "Bearer s3cret", http.StatusOK, "go_goroutines"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.contains) {
+				t.Errorf("Expected body to contain %q, got %q", tt.contains, rec.Body.String())
+			}
+			if tt.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestProtectedMetricsHandlerWithoutToken(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ProtectedMetricsHandler("").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "go_goroutines") {
+		t.Errorf("Expected metrics without authentication when no token is set, got %d", rec.Code)
+	}
+}
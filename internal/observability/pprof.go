@@ -0,0 +1,60 @@
+package observability
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"strings"
+)
+
+// PprofPath is the path prefix the profiling endpoints are served under
+const PprofPath = "/debug/pprof/"
+
+// maxProfileSeconds caps the duration of CPU profile and execution trace requests
+const maxProfileSeconds = 300
+
+// PprofHandler returns a handler serving the net/http/pprof endpoints under PprofPath,
+// requiring an "Authorization: Bearer <token>" header:
+//   - /debug/pprof/ lists the available profiles
+//   - /debug/pprof/<name> writes a profile (goroutine, heap, allocs, threadcreate, block, mutex);
+//     ?debug=1 or ?debug=2 returns it as text instead of the binary format read by "go tool pprof"
+//   - /debug/pprof/profile?seconds=N records a CPU profile (default 30s)
+//   - /debug/pprof/trace?seconds=N records an execution trace (default 1s)
+//   - /debug/pprof/cmdline and /debug/pprof/symbol serve the command line and symbol lookups
+//
+// Importing net/http/pprof also registers these handlers on http.DefaultServeMux; that's
+// harmless as long as no server in this process serves the default mux.
+func PprofHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PprofPath, pprof.Index)
+	mux.HandleFunc(PprofPath+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(PprofPath+"profile", limitSeconds(pprof.Profile))
+	mux.HandleFunc(PprofPath+"symbol", pprof.Symbol)
+	mux.HandleFunc(PprofPath+"trace", limitSeconds(pprof.Trace))
+
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pprof"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// limitSeconds rejects recordings longer than maxProfileSeconds
+func limitSeconds(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if value := r.URL.Query().Get("seconds"); value != "" {
+			seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || seconds <= 0 || seconds > maxProfileSeconds {
+				http.Error(w, fmt.Sprintf("seconds must be > 0 and at most %d", maxProfileSeconds), http.StatusBadRequest)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,59 @@
+package observability
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPprofHandler(t *testing.T) {
+	handler := PprofHandler("s3cret")
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		status        int
+		contains      string
+	}{
+		{"missing token", "/debug/pprof/", "", http.StatusUnauthorized, "Unauthorized"},
+		{"wrong token", "/debug/pprof/", "Bearer nope", http.StatusUnauthorized, "Unauthorized"},
+		{"index", "/debug/pprof/", "Bearer s3cret", http.StatusOK, "goroutine"},
+		{"named profile", "/debug/pprof/goroutine?debug=1", "Bearer s3cret", http.StatusOK, "goroutine profile"},
+		{"cmdline", "/debug/pprof/cmdline", "Bearer s3cret", http.StatusOK, ""},
+		{"symbol", "/debug/pprof/symbol", "Bearer s3cret", http.StatusOK, "num_symbols"},
+		{"trace too long", "/debug/pprof/trace?seconds=301", "Bearer s3cret", http.StatusBadRequest, "seconds"},
+		{"profile too long", "/debug/pprof/profile?seconds=0", "Bearer s3cret", http.StatusBadRequest, "seconds"},
+		{"unknown profile", "/debug/pprof/nope", "Bearer s3cret", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.contains) {
+				t.Errorf("Expected body to contain %q, got %q", tt.contains, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestPprofHandlerTrace(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/trace?seconds=0.05", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	PprofHandler("s3cret").ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+		t.Errorf("Expected a trace, got status %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+}
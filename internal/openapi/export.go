@@ -0,0 +1,222 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+)
+
+// templateRegexParam matches a parameter of a regex built by pathTemplateRegex
+var templateRegexParam = regexp.MustCompile(`\(\?P<(\w+)>\[\^/\]\+\)`)
+
+// regexMetaChars are the characters that make a regex URI more than an OpenAPI path template
+const regexMetaChars = `.+*?()|[]{}^$`
+
+// GenerateSpec converts mocks to a minimal OpenAPI 3.0 document. Mocks are grouped by path
+// and method, with a response per status; when several mocks share a status, the one with
+// the highest priority is used. Regex URIs in the form produced by the importer (e.g.
+// ^/users/(?P<id>[^/]+)$) are converted back to path templates. Mocks that can't be
+// expressed as an operation (other regex URIs or methods, empty URIs or methods) are skipped.
+func GenerateSpec(title string, mocks []models.Mock) *OpenAPISpec {
+	sorted := make([]models.Mock, len(mocks))
+	copy(sorted, mocks)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority > sorted[j].Priority
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	spec := &OpenAPISpec{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: title, Version: "1.0.0"},
+		Paths:   make(map[string]PathItem),
+	}
+	operationIDs := make(map[string]bool)
+
+	for i := range sorted {
+		mock := &sorted[i]
+		path, params, ok := mockPath(&mock.Request)
+		if !ok || mock.Request.Method == "" || mock.Request.IsRegex.Method {
+			log.Printf("Skipping mock '%s' in OpenAPI export: its URI or method isn't an exact path or method\n", mock.Name)
+			continue
+		}
+
+		pathItem := spec.Paths[path]
+		operation := pathItem.operation(mock.Request.Method)
+		if operation == nil {
+			log.Printf("Skipping mock '%s' in OpenAPI export: unsupported method %s\n", mock.Name, mock.Request.Method)
+			continue
+		}
+		if *operation == nil {
+			*operation = &Operation{
+				OperationID: uniqueOperationID(mock.Name, operationIDs),
+				Parameters:  pathParameters(params),
+				Responses:   make(map[string]Response),
+			}
+		}
+
+		status := mock.Response.StatusCode
+		if status == 0 {
+			status = http.StatusOK
+		}
+		code := fmt.Sprintf("%d", status)
+		if _, exists := (*operation).Responses[code]; !exists {
+			(*operation).Responses[code] = mockResponse(&mock.Response, status)
+		}
+		spec.Paths[path] = pathItem
+	}
+
+	return spec
+}
+
+// operation returns the operation field of the method, or nil if the method isn't supported
+func (item *PathItem) operation(method string) **Operation {
+	switch strings.ToUpper(method) {
+	case http.MethodGet:
+		return &item.Get
+	case http.MethodPost:
+		return &item.Post
+	case http.MethodPut:
+		return &item.Put
+	case http.MethodPatch:
+		return &item.Patch
+	case http.MethodDelete:
+		return &item.Delete
+	case http.MethodHead:
+		return &item.Head
+	case http.MethodOptions:
+		return &item.Options
+	}
+	return nil
+}
+
+// mockPath returns the OpenAPI path of a mock and its parameters. Exact URIs are used as-is
+// (including literal templates such as /users/{id}); regex URIs must be path template
+// regexes (see pathTemplateRegex).
+func mockPath(req *models.Request) (string, []string, bool) {
+	if req.URI == "" {
+		return "", nil, false
+	}
+	if !req.IsRegex.URI {
+		var params []string
+		for _, match := range pathTemplateParam.FindAllStringSubmatch(req.URI, -1) {
+			params = append(params, match[1])
+		}
+		return req.URI, params, true
+	}
+	if !strings.HasPrefix(req.URI, "^") || !strings.HasSuffix(req.URI, "$") {
+		return "", nil, false
+	}
+
+	pattern := req.URI[1 : len(req.URI)-1]
+	var path strings.Builder
+	var params []string
+	last := 0
+	for _, match := range templateRegexParam.FindAllStringSubmatchIndex(pattern, -1) {
+		literal, ok := unquoteMeta(pattern[last:match[0]])
+		if !ok {
+			return "", nil, false
+		}
+		name := pattern[match[2]:match[3]]
+		path.WriteString(literal + "{" + name + "}")
+		params = append(params, name)
+		last = match[1]
+	}
+	literal, ok := unquoteMeta(pattern[last:])
+	if !ok {
+		return "", nil, false
+	}
+	path.WriteString(literal)
+	return path.String(), params, true
+}
+
+// unquoteMeta reverses regexp.QuoteMeta. It returns false if the string has unescaped
+// regex metacharacters, i.e. it doesn't match only itself.
+func unquoteMeta(quoted string) (string, bool) {
+	var literal strings.Builder
+	for i := 0; i < len(quoted); i++ {
+		c := quoted[i]
+		if c == '\\' && i+1 < len(quoted) {
+			i++
+			literal.WriteByte(quoted[i])
+			continue
+		}
+		if strings.IndexByte(regexMetaChars, c) >= 0 || c == '\\' {
+			return "", false
+		}
+		literal.WriteByte(c)
+	}
+	return literal.String(), true
+}
+
+// uniqueOperationID returns the mock name as operation ID, with a suffix if it's taken
+func uniqueOperationID(name string, taken map[string]bool) string {
+	id := name
+	for n := 2; taken[id]; n++ {
+		id = fmt.Sprintf("%s_%d", name, n)
+	}
+	taken[id] = true
+	return id
+}
+
+// pathParameters declares the parameters of a path template, which OpenAPI requires
+func pathParameters(names []string) []Parameter {
+	var params []Parameter
+	for _, name := range names {
+		params = append(params, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   map[string]interface{}{"type": "string"},
+		})
+	}
+	return params
+}
+
+// mockResponse converts a mock response. JSON bodies become JSON examples and other bodies
+// string examples of the response's Content-Type; the other headers are documented with
+// their value as example.
+func mockResponse(resp *models.Response, status int) Response {
+	description := http.StatusText(status)
+	if description == "" {
+		description = "Mock response"
+	}
+	response := Response{Description: description}
+
+	contentType := ""
+	for name, value := range resp.Headers {
+		if strings.EqualFold(name, "Content-Type") {
+			contentType = value
+			continue
+		}
+		if response.Headers == nil {
+			response.Headers = make(map[string]Header)
+		}
+		response.Headers[name] = Header{Schema: map[string]interface{}{"type": "string", "example": value}}
+	}
+
+	if resp.Body == "" {
+		return response
+	}
+
+	var example interface{}
+	if err := json.Unmarshal([]byte(resp.Body), &example); err == nil {
+		if contentType == "" {
+			contentType = "application/json"
+		}
+	} else {
+		example = resp.Body
+		if contentType == "" {
+			contentType = "text/plain"
+		}
+	}
+	response.Content = map[string]MediaType{contentType: {Example: example}}
+	return response
+}
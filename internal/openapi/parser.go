@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/comfortablynumb/pmp-mock-http/internal/models"
@@ -91,6 +93,7 @@ type Response struct {
 	Description string               `json:"description" yaml:"description"`
 	Content     map[string]MediaType `json:"content,omitempty" yaml:"content,omitempty"`
 	Headers     map[string]Header    `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Schema      interface{}          `json:"schema,omitempty" yaml:"schema,omitempty"` // Swagger 2.0 only
 }
 
 // MediaType describes a media type
@@ -120,6 +123,9 @@ type Components struct {
 // Parser handles OpenAPI/Swagger spec parsing
 type Parser struct {
 	generateExamples bool
+	openAPI31        bool                   // Schemas of the spec being converted follow JSON Schema 2020-12 (OpenAPI 3.1)
+	validateRequests bool                   // Validate request bodies against their JSON schema
+	schemas          map[string]interface{} // Reusable schemas of the spec being converted, by $ref
 }
 
 // NewParser creates a new OpenAPI parser
@@ -129,6 +135,12 @@ func NewParser(generateExamples bool) *Parser {
 	}
 }
 
+// SetValidateRequests sets whether imported mocks validate request bodies against the
+// JSON schema of the operation's requestBody (validate_schema)
+func (p *Parser) SetValidateRequests(validate bool) {
+	p.validateRequests = validate
+}
+
 // ParseFile parses an OpenAPI or Swagger spec file
 func (p *Parser) ParseFile(filePath string) (*models.MockSpec, error) {
 	// Read file
@@ -203,11 +215,17 @@ func (p *Parser) convertOpenAPIToMocks(spec *OpenAPISpec) *models.MockSpec {
 		Mocks: []models.Mock{},
 	}
 
-	log.Printf("Converting OpenAPI spec: %s v%s\n", spec.Info.Title, spec.Info.Version)
+	log.Printf("Converting OpenAPI %s spec: %s v%s\n", spec.OpenAPI, spec.Info.Title, spec.Info.Version)
+	p.openAPI31 = isOpenAPI31(spec.OpenAPI)
+	p.schemas = nil
+	if spec.Components != nil {
+		p.schemas = schemaRefs("#/components/schemas/", spec.Components.Schemas)
+	}
 
 	priority := 100 // Start with high priority
 
-	for path, pathItem := range spec.Paths {
+	for _, path := range sortedPaths(spec.Paths) {
+		pathItem := spec.Paths[path]
 		operations := map[string]*Operation{
 			"GET":     pathItem.Get,
 			"POST":    pathItem.Post,
@@ -223,9 +241,9 @@ func (p *Parser) convertOpenAPIToMocks(spec *OpenAPISpec) *models.MockSpec {
 				continue
 			}
 
-			mock := p.createMockFromOperation(path, method, operation, priority)
-			mockSpec.Mocks = append(mockSpec.Mocks, mock)
-			priority--
+			mocks := p.createMocksFromOperation(path, method, operation, priority)
+			mockSpec.Mocks = append(mockSpec.Mocks, mocks...)
+			priority -= len(mocks)
 		}
 	}
 
@@ -240,6 +258,8 @@ func (p *Parser) convertSwaggerToMocks(spec *SwaggerSpec) *models.MockSpec {
 	}
 
 	log.Printf("Converting Swagger spec: %s v%s\n", spec.Info.Title, spec.Info.Version)
+	p.openAPI31 = false
+	p.schemas = schemaRefs("#/definitions/", spec.Definitions)
 
 	priority := 100
 	basePath := spec.BasePath
@@ -247,7 +267,8 @@ func (p *Parser) convertSwaggerToMocks(spec *SwaggerSpec) *models.MockSpec {
 		basePath = ""
 	}
 
-	for path, pathItem := range spec.Paths {
+	for _, path := range sortedPaths(spec.Paths) {
+		pathItem := spec.Paths[path]
 		fullPath := basePath + path
 
 		operations := map[string]*Operation{
@@ -265,9 +286,9 @@ func (p *Parser) convertSwaggerToMocks(spec *SwaggerSpec) *models.MockSpec {
 				continue
 			}
 
-			mock := p.createMockFromOperation(fullPath, method, operation, priority)
-			mockSpec.Mocks = append(mockSpec.Mocks, mock)
-			priority--
+			mocks := p.createMocksFromOperation(fullPath, method, operation, priority)
+			mockSpec.Mocks = append(mockSpec.Mocks, mocks...)
+			priority -= len(mocks)
 		}
 	}
 
@@ -275,49 +296,80 @@ func (p *Parser) convertSwaggerToMocks(spec *SwaggerSpec) *models.MockSpec {
 	return mockSpec
 }
 
-// createMockFromOperation creates a mock from an operation
-func (p *Parser) createMockFromOperation(path, method string, operation *Operation, priority int) models.Mock {
+// createMocksFromOperation creates a mock per documented response status of an operation.
+// Each mock gets the scenario of its status class (see responseScenario), so activating e.g.
+// client_error makes the operation return its 4xx response. The default response (200, then
+// 201, then the lowest status) gets the highest priority and, besides its own scenario, the
+// scenarios of the classes the operation has no response for, so it's served whenever no
+// other response applies. Operations with a single response get no scenarios.
+func (p *Parser) createMocksFromOperation(path, method string, operation *Operation, priority int) []models.Mock {
 	mockName := operation.OperationID
 	if mockName == "" {
 		mockName = fmt.Sprintf("%s %s", method, path)
 	}
 
-	// Get the best response (prefer 200, then 201, then first available)
-	var statusCode int
-	var response *Response
-
-	for code, resp := range operation.Responses {
-		if code == "200" {
-			statusCode = 200
-			response = &resp
-			break
-		} else if code == "201" && statusCode != 200 {
-			statusCode = 201
-			response = &resp
-		} else if statusCode == 0 {
-			// Parse status code
-			if _, err := fmt.Sscanf(code, "%d", &statusCode); err != nil {
-				// If parsing fails, skip this response
-				continue
-			}
-			response = &resp
+	codes := responseCodes(operation.Responses)
+	if len(codes) == 0 {
+		return []models.Mock{p.createMock(mockName, path, method, operation, 200, nil, priority, nil)}
+	}
+	if len(codes) == 1 {
+		response := operation.Responses[codes[0]]
+		return []models.Mock{p.createMock(mockName, path, method, operation, responseStatus(codes[0]), &response, priority, nil)}
+	}
+
+	defaultCode := codes[0]
+	for _, preferred := range []string{"201", "200"} {
+		if _, exists := operation.Responses[preferred]; exists {
+			defaultCode = preferred
 		}
 	}
 
-	if statusCode == 0 {
-		statusCode = 200
+	mocks := make([]models.Mock, 0, len(codes))
+	covered := make(map[string]bool)
+	for _, code := range codes {
+		if code == defaultCode {
+			continue
+		}
+		response := operation.Responses[code]
+		status := responseStatus(code)
+		scenario := responseScenario(status)
+		covered[scenario] = true
+		mocks = append(mocks, p.createMock(fmt.Sprintf("%s (%s)", mockName, code), path, method, operation, status, &response, 0, []string{scenario}))
+	}
+
+	defaultStatus := responseStatus(defaultCode)
+	defaultScenarios := []string{responseScenario(defaultStatus)}
+	for _, scenario := range []string{ScenarioSuccess, ScenarioRedirect, ScenarioClientError, ScenarioServerError} {
+		if !covered[scenario] && scenario != defaultScenarios[0] {
+			defaultScenarios = append(defaultScenarios, scenario)
+		}
 	}
+	defaultResponse := operation.Responses[defaultCode]
+	mocks = append([]models.Mock{p.createMock(mockName, path, method, operation, defaultStatus, &defaultResponse, 0, defaultScenarios)}, mocks...)
+
+	for i := range mocks {
+		mocks[i].Priority = priority - i
+	}
+	return mocks
+}
 
+// createMock creates the mock of one response of an operation
+func (p *Parser) createMock(name, path, method string, operation *Operation, statusCode int, response *Response, priority int, scenarios []string) models.Mock {
 	// Extract response body example
 	responseBody := p.extractResponseExample(response)
 
+	// Path templates such as /users/{id} become regexes matching any value of the parameters
+	uri, isRegex := pathTemplateRegex(path)
+
 	// Create the mock
 	mock := models.Mock{
-		Name:     mockName,
-		Priority: priority,
+		Name:      name,
+		Priority:  priority,
+		Scenarios: scenarios,
 		Request: models.Request{
-			URI:    path,
-			Method: method,
+			URI:     uri,
+			Method:  method,
+			IsRegex: models.RegexConfig{URI: isRegex},
 		},
 		Response: models.Response{
 			StatusCode: statusCode,
@@ -326,12 +378,76 @@ func (p *Parser) createMockFromOperation(path, method string, operation *Operati
 		},
 	}
 
+	if p.validateRequests {
+		mock.Request.ValidateSchema = p.requestBodySchema(operation)
+	}
+
 	return mock
 }
 
+// responseCodes returns the status codes of the responses in ascending order. Ranges such as
+// 4XX are kept (they sort after the codes of their class); the default response is skipped.
+func responseCodes(responses map[string]Response) []string {
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		if responseStatus(code) != 0 {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// responseStatus returns the HTTP status of a response code, the lowest of its class for
+// ranges such as 4XX, or 0 if the code is not a status (e.g. default)
+func responseStatus(code string) int {
+	code = strings.ToUpper(code)
+	if len(code) == 3 && code[1:] == "XX" && code[0] >= '1' && code[0] <= '5' {
+		return int(code[0]-'0') * 100
+	}
+
+	status, err := strconv.Atoi(code)
+	if err != nil || status < 100 || status > 599 {
+		return 0
+	}
+	return status
+}
+
+// Scenarios of the mocks generated for the response status classes of an operation
+const (
+	ScenarioInformational = "informational"
+	ScenarioSuccess       = "success"
+	ScenarioRedirect      = "redirect"
+	ScenarioClientError   = "client_error"
+	ScenarioServerError   = "server_error"
+)
+
+// responseScenario returns the scenario of the mock of a response status
+func responseScenario(status int) string {
+	switch status / 100 {
+	case 1:
+		return ScenarioInformational
+	case 3:
+		return ScenarioRedirect
+	case 4:
+		return ScenarioClientError
+	case 5:
+		return ScenarioServerError
+	}
+	return ScenarioSuccess
+}
+
 // extractResponseExample extracts an example from a response
 func (p *Parser) extractResponseExample(response *Response) string {
-	if response == nil || response.Content == nil {
+	if response == nil {
+		return ""
+	}
+
+	// Swagger 2.0 responses have their schema outside of a content map
+	if response.Content == nil {
+		if p.generateExamples && response.Schema != nil {
+			return p.generateExampleFromSchema(response.Schema)
+		}
 		return ""
 	}
 
@@ -384,46 +500,258 @@ func (p *Parser) extractResponseHeaders(response *Response) map[string]string {
 	return headers
 }
 
+// isOpenAPI31 reports whether the version is OpenAPI 3.1.x, whose schemas are JSON Schema 2020-12
+func isOpenAPI31(version string) bool {
+	return version == "3.1" || strings.HasPrefix(version, "3.1.")
+}
+
+// schemaRefs indexes reusable schemas by their local $ref, e.g. "#/components/schemas/Pet"
+func schemaRefs(prefix string, schemas map[string]interface{}) map[string]interface{} {
+	refs := make(map[string]interface{}, len(schemas))
+	for name, schema := range schemas {
+		refs[prefix+name] = schema
+	}
+	return refs
+}
+
+// refEscaper unescapes the JSON pointer tokens of a $ref
+var refEscaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// resolveRef returns the schema of a local $ref, or nil if it's unknown
+func (p *Parser) resolveRef(ref string) map[string]interface{} {
+	schema, _ := p.schemas[refEscaper.Replace(ref)].(map[string]interface{})
+	return schema
+}
+
 // generateExampleFromSchema generates an example value from a JSON schema
 func (p *Parser) generateExampleFromSchema(schema interface{}) string {
-	// Simplified schema example generation
 	schemaMap, ok := schema.(map[string]interface{})
 	if !ok {
 		return `{"example": "generated"}`
 	}
 
-	schemaType, _ := schemaMap["type"].(string)
+	example, ok := p.exampleValue(schemaMap, make(map[string]bool))
+	if !ok {
+		return `{"example": "generated from schema"}`
+	}
 
-	switch schemaType {
-	case "object":
-		properties, ok := schemaMap["properties"].(map[string]interface{})
-		if !ok {
-			return `{}`
+	jsonData, err := json.Marshal(example)
+	if err != nil {
+		return `{"example": "generated from schema"}`
+	}
+	return string(jsonData)
+}
+
+// exampleValue generates an example value from a schema, preferring the examples it documents.
+// Local $refs are resolved recursively; visiting holds the refs being resolved, so a schema
+// that references itself yields no example (false) instead of recursing forever.
+// Unknown or unresolvable schemas yield no example either.
+func (p *Parser) exampleValue(schema map[string]interface{}, visiting map[string]bool) (interface{}, bool) {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved := p.resolveRef(ref)
+		if resolved == nil {
+			log.Printf("Warning: unresolved schema reference %s\n", ref)
+			return nil, false
+		}
+		if visiting[ref] {
+			return nil, false
 		}
 
-		result := make(map[string]interface{})
-		for propName := range properties {
-			result[propName] = "example"
+		visiting[ref] = true
+		defer delete(visiting, ref)
+		return p.exampleValue(resolved, visiting)
+	}
+
+	if example, ok := schemaExample(schema); ok {
+		return example, true
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok && len(allOf) > 0 {
+		return p.allOfExample(allOf, visiting)
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if options, ok := schema[key].([]interface{}); ok && len(options) > 0 {
+			for _, option := range options {
+				optionMap, ok := option.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if example, ok := p.exampleValue(optionMap, visiting); ok {
+					return example, true
+				}
+			}
+			return nil, false
 		}
+	}
 
-		if jsonData, err := json.Marshal(result); err == nil {
-			return string(jsonData)
+	switch schemaType(schema) {
+	case "object":
+		result := make(map[string]interface{})
+		properties, _ := schema["properties"].(map[string]interface{})
+		for propName, propSchema := range properties {
+			propMap, ok := propSchema.(map[string]interface{})
+			if !ok {
+				result[propName] = "example"
+				continue
+			}
+			if example, ok := p.exampleValue(propMap, visiting); ok {
+				result[propName] = example
+			}
 		}
+		return result, true
 
 	case "array":
-		return `[{"example": "item"}]`
+		items, ok := schema["items"].(map[string]interface{})
+		if !ok {
+			return []interface{}{"example"}, true
+		}
+		if example, ok := p.exampleValue(items, visiting); ok {
+			return []interface{}{example}, true
+		}
+		return []interface{}{}, true
 
 	case "string":
-		return `"example string"`
+		return "example string", true
 
-	case "number", "integer":
-		return `123`
+	case "integer":
+		return int64(p.numberExample(schema, 123)), true
+
+	case "number":
+		return p.numberExample(schema, 123), true
 
 	case "boolean":
-		return `true`
+		return true, true
+
+	case "null":
+		return nil, true
+	}
+
+	return nil, false
+}
+
+// allOfExample merges the object examples of the allOf schemas. If any of them isn't an
+// object, the example of the first schema that has one is used instead.
+func (p *Parser) allOfExample(allOf []interface{}, visiting map[string]bool) (interface{}, bool) {
+	merged := make(map[string]interface{})
+	var first interface{}
+	found, objects := false, true
+
+	for _, part := range allOf {
+		partMap, ok := part.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		example, ok := p.exampleValue(partMap, visiting)
+		if !ok {
+			continue
+		}
+		if !found {
+			first, found = example, true
+		}
+		object, ok := example.(map[string]interface{})
+		if !ok {
+			objects = false
+			continue
+		}
+		for key, value := range object {
+			merged[key] = value
+		}
+	}
+
+	if !found {
+		return nil, false
+	}
+	if !objects {
+		return first, true
+	}
+	return merged, true
+}
+
+// numberExample returns the lowest value allowed by the schema's minimum, or def without one.
+// OpenAPI 3.0 marks exclusive bounds with a boolean, 3.1 (JSON Schema 2020-12) with a number.
+func (p *Parser) numberExample(schema map[string]interface{}, def float64) float64 {
+	if p.openAPI31 {
+		if bound, ok := toFloat(schema["exclusiveMinimum"]); ok {
+			return bound + 1
+		}
+		if bound, ok := toFloat(schema["minimum"]); ok {
+			return bound
+		}
+		return def
 	}
 
-	return `{"example": "generated from schema"}`
+	bound, ok := toFloat(schema["minimum"])
+	if !ok {
+		return def
+	}
+	if exclusive, _ := schema["exclusiveMinimum"].(bool); exclusive {
+		return bound + 1
+	}
+	return bound
+}
+
+// schemaExample returns the example documented by the schema: the first of the
+// examples array (JSON Schema 2020-12), example (OpenAPI 3.0), const, default or the first enum value
+func schemaExample(schema map[string]interface{}) (interface{}, bool) {
+	if examples, ok := schema["examples"].([]interface{}); ok && len(examples) > 0 {
+		return examples[0], true
+	}
+	for _, key := range []string{"example", "const", "default"} {
+		if value, exists := schema[key]; exists {
+			return value, true
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0], true
+	}
+	return nil, false
+}
+
+// schemaType returns the type of the schema. Type arrays (OpenAPI 3.1) yield their first
+// non-null type, and untyped schemas with properties or items are objects or arrays.
+func schemaType(schema map[string]interface{}) string {
+	switch schemaType := schema["type"].(type) {
+	case string:
+		return schemaType
+	case []interface{}:
+		nullable := false
+		for _, t := range schemaType {
+			name, _ := t.(string)
+			if name == "null" {
+				nullable = true
+				continue
+			}
+			if name != "" {
+				return name
+			}
+		}
+		if nullable {
+			return "null"
+		}
+	}
+
+	if _, ok := schema["properties"]; ok {
+		return "object"
+	}
+	if _, ok := schema["items"]; ok {
+		return "array"
+	}
+	return ""
+}
+
+// toFloat converts a number decoded from JSON or YAML to a float64
+func toFloat(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case uint64:
+		return float64(number), true
+	}
+	return 0, false
 }
 
 // SaveMocks saves the generated mocks to a file
@@ -0,0 +1,328 @@
+package openapi
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// findMock returns the mock with the given name, or nil
+func findMock(spec *models.MockSpec, name string) *models.Mock {
+	for i := range spec.Mocks {
+		if spec.Mocks[i].Name == name {
+			return &spec.Mocks[i]
+		}
+	}
+	return nil
+}
+
+func TestParseOpenAPI31(t *testing.T) {
+	parser := NewParser(true)
+	spec, err := parser.ParseFile("testdata/openapi-3.1.yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse OpenAPI 3.1 spec: %v", err)
+	}
+	if len(spec.Mocks) != 3 {
+		t.Fatalf("Expected 3 mocks, got %d", len(spec.Mocks))
+	}
+
+	listPets := findMock(spec, "listPets")
+	if listPets == nil {
+		t.Fatal("Mock listPets not found")
+	}
+	var pets []map[string]interface{}
+	if err := json.Unmarshal([]byte(listPets.Response.Body), &pets); err != nil {
+		t.Fatalf("Expected a JSON array body, got %s: %v", listPets.Response.Body, err)
+	}
+	if len(pets) != 1 {
+		t.Fatalf("Expected one example pet, got %v", pets)
+	}
+
+	pet := pets[0]
+	if pet["id"] != float64(1) {
+		t.Errorf("Expected id above the exclusive minimum 0, got %v", pet["id"])
+	}
+	if pet["name"] != "Rex" {
+		t.Errorf("Expected the first schema example as name, got %v", pet["name"])
+	}
+	if pet["tag"] != "example string" {
+		t.Errorf("Expected the non-null type of the type array for tag, got %v", pet["tag"])
+	}
+	owner, ok := pet["owner"].(map[string]interface{})
+	if !ok || owner["verified"] != true {
+		t.Errorf("Expected a nullable object owner with properties, got %v", pet["owner"])
+	}
+
+	countPets := findMock(spec, "countPets")
+	if countPets == nil {
+		t.Fatal("Mock countPets not found")
+	}
+	if countPets.Response.Body != "5" {
+		t.Errorf("Expected the minimum as count, got %s", countPets.Response.Body)
+	}
+}
+
+func TestGenerateExampleFromSchemaOpenAPI30(t *testing.T) {
+	parser := NewParser(true)
+
+	tests := []struct {
+		name     string
+		schema   map[string]interface{}
+		expected string
+	}{
+		{"exclusive boolean minimum", map[string]interface{}{"type": "integer", "minimum": 10, "exclusiveMinimum": true}, "11"},
+		{"inclusive minimum", map[string]interface{}{"type": "number", "minimum": 2.5}, "2.5"},
+		{"example", map[string]interface{}{"type": "string", "nullable": true, "example": "hello"}, `"hello"`},
+		{"enum", map[string]interface{}{"type": "string", "enum": []interface{}{"active", "inactive"}}, `"active"`},
+		{"untyped", map[string]interface{}{"description": "anything"}, `{"example": "generated from schema"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parser.generateExampleFromSchema(tt.schema); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGenerateExampleFromSchemaRefs(t *testing.T) {
+	parser := NewParser(true)
+	spec, err := parser.ParseFile("testdata/refs.yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+
+	order := findMock(spec, "getLatestOrder")
+	if order == nil {
+		t.Fatal("Mock getLatestOrder not found")
+	}
+	expected := `{"customer":{"email":"jane@example.com","name":"Jane"},"id":"example string","items":[{"quantity":1,"sku":"SKU-1"}]}`
+	if order.Response.Body != expected {
+		t.Errorf("Expected nested references to be resolved:\n  expected %s\n  got      %s", expected, order.Response.Body)
+	}
+
+	// Self-references stop at the first level instead of recursing forever
+	categories := findMock(spec, "listCategories")
+	if categories == nil {
+		t.Fatal("Mock listCategories not found")
+	}
+	expected = `[{"children":[],"name":"Books"}]`
+	if categories.Response.Body != expected {
+		t.Errorf("Expected the cyclic reference to be cut:\n  expected %s\n  got      %s", expected, categories.Response.Body)
+	}
+}
+
+func TestGenerateExampleFromSchemaSwaggerRefs(t *testing.T) {
+	swagger := `{
+		"swagger": "2.0",
+		"info": {"title": "Users", "version": "1.0.0"},
+		"paths": {
+			"/users/me": {
+				"get": {
+					"operationId": "getMe",
+					"responses": {"200": {"description": "The user", "schema": {"$ref": "#/definitions/User"}}}
+				}
+			}
+		},
+		"definitions": {
+			"User": {"type": "object", "properties": {"id": {"type": "integer"}, "missing": {"$ref": "#/definitions/Unknown"}}}
+		}
+	}`
+
+	spec, err := NewParser(true).Parse([]byte(swagger), "swagger.json")
+	if err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+	if got := spec.Mocks[0].Response.Body; got != `{"id":123}` {
+		t.Errorf("Expected the definition to be resolved without the unknown reference, got %s", got)
+	}
+}
+
+func TestPathTemplateRegex(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+		isRegex  bool
+		matches  []string
+		rejects  []string
+	}{
+		{"/users", "/users", false, nil, nil},
+		{"/users/{id}", `^/users/(?P<id>[^/]+)$`, true, []string{"/users/42"}, []string{"/users", "/users/42/posts", "/admin/users/42"}},
+		{"/users/{userId}/posts/{post-id}", `^/users/(?P<userId>[^/]+)/posts/(?P<post_id>[^/]+)$`, true, []string{"/users/1/posts/abc"}, []string{"/users/1/posts"}},
+		{"/files/{name}.{ext}", `^/files/(?P<name>[^/]+)\.(?P<ext>[^/]+)$`, true, []string{"/files/report.pdf"}, []string{"/files/report"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			pattern, isRegex := pathTemplateRegex(tt.path)
+			if pattern != tt.expected || isRegex != tt.isRegex {
+				t.Fatalf("Expected (%s, %v), got (%s, %v)", tt.expected, tt.isRegex, pattern, isRegex)
+			}
+			if !isRegex {
+				return
+			}
+
+			compiled := regexp.MustCompile(pattern)
+			for _, path := range tt.matches {
+				if !compiled.MatchString(path) {
+					t.Errorf("Expected %s to match %s", pattern, path)
+				}
+			}
+			for _, path := range tt.rejects {
+				if compiled.MatchString(path) {
+					t.Errorf("Expected %s not to match %s", pattern, path)
+				}
+			}
+		})
+	}
+
+	// Parameter names are kept as capture group names
+	pattern, _ := pathTemplateRegex("/users/{userId}/posts/{post-id}")
+	compiled := regexp.MustCompile(pattern)
+	match := compiled.FindStringSubmatch("/users/7/posts/hello")
+	if match[compiled.SubexpIndex("userId")] != "7" || match[compiled.SubexpIndex("post_id")] != "hello" {
+		t.Errorf("Expected the parameters to be captured by name, got %v", match)
+	}
+}
+
+func TestParsePathTemplates(t *testing.T) {
+	spec, err := NewParser(false).ParseFile("testdata/openapi-3.1.yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+
+	listPets := findMock(spec, "listPets")
+	if listPets == nil || listPets.Request.URI != "/pets" || listPets.Request.IsRegex.URI {
+		t.Errorf("Expected paths without parameters to match exactly, got %+v", listPets)
+	}
+	getPet := findMock(spec, "getPet")
+	if getPet == nil {
+		t.Fatal("Mock getPet not found")
+	}
+	if getPet.Request.URI != `^/pets/(?P<petId>[^/]+)$` || !getPet.Request.IsRegex.URI {
+		t.Errorf("Expected the path template to become a regex, got %s (regex: %v)", getPet.Request.URI, getPet.Request.IsRegex.URI)
+	}
+
+	// Literal paths take precedence over templates that also match them
+	countPets := findMock(spec, "countPets")
+	if countPets == nil || countPets.Priority <= getPet.Priority {
+		t.Errorf("Expected /pets/count to have a higher priority than /pets/{petId}")
+	}
+}
+
+func TestParseRequestBodySchema(t *testing.T) {
+	parser := NewParser(false)
+	spec, err := parser.ParseFile("testdata/request-body.yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+	if createUser := findMock(spec, "createUser"); createUser == nil || createUser.Request.ValidateSchema != nil {
+		t.Fatal("Expected no validate_schema without SetValidateRequests")
+	}
+
+	parser.SetValidateRequests(true)
+	spec, err = parser.ParseFile("testdata/request-body.yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+	if listUsers := findMock(spec, "listUsers"); listUsers == nil || listUsers.Request.ValidateSchema != nil {
+		t.Error("Expected no validate_schema for operations without a request body")
+	}
+	createUser := findMock(spec, "createUser")
+	if createUser == nil || createUser.Request.ValidateSchema == nil {
+		t.Fatal("Expected validate_schema for createUser")
+	}
+
+	schemaJSON, err := json.Marshal(createUser.Request.ValidateSchema)
+	if err != nil {
+		t.Fatalf("Failed to marshal schema: %v", err)
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schemaJSON))
+	if err != nil {
+		t.Fatalf("Expected a valid JSON schema, got %v: %s", err, schemaJSON)
+	}
+
+	tests := []struct {
+		body  string
+		valid bool
+	}{
+		{`{"name": "Jane", "email": "jane@example.com"}`, true},
+		{`{"name": "Jane", "email": "jane@example.com", "nickname": null}`, true},
+		{`{"name": "Jane", "email": "jane@example.com", "manager": {"name": "John", "email": "john@example.com"}}`, true},
+		{`{"name": "Jane"}`, false},
+		{`{"name": "", "email": "jane@example.com"}`, false},
+		{`{"name": "Jane", "email": "jane@example.com", "manager": {"name": "John"}}`, false},
+	}
+	for _, tt := range tests {
+		result, err := schema.Validate(gojsonschema.NewStringLoader(tt.body))
+		if err != nil {
+			t.Fatalf("Failed to validate %s: %v", tt.body, err)
+		}
+		if result.Valid() != tt.valid {
+			t.Errorf("Expected valid=%v for %s, got %v", tt.valid, tt.body, result.Errors())
+		}
+	}
+}
+
+func TestParseResponseScenarios(t *testing.T) {
+	spec, err := NewParser(false).ParseFile("testdata/responses.yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+	if len(spec.Mocks) != 6 {
+		t.Fatalf("Expected 6 mocks (the default response is skipped), got %d", len(spec.Mocks))
+	}
+
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		scenarios []string
+	}{
+		{"createAccount", 201, `{"id":1}`, []string{"success", "redirect"}},
+		{"createAccount (400)", 400, `{"error":"invalid"}`, []string{"client_error"}},
+		{"createAccount (409)", 409, "", []string{"client_error"}},
+		{"createAccount (5XX)", 500, `{"error":"unavailable"}`, []string{"server_error"}},
+		{"getAccount", 200, "", []string{"success", "redirect", "server_error"}},
+		{"getAccount (404)", 404, "", []string{"client_error"}},
+	}
+
+	for _, tt := range tests {
+		mock := findMock(spec, tt.name)
+		if mock == nil {
+			t.Errorf("Mock %s not found", tt.name)
+			continue
+		}
+		if mock.Response.StatusCode != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, mock.Response.StatusCode)
+		}
+		if tt.body != "" && mock.Response.Body != tt.body {
+			t.Errorf("%s: expected body %s, got %s", tt.name, tt.body, mock.Response.Body)
+		}
+		if strings.Join(mock.Scenarios, ",") != strings.Join(tt.scenarios, ",") {
+			t.Errorf("%s: expected scenarios %v, got %v", tt.name, tt.scenarios, mock.Scenarios)
+		}
+	}
+
+	// The default response wins while no scenario is active, the lowest status within a scenario
+	defaultMock := findMock(spec, "createAccount")
+	badRequest := findMock(spec, "createAccount (400)")
+	conflict := findMock(spec, "createAccount (409)")
+	if defaultMock.Priority <= badRequest.Priority || badRequest.Priority <= conflict.Priority {
+		t.Errorf("Expected priorities 201 > 400 > 409, got %d, %d, %d", defaultMock.Priority, badRequest.Priority, conflict.Priority)
+	}
+
+	// Operations with a single response aren't tied to scenarios
+	spec, err = NewParser(false).ParseFile("testdata/request-body.yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+	if listUsers := findMock(spec, "listUsers"); listUsers == nil || listUsers.Scenarios != nil {
+		t.Errorf("Expected no scenarios for a single response, got %+v", listUsers)
+	}
+}
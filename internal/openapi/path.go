@@ -0,0 +1,56 @@
+package openapi
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// pathTemplateParam matches a {param} of an OpenAPI path template
+var pathTemplateParam = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// invalidCaptureChars matches the characters not allowed in regex capture group names
+var invalidCaptureChars = regexp.MustCompile(`\W`)
+
+// pathTemplateRegex converts an OpenAPI path template such as /users/{id} to an anchored
+// regex that matches one path segment per parameter, e.g. ^/users/(?P<id>[^/]+)$. Parameters
+// become named capture groups, so their values can be read by name. It returns false for
+// paths without parameters, which are matched exactly.
+func pathTemplateRegex(path string) (string, bool) {
+	matches := pathTemplateParam.FindAllStringSubmatchIndex(path, -1)
+	if len(matches) == 0 {
+		return path, false
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	for _, match := range matches {
+		pattern.WriteString(regexp.QuoteMeta(path[last:match[0]]))
+		name := invalidCaptureChars.ReplaceAllString(path[match[2]:match[3]], "_")
+		pattern.WriteString("(?P<" + name + ">[^/]+)")
+		last = match[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(path[last:]))
+	pattern.WriteString("$")
+	return pattern.String(), true
+}
+
+// sortedPaths returns the paths in the order their mocks get decreasing priorities: paths with
+// fewer parameters first, so /users/me is matched before /users/{id}, then alphabetically
+func sortedPaths(paths map[string]PathItem) []string {
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		paramsI := len(pathTemplateParam.FindAllStringIndex(sorted[i], -1))
+		paramsJ := len(pathTemplateParam.FindAllStringIndex(sorted[j], -1))
+		if paramsI != paramsJ {
+			return paramsI < paramsJ
+		}
+		return sorted[i] < sorted[j]
+	})
+	return sorted
+}
@@ -0,0 +1,284 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+)
+
+// PostmanCollection represents a Postman v2.1 collection
+type PostmanCollection struct {
+	Info  PostmanInfo   `json:"info"`
+	Items []PostmanItem `json:"item"`
+}
+
+// PostmanInfo contains collection metadata
+type PostmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"` // e.g. https://schema.getpostman.com/json/collection/v2.1.0/collection.json
+}
+
+// PostmanItem is a request or a folder of items
+type PostmanItem struct {
+	Name      string            `json:"name"`
+	Items     []PostmanItem     `json:"item,omitempty"` // Set for folders
+	Request   *PostmanRequest   `json:"request,omitempty"`
+	Responses []PostmanResponse `json:"response,omitempty"` // Saved example responses
+}
+
+// PostmanRequest describes a request. Collections may also store it as a plain URL string.
+type PostmanRequest struct {
+	Method string          `json:"method"`
+	URL    PostmanURL      `json:"url"`
+	Header []PostmanHeader `json:"header,omitempty"`
+}
+
+// PostmanURL is the URL of a request. Collections store it as a string or as an object.
+type PostmanURL struct {
+	Raw   string          `json:"raw"`
+	Path  []interface{}   `json:"path,omitempty"` // Segments; path variables start with ':'
+	Query []PostmanHeader `json:"query,omitempty"`
+}
+
+// PostmanHeader is a key/value pair of a header or query string
+type PostmanHeader struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// PostmanResponse is a saved example response
+type PostmanResponse struct {
+	Name            string          `json:"name"`
+	OriginalRequest *PostmanRequest `json:"originalRequest,omitempty"`
+	Code            int             `json:"code"`
+	Header          []PostmanHeader `json:"header,omitempty"`
+	Body            string          `json:"body"`
+}
+
+// UnmarshalJSON accepts a request given as a plain URL string
+func (r *PostmanRequest) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*r = PostmanRequest{Method: http.MethodGet, URL: PostmanURL{Raw: raw}}
+		return nil
+	}
+
+	type request PostmanRequest
+	return json.Unmarshal(data, (*request)(r))
+}
+
+// UnmarshalJSON accepts a URL given as a string
+func (u *PostmanURL) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*u = PostmanURL{Raw: raw}
+		return nil
+	}
+
+	type postmanURL PostmanURL
+	return json.Unmarshal(data, (*postmanURL)(u))
+}
+
+// IsPostmanCollection reports whether the data is a Postman collection
+func IsPostmanCollection(data []byte) bool {
+	var collection PostmanCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return false
+	}
+	return strings.Contains(collection.Info.Schema, "schema.getpostman.com")
+}
+
+// ParsePostmanCollection converts a Postman v2.1 collection to mocks. Every saved example
+// response of a request becomes a mock (in order, with decreasing priorities), and requests
+// without examples get a placeholder response. Path variables (:id) match any segment, and
+// collection variables ({{baseUrl}}) are kept as literal placeholders, except in the host,
+// which mocks don't match.
+func ParsePostmanCollection(data []byte) (*models.MockSpec, error) {
+	var collection PostmanCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("failed to parse Postman collection: %w", err)
+	}
+
+	log.Printf("Converting Postman collection: %s\n", collection.Info.Name)
+
+	mockSpec := &models.MockSpec{
+		Mocks: []models.Mock{},
+	}
+	priority := 100
+	addPostmanItems(mockSpec, collection.Items, "", &priority)
+
+	log.Printf("Generated %d mocks from Postman collection\n", len(mockSpec.Mocks))
+	return mockSpec, nil
+}
+
+// addPostmanItems adds the mocks of the items, recursing into folders
+func addPostmanItems(mockSpec *models.MockSpec, items []PostmanItem, folder string, priority *int) {
+	for _, item := range items {
+		name := item.Name
+		if folder != "" {
+			name = folder + " / " + item.Name
+		}
+
+		if item.Request == nil {
+			addPostmanItems(mockSpec, item.Items, name, priority)
+			continue
+		}
+
+		if len(item.Responses) == 0 {
+			mock := postmanMock(name, item.Request, *priority)
+			mock.Response = models.Response{
+				StatusCode: http.StatusOK,
+				Headers:    map[string]string{"Content-Type": "application/json"},
+				Body:       `{"message": "Mock response - add your own example"}`,
+			}
+			mockSpec.Mocks = append(mockSpec.Mocks, mock)
+			*priority--
+			continue
+		}
+
+		for _, example := range item.Responses {
+			request := item.Request
+			if example.OriginalRequest != nil {
+				request = example.OriginalRequest
+			}
+
+			mock := postmanMock(fmt.Sprintf("%s (%s)", name, example.Name), request, *priority)
+			mock.Response = models.Response{
+				StatusCode: example.Code,
+				Headers:    postmanHeaders(example.Header),
+				Body:       example.Body,
+			}
+			if mock.Response.StatusCode == 0 {
+				mock.Response.StatusCode = http.StatusOK
+			}
+			mockSpec.Mocks = append(mockSpec.Mocks, mock)
+			*priority--
+		}
+	}
+}
+
+// postmanMock creates a mock matching the method, path and query string of a request
+func postmanMock(name string, request *PostmanRequest, priority int) models.Mock {
+	method := strings.ToUpper(request.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	uri, isRegex := postmanPath(&request.URL)
+	mock := models.Mock{
+		Name:     name,
+		Priority: priority,
+		Request: models.Request{
+			URI:     uri,
+			Method:  method,
+			IsRegex: models.RegexConfig{URI: isRegex},
+		},
+	}
+
+	for _, param := range postmanQuery(&request.URL) {
+		value := param.Value
+		if strings.Contains(value, "{{") {
+			value = "" // The variable's value isn't known, so only require the parameter
+		}
+		mock.Request.QueryParams = append(mock.Request.QueryParams, models.QueryParamMatcher{Key: param.Key, Value: value})
+	}
+	return mock
+}
+
+// postmanURLHost matches the scheme and host of a raw URL, including a {{variable}} host
+var postmanURLHost = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*://)?[^/?#]*`)
+
+// postmanPath returns the path of a URL, as a regex if it has path variables (see pathTemplateRegex)
+func postmanPath(u *PostmanURL) (string, bool) {
+	var segments []string
+	if len(u.Path) > 0 {
+		for _, segment := range u.Path {
+			segments = append(segments, fmt.Sprint(segment))
+		}
+	} else {
+		raw := strings.SplitN(strings.SplitN(u.Raw, "?", 2)[0], "#", 2)[0]
+		raw = strings.TrimPrefix(postmanURLHost.ReplaceAllString(raw, ""), "/")
+		if raw != "" {
+			segments = strings.Split(raw, "/")
+		}
+	}
+
+	path := "/" + strings.Join(segments, "/")
+	hasVariables := false
+	for _, segment := range segments {
+		if strings.HasPrefix(segment, ":") && len(segment) > 1 {
+			hasVariables = true
+		}
+	}
+	if !hasVariables {
+		return path, false
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for _, segment := range segments {
+		pattern.WriteString("/")
+		if strings.HasPrefix(segment, ":") && len(segment) > 1 {
+			name := invalidCaptureChars.ReplaceAllString(segment[1:], "_")
+			pattern.WriteString("(?P<" + name + ">[^/]+)")
+			continue
+		}
+		pattern.WriteString(regexp.QuoteMeta(segment))
+	}
+	pattern.WriteString("$")
+	return pattern.String(), true
+}
+
+// postmanQuery returns the enabled query parameters of a URL
+func postmanQuery(u *PostmanURL) []PostmanHeader {
+	if len(u.Query) > 0 {
+		var params []PostmanHeader
+		for _, param := range u.Query {
+			if !param.Disabled && param.Key != "" {
+				params = append(params, param)
+			}
+		}
+		return params
+	}
+
+	parts := strings.SplitN(strings.SplitN(u.Raw, "#", 2)[0], "?", 2)
+	if len(parts) < 2 {
+		return nil
+	}
+	var params []PostmanHeader
+	for _, pair := range strings.Split(parts[1], "&") {
+		key, value, _ := strings.Cut(pair, "=")
+		if key != "" {
+			params = append(params, PostmanHeader{Key: key, Value: value})
+		}
+	}
+	return params
+}
+
+// postmanHeaders converts the enabled headers of a response, leaving out the ones that describe
+// the recorded transfer rather than the body (see importedHeader)
+func postmanHeaders(headers []PostmanHeader) map[string]string {
+	result := make(map[string]string)
+	for _, header := range headers {
+		if !header.Disabled && importedHeader(header.Key) {
+			result[header.Key] = header.Value
+		}
+	}
+	return result
+}
+
+// importedHeader reports whether a recorded response header is kept in imported mocks.
+// Headers that depend on how the body was transferred would be wrong for the mock's body.
+func importedHeader(name string) bool {
+	switch strings.ToLower(name) {
+	case "content-length", "transfer-encoding", "content-encoding", "connection", "keep-alive":
+		return false
+	}
+	return true
+}
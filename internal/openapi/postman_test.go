@@ -0,0 +1,67 @@
+package openapi
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParsePostmanCollection(t *testing.T) {
+	data, err := os.ReadFile("testdata/postman-collection.json")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	if !IsPostmanCollection(data) {
+		t.Fatal("Expected the fixture to be detected as a Postman collection")
+	}
+	if IsPostmanCollection([]byte(`{"openapi": "3.0.3", "info": {"title": "API"}}`)) {
+		t.Error("Expected an OpenAPI spec not to be detected as a Postman collection")
+	}
+
+	spec, err := ParsePostmanCollection(data)
+	if err != nil {
+		t.Fatalf("Failed to parse collection: %v", err)
+	}
+	if len(spec.Mocks) != 4 {
+		t.Fatalf("Expected 4 mocks, got %d", len(spec.Mocks))
+	}
+
+	found := findMock(spec, "Users / Get user (Found)")
+	if found == nil {
+		t.Fatal("Mock 'Users / Get user (Found)' not found")
+	}
+	if found.Request.Method != "GET" || found.Request.URI != `^/users/(?P<userId>[^/]+)$` || !found.Request.IsRegex.URI {
+		t.Errorf("Expected the path variable to match any segment, got %s %s", found.Request.Method, found.Request.URI)
+	}
+	if len(found.Request.QueryParams) != 1 || found.Request.QueryParams[0].Key != "expand" || found.Request.QueryParams[0].Value != "" {
+		t.Errorf("Expected only the enabled expand parameter, without its variable value, got %+v", found.Request.QueryParams)
+	}
+	if found.Response.StatusCode != 200 || found.Response.Body != `{"id": 42, "name": "Jane"}` {
+		t.Errorf("Expected the saved example response, got %d %s", found.Response.StatusCode, found.Response.Body)
+	}
+	if _, exists := found.Response.Headers["Content-Length"]; exists || found.Response.Headers["Content-Type"] != "application/json" {
+		t.Errorf("Expected Content-Type without Content-Length, got %v", found.Response.Headers)
+	}
+
+	// Examples use their original request, if saved
+	notFound := findMock(spec, "Users / Get user (Not found)")
+	if notFound == nil || notFound.Request.URI != "/users/404" || notFound.Response.StatusCode != 404 {
+		t.Errorf("Expected the example's original request and status, got %+v", notFound)
+	}
+	if notFound.Priority >= found.Priority {
+		t.Errorf("Expected examples in collection order, got priorities %d and %d", found.Priority, notFound.Priority)
+	}
+
+	// Collection variables outside the host are kept as literal placeholders
+	token := findMock(spec, "Create token")
+	if token == nil || token.Request.Method != "POST" || token.Request.URI != "/auth/{{tenant}}/token" || token.Request.IsRegex.URI {
+		t.Errorf("Expected the variable to be kept literally, got %+v", token)
+	}
+	if token.Response.StatusCode != 200 || token.Response.Body == "" {
+		t.Errorf("Expected a placeholder response for a request without examples, got %+v", token.Response)
+	}
+
+	health := findMock(spec, "Health")
+	if health == nil || health.Request.Method != "GET" || health.Request.URI != "/health" {
+		t.Errorf("Expected a GET mock for a request given as a URL string, got %+v", health)
+	}
+}
@@ -0,0 +1,81 @@
+package openapi
+
+import (
+	"log"
+	"strings"
+)
+
+// requestBodySchema converts the JSON schema of the operation's request body (requestBody,
+// or the body parameter in Swagger 2.0) to a self-contained JSON Schema for validate_schema.
+// It returns nil if the body has no JSON schema. Referenced schemas are copied under
+// definitions, so references (including cyclic ones) keep working.
+func (p *Parser) requestBodySchema(operation *Operation) map[string]interface{} {
+	var bodySchema map[string]interface{}
+	if operation.RequestBody != nil {
+		for contentType, mediaType := range operation.RequestBody.Content {
+			if schemaMap, ok := mediaType.Schema.(map[string]interface{}); ok && strings.Contains(contentType, "json") {
+				bodySchema = schemaMap
+				break
+			}
+		}
+	}
+	for _, param := range operation.Parameters {
+		if schemaMap, ok := param.Schema.(map[string]interface{}); ok && bodySchema == nil && param.In == "body" {
+			bodySchema = schemaMap
+		}
+	}
+	if bodySchema == nil {
+		return nil
+	}
+
+	definitions := make(map[string]interface{})
+	schema, _ := p.toJSONSchema(bodySchema, definitions).(map[string]interface{})
+	if len(definitions) > 0 {
+		schema["definitions"] = definitions
+	}
+	return schema
+}
+
+// toJSONSchema copies an OpenAPI schema, pointing local $refs to definitions (adding the
+// referenced schemas to it) and turning OpenAPI 3.0 nullable types into type arrays.
+// Unresolved references are dropped, so they accept any value.
+func (p *Parser) toJSONSchema(value interface{}, definitions map[string]interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, child := range value {
+			if key != "$ref" {
+				result[key] = p.toJSONSchema(child, definitions)
+				continue
+			}
+
+			ref, _ := child.(string)
+			resolved := p.resolveRef(ref)
+			if resolved == nil {
+				log.Printf("Warning: unresolved schema reference %s (accepting any value)\n", ref)
+				continue
+			}
+			name := refEscaper.Replace(ref[strings.LastIndex(ref, "/")+1:])
+			if _, exists := definitions[name]; !exists {
+				definitions[name] = true // Placeholder, so cyclic references stop here
+				definitions[name] = p.toJSONSchema(resolved, definitions)
+			}
+			result["$ref"] = "#/definitions/" + name
+		}
+
+		if nullable, _ := value["nullable"].(bool); nullable {
+			if schemaType, ok := value["type"].(string); ok {
+				result["type"] = []interface{}{schemaType, "null"}
+			}
+		}
+		return result
+
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, child := range value {
+			result[i] = p.toJSONSchema(child, definitions)
+		}
+		return result
+	}
+	return value
+}
@@ -4,37 +4,51 @@ import "fmt"
 
 // MockGitClient is a mock implementation of GitClient for testing
 type MockGitClient struct {
-	CloneCalls     []CloneCall
-	PullCalls      []PullCall
-	CloneError     error
-	PullError      error
-	CloneCallback  func(repoURL, destPath string) error
+	CloneCalls    []CloneCall
+	PullCalls     []PullCall
+	CheckoutCalls []CheckoutCall
+	CheckoutError error
+	CloneError    error
+	PullError     error
+	CloneCallback func(repoURL, destPath string) error
+	Ref           string // Ref returned by CurrentRef
 }
 
 // CloneCall records a call to Clone
 type CloneCall struct {
-	RepoURL  string
-	DestPath string
+	RepoURL     string
+	DestPath    string
+	Credentials *Credentials
 }
 
 // PullCall records a call to Pull
 type PullCall struct {
+	RepoPath    string
+	Ref         string
+	Credentials *Credentials
+}
+
+// CheckoutCall records a call to Checkout
+type CheckoutCall struct {
 	RepoPath string
+	Ref      string
 }
 
 // NewMockGitClient creates a new mock git client
 func NewMockGitClient() *MockGitClient {
 	return &MockGitClient{
-		CloneCalls: make([]CloneCall, 0),
-		PullCalls:  make([]PullCall, 0),
+		CloneCalls:    make([]CloneCall, 0),
+		PullCalls:     make([]PullCall, 0),
+		CheckoutCalls: make([]CheckoutCall, 0),
 	}
 }
 
 // Clone records the call and returns the configured error
-func (m *MockGitClient) Clone(repoURL, destPath string) error {
+func (m *MockGitClient) Clone(repoURL, destPath string, creds *Credentials) error {
 	m.CloneCalls = append(m.CloneCalls, CloneCall{
-		RepoURL:  repoURL,
-		DestPath: destPath,
+		RepoURL:     repoURL,
+		DestPath:    destPath,
+		Credentials: creds,
 	})
 	if m.CloneError != nil {
 		return m.CloneError
@@ -46,9 +60,11 @@ func (m *MockGitClient) Clone(repoURL, destPath string) error {
 }
 
 // Pull records the call and returns the configured error
-func (m *MockGitClient) Pull(repoPath string) error {
+func (m *MockGitClient) Pull(repoPath, ref string, creds *Credentials) error {
 	m.PullCalls = append(m.PullCalls, PullCall{
-		RepoPath: repoPath,
+		RepoPath:    repoPath,
+		Ref:         ref,
+		Credentials: creds,
 	})
 	if m.PullError != nil {
 		return m.PullError
@@ -56,6 +72,20 @@ func (m *MockGitClient) Pull(repoPath string) error {
 	return nil
 }
 
+// Checkout records the call and returns the configured error
+func (m *MockGitClient) Checkout(repoPath, ref string) error {
+	m.CheckoutCalls = append(m.CheckoutCalls, CheckoutCall{
+		RepoPath: repoPath,
+		Ref:      ref,
+	})
+	return m.CheckoutError
+}
+
+// CurrentRef returns the configured ref
+func (m *MockGitClient) CurrentRef(repoPath string) (string, error) {
+	return m.Ref, nil
+}
+
 // SetCloneError sets the error to return from Clone
 func (m *MockGitClient) SetCloneError(err error) {
 	m.CloneError = err
@@ -66,6 +96,11 @@ func (m *MockGitClient) SetPullError(err error) {
 	m.PullError = err
 }
 
+// SetCheckoutError sets the error to return from Checkout
+func (m *MockGitClient) SetCheckoutError(err error) {
+	m.CheckoutError = err
+}
+
 // SetCloneCallback sets a callback to run after Clone is called
 func (m *MockGitClient) SetCloneCallback(callback func(repoURL, destPath string) error) {
 	m.CloneCallback = callback
@@ -91,6 +126,16 @@ func (m *MockGitClient) AssertCloneCalled(repoURL, destPath string) error {
 	return fmt.Errorf("Clone not called with repoURL=%s, destPath=%s", repoURL, destPath)
 }
 
+// AssertCheckoutCalled verifies Checkout was called with expected parameters
+func (m *MockGitClient) AssertCheckoutCalled(repoPath, ref string) error {
+	for _, call := range m.CheckoutCalls {
+		if call.RepoPath == repoPath && call.Ref == ref {
+			return nil
+		}
+	}
+	return fmt.Errorf("Checkout not called with repoPath=%s, ref=%s", repoPath, ref)
+}
+
 // AssertPullCalled verifies Pull was called with expected parameters
 func (m *MockGitClient) AssertPullCalled(repoPath string) error {
 	for _, call := range m.PullCalls {
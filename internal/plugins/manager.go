@@ -6,14 +6,36 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Manager handles cloning and managing plugin repositories
 type Manager struct {
-	pluginsDir   string
-	repos        []string
-	gitClient    GitClient
-	includeOnly  []string // Relative paths from pmp-mock-http to include
+	pluginsDir  string
+	repos       []string
+	gitClient   GitClient
+	credentials *Credentials // Credentials for private repositories (nil = anonymous)
+	includeOnly []string     // Relative paths from pmp-mock-http to include
+	plugins     []PluginInfo // Metadata from the last SetupPlugins run
+	mu          sync.RWMutex
+	setupMu     sync.Mutex // Serializes SetupPlugins runs
+	refreshMu   sync.Mutex // Protects stopRefresh and refreshDone
+	stopRefresh chan struct{}
+	refreshDone chan struct{}
+}
+
+// PluginInfo describes a plugin repository after the last setup
+type PluginInfo struct {
+	Repo        string    `json:"repo"`                 // Repository URL, with embedded credentials redacted
+	PinnedRef   string    `json:"pinned_ref,omitempty"` // Branch, tag or commit the repository is pinned to
+	Name        string    `json:"name"`                 // Repository name
+	Dir         string    `json:"dir"`                  // Local clone directory
+	MockDirs    []string  `json:"mock_dirs"`            // Directories mocks are loaded from
+	Ref         string    `json:"ref,omitempty"`        // Commit currently checked out
+	LastUpdated time.Time `json:"last_updated"`         // Time of the last successful clone or pull
+	Error       string    `json:"error,omitempty"`      // Error from the last clone or pull, if any
+	Local       bool      `json:"local,omitempty"`      // Local directory used without git
 }
 
 // NewManager creates a new plugin manager with a real git client
@@ -36,8 +58,18 @@ func NewManagerWithGitClient(pluginsDir string, repos []string, gitClient GitCli
 	}
 }
 
-// SetupPlugins clones all plugin repositories and returns directories to watch
+// SetCredentials sets the credentials used to clone and pull private repositories
+func (m *Manager) SetCredentials(creds *Credentials) {
+	m.setupMu.Lock()
+	defer m.setupMu.Unlock()
+	m.credentials = creds
+}
+
+// SetupPlugins clones all plugin repositories, checks the local plugin directories and returns directories to watch
 func (m *Manager) SetupPlugins() ([]string, error) {
+	m.setupMu.Lock()
+	defer m.setupMu.Unlock()
+
 	if len(m.repos) == 0 {
 		return []string{}, nil
 	}
@@ -48,70 +80,192 @@ func (m *Manager) SetupPlugins() ([]string, error) {
 	}
 
 	var pluginDirs []string
+	infos := make([]PluginInfo, 0, len(m.repos))
+	previous := m.pluginsByRepo()
+
+	for _, spec := range m.repos {
+		if localPath, ok := localPluginPath(spec); ok {
+			info := m.setupLocalPlugin(localPath)
+			pluginDirs = append(pluginDirs, info.MockDirs...)
+			infos = append(infos, info)
+			continue
+		}
+
+		repoURL, pinnedRef := parseRepoSpec(spec)
 
-	for _, repoURL := range m.repos {
 		// Extract repository name from URL
 		repoName := extractRepoName(repoURL)
+		displayURL := m.credentials.Redact(repoURL)
 		if repoName == "" {
-			log.Printf("Warning: invalid repository URL: %s\n", repoURL)
+			log.Printf("Warning: invalid repository URL: %s\n", displayURL)
 			continue
 		}
 
 		pluginPath := filepath.Join(m.pluginsDir, repoName)
+		info := PluginInfo{Repo: displayURL, PinnedRef: pinnedRef, Name: repoName, Dir: pluginPath, LastUpdated: previous[displayURL].LastUpdated}
 
 		// Check if plugin already exists
 		if _, err := os.Stat(pluginPath); err == nil {
 			// Plugin exists, update it
 			log.Printf("Plugin '%s' already exists, updating...\n", repoName)
-			if err := m.updateRepo(pluginPath); err != nil {
-				log.Printf("Warning: failed to update plugin '%s': %v\n", repoName, err)
+			if err := m.updateRepo(pluginPath, pinnedRef); err != nil {
+				info.Error = m.credentials.Redact(err.Error())
+				log.Printf("Warning: failed to update plugin '%s': %s\n", repoName, info.Error)
 				// Continue using existing version
+			} else {
+				info.LastUpdated = time.Now()
 			}
 		} else {
 			// Clone the repository
-			log.Printf("Cloning plugin from %s...\n", repoURL)
+			log.Printf("Cloning plugin from %s...\n", displayURL)
 			if err := m.cloneRepo(repoURL, pluginPath); err != nil {
-				log.Printf("Warning: failed to clone plugin '%s': %v\n", repoName, err)
+				info.Error = m.credentials.Redact(err.Error())
+				log.Printf("Warning: failed to clone plugin '%s': %s\n", repoName, info.Error)
+				infos = append(infos, info)
 				continue
 			}
+			if pinnedRef != "" {
+				if err := m.gitClient.Checkout(pluginPath, pinnedRef); err != nil {
+					// Don't load mocks from the default branch instead of the pinned ref
+					log.Printf("Warning: failed to check out '%s' in plugin '%s': %v\n", pinnedRef, repoName, err)
+					info.Error = err.Error()
+					infos = append(infos, info)
+					continue
+				}
+			}
 			log.Printf("Plugin '%s' cloned successfully\n", repoName)
+			info.LastUpdated = time.Now()
+		}
+
+		if ref, err := m.gitClient.CurrentRef(pluginPath); err == nil {
+			info.Ref = ref
 		}
 
 		// Look for pmp-mock-http directory in the plugin
 		pmpMockHTTPDir := filepath.Join(pluginPath, "pmp-mock-http")
 		if _, err := os.Stat(pmpMockHTTPDir); err != nil {
 			log.Printf("Warning: plugin '%s' does not have a 'pmp-mock-http' directory, skipping\n", repoName)
+			info.Error = "no pmp-mock-http directory"
+			infos = append(infos, info)
 			continue
 		}
 
-		// If includeOnly is specified, only add those subdirectories
-		if len(m.includeOnly) > 0 {
-			for _, subdir := range m.includeOnly {
-				subdirPath := filepath.Join(pmpMockHTTPDir, subdir)
-				if _, err := os.Stat(subdirPath); err == nil {
-					pluginDirs = append(pluginDirs, subdirPath)
-					log.Printf("Including plugin subdirectory: %s/%s\n", repoName, subdir)
-				} else {
-					log.Printf("Warning: plugin '%s' does not have subdirectory '%s', skipping\n", repoName, subdir)
-				}
+		m.addMockDirs(&info, pmpMockHTTPDir)
+		pluginDirs = append(pluginDirs, info.MockDirs...)
+		infos = append(infos, info)
+	}
+
+	m.mu.Lock()
+	m.plugins = infos
+	m.mu.Unlock()
+
+	return pluginDirs, nil
+}
+
+// setupLocalPlugin uses a local directory as a plugin, without git. Mocks are loaded from its
+// pmp-mock-http directory like in repositories, or from the directory itself if it has none.
+func (m *Manager) setupLocalPlugin(path string) PluginInfo {
+	info := PluginInfo{Repo: path, Name: filepath.Base(path), Dir: path, Local: true}
+
+	if stat, err := os.Stat(path); err != nil || !stat.IsDir() {
+		log.Printf("Warning: local plugin '%s' is not a directory, skipping\n", path)
+		info.Error = "not a directory"
+		return info
+	}
+
+	mockRoot := filepath.Join(path, "pmp-mock-http")
+	if _, err := os.Stat(mockRoot); err != nil {
+		mockRoot = path
+	}
+	m.addMockDirs(&info, mockRoot)
+	return info
+}
+
+// addMockDirs adds the directories of the plugin that mocks are loaded from
+func (m *Manager) addMockDirs(info *PluginInfo, mockRoot string) {
+	// If includeOnly is specified, only add those subdirectories
+	if len(m.includeOnly) > 0 {
+		for _, subdir := range m.includeOnly {
+			subdirPath := filepath.Join(mockRoot, subdir)
+			if _, err := os.Stat(subdirPath); err == nil {
+				info.MockDirs = append(info.MockDirs, subdirPath)
+				log.Printf("Including plugin subdirectory: %s/%s\n", info.Name, subdir)
+			} else {
+				log.Printf("Warning: plugin '%s' does not have subdirectory '%s', skipping\n", info.Name, subdir)
 			}
-		} else {
-			// Include the entire pmp-mock-http directory
-			pluginDirs = append(pluginDirs, pmpMockHTTPDir)
 		}
+		return
 	}
 
-	return pluginDirs, nil
+	// Include the entire mock directory
+	info.MockDirs = append(info.MockDirs, mockRoot)
+}
+
+// localPluginPath returns the directory of a plugin spec that is a file:// URL or an absolute path
+func localPluginPath(spec string) (string, bool) {
+	if strings.HasPrefix(spec, "file://") {
+		return filepath.Clean(strings.TrimPrefix(spec, "file://")), true
+	}
+	if filepath.IsAbs(spec) {
+		return filepath.Clean(spec), true
+	}
+	return "", false
+}
+
+// GetPlugins returns the plugin metadata from the last SetupPlugins run
+func (m *Manager) GetPlugins() []PluginInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	plugins := make([]PluginInfo, len(m.plugins))
+	copy(plugins, m.plugins)
+	return plugins
+}
+
+// pluginsByRepo returns the current plugin metadata indexed by repository URL
+func (m *Manager) pluginsByRepo() map[string]PluginInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	byRepo := make(map[string]PluginInfo, len(m.plugins))
+	for _, info := range m.plugins {
+		byRepo[info.Repo] = info
+	}
+	return byRepo
 }
 
 // cloneRepo clones a git repository to the specified path
 func (m *Manager) cloneRepo(repoURL, destPath string) error {
-	return m.gitClient.Clone(repoURL, destPath)
+	return m.gitClient.Clone(repoURL, destPath, m.credentials)
+}
+
+// updateRepo updates an existing git repository, resetting it to the pinned ref if set
+func (m *Manager) updateRepo(repoPath, pinnedRef string) error {
+	return m.gitClient.Pull(repoPath, pinnedRef, m.credentials)
 }
 
-// updateRepo updates an existing git repository
-func (m *Manager) updateRepo(repoPath string) error {
-	return m.gitClient.Pull(repoPath)
+// parseRepoSpec splits a plugin spec like https://github.com/user/repo.git@v1.2.0 into the
+// repository URL and the pinned branch, tag or commit. Only an @ in the repository path starts
+// the ref, so user info like git@github.com:user/repo.git isn't mistaken for one.
+func parseRepoSpec(spec string) (repoURL, ref string) {
+	pathStart := strings.Index(spec, ":")
+	if scheme := strings.Index(spec, "://"); scheme >= 0 {
+		pathStart = scheme + 3
+		slash := strings.Index(spec[pathStart:], "/")
+		if slash < 0 {
+			return spec, ""
+		}
+		pathStart += slash
+	}
+	if pathStart < 0 {
+		pathStart = 0
+	}
+
+	at := strings.Index(spec[pathStart:], "@")
+	if at < 0 {
+		return spec, ""
+	}
+	return spec[:pathStart+at], spec[pathStart+at+1:]
 }
 
 // extractRepoName extracts the repository name from a git URL
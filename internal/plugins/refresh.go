@@ -0,0 +1,79 @@
+package plugins
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// StartAutoRefresh re-runs SetupPlugins every interval until StopAutoRefresh is called.
+// onChange is called with the plugin directories when a repository moved to another commit
+// or the directories mocks are loaded from changed. Calling it again while running does nothing.
+func (m *Manager) StartAutoRefresh(interval time.Duration, onChange func(dirs []string)) {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+	if m.stopRefresh != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	m.stopRefresh = stop
+	m.refreshDone = done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.refresh(onChange)
+			}
+		}
+	}()
+}
+
+// StopAutoRefresh stops the periodic refresh, waiting for a running refresh to finish
+func (m *Manager) StopAutoRefresh() {
+	m.refreshMu.Lock()
+	stop, done := m.stopRefresh, m.refreshDone
+	m.stopRefresh = nil
+	m.refreshDone = nil
+	m.refreshMu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// refresh updates the plugins and calls onChange if their state changed
+func (m *Manager) refresh(onChange func(dirs []string)) {
+	before := pluginState(m.GetPlugins())
+
+	dirs, err := m.SetupPlugins()
+	if err != nil {
+		log.Printf("Warning: failed to refresh plugins: %v\n", err)
+		return
+	}
+
+	if pluginState(m.GetPlugins()) != before {
+		log.Println("Plugins changed, reloading mocks...")
+		onChange(dirs)
+	}
+}
+
+// pluginState summarizes the checked out commits and mock directories of the plugins
+func pluginState(infos []PluginInfo) string {
+	var state strings.Builder
+	for _, info := range infos {
+		fmt.Fprintf(&state, "%s %s %s\n", info.Repo, info.Ref, strings.Join(info.MockDirs, ","))
+	}
+	return state.String()
+}
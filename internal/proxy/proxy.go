@@ -1,9 +1,12 @@
 package proxy
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
@@ -15,6 +18,8 @@ type Config struct {
 	Target       string
 	PreserveHost bool
 	Timeout      time.Duration
+	StripHeaders []string          // Headers removed from proxied responses (e.g. "Strict-Transport-Security")
+	AddHeaders   map[string]string // Headers added to proxied responses, replacing upstream values
 }
 
 // Client handles proxying requests to a backend
@@ -22,6 +27,7 @@ type Client struct {
 	config     *Config
 	httpClient *http.Client
 	targetURL  *url.URL
+	timeout    time.Duration
 }
 
 // NewClient creates a new proxy client
@@ -42,26 +48,35 @@ func NewClient(config *Config) (*Client, error) {
 
 	return &Client{
 		config: config,
+		// The timeout is enforced per request in Forward so streaming responses can outlive it
 		httpClient: &http.Client{
-			Timeout: timeout,
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				// Don't follow redirects, return them to the client
 				return http.ErrUseLastResponse
 			},
 		},
 		targetURL: targetURL,
+		timeout:   timeout,
 	}, nil
 }
 
-// Forward forwards a request to the proxy target
+// Forward forwards a request to the proxy target. Server-Sent Events responses are
+// streamed to the client as they arrive and WebSocket upgrades are tunneled, both
+// without the proxy timeout once the response headers have been received.
 func (c *Client) Forward(w http.ResponseWriter, r *http.Request) error {
 	// Build the target URL
 	targetURL := *c.targetURL
 	targetURL.Path = r.URL.Path
 	targetURL.RawQuery = r.URL.RawQuery
 
+	// Cancel the request when the timeout expires, unless it turns into a stream
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	timer := time.AfterFunc(c.timeout, cancel)
+	defer timer.Stop()
+
 	// Create the proxy request
-	proxyReq, err := http.NewRequest(r.Method, targetURL.String(), r.Body)
+	proxyReq, err := http.NewRequestWithContext(ctx, r.Method, targetURL.String(), r.Body)
 	if err != nil {
 		return fmt.Errorf("failed to create proxy request: %w", err)
 	}
@@ -96,16 +111,30 @@ func (c *Client) Forward(w http.ResponseWriter, r *http.Request) error {
 	}
 	defer resp.Body.Close() //nolint:errcheck // cleanup
 
+	// WebSocket (and other protocol) upgrades are tunneled over the hijacked connection
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		timer.Stop()
+		return c.tunnelUpgrade(w, resp)
+	}
+
 	// Copy response headers
 	for key, values := range resp.Header {
 		for _, value := range values {
 			w.Header().Add(key, value)
 		}
 	}
+	c.rewriteHeaders(w.Header())
 
 	// Write status code
 	w.WriteHeader(resp.StatusCode)
 
+	// Stream Server-Sent Events, flushing each chunk as it arrives
+	if isEventStream(resp) {
+		timer.Stop()
+		log.Printf("Streaming proxied event stream: %d\n", resp.StatusCode)
+		return streamBody(w, resp.Body)
+	}
+
 	// Copy response body
 	if _, err := io.Copy(w, resp.Body); err != nil {
 		log.Printf("Error copying proxy response body: %v\n", err)
@@ -116,6 +145,98 @@ func (c *Client) Forward(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// rewriteHeaders removes and adds the configured response headers
+func (c *Client) rewriteHeaders(header http.Header) {
+	for _, key := range c.config.StripHeaders {
+		header.Del(key)
+	}
+	for key, value := range c.config.AddHeaders {
+		header.Set(key, value)
+	}
+}
+
+// isEventStream checks if the response is a Server-Sent Events stream
+func isEventStream(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// streamBody copies the body to the client, flushing after every read
+func streamBody(w http.ResponseWriter, body io.Reader) error {
+	flusher, canFlush := w.(http.Flusher)
+	if canFlush {
+		flusher.Flush()
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				// The client went away
+				return nil
+			}
+			if canFlush {
+				flusher.Flush()
+			}
+		}
+		if readErr != nil {
+			// The stream ends when the upstream closes it or the client disconnects
+			if readErr != io.EOF && !errors.Is(readErr, context.Canceled) {
+				log.Printf("Error streaming proxy response body: %v\n", readErr)
+			}
+			return nil
+		}
+	}
+}
+
+// tunnelUpgrade relays a 101 Switching Protocols response and copies data in both
+// directions between the client and the upstream until either side closes
+func (c *Client) tunnelUpgrade(w http.ResponseWriter, resp *http.Response) error {
+	upstream, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		return fmt.Errorf("upstream upgrade response is not writable")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return fmt.Errorf("connection does not support upgrades")
+	}
+
+	clientConn, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		return fmt.Errorf("failed to hijack connection: %w", err)
+	}
+	defer clientConn.Close() //nolint:errcheck // tunnel cleanup
+
+	// Relay the upgrade response
+	fmt.Fprintf(clientBuf, "HTTP/1.1 %d %s\r\n", resp.StatusCode, http.StatusText(resp.StatusCode))
+	if err := resp.Header.Write(clientBuf); err != nil {
+		return fmt.Errorf("failed to write upgrade response: %w", err)
+	}
+	clientBuf.WriteString("\r\n")
+	if err := clientBuf.Flush(); err != nil {
+		return fmt.Errorf("failed to write upgrade response: %w", err)
+	}
+
+	log.Printf("Tunneling upgraded connection (%s)\n", resp.Header.Get("Upgrade"))
+
+	done := make(chan struct{}, 2)
+	go func() {
+		// Bytes the client sent after the upgrade request may already be buffered
+		_, _ = io.Copy(upstream, clientBuf)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(clientConn, upstream)
+		done <- struct{}{}
+	}()
+
+	// Close both sides once either direction finishes
+	<-done
+	return nil
+}
+
 // getClientIP extracts the client IP from the request
 func getClientIP(r *http.Request) string {
 	// Check X-Forwarded-For header first
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 func TestNewClient(t *testing.T) {
@@ -271,6 +273,45 @@ func TestClientForwardXForwardedHeaders(t *testing.T) {
 	}
 }
 
+func TestClientForwardRewritesResponseHeaders(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("X-Backend", "true")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	client, err := NewClient(&Config{
+		Target:       backend.URL,
+		Timeout:      5 * time.Second,
+		StripHeaders: []string{"strict-transport-security"},
+		AddHeaders:   map[string]string{"X-Frame-Options": "SAMEORIGIN", "X-Environment": "local"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy client: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	if err := client.Forward(w, httptest.NewRequest("GET", "/", nil)); err != nil {
+		t.Fatalf("Forward() error = %v", err)
+	}
+
+	resp := w.Result()
+	if got := resp.Header.Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Expected Strict-Transport-Security to be stripped, got %s", got)
+	}
+	if got := resp.Header.Values("X-Frame-Options"); len(got) != 1 || got[0] != "SAMEORIGIN" {
+		t.Errorf("Expected X-Frame-Options to be overridden, got %v", got)
+	}
+	if got := resp.Header.Get("X-Environment"); got != "local" {
+		t.Errorf("Expected X-Environment header, got %s", got)
+	}
+	if got := resp.Header.Get("X-Backend"); got != "true" {
+		t.Errorf("Expected upstream headers to be kept, got X-Backend %s", got)
+	}
+}
+
 func TestClientForwardError(t *testing.T) {
 	// Create a config with an unreachable target
 	config := &Config{
@@ -292,3 +333,126 @@ func TestClientForwardError(t *testing.T) {
 		t.Error("Forward() expected error for unreachable target, got nil")
 	}
 }
+
+func TestClientForwardStreamsEventStream(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		_, _ = io.WriteString(w, "data: first\n\n")
+		flusher.Flush()
+		// Outlive the proxy timeout
+		time.Sleep(300 * time.Millisecond)
+		_, _ = io.WriteString(w, "data: second\n\n")
+		flusher.Flush()
+	}))
+	defer backend.Close()
+
+	client, err := NewClient(&Config{Target: backend.URL, Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create proxy client: %v", err)
+	}
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := client.Forward(w, r); err != nil {
+			t.Errorf("Forward() error = %v", err)
+		}
+	}))
+	defer front.Close()
+
+	resp, err := http.Get(front.URL + "/events")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // test cleanup
+
+	// The first event arrives before the upstream finishes
+	buf := make([]byte, len("data: first\n\n"))
+	start := time.Now()
+	if _, err := io.ReadFull(resp.Body, buf); err != nil {
+		t.Fatalf("Failed to read first event: %v", err)
+	}
+	if string(buf) != "data: first\n\n" {
+		t.Errorf("Expected first event, got %q", string(buf))
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("First event was buffered for %v", elapsed)
+	}
+
+	rest, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	if string(rest) != "data: second\n\n" {
+		t.Errorf("Expected second event after the timeout, got %q", string(rest))
+	}
+}
+
+func TestClientForwardTunnelsWebSocket(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close() //nolint:errcheck // test cleanup
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, append([]byte("echo: "), message...)); err != nil {
+				return
+			}
+		}
+	}))
+	defer backend.Close()
+
+	client, err := NewClient(&Config{Target: backend.URL, Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create proxy client: %v", err)
+	}
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := client.Forward(w, r); err != nil {
+			t.Errorf("Forward() error = %v", err)
+		}
+	}))
+	defer front.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(front.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial through proxy failed: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck // test cleanup
+
+	// Messages still flow after the proxy timeout
+	time.Sleep(200 * time.Millisecond)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(message) != "echo: hello" {
+		t.Errorf("Expected 'echo: hello', got '%s'", string(message))
+	}
+}
+
+func TestClientForwardTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond)
+		_, _ = io.WriteString(w, "too late")
+	}))
+	defer backend.Close()
+
+	client, err := NewClient(&Config{Target: backend.URL, Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create proxy client: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	if err := client.Forward(w, httptest.NewRequest("GET", "/slow", nil)); err == nil {
+		t.Error("Forward() expected timeout error for a slow non-streaming response")
+	}
+}
@@ -0,0 +1,41 @@
+package saml
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+)
+
+// AuthnRequest is the part of a SAML 2.0 AuthnRequest the IdP needs to answer it
+type AuthnRequest struct {
+	XMLName                     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	ID                          string   `xml:"ID,attr"`
+	Destination                 string   `xml:"Destination,attr"`
+	AssertionConsumerServiceURL string   `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string   `xml:"ProtocolBinding,attr"`
+	Issuer                      string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"` // Entity ID of the SP
+}
+
+// ParseAuthnRequest decodes and parses a SAMLRequest parameter. Requests sent with the
+// HTTP-Redirect binding are deflated; those sent with the HTTP-POST binding are only base64-encoded.
+func ParseAuthnRequest(encoded string, deflated bool) (*AuthnRequest, error) {
+	var data []byte
+	var err error
+	if deflated {
+		data, err = DecodeSAMLRequest(encoded)
+	} else {
+		data, err = base64.StdEncoding.DecodeString(encoded)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode SAML request: %w", err)
+	}
+
+	var request AuthnRequest
+	if err := xml.Unmarshal(data, &request); err != nil {
+		return nil, fmt.Errorf("failed to parse AuthnRequest: %w", err)
+	}
+	if request.ID == "" {
+		return nil, fmt.Errorf("AuthnRequest has no ID")
+	}
+	return &request, nil
+}
@@ -27,6 +27,7 @@ type SAMLProvider struct {
 	privateKey      *rsa.PrivateKey
 	assertionExpiry time.Duration
 	sessions        map[string]*SAMLSession
+	signMode        string // Which parts of responses are signed (see SetSignMode)
 	mu              sync.RWMutex
 }
 
@@ -46,6 +47,7 @@ type SAMLResponse struct {
 	Version      string   `xml:"Version,attr"`
 	IssueInstant string   `xml:"IssueInstant,attr"`
 	Destination  string   `xml:"Destination,attr,omitempty"`
+	InResponseTo string   `xml:"InResponseTo,attr,omitempty"`
 	Issuer       Issuer   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
 	Status       Status   `xml:"urn:oasis:names:tc:SAML:2.0:protocol Status"`
 	Assertion    Assertion `xml:"urn:oasis:names:tc:SAML:2.0:assertion Assertion"`
@@ -108,6 +110,7 @@ type SubjectConfirmationData struct {
 	XMLName      xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:assertion SubjectConfirmationData"`
 	NotOnOrAfter string   `xml:"NotOnOrAfter,attr"`
 	Recipient    string   `xml:"Recipient,attr"`
+	InResponseTo string   `xml:"InResponseTo,attr,omitempty"`
 }
 
 // Conditions represents SAML conditions
@@ -198,14 +201,27 @@ func NewSAMLProvider(issuer string) (*SAMLProvider, error) {
 		privateKey:      privateKey,
 		assertionExpiry: time.Hour,
 		sessions:        make(map[string]*SAMLSession),
+		signMode:        SignAssertion,
 	}, nil
 }
 
-// HandleSSO handles SP-initiated SSO
+// HandleSSO handles SP-initiated SSO (with an AuthnRequest via the HTTP-Redirect or HTTP-POST
+// binding) and IdP-initiated SSO (without a request, posting to the acs parameter)
 func (p *SAMLProvider) HandleSSO(w http.ResponseWriter, r *http.Request) {
 	// Parse SAML request (if present)
-	samlRequest := r.URL.Query().Get("SAMLRequest")
-	relayState := r.URL.Query().Get("RelayState")
+	samlRequest := r.FormValue("SAMLRequest")
+	relayState := r.FormValue("RelayState")
+
+	var authnRequest *AuthnRequest
+	if samlRequest != "" {
+		var err error
+		authnRequest, err = ParseAuthnRequest(samlRequest, r.Method != http.MethodPost)
+		if err != nil {
+			log.Printf("SAML: Invalid SAMLRequest: %v\n", err)
+			http.Error(w, "Invalid SAMLRequest", http.StatusBadRequest)
+			return
+		}
+	}
 
 	// For mock purposes, auto-authenticate
 	nameID := "user@example.com"
@@ -229,14 +245,17 @@ func (p *SAMLProvider) HandleSSO(w http.ResponseWriter, r *http.Request) {
 	p.sessions[sessionID] = session
 	p.mu.Unlock()
 
-	// Get ACS URL from request or use default
-	acsURL := r.URL.Query().Get("acs")
+	// Get ACS URL from the AuthnRequest, the acs parameter or use default
+	acsURL := r.FormValue("acs")
+	if authnRequest != nil && authnRequest.AssertionConsumerServiceURL != "" {
+		acsURL = authnRequest.AssertionConsumerServiceURL
+	}
 	if acsURL == "" {
 		acsURL = "http://localhost:8080/saml/acs"
 	}
 
 	// Generate SAML response
-	samlResponse := p.generateSAMLResponse(nameID, sessionID, acsURL, session.Attributes)
+	samlResponse := p.generateSAMLResponse(nameID, sessionID, acsURL, session.Attributes, authnRequest)
 
 	// Encode response
 	encoded, err := p.encodeSAMLResponse(samlResponse)
@@ -277,11 +296,20 @@ func (p *SAMLProvider) HandleMetadata(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// generateSAMLResponse generates a SAML response
-func (p *SAMLProvider) generateSAMLResponse(nameID, sessionID, acsURL string, attributes map[string]string) *SAMLResponse {
+// generateSAMLResponse generates a SAML response. For SP-initiated flows, the response refers
+// to the AuthnRequest and the audience is the SP's issuer; otherwise authnRequest is nil.
+func (p *SAMLProvider) generateSAMLResponse(nameID, sessionID, acsURL string, attributes map[string]string, authnRequest *AuthnRequest) *SAMLResponse {
 	now := time.Now()
 	notOnOrAfter := now.Add(p.assertionExpiry)
 
+	inResponseTo, audience := "", acsURL
+	if authnRequest != nil {
+		inResponseTo = authnRequest.ID
+		if authnRequest.Issuer != "" {
+			audience = authnRequest.Issuer
+		}
+	}
+
 	// Build attributes
 	var attrs []Attribute
 	for name, value := range attributes {
@@ -302,6 +330,7 @@ func (p *SAMLProvider) generateSAMLResponse(nameID, sessionID, acsURL string, at
 		Version:      "2.0",
 		IssueInstant: now.UTC().Format(time.RFC3339),
 		Destination:  acsURL,
+		InResponseTo: inResponseTo,
 		Issuer: Issuer{
 			Value: p.issuer,
 		},
@@ -327,6 +356,7 @@ func (p *SAMLProvider) generateSAMLResponse(nameID, sessionID, acsURL string, at
 					SubjectConfirmationData: SubjectConfirmationData{
 						NotOnOrAfter: notOnOrAfter.UTC().Format(time.RFC3339),
 						Recipient:    acsURL,
+						InResponseTo: inResponseTo,
 					},
 				},
 			},
@@ -334,7 +364,7 @@ func (p *SAMLProvider) generateSAMLResponse(nameID, sessionID, acsURL string, at
 				NotBefore:    now.UTC().Format(time.RFC3339),
 				NotOnOrAfter: notOnOrAfter.UTC().Format(time.RFC3339),
 				AudienceRestriction: AudienceRestriction{
-					Audience: acsURL,
+					Audience: audience,
 				},
 			},
 			AttributeStatement: AttributeStatement{
@@ -361,6 +391,12 @@ func (p *SAMLProvider) encodeSAMLResponse(response *SAMLResponse) (string, error
 		return "", fmt.Errorf("failed to marshal SAML response: %w", err)
 	}
 
+	// Sign before encoding, since any later change to the XML breaks the signature
+	xmlData, err = p.signResponse(xmlData)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign SAML response: %w", err)
+	}
+
 	// Base64 encode (HTTP-POST binding doesn't use deflate)
 	encoded := base64.StdEncoding.EncodeToString(xmlData)
 	return encoded, nil
@@ -421,8 +457,9 @@ func (p *SAMLProvider) getCertificateString() string {
 
 // DecodeSAMLRequest decodes a SAML request (for SP-initiated flow)
 func DecodeSAMLRequest(encoded string) ([]byte, error) {
-	// URL decode
-	decoded, err := url.QueryUnescape(encoded)
+	// URL decode. Values read via URL.Query are already decoded, and a '+' there is part of
+	// the base64 encoding, so it must not become a space.
+	decoded, err := url.PathUnescape(encoded)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,187 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+func TestSignedResponse(t *testing.T) {
+	provider, err := NewSAMLProvider("http://localhost:8083")
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	// Verify with the certificate published in the metadata
+	certDER, err := base64.StdEncoding.DecodeString(provider.getCertificateString())
+	if err != nil {
+		t.Fatalf("Failed to decode certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	validator := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{Roots: []*x509.Certificate{cert}})
+
+	tests := []struct {
+		mode            string
+		signedResponse  bool
+		signedAssertion bool
+	}{
+		{SignAssertion, false, true},
+		{SignResponse, true, false},
+		{SignBoth, true, true},
+		{SignNone, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			if err := provider.SetSignMode(tt.mode); err != nil {
+				t.Fatalf("SetSignMode failed: %v", err)
+			}
+
+			response := provider.generateSAMLResponse("user@example.com", "session-1", "http://sp.example.com/acs", map[string]string{"role": "admin"}, nil)
+			encoded, err := provider.encodeSAMLResponse(response)
+			if err != nil {
+				t.Fatalf("Failed to encode response: %v", err)
+			}
+			xmlData, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			doc := etree.NewDocument()
+			if err := doc.ReadFromBytes(xmlData); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			root := doc.Root()
+			assertion := root.FindElement("./Assertion")
+
+			for _, el := range []struct {
+				name     string
+				element  *etree.Element
+				expected bool
+			}{
+				{"response", root, tt.signedResponse},
+				{"assertion", assertion, tt.signedAssertion},
+			} {
+				signature := el.element.FindElement("./Signature")
+				if (signature != nil) != el.expected {
+					t.Fatalf("Expected %s signed=%v", el.name, el.expected)
+				}
+				if signature == nil {
+					continue
+				}
+				if previous := el.element.ChildElements()[0]; previous.Tag != "Issuer" || el.element.ChildElements()[1] != signature {
+					t.Errorf("Expected the %s signature right after the Issuer", el.name)
+				}
+				if _, err := validator.Validate(el.element.Copy()); err != nil {
+					t.Errorf("Expected a valid %s signature, got %v", el.name, err)
+				}
+			}
+
+			// Tampering with the signed content breaks the signature
+			if tt.signedAssertion {
+				assertion.FindElement("./Subject/NameID").SetText("admin@example.com")
+				if _, err := validator.Validate(assertion.Copy()); err == nil {
+					t.Error("Expected a tampered assertion to fail validation")
+				}
+			}
+		})
+	}
+
+	if err := provider.SetSignMode("all"); err == nil {
+		t.Error("Expected an error for an invalid sign mode")
+	}
+}
+
+func TestHandleSSOAuthnRequest(t *testing.T) {
+	provider, err := NewSAMLProvider("http://localhost:8083")
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	authnRequest := `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"
+    ID="_request-42" Version="2.0" IssueInstant="2024-01-15T10:00:00Z"
+    Destination="http://localhost:8083/saml/sso"
+    AssertionConsumerServiceURL="http://sp.example.com/saml/acs"
+    ProtocolBinding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST">
+  <saml:Issuer>http://sp.example.com/metadata</saml:Issuer>
+</samlp:AuthnRequest>`
+
+	var deflated bytes.Buffer
+	writer, _ := flate.NewWriter(&deflated, flate.DefaultCompression)
+	if _, err := writer.Write([]byte(authnRequest)); err != nil {
+		t.Fatalf("Failed to deflate request: %v", err)
+	}
+	writer.Close() //nolint:errcheck // in-memory writer
+
+	redirect := httptest.NewRequest("GET", "/saml/sso?"+url.Values{
+		"SAMLRequest": {base64.StdEncoding.EncodeToString(deflated.Bytes())},
+		"RelayState":  {"/dashboard"},
+	}.Encode(), nil)
+	post := httptest.NewRequest("POST", "/saml/sso", strings.NewReader(url.Values{
+		"SAMLRequest": {base64.StdEncoding.EncodeToString([]byte(authnRequest))},
+	}.Encode()))
+	post.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	formValue := regexp.MustCompile(`name="SAMLResponse" value="([^"]+)"`)
+
+	for name, req := range map[string]*http.Request{"HTTP-Redirect": redirect, "HTTP-POST": post} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			provider.HandleSSO(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), `action="http://sp.example.com/saml/acs"`) {
+				t.Errorf("Expected the form to post to the request's ACS URL, got %s", w.Body.String())
+			}
+
+			match := formValue.FindStringSubmatch(w.Body.String())
+			if match == nil {
+				t.Fatalf("SAMLResponse not found in %s", w.Body.String())
+			}
+			xmlData, err := base64.StdEncoding.DecodeString(match[1])
+			if err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			doc := etree.NewDocument()
+			if err := doc.ReadFromBytes(xmlData); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+
+			root := doc.Root()
+			if got := root.SelectAttrValue("InResponseTo", ""); got != "_request-42" {
+				t.Errorf("Expected InResponseTo _request-42, got %q", got)
+			}
+			if got := root.SelectAttrValue("Destination", ""); got != "http://sp.example.com/saml/acs" {
+				t.Errorf("Expected Destination of the ACS URL, got %q", got)
+			}
+			confirmation := root.FindElement("./Assertion/Subject/SubjectConfirmation/SubjectConfirmationData")
+			if got := confirmation.SelectAttrValue("InResponseTo", ""); got != "_request-42" {
+				t.Errorf("Expected subject confirmation InResponseTo _request-42, got %q", got)
+			}
+			if got := root.FindElement("./Assertion/Conditions/AudienceRestriction/Audience").Text(); got != "http://sp.example.com/metadata" {
+				t.Errorf("Expected the SP's issuer as audience, got %q", got)
+			}
+		})
+	}
+
+	// Invalid requests are rejected instead of falling back to the default ACS URL
+	w := httptest.NewRecorder()
+	provider.HandleSSO(w, httptest.NewRequest("GET", "/saml/sso?SAMLRequest=not-a-request", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid SAMLRequest, got %d", w.Code)
+	}
+}
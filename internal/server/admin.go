@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// SetAdminAccess restricts the control endpoints (/__*) to the given source networks.
+// Entries are CIDRs (e.g. "10.0.0.0/8") or single IPs. Requests using one of the open
+// methods (e.g. GET) are accepted from any source. An empty allowlist allows every source.
+func (s *Server) SetAdminAccess(allowCIDRs []string, openMethods []string) error {
+	nets := make([]*net.IPNet, 0, len(allowCIDRs))
+	for _, entry := range allowCIDRs {
+		network, err := parseAdminNet(entry)
+		if err != nil {
+			return err
+		}
+		nets = append(nets, network)
+	}
+
+	methods := make(map[string]bool, len(openMethods))
+	for _, method := range openMethods {
+		methods[strings.ToUpper(method)] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adminNets = nil
+	if len(nets) > 0 {
+		s.adminNets = nets
+	}
+	s.adminOpenMethods = methods
+	return nil
+}
+
+// parseAdminNet parses a CIDR, or a single IP as a network containing only that IP
+func parseAdminNet(entry string) (*net.IPNet, error) {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid admin CIDR %q: %w", entry, err)
+		}
+		return network, nil
+	}
+
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid admin IP %q", entry)
+	}
+	bits := 32
+	if ip.To4() == nil {
+		bits = 128
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// withControl wraps a control endpoint handler so it enforces the admin access
+// restrictions and applies the server's CORS configuration
+func (s *Server) withControl(next http.HandlerFunc) http.HandlerFunc {
+	cors := s.withCORS(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.adminAllowed(r) {
+			log.Printf("%s %s from %s: source not allowed to call control endpoints, returning 403\n", r.Method, r.URL.Path, r.RemoteAddr)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		cors(w, r)
+	}
+}
+
+// adminAllowed reports whether the request may call a control endpoint. The source is the
+// connection's peer address; forwarding headers are ignored since clients can set them.
+func (s *Server) adminAllowed(r *http.Request) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.adminNets == nil || s.adminOpenMethods[r.Method] {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range s.adminNets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/matcher"
+)
+
+// defaultAuthRealm is the realm of WWW-Authenticate challenges if the mock doesn't set one
+const defaultAuthRealm = "pmp-mock-http"
+
+// writeAuthError writes a 401 with a Basic (RFC 7617) or Bearer (RFC 6750) challenge for
+// a request whose credentials are missing or wrong
+func (s *Server) writeAuthError(w http.ResponseWriter, authErr *matcher.AuthError) {
+	realm := authErr.Auth.Realm
+	if realm == "" {
+		realm = defaultAuthRealm
+	}
+
+	if strings.EqualFold(authErr.Auth.Type, matcher.AuthBearer) {
+		if authErr.Missing {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q`, realm))
+		} else {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q, error="invalid_token"`, realm))
+		}
+	} else {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, realm))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"error":             "unauthorized",
+		"error_description": authErr.Error(),
+	}); err != nil {
+		log.Printf("Error encoding response: %v\n", err)
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tlsRecordTypeHandshake is the first byte of a TLS ClientHello record
+const tlsRecordTypeHandshake = 0x16
+
+// detectTimeout is how long a new connection may take to send its first byte
+const detectTimeout = 10 * time.Second
+
+// StartAutoTLS serves HTTPS and plain HTTP on the same port. The first byte of each
+// connection tells a TLS ClientHello apart from a plaintext HTTP request.
+func (s *Server) StartAutoTLS(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	addr := fmt.Sprintf(":%d", s.port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("Mock server listening on http://localhost%s and https://localhost%s (TLS auto-detection)\n", addr, addr)
+
+	server := &http.Server{
+		Addr:           addr,
+		Handler:        s.Handler(),
+		MaxHeaderBytes: s.maxHeaderBytes,
+	}
+
+	tlsConfig := s.applyClientAuth(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"http/1.1"},
+	})
+	s.trackHTTPServer(server)
+	return serveResult(server.Serve(newAutoTLSListener(listener, tlsConfig)))
+}
+
+// autoTLSListener wraps accepted connections in TLS when they start with a TLS handshake.
+// Detection runs per connection, so a client that doesn't send anything can't block Accept.
+type autoTLSListener struct {
+	net.Listener
+	config    *tls.Config
+	conns     chan net.Conn
+	errs      chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newAutoTLSListener(inner net.Listener, config *tls.Config) *autoTLSListener {
+	l := &autoTLSListener{
+		Listener: inner,
+		config:   config,
+		conns:    make(chan net.Conn),
+		errs:     make(chan error, 1),
+		done:     make(chan struct{}),
+	}
+	go l.acceptLoop()
+	return l
+}
+
+// acceptLoop accepts connections from the inner listener and detects their protocol
+func (l *autoTLSListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.errs <- err:
+			case <-l.done:
+			}
+			return
+		}
+		go l.detect(conn)
+	}
+}
+
+// detect peeks the first byte of the connection and hands it to Accept, wrapped in TLS if needed
+func (l *autoTLSListener) detect(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	_ = conn.SetReadDeadline(time.Now().Add(detectTimeout))
+	first, err := reader.Peek(1)
+	_ = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close() //nolint:errcheck // connection never sent a request
+		return
+	}
+
+	var detected net.Conn = &peekedConn{Conn: conn, reader: reader}
+	if first[0] == tlsRecordTypeHandshake {
+		detected = tls.Server(detected, l.config)
+	}
+
+	select {
+	case l.conns <- detected:
+	case <-l.done:
+		detected.Close() //nolint:errcheck // listener is closed
+	}
+}
+
+// Accept returns the next connection whose protocol has been detected
+func (l *autoTLSListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting connections
+func (l *autoTLSListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// peekedConn is a connection whose first bytes were buffered while detecting its protocol
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Read reads the buffered bytes before the rest of the connection
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
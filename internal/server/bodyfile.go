@@ -0,0 +1,83 @@
+package server
+
+import (
+	"crypto/sha256"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+)
+
+// serveBodyFile sends the body file of a response. 200 responses are served like
+// http.ServeContent: Range requests get 206 (or 416 if unsatisfiable), and the Content-Type is
+// detected from the file name if none is set. Other status codes send the whole file.
+// Returns the status code sent.
+func (s *Server) serveBodyFile(w http.ResponseWriter, r *http.Request, resp *models.Response, statusCode int) int {
+	file, err := os.Open(resp.BodyFile)
+	if err != nil {
+		log.Printf("Error opening body file: %v\n", err)
+		http.Error(w, "Error reading body file", http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+	defer file.Close() //nolint:errcheck // read-only file
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		log.Printf("Error reading body file %s: not a regular file\n", resp.BodyFile)
+		http.Error(w, "Error reading body file", http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+
+	// ServeContent answers If-None-Match and If-Range based on the ETag header
+	if resp.ETag != "" {
+		etag := responseETag(resp.ETag, "")
+		if strings.EqualFold(resp.ETag, ETagAuto) {
+			hash := sha256.New()
+			if _, err := io.Copy(hash, file); err != nil {
+				log.Printf("Error hashing body file: %v\n", err)
+			}
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				log.Printf("Error rewinding body file: %v\n", err)
+			}
+			etag = hashETag(hash.Sum(nil))
+		}
+		w.Header().Set("ETag", etag)
+	}
+
+	var body http.ResponseWriter = w
+	if resp.ThrottleBytesPerSec > 0 {
+		body = newThrottledWriter(r.Context(), w, resp.ThrottleBytesPerSec)
+	}
+
+	if statusCode != http.StatusOK {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+		w.WriteHeader(statusCode)
+		if r.Method != http.MethodHead {
+			if _, err := io.Copy(body, file); err != nil {
+				log.Printf("Error writing response body: %v\n", err)
+			}
+		}
+		return statusCode
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes") // Also advertised on 416 responses
+	recorder := &statusWriter{ResponseWriter: body, status: http.StatusOK}
+	http.ServeContent(recorder, r, info.Name(), info.ModTime(), file)
+	return recorder.status
+}
+
+// statusWriter remembers the status code written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before writing it
+func (w *statusWriter) WriteHeader(statusCode int) {
+	w.status = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// SetTLSClientCA makes TLS listeners request client certificates and verify them against the
+// CA certificates of the PEM file, for mocks matching on client_cert. Clients without a
+// certificate can still connect; clients with an invalid one are rejected during the handshake.
+func (s *Server) SetTLSClientCA(caFile string) error {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return fmt.Errorf("failed to read client CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no PEM certificates found in client CA file %s", caFile)
+	}
+	s.clientCAs = pool
+	return nil
+}
+
+// applyClientAuth configures client certificate verification on a TLS config, if enabled
+func (s *Server) applyClientAuth(config *tls.Config) *tls.Config {
+	if s.clientCAs == nil {
+		return config
+	}
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	config.ClientCAs = s.clientCAs
+	return config
+}
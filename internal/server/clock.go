@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/clock"
+)
+
+// useClock makes the server and its components read the time from the given clock
+func (s *Server) useClock(c *clock.Virtual) {
+	s.clock = c
+	s.templateRenderer.SetClock(c)
+	s.callbackExecutor.SetClock(c)
+	s.matcher.SetClock(c)
+}
+
+// Clock returns the server's virtual clock. It follows the system time until it's fixed,
+// e.g. with --fixed-time or the /__clock endpoint.
+func (s *Server) Clock() *clock.Virtual {
+	return s.clock
+}
+
+// clockUpdate is the request body of POST /__clock
+type clockUpdate struct {
+	Time    string `json:"time"`    // RFC 3339 time to fix the clock at
+	Advance string `json:"advance"` // Duration to move the clock by, e.g. "1h30m" (applied after time)
+}
+
+// handleClock handles reading (GET), setting or advancing (POST) and resetting (DELETE) the virtual clock
+func (s *Server) handleClock(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var update clockUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if update.Time == "" && update.Advance == "" {
+			http.Error(w, "time or advance is required", http.StatusBadRequest)
+			return
+		}
+
+		var advance time.Duration
+		if update.Advance != "" {
+			d, err := time.ParseDuration(update.Advance)
+			if err != nil {
+				http.Error(w, "Invalid advance duration: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			advance = d
+		}
+		if update.Time != "" {
+			t, err := time.Parse(time.RFC3339, update.Time)
+			if err != nil {
+				http.Error(w, "Invalid time, expected RFC 3339: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.clock.Set(t)
+		}
+		if update.Advance != "" {
+			s.clock.Advance(advance)
+		}
+		log.Printf("Virtual clock set to %s\n", s.clock.Now().Format(time.RFC3339))
+	case http.MethodDelete:
+		s.clock.Reset()
+		log.Printf("Virtual clock reset to the system time\n")
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"time":  s.clock.Now().Format(time.RFC3339Nano),
+		"fixed": s.clock.Fixed(),
+	}); err != nil {
+		log.Printf("Error encoding response: %v\n", err)
+	}
+}
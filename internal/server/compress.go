@@ -0,0 +1,49 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/compress"
+)
+
+// DefaultCompressMinBytes is the smallest body that is compressed by default
+const DefaultCompressMinBytes = 1024
+
+// SetCompression enables gzip compression of mock response bodies for clients that accept it.
+// Bodies shorter than minBytes are sent uncompressed; level is a gzip level from 1 (fastest) to 9 (best).
+func (s *Server) SetCompression(enabled bool, level, minBytes int) {
+	s.compress = enabled
+	s.compressLevel = level
+	s.compressMinBytes = minBytes
+}
+
+// compressBody gzips the response body if compression is enabled and applies to the request.
+// It sets the Content-Encoding and Vary headers and returns false when the body is sent as is.
+func (s *Server) compressBody(w http.ResponseWriter, r *http.Request, body string) ([]byte, bool) {
+	if !s.compress || len(body) < s.compressMinBytes {
+		return nil, false
+	}
+	// Mocks that set their own encoding already return an encoded body
+	if w.Header().Get("Content-Encoding") != "" || !compress.AcceptsGzip(r.Header.Get("Accept-Encoding")) {
+		return nil, false
+	}
+
+	var compressed bytes.Buffer
+	gzipWriter, err := gzip.NewWriterLevel(&compressed, s.compressLevel)
+	if err != nil {
+		return nil, false
+	}
+	if _, err := gzipWriter.Write([]byte(body)); err != nil {
+		return nil, false
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, false
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Del("Content-Length")
+	return compressed.Bytes(), true
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+	"github.com/comfortablynumb/pmp-mock-http/internal/template"
+)
+
+// sameSiteModes maps the same_site values of response cookies to their http.SameSite mode
+var sameSiteModes = map[string]http.SameSite{
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
+// setCookies adds a Set-Cookie header for each cookie of the response. Values are rendered as
+// templates if the response body is a template; values that fail to render are sent unchanged.
+func (s *Server) setCookies(w http.ResponseWriter, resp *models.Response, requestData *template.RequestData) {
+	for _, cookie := range resp.Cookies {
+		value := cookie.Value
+		if resp.Template {
+			rendered, err := s.templateRenderer.RenderWithDelims(value, requestData, resp.TemplateDelims)
+			if err != nil {
+				log.Printf("Error rendering cookie template for '%s': %v\n", cookie.Name, err)
+			} else {
+				value = rendered
+			}
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookie.Name,
+			Value:    value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			MaxAge:   cookie.MaxAge,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HTTPOnly,
+			SameSite: sameSiteModes[strings.ToLower(cookie.SameSite)],
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+)
+
+// Server-wide CORS settings used by mocks when CORS isn't enabled for the whole server
+const (
+	defaultCORSOrigins = "*"
+	defaultCORSMethods = "GET,POST,PUT,DELETE,PATCH,OPTIONS"
+	defaultCORSHeaders = "Content-Type,Authorization"
+	defaultCORSMaxAge  = 86400 // 24 hours
+)
+
+// handleMockPreflight answers a CORS preflight with the CORS settings of the mock the
+// actual request would go to. Returns false if no such mock defines CORS, leaving the
+// preflight to the server-wide configuration.
+func (s *Server) handleMockPreflight(w http.ResponseWriter, r *http.Request) bool {
+	method := r.Header.Get("Access-Control-Request-Method")
+	if r.Method != http.MethodOptions || method == "" {
+		return false
+	}
+
+	preflight := r.Clone(r.Context())
+	preflight.Method = method
+	s.mu.RLock()
+	cors := s.matcher.FindCORS(preflight)
+	s.mu.RUnlock()
+	if cors == nil {
+		return false
+	}
+
+	s.applyMockCORS(w, r, cors)
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// applyMockCORS sets the CORS headers of a mock, replacing the server-wide ones. Settings
+// the mock leaves empty fall back to the server-wide configuration.
+func (s *Server) applyMockCORS(w http.ResponseWriter, r *http.Request, cors *models.CORSConfig) {
+	origins, methods, headers := defaultCORSOrigins, defaultCORSMethods, defaultCORSHeaders
+	if s.corsConfig != nil && s.corsConfig.Enabled {
+		origins, methods, headers = s.corsConfig.Origins, s.corsConfig.Methods, s.corsConfig.Headers
+	}
+	if cors.Origins != "" {
+		origins = cors.Origins
+	}
+	if cors.Methods != "" {
+		methods = cors.Methods
+	}
+	if cors.Headers != "" {
+		headers = cors.Headers
+	}
+	maxAge := cors.MaxAge
+	if maxAge <= 0 {
+		maxAge = defaultCORSMaxAge
+	}
+
+	h := w.Header()
+	if origin := allowedOrigin(origins, r.Header.Get("Origin"), cors.Credentials); origin != "" {
+		h.Set("Access-Control-Allow-Origin", origin)
+	} else {
+		h.Del("Access-Control-Allow-Origin")
+	}
+	if origins != "*" || cors.Credentials {
+		h.Add("Vary", "Origin")
+	}
+	h.Set("Access-Control-Allow-Methods", methods)
+	h.Set("Access-Control-Allow-Headers", headers)
+	h.Set("Access-Control-Max-Age", strconv.Itoa(maxAge))
+	if cors.Credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	} else {
+		h.Del("Access-Control-Allow-Credentials")
+	}
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for the request origin, or ""
+// if it isn't allowed. Credentialed requests get the origin echoed, since browsers reject "*".
+func allowedOrigin(origins, origin string, credentials bool) string {
+	for _, allowed := range strings.Split(origins, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" {
+			if credentials && origin != "" {
+				return origin
+			}
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
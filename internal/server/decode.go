@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// maxDecodedBodySize is the largest decompressed request body, so small compressed bodies
+// can't expand to gigabytes in memory
+const maxDecodedBodySize = 16 << 20
+
+// decodeRequestBody decompresses a request body according to its Content-Encoding header.
+// Multiple encodings are undone in reverse order of application. Unknown encodings and
+// bodies decompressing to more than maxDecodedBodySize return an error.
+func decodeRequestBody(contentEncoding string, body []byte) ([]byte, error) {
+	if contentEncoding == "" || len(body) == 0 {
+		return body, nil
+	}
+
+	encodings := strings.Split(contentEncoding, ",")
+	decoded := body
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+
+		var reader io.Reader
+		switch encoding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			gzipReader, err := gzip.NewReader(bytes.NewReader(decoded))
+			if err != nil {
+				return nil, fmt.Errorf("invalid gzip body: %w", err)
+			}
+			defer gzipReader.Close() //nolint:errcheck // cleanup operation
+			reader = gzipReader
+		case "deflate":
+			// Most clients send zlib-wrapped deflate, but some send raw deflate streams
+			zlibReader, err := zlib.NewReader(bytes.NewReader(decoded))
+			if err != nil {
+				reader = flate.NewReader(bytes.NewReader(decoded))
+			} else {
+				defer zlibReader.Close() //nolint:errcheck // cleanup operation
+				reader = zlibReader
+			}
+		case "br":
+			reader = brotli.NewReader(bytes.NewReader(decoded))
+		default:
+			return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+		}
+
+		var err error
+		decoded, err = io.ReadAll(io.LimitReader(reader, maxDecodedBodySize+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s body: %w", encoding, err)
+		}
+		if len(decoded) > maxDecodedBodySize {
+			return nil, fmt.Errorf("decoded %s body exceeds %d bytes", encoding, maxDecodedBodySize)
+		}
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+	"github.com/comfortablynumb/pmp-mock-http/internal/template"
+	"github.com/comfortablynumb/pmp-mock-http/internal/tracker"
+)
+
+// SetDefaultResponse sets the response returned for unmatched requests when no proxy is
+// configured, instead of a plain 404. A zero status code defaults to 404.
+func (s *Server) SetDefaultResponse(response *models.Response) {
+	s.defaultResponse = response
+}
+
+// SetDefaultResponseMock uses the response of the named mock for unmatched requests when no
+// proxy is configured. The mock is looked up on every request, so reloads apply.
+func (s *Server) SetDefaultResponseMock(name string) {
+	s.defaultMock = name
+}
+
+// getDefaultResponse returns the response for unmatched requests, or nil for a plain 404
+func (s *Server) getDefaultResponse() *models.Response {
+	if s.defaultMock != "" {
+		s.mu.RLock()
+		mock := s.matcher.GetMock(s.defaultMock)
+		s.mu.RUnlock()
+		if mock != nil {
+			return &mock.Response
+		}
+		log.Printf("Warning: default response mock '%s' not found\n", s.defaultMock)
+	}
+	return s.defaultResponse
+}
+
+// writeDefaultResponse writes the default response of an unmatched request, rendering its
+// headers and body as templates if enabled
+func (s *Server) writeDefaultResponse(w http.ResponseWriter, r *http.Request, response *models.Response, headers map[string]string, body string) {
+	requestData := template.NewRequestData(r, body)
+
+	for key, value := range s.renderHeaderTemplates(response.Headers, response.HeaderTemplates, response.TemplateDelims, requestData) {
+		w.Header().Set(key, value)
+	}
+
+	responseBody := response.Body
+	if response.Template && responseBody != "" {
+		rendered, err := s.templateRenderer.RenderWithDelims(responseBody, requestData, response.TemplateDelims)
+		if err != nil {
+			log.Printf("Error rendering default response template: %v\n", err)
+		} else {
+			responseBody = rendered
+		}
+	}
+
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusNotFound
+	}
+	w.WriteHeader(statusCode)
+	if r.Method != http.MethodHead {
+		if _, err := w.Write([]byte(responseBody)); err != nil {
+			log.Printf("Error writing default response: %v\n", err)
+		}
+	}
+
+	if s.tracker != nil {
+		s.trackRequest(w, r, tracker.RequestLog{
+			Method: r.Method, URI: r.URL.RequestURI(), Headers: headers, Body: body,
+			Matched: false, StatusCode: statusCode,
+			Response: responseBody, RemoteAddr: r.RemoteAddr,
+		})
+	}
+}
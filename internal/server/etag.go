@@ -0,0 +1,55 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// ETagAuto makes the server compute the ETag of a response from its body
+const ETagAuto = "auto"
+
+// responseETag returns the ETag header value of a response, or "" if it has none.
+// Configured values are quoted if needed; "auto" hashes the rendered body.
+func responseETag(etag, body string) string {
+	switch {
+	case etag == "":
+		return ""
+	case strings.EqualFold(etag, ETagAuto):
+		sum := sha256.Sum256([]byte(body))
+		return hashETag(sum[:])
+	case strings.HasPrefix(etag, `"`), strings.HasPrefix(etag, `W/"`):
+		return etag
+	default:
+		return `"` + etag + `"`
+	}
+}
+
+// hashETag builds a strong ETag from a content hash
+func hashETag(sum []byte) string {
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// isNotModified reports whether a GET or HEAD request's If-None-Match matches the ETag of a
+// successful response. Uses the weak comparison of RFC 9110, so W/ prefixes are ignored.
+func isNotModified(r *http.Request, statusCode int, etag string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if statusCode < 200 || statusCode > 299 {
+		return false
+	}
+
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/tracker"
+)
+
+// Expect: 100-continue actions
+const (
+	expectActionContinue = "continue"
+	expectActionWithhold = "withhold"
+	expectActionReject   = "reject"
+)
+
+// handleExpectContinue applies the 100-continue configuration of the matching mock before
+// the body is read (reading it is what makes net/http send "100 Continue").
+// Returns true if the request has been fully handled.
+func (s *Server) handleExpectContinue(w http.ResponseWriter, r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+		return false
+	}
+
+	s.mu.RLock()
+	config := s.matcher.FindExpectContinue(r)
+	s.mu.RUnlock()
+	if config == nil {
+		return false
+	}
+
+	switch strings.ToLower(config.Action) {
+	case expectActionReject:
+		log.Printf("%s %s from %s\n", r.Method, r.URL.Path, r.RemoteAddr)
+		log.Printf("Rejected Expect: 100-continue with 417\n")
+		http.Error(w, "Expectation Failed", http.StatusExpectationFailed)
+		if s.tracker != nil {
+			s.trackRequest(w, r, tracker.RequestLog{
+				Method: r.Method, URI: r.URL.RequestURI(),
+				Matched: false, StatusCode: http.StatusExpectationFailed,
+				RemoteAddr: r.RemoteAddr,
+			})
+		}
+		return true
+	case expectActionWithhold:
+		// Never read the body, so no 100 Continue is sent and the mock answers right away
+		r.Body = http.NoBody
+		return false
+	}
+
+	if config.Delay > 0 {
+		time.Sleep(time.Duration(config.Delay) * time.Millisecond)
+	}
+	return false
+}
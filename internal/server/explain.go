@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/matcher"
+)
+
+// explainRequest describes the request to explain
+type explainRequest struct {
+	Method  string            `json:"method"` // Defaults to GET
+	URI     string            `json:"uri"`    // Path and query string, e.g. "/api/users?page=2"
+	Host    string            `json:"host"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// handleMatchExplain reports, for every mock, which of its conditions the described request
+// passes or fails, without affecting sequences, counters or scenario states
+func (s *Server) handleMatchExplain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var desc explainRequest
+	if err := json.NewDecoder(r.Body).Decode(&desc); err != nil {
+		http.Error(w, "Invalid request description: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if desc.Method == "" {
+		desc.Method = http.MethodGet
+	}
+	if !strings.HasPrefix(desc.URI, "/") {
+		http.Error(w, "Invalid request description: uri must start with '/'", http.StatusBadRequest)
+		return
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(desc.Method), desc.URI, strings.NewReader(desc.Body))
+	if err != nil {
+		http.Error(w, "Invalid request description: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Host = desc.Host
+	for key, value := range desc.Headers {
+		req.Header.Set(key, value)
+	}
+
+	s.mu.RLock()
+	explanations, err := s.matcher.ExplainMatch(req)
+	s.mu.RUnlock()
+	if err != nil {
+		http.Error(w, "Failed to explain request: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"match":        matcher.ExplainedMatch(explanations),
+		"explanations": explanations,
+	}); err != nil {
+		log.Printf("Error encoding response: %v\n", err)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/tracker"
+)
+
+// DefaultMaintenanceBody is the response body sent while in maintenance mode if none is configured
+const DefaultMaintenanceBody = `{"error":"Service under maintenance","status":503}`
+
+// SetMaintenance configures maintenance mode. While enabled, every request except the
+// allowed paths gets a 503 with the given body (DefaultMaintenanceBody if empty).
+func (s *Server) SetMaintenance(enabled bool, body string, allowPaths []string) {
+	if body == "" {
+		body = DefaultMaintenanceBody
+	}
+
+	s.maintenanceBody = body
+	s.maintenanceAllow = make(map[string]bool, len(allowPaths))
+	for _, path := range allowPaths {
+		s.maintenanceAllow[path] = true
+	}
+	s.maintenance.Store(enabled)
+}
+
+// handleMaintenanceMode writes the maintenance response if maintenance mode is enabled
+// and the path isn't allowed. Returns true if the request has been handled.
+func (s *Server) handleMaintenanceMode(w http.ResponseWriter, r *http.Request) bool {
+	if !s.maintenance.Load() || s.maintenanceAllow[r.URL.Path] {
+		return false
+	}
+
+	body := s.maintenanceBody
+	if body == "" {
+		body = DefaultMaintenanceBody
+	}
+
+	log.Printf("%s %s from %s: maintenance mode, returning 503\n", r.Method, r.URL.Path, r.RemoteAddr)
+	if json.Valid([]byte(body)) {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if _, err := w.Write([]byte(body)); err != nil {
+		log.Printf("Error writing maintenance response: %v\n", err)
+	}
+
+	if s.tracker != nil {
+		s.trackRequest(w, r, tracker.RequestLog{
+			Method: r.Method, URI: r.URL.RequestURI(),
+			Matched: false, StatusCode: http.StatusServiceUnavailable,
+			Response: body, RemoteAddr: r.RemoteAddr,
+		})
+	}
+	return true
+}
+
+// handleMaintenance handles reading (GET) and toggling (POST) maintenance mode
+func (s *Server) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Enabled == nil {
+			http.Error(w, "enabled is required", http.StatusBadRequest)
+			return
+		}
+		s.maintenance.Store(*req.Enabled)
+		log.Printf("Maintenance mode enabled: %v\n", *req.Enabled)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": s.maintenance.Load(),
+	}); err != nil {
+		log.Printf("Error encoding response: %v\n", err)
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+)
+
+// ManagedMocks provides mocks managed outside of the mock files, e.g. by the management API
+type ManagedMocks interface {
+	GetAllMocks() []models.Mock
+}
+
+// SetManagedMocks serves the mocks of the source along with the mocks of the files, and
+// keeps them when the files are reloaded. Call RefreshManagedMocks when they change.
+func (s *Server) SetManagedMocks(source ManagedMocks) {
+	s.mu.Lock()
+	s.managedMocks = source
+	s.mu.Unlock()
+
+	s.RefreshManagedMocks()
+}
+
+// RefreshManagedMocks re-reads the managed mocks and updates the served mocks with them.
+// Refreshes run one at a time, so overlapping ones can't apply an older read last. The
+// sequence counters and random sources of the mocks that didn't change are kept.
+func (s *Server) RefreshManagedMocks() {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	s.mu.RLock()
+	source := s.managedMocks
+	s.mu.RUnlock()
+
+	var managed []models.Mock
+	if source != nil {
+		managed = source.GetAllMocks()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.managed = managed
+	s.matcher.SwapMocks(s.servedMocks())
+}
+
+// servedMocks returns the file mocks followed by the managed mocks. The caller must hold s.mu.
+func (s *Server) servedMocks() []models.Mock {
+	if len(s.managed) == 0 {
+		return s.fileMocks
+	}
+
+	mocks := make([]models.Mock, 0, len(s.fileMocks)+len(s.managed))
+	mocks = append(mocks, s.fileMocks...)
+	return append(mocks, s.managed...)
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/oauth"
+)
+
+// SetOAuthProvider serves the OAuth2 provider's endpoints next to the mocks. Call it before
+// starting the server. The endpoints take precedence over mocks with the same paths.
+func (s *Server) SetOAuthProvider(provider *oauth.OAuth2Provider) {
+	s.oauthProvider = provider
+}
+
+// registerOAuthEndpoints registers the OAuth2 provider's endpoints, wrapped in the CORS
+// middleware so browser-based clients can exchange tokens
+func (s *Server) registerOAuthEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("/oauth/authorize", s.withCORS(s.oauthProvider.HandleAuthorize))
+	mux.HandleFunc("/oauth/token", s.withCORS(s.oauthProvider.HandleToken))
+	mux.HandleFunc("/oauth/userinfo", s.withCORS(s.oauthProvider.HandleUserInfo))
+	mux.HandleFunc("/.well-known/jwks.json", s.withCORS(s.oauthProvider.HandleJWKS))
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/plugins"
+)
+
+// SetPluginManager sets the plugin manager listed by /__plugins and the function
+// /__plugins/refresh runs to update the plugins and reload the mocks
+func (s *Server) SetPluginManager(manager *plugins.Manager, refresh func() error) {
+	s.pluginManager = manager
+	s.pluginRefresh = refresh
+}
+
+// handlePluginsList handles listing the loaded plugins
+func (s *Server) handlePluginsList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pluginList := []plugins.PluginInfo{}
+	if s.pluginManager != nil {
+		pluginList = s.pluginManager.GetPlugins()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"plugins": pluginList,
+		"count":   len(pluginList),
+	}); err != nil {
+		log.Printf("Error encoding response: %v\n", err)
+	}
+}
+
+// handlePluginsRefresh handles pulling the plugin repositories and reloading the mocks
+func (s *Server) handlePluginsRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.pluginRefresh == nil {
+		http.Error(w, "Plugin refresh not available", http.StatusNotImplemented)
+		return
+	}
+
+	if err := s.pluginRefresh(); err != nil {
+		log.Printf("Error refreshing plugins: %v\n", err)
+		http.Error(w, "Failed to refresh plugins: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pluginList := []plugins.PluginInfo{}
+	if s.pluginManager != nil {
+		pluginList = s.pluginManager.GetPlugins()
+	}
+
+	log.Println("Plugins refreshed")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"plugins": pluginList,
+		"count":   len(pluginList),
+	}); err != nil {
+		log.Printf("Error encoding response: %v\n", err)
+	}
+}
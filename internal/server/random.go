@@ -0,0 +1,34 @@
+package server
+
+import (
+	"math/rand"
+	"time"
+)
+
+// SetSeed seeds the random source of chaos injection, random latencies and weighted
+// response selection, making them reproducible for a given request order. Zero uses a
+// random seed.
+func (s *Server) SetSeed(seed int64) {
+	s.matcher.SetSeed(seed)
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	s.rngMu.Lock()
+	defer s.rngMu.Unlock()
+	s.rng = rand.New(rand.NewSource(seed))
+}
+
+// randFloat returns a random number in [0.0, 1.0) from the server's source
+func (s *Server) randFloat() float64 {
+	s.rngMu.Lock()
+	defer s.rngMu.Unlock()
+	return s.rng.Float64()
+}
+
+// randIntn returns a random number in [0, n) from the server's source
+func (s *Server) randIntn(n int) int {
+	s.rngMu.Lock()
+	defer s.rngMu.Unlock()
+	return s.rng.Intn(n)
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+)
+
+// resetConnection closes the client connection without writing a response. TCP connections
+// are closed with SO_LINGER 0, so the client sees a reset instead of a clean EOF. Reports
+// false if the connection can't be hijacked (HTTP/2 and HTTP/3).
+func resetConnection(w http.ResponseWriter) bool {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		log.Printf("Warning: chaos connection_reset requires HTTP/1.x, sending a regular response\n")
+		return false
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("Error hijacking connection for reset: %v\n", err)
+		return false
+	}
+
+	raw := conn
+	if tlsConn, ok := raw.(*tls.Conn); ok {
+		raw = tlsConn.NetConn()
+	}
+	if tcpConn, ok := raw.(*net.TCPConn); ok {
+		_ = tcpConn.SetLinger(0)
+	}
+	raw.Close() //nolint:errcheck // connection is discarded
+	return true
+}
@@ -2,20 +2,30 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"math/rand"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/comfortablynumb/pmp-mock-http/internal/callback"
+	"github.com/comfortablynumb/pmp-mock-http/internal/clock"
 	"github.com/comfortablynumb/pmp-mock-http/internal/matcher"
 	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+	"github.com/comfortablynumb/pmp-mock-http/internal/oauth"
 	"github.com/comfortablynumb/pmp-mock-http/internal/observability"
+	"github.com/comfortablynumb/pmp-mock-http/internal/plugins"
 	"github.com/comfortablynumb/pmp-mock-http/internal/proxy"
 	"github.com/comfortablynumb/pmp-mock-http/internal/recorder"
 	"github.com/comfortablynumb/pmp-mock-http/internal/sse"
@@ -45,8 +55,37 @@ type Server struct {
 	proxyClient      *proxy.Client
 	recorder         *recorder.Recorder
 	corsConfig       *CORSConfig
+	decodeBody       bool                          // Decompress request bodies before matching
+	compress         bool                          // Gzip response bodies for clients that accept it
+	compressLevel    int                           // Gzip compression level
+	compressMinBytes int                           // Smallest body that gets compressed
+	prettyJSON       bool                          // Indent JSON response bodies of every mock
+	maxHeaderBytes   int                           // Max request header size (0 = Go default of 1MB)
+	clientCAs        *x509.CertPool                // CAs verifying client certificates (nil = not requested)
+	defaultResponse  *models.Response              // Response for unmatched requests without a proxy (nil = 404)
+	defaultMock      string                        // Mock whose response is used for unmatched requests
+	pluginManager    *plugins.Manager              // Plugin repositories exposed via /__plugins
+	pluginRefresh    func() error                  // Re-runs plugin setup and reloads mocks
+	clock            *clock.Virtual                // Time source for templates and time-based matching
+	rng              *rand.Rand                    // Source for chaos and random latencies (see SetSeed)
+	rngMu            sync.Mutex                    // Protects rng
+	maintenance      atomic.Bool                   // Return 503 for every request except the allowed paths
+	maintenanceBody  string                        // Response body while in maintenance mode
+	maintenanceAllow map[string]bool               // Paths served normally during maintenance (e.g. health checks)
+	adminNets        []*net.IPNet                  // Sources allowed to call control endpoints (nil = any source)
+	adminOpenMethods map[string]bool               // Methods control endpoints accept from any source
+	oauthProvider    *oauth.OAuth2Provider         // Built-in OAuth2 provider served under /oauth/ (nil = disabled)
+	fileMocks        []models.Mock                 // Mocks passed to NewServer or UpdateMocks
+	managedMocks     ManagedMocks                  // Source of the mocks served along with fileMocks (nil = none)
+	managed          []models.Mock                 // Mocks last read from managedMocks
+	refreshMu        sync.Mutex                    // Serializes RefreshManagedMocks, so the newest read is applied last
 	wsHandlers       map[string]*websocket.Handler // Cache WebSocket handlers by mock name
 	sseHandlers      map[string]*sse.Handler       // Cache SSE handlers by mock name
+	handlersMu       sync.Mutex                    // Protects wsHandlers and sseHandlers
+	httpServers      []*http.Server                // Servers started by the Start methods, stopped by Shutdown
+	http3Servers     []*http3.Server               // HTTP/3 servers started by the Start methods
+	shutdown         bool                          // Set once Shutdown has been called
+	serversMu        sync.Mutex                    // Protects httpServers, http3Servers and shutdown
 	mu               sync.RWMutex
 }
 
@@ -61,9 +100,10 @@ func NewServer(port int, mocks []models.Mock, proxyConfig *proxy.Config, corsCon
 		}
 	}
 
-	return &Server{
+	s := &Server{
 		port:             port,
 		matcher:          matcher.NewMatcher(mocks),
+		fileMocks:        mocks,
 		tracker:          nil,
 		templateRenderer: template.NewRenderer(),
 		callbackExecutor: callback.NewExecutor(),
@@ -72,7 +112,11 @@ func NewServer(port int, mocks []models.Mock, proxyConfig *proxy.Config, corsCon
 		corsConfig:       corsConfig,
 		wsHandlers:       make(map[string]*websocket.Handler),
 		sseHandlers:      make(map[string]*sse.Handler),
+		rng:              rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
+	s.useClock(clock.NewVirtual())
+	s.callbackExecutor.SetVariables(s.templateRenderer.Variables())
+	return s
 }
 
 // NewServerWithTracker creates a new mock server with request tracking
@@ -86,9 +130,10 @@ func NewServerWithTracker(port int, mocks []models.Mock, t *tracker.Tracker, pro
 		}
 	}
 
-	return &Server{
+	s := &Server{
 		port:             port,
 		matcher:          matcher.NewMatcher(mocks),
+		fileMocks:        mocks,
 		tracker:          t,
 		templateRenderer: template.NewRenderer(),
 		callbackExecutor: callback.NewExecutor(),
@@ -97,177 +142,244 @@ func NewServerWithTracker(port int, mocks []models.Mock, t *tracker.Tracker, pro
 		corsConfig:       corsConfig,
 		wsHandlers:       make(map[string]*websocket.Handler),
 		sseHandlers:      make(map[string]*sse.Handler),
+		rng:              rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
+	s.useClock(clock.NewVirtual())
+	s.callbackExecutor.SetVariables(s.templateRenderer.Variables())
+	return s
+}
+
+// Handler returns a handler serving the mocks and the control endpoints (/__*), for
+// embedding the mock server in another program. Each call returns a new ServeMux.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleRequest)
+	s.registerControlEndpoints(mux)
+	if s.oauthProvider != nil {
+		s.registerOAuthEndpoints(mux)
+	}
+	return mux
 }
 
 // Start starts the HTTP server
 func (s *Server) Start() error {
-	http.HandleFunc("/", s.handleRequest)
+	addr := fmt.Sprintf(":%d", s.port)
+	log.Printf("Mock server listening on http://localhost%s\n", addr)
+
+	server := &http.Server{
+		Addr:           addr,
+		Handler:        s.Handler(),
+		MaxHeaderBytes: s.maxHeaderBytes,
+	}
+	s.trackHTTPServer(server)
+
+	return serveResult(server.ListenAndServe())
+}
 
+// registerControlEndpoints registers the recording and scenario control endpoints on the
+// given mux, wrapped in the CORS middleware so browser-based tooling can call them
+func (s *Server) registerControlEndpoints(mux *http.ServeMux) {
 	// Register recording control endpoints
-	http.HandleFunc("/__recording/start", s.handleRecordingStart)
-	http.HandleFunc("/__recording/stop", s.handleRecordingStop)
-	http.HandleFunc("/__recording/status", s.handleRecordingStatus)
-	http.HandleFunc("/__recording/clear", s.handleRecordingClear)
-	http.HandleFunc("/__recording/export", s.handleRecordingExport)
-	http.HandleFunc("/__recording/list", s.handleRecordingList)
+	mux.HandleFunc("/__recording/start", s.withControl(s.handleRecordingStart))
+	mux.HandleFunc("/__recording/stop", s.withControl(s.handleRecordingStop))
+	mux.HandleFunc("/__recording/status", s.withControl(s.handleRecordingStatus))
+	mux.HandleFunc("/__recording/clear", s.withControl(s.handleRecordingClear))
+	mux.HandleFunc("/__recording/export", s.withControl(s.handleRecordingExport))
+	mux.HandleFunc("/__recording/list", s.withControl(s.handleRecordingList))
 
 	// Register scenario control endpoints
-	http.HandleFunc("/__scenario/list", s.handleScenarioList)
-	http.HandleFunc("/__scenario/active", s.handleScenarioActive)
-	http.HandleFunc("/__scenario/set", s.handleScenarioSet)
+	mux.HandleFunc("/__scenario/list", s.withControl(s.handleScenarioList))
+	mux.HandleFunc("/__scenario/active", s.withControl(s.handleScenarioActive))
+	mux.HandleFunc("/__scenario/set", s.withControl(s.handleScenarioSet))
+	mux.HandleFunc("/__scenario/state", s.withControl(s.handleScenarioState))
 
-	addr := fmt.Sprintf(":%d", s.port)
-	log.Printf("Mock server listening on http://localhost%s\n", addr)
+	// Register plugin endpoints
+	mux.HandleFunc("/__plugins", s.withControl(s.handlePluginsList))
+	mux.HandleFunc("/__plugins/refresh", s.withControl(s.handlePluginsRefresh))
+
+	// Register virtual clock endpoint
+	mux.HandleFunc("/__clock", s.withControl(s.handleClock))
+
+	// Register maintenance mode endpoint
+	mux.HandleFunc("/__maintenance", s.withControl(s.handleMaintenance))
 
-	return http.ListenAndServe(addr, nil)
+	// Register template function listing
+	mux.HandleFunc("/__template/functions", s.withControl(s.handleTemplateFunctions))
+
+	// Register template variables endpoint
+	mux.HandleFunc("/__vars", s.withControl(s.handleVars))
+
+	// Register match debugging endpoint
+	mux.HandleFunc("/__match/explain", s.withControl(s.handleMatchExplain))
+
+	// Register mock hit counters
+	mux.HandleFunc("/__mocks/stats", s.withControl(s.handleMockStats))
+	mux.HandleFunc("/__mocks/stats/reset", s.withControl(s.handleMockStatsReset))
 }
 
-// StartTLS starts the HTTPS server with TLS and HTTP/2 support
-func (s *Server) StartTLS(certFile, keyFile string) error {
-	http.HandleFunc("/", s.handleRequest)
+// withCORS wraps a handler so it applies the server's CORS configuration
+func (s *Server) withCORS(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.applyCORS(w, r) {
+			return
+		}
+		next(w, r)
+	}
+}
 
-	// Register recording control endpoints
-	http.HandleFunc("/__recording/start", s.handleRecordingStart)
-	http.HandleFunc("/__recording/stop", s.handleRecordingStop)
-	http.HandleFunc("/__recording/status", s.handleRecordingStatus)
-	http.HandleFunc("/__recording/clear", s.handleRecordingClear)
-	http.HandleFunc("/__recording/export", s.handleRecordingExport)
-	http.HandleFunc("/__recording/list", s.handleRecordingList)
+// applyCORS sets the CORS headers if CORS is enabled.
+// Returns true if the request was a preflight request and has been fully handled.
+func (s *Server) applyCORS(w http.ResponseWriter, r *http.Request) bool {
+	if s.corsConfig == nil || !s.corsConfig.Enabled {
+		return false
+	}
 
-	// Register scenario control endpoints
-	http.HandleFunc("/__scenario/list", s.handleScenarioList)
-	http.HandleFunc("/__scenario/active", s.handleScenarioActive)
-	http.HandleFunc("/__scenario/set", s.handleScenarioSet)
+	w.Header().Set("Access-Control-Allow-Origin", s.corsConfig.Origins)
+	w.Header().Set("Access-Control-Allow-Methods", s.corsConfig.Methods)
+	w.Header().Set("Access-Control-Allow-Headers", s.corsConfig.Headers)
+	w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
 
+	// Handle preflight requests
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+
+	return false
+}
+
+// StartTLS starts the HTTPS server with TLS and HTTP/2 support
+func (s *Server) StartTLS(certFile, keyFile string) error {
 	addr := fmt.Sprintf(":%d", s.port)
 	log.Printf("Mock server listening on https://localhost%s (TLS with HTTP/2 enabled)\n", addr)
 
 	// Create server with explicit HTTP/2 support
 	server := &http.Server{
-		Addr:         addr,
-		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler)), // Enable HTTP/2
+		Addr:           addr,
+		Handler:        s.Handler(),
+		MaxHeaderBytes: s.maxHeaderBytes,
+		TLSNextProto:   make(map[string]func(*http.Server, *tls.Conn, http.Handler)), // Enable HTTP/2
+		TLSConfig:      s.applyClientAuth(nil),
 	}
+	s.trackHTTPServer(server)
 
-	return server.ListenAndServeTLS(certFile, keyFile)
+	return serveResult(server.ListenAndServeTLS(certFile, keyFile))
 }
 
 // StartHTTP3 starts the HTTP/3 server with QUIC
 func (s *Server) StartHTTP3(certFile, keyFile string) error {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", s.handleRequest)
-
-	// Register recording control endpoints
-	mux.HandleFunc("/__recording/start", s.handleRecordingStart)
-	mux.HandleFunc("/__recording/stop", s.handleRecordingStop)
-	mux.HandleFunc("/__recording/status", s.handleRecordingStatus)
-	mux.HandleFunc("/__recording/clear", s.handleRecordingClear)
-	mux.HandleFunc("/__recording/export", s.handleRecordingExport)
-	mux.HandleFunc("/__recording/list", s.handleRecordingList)
-
-	// Register scenario control endpoints
-	mux.HandleFunc("/__scenario/list", s.handleScenarioList)
-	mux.HandleFunc("/__scenario/active", s.handleScenarioActive)
-	mux.HandleFunc("/__scenario/set", s.handleScenarioSet)
-
 	addr := fmt.Sprintf(":%d", s.port)
 	log.Printf("Mock server listening on https://localhost%s (HTTP/3 with QUIC enabled)\n", addr)
 
 	// Create HTTP/3 server
 	server := &http3.Server{
-		Addr:    addr,
-		Handler: mux,
+		Addr:           addr,
+		Handler:        s.Handler(),
+		MaxHeaderBytes: s.maxHeaderBytes,
 	}
+	s.trackHTTP3Server(server)
 
-	return server.ListenAndServeTLS(certFile, keyFile)
+	return serveResult(server.ListenAndServeTLS(certFile, keyFile))
 }
 
 // StartDualStack starts both HTTP/2 (TLS) and HTTP/3 (QUIC) servers on the same port
 func (s *Server) StartDualStack(certFile, keyFile string) error {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", s.handleRequest)
-
-	// Register recording control endpoints
-	mux.HandleFunc("/__recording/start", s.handleRecordingStart)
-	mux.HandleFunc("/__recording/stop", s.handleRecordingStop)
-	mux.HandleFunc("/__recording/status", s.handleRecordingStatus)
-	mux.HandleFunc("/__recording/clear", s.handleRecordingClear)
-	mux.HandleFunc("/__recording/export", s.handleRecordingExport)
-	mux.HandleFunc("/__recording/list", s.handleRecordingList)
-
-	// Register scenario control endpoints
-	mux.HandleFunc("/__scenario/list", s.handleScenarioList)
-	mux.HandleFunc("/__scenario/active", s.handleScenarioActive)
-	mux.HandleFunc("/__scenario/set", s.handleScenarioSet)
-
+	mux := s.Handler()
 	addr := fmt.Sprintf(":%d", s.port)
 	log.Printf("Mock server listening on https://localhost%s (HTTP/2 + HTTP/3 dual-stack)\n", addr)
 
 	// Create HTTP/3 server
 	http3Server := &http3.Server{
-		Addr:    addr,
-		Handler: mux,
+		Addr:           addr,
+		Handler:        mux,
+		MaxHeaderBytes: s.maxHeaderBytes,
 	}
+	s.trackHTTP3Server(http3Server)
 
 	// Start HTTP/3 server in background
 	go func() {
-		if err := http3Server.ListenAndServeTLS(certFile, keyFile); err != nil {
+		if err := serveResult(http3Server.ListenAndServeTLS(certFile, keyFile)); err != nil {
 			log.Printf("HTTP/3 server error: %v\n", err)
 		}
 	}()
 
 	// Create and start HTTP/2 server (also serves HTTP/1.1)
 	http2Server := &http.Server{
-		Addr:         addr,
-		Handler:      mux,
-		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler)), // Enable HTTP/2
+		Addr:           addr,
+		Handler:        mux,
+		MaxHeaderBytes: s.maxHeaderBytes,
+		TLSNextProto:   make(map[string]func(*http.Server, *tls.Conn, http.Handler)), // Enable HTTP/2
+		TLSConfig:      s.applyClientAuth(nil),
 	}
+	s.trackHTTPServer(http2Server)
 
-	return http2Server.ListenAndServeTLS(certFile, keyFile)
+	return serveResult(http2Server.ListenAndServeTLS(certFile, keyFile))
 }
 
 // handleRequest handles incoming HTTP requests
 func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
-	log.Printf("%s %s from %s\n", r.Method, r.URL.Path, r.RemoteAddr)
+	// Preflights for mocks with their own CORS settings take precedence over the server-wide ones
+	if s.handleMockPreflight(w, r) {
+		log.Printf("%s %s from %s\n", r.Method, r.URL.Path, r.RemoteAddr)
+		return
+	}
 
 	// Handle CORS if enabled
-	if s.corsConfig != nil && s.corsConfig.Enabled {
-		w.Header().Set("Access-Control-Allow-Origin", s.corsConfig.Origins)
-		w.Header().Set("Access-Control-Allow-Methods", s.corsConfig.Methods)
-		w.Header().Set("Access-Control-Allow-Headers", s.corsConfig.Headers)
-		w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
-
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
+	if s.applyCORS(w, r) {
+		log.Printf("%s %s from %s\n", r.Method, r.URL.Path, r.RemoteAddr)
+		return
 	}
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	// Short-circuit everything but the allowed paths during maintenance
+	if s.handleMaintenanceMode(w, r) {
+		return
+	}
+
+	// Answer "Expect: 100-continue" as configured by the mock before the body is read
+	if s.handleExpectContinue(w, r) {
+		return
+	}
 
 	// Read the body first so we can log it and use it for matching
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
+		log.Printf("%s %s from %s\n", r.Method, r.URL.Path, r.RemoteAddr)
 		log.Printf("Error reading request body: %v\n", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
 
+	// Decompress the body for matching if enabled. The original bytes are kept for the proxy.
+	matchBytes := bodyBytes
+	if s.decodeBody {
+		if decoded, err := decodeRequestBody(r.Header.Get("Content-Encoding"), bodyBytes); err != nil {
+			log.Printf("Error decoding request body, matching raw body: %v\n", err)
+		} else {
+			matchBytes = decoded
+		}
+	}
+
 	// Restore the body for the matcher to read
-	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	r.Body = io.NopCloser(bytes.NewBuffer(matchBytes))
 
-	// Log request details (limit body size to avoid hanging on large payloads)
+	// Limit the logged body size to avoid hanging on large payloads
 	bodyStr := ""
-	if len(bodyBytes) > 0 {
+	if len(matchBytes) > 0 {
 		const maxLogSize = 1024 // Log up to 1KB of body
-		if len(bodyBytes) <= maxLogSize {
-			bodyStr = string(bodyBytes)
-			log.Printf("Request body: %s\n", bodyStr)
+		if len(matchBytes) <= maxLogSize {
+			bodyStr = string(matchBytes)
 		} else {
-			bodyStr = string(bodyBytes[:maxLogSize]) + "..."
-			log.Printf("Request body: %s (%d bytes total)\n", bodyStr, len(bodyBytes))
+			bodyStr = string(matchBytes[:maxLogSize]) + "..."
+		}
+	}
+
+	// Request details are logged once the matched mock (and its log level) is known
+	logRequest := func() {
+		log.Printf("%s %s from %s\n", r.Method, r.URL.Path, r.RemoteAddr)
+		if len(matchBytes) > len(bodyStr) {
+			log.Printf("Request body: %s (%d bytes total)\n", bodyStr, len(matchBytes))
+		} else if bodyStr != "" {
+			log.Printf("Request body: %s\n", bodyStr)
 		}
 	}
 
@@ -279,8 +391,42 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Find a matching mock
+	// Find a matching mock. The lock is only held while matching so long-lived
+	// streams (WebSocket, SSE, proxied streams) don't block mock reloads.
+	s.mu.RLock()
 	mock, err := s.matcher.FindMatch(r)
+	s.mu.RUnlock()
+
+	// Silent mocks skip the per-request log lines, debug mocks also log headers and the response
+	logLevel := mockLogLevel(mock)
+	if logLevel != logLevelSilent {
+		logRequest()
+	}
+	if errors.Is(err, matcher.ErrNotAcceptable) {
+		log.Printf("No acceptable representation for %s %s (Accept: %s)\n", r.Method, r.URL.Path, r.Header.Get("Accept"))
+		http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
+		if s.tracker != nil {
+			s.trackRequest(w, r, tracker.RequestLog{
+				Method: r.Method, URI: r.URL.RequestURI(), Headers: headers, Body: bodyStr,
+				Matched: false, StatusCode: http.StatusNotAcceptable,
+				Response: "Not Acceptable", RemoteAddr: r.RemoteAddr,
+			})
+		}
+		return
+	}
+	var authErr *matcher.AuthError
+	if errors.As(err, &authErr) {
+		log.Printf("Unauthorized %s %s: %v\n", r.Method, r.URL.Path, authErr)
+		s.writeAuthError(w, authErr)
+		if s.tracker != nil {
+			s.trackRequest(w, r, tracker.RequestLog{
+				Method: r.Method, URI: r.URL.RequestURI(), Headers: headers, Body: bodyStr,
+				Matched: true, MockName: authErr.Mock + " (unauthorized)",
+				StatusCode: http.StatusUnauthorized, Response: "Unauthorized", RemoteAddr: r.RemoteAddr,
+			})
+		}
+		return
+	}
 	if err != nil {
 		log.Printf("Error matching request: %v\n", err)
 		observability.Error("Failed to match request",
@@ -290,7 +436,7 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 		)
 		http.Error(w, "Error processing request", http.StatusInternalServerError)
 		if s.tracker != nil {
-			s.tracker.Log(tracker.RequestLog{
+			s.trackRequest(w, r, tracker.RequestLog{
 				Method: r.Method, URI: r.URL.RequestURI(), Headers: headers, Body: bodyStr,
 				Matched: false, StatusCode: http.StatusInternalServerError,
 				Response: "Error processing request", RemoteAddr: r.RemoteAddr,
@@ -319,7 +465,7 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 				observability.Error("Proxy forward error", zap.Error(err))
 				http.Error(w, "Proxy error", http.StatusBadGateway)
 				if s.tracker != nil {
-					s.tracker.Log(tracker.RequestLog{
+					s.trackRequest(w, r, tracker.RequestLog{
 						Method: r.Method, URI: r.URL.RequestURI(), Headers: headers, Body: bodyStr,
 						Matched: false, StatusCode: http.StatusBadGateway,
 						Response: "Proxy error", RemoteAddr: r.RemoteAddr,
@@ -332,10 +478,14 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// No proxy configured, return 404
+		// No proxy configured, return the default response or a 404
+		if response := s.getDefaultResponse(); response != nil {
+			s.writeDefaultResponse(w, r, response, headers, bodyStr)
+			return
+		}
 		http.NotFound(w, r)
 		if s.tracker != nil {
-			s.tracker.Log(tracker.RequestLog{
+			s.trackRequest(w, r, tracker.RequestLog{
 				Method: r.Method, URI: r.URL.RequestURI(), Headers: headers, Body: bodyStr,
 				Matched: false, StatusCode: http.StatusNotFound,
 				Response: "404 page not found", RemoteAddr: r.RemoteAddr,
@@ -344,7 +494,17 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Matched mock: %s\n", mock.Name)
+	if logLevel != logLevelSilent {
+		log.Printf("Matched mock: %s\n", mock.Name)
+	}
+
+	// The mock's CORS settings replace the server-wide headers set above
+	if mock.CORS != nil {
+		s.applyMockCORS(w, r, mock.CORS)
+	}
+	if logLevel == logLevelDebug {
+		log.Printf("Request headers: %v\n", r.Header)
+	}
 	observability.RecordMockMatch(mock.Name)
 	observability.Debug("Mock matched",
 		zap.String("mock_name", mock.Name),
@@ -352,9 +512,30 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 		zap.String("path", r.URL.Path),
 	)
 
+	// Validate the Bearer token if the mock requires one
+	var jwtClaims map[string]interface{}
+	if mock.Request.JWT != nil {
+		claims, err := s.matcher.VerifyJWT(r, mock.Request.JWT)
+		if err != nil {
+			log.Printf("JWT validation failed for mock %s: %v\n", mock.Name, err)
+			s.writeJWTError(w, err)
+			if s.tracker != nil {
+				s.trackRequest(w, r, tracker.RequestLog{
+					Method: r.Method, URI: r.URL.RequestURI(), Headers: headers, Body: bodyStr,
+					Matched: true, MockName: mock.Name + " (invalid JWT)", MockConfig: mock,
+					StatusCode: http.StatusUnauthorized, Response: "Unauthorized", RemoteAddr: r.RemoteAddr,
+				})
+			}
+			return
+		}
+		jwtClaims = claims
+	}
+
 	// Handle WebSocket protocol
 	if mock.Protocol == "websocket" {
-		log.Printf("Handling WebSocket connection for mock: %s\n", mock.Name)
+		if logLevel != logLevelSilent {
+			log.Printf("Handling WebSocket connection for mock: %s\n", mock.Name)
+		}
 		observability.RecordWebSocketConnection(1)
 		s.handleWebSocket(w, r, mock)
 		observability.RecordWebSocketConnection(-1)
@@ -365,13 +546,16 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 	if mock.Protocol == "sse" {
 		observability.RecordSSEConnection(1)
 		defer observability.RecordSSEConnection(-1)
-		log.Printf("Handling SSE stream for mock: %s\n", mock.Name)
+		if logLevel != logLevelSilent {
+			log.Printf("Handling SSE stream for mock: %s\n", mock.Name)
+		}
 		s.handleSSE(w, r, mock)
 		return
 	}
 
 	// Create request data for templates and callbacks
-	requestData := template.NewRequestData(r, string(bodyBytes))
+	requestData := template.NewRequestData(r, string(matchBytes))
+	requestData.JWT = jwtClaims
 
 	// Execute callback if specified
 	if mock.Response.Callback != nil {
@@ -379,7 +563,17 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Apply chaos engineering (if enabled)
-	chaosStatusCode, shouldFail := s.applyChaos(mock.Response.Chaos)
+	chaosStatusCode, shouldFail, reset := s.applyChaos(w, mock.Response.Chaos)
+	if reset {
+		if s.tracker != nil {
+			s.trackRequest(w, r, tracker.RequestLog{
+				Method: r.Method, URI: r.URL.RequestURI(), Headers: headers, Body: bodyStr,
+				Matched: true, MockName: mock.Name + " (connection reset)", MockConfig: mock,
+				RemoteAddr: r.RemoteAddr,
+			})
+		}
+		return
+	}
 	if shouldFail {
 		// Chaos injected a failure - return error immediately
 		w.WriteHeader(chaosStatusCode)
@@ -390,7 +584,7 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 
 		// Track the chaos response
 		if s.tracker != nil {
-			s.tracker.Log(tracker.RequestLog{
+			s.trackRequest(w, r, tracker.RequestLog{
 				Method: r.Method, URI: r.URL.RequestURI(), Headers: headers, Body: bodyStr,
 				Matched: true, MockName: mock.Name + " (chaos)", MockConfig: mock,
 				StatusCode: chaosStatusCode, Response: chaosBody, RemoteAddr: r.RemoteAddr,
@@ -401,27 +595,86 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 
 	// Calculate latency (advanced latency or standard delay)
 	latency := s.calculateLatency(mock.Response.Latency, mock.Response.Delay)
+
+	// Simulate a gateway giving up on a slow upstream
+	if timeout := mock.Response.SimulateGatewayTimeout; timeout > 0 && (latency == 0 || latency > timeout) {
+		if !sleepContext(r.Context(), time.Duration(timeout)*time.Millisecond) {
+			log.Printf("Client disconnected while waiting for gateway timeout\n")
+			return
+		}
+
+		log.Printf("Simulating gateway timeout after %dms\n", timeout)
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusGatewayTimeout)
+		if _, err := w.Write([]byte(gatewayTimeoutBody)); err != nil {
+			log.Printf("Error writing gateway timeout response: %v\n", err)
+		}
+
+		if s.tracker != nil {
+			s.trackRequest(w, r, tracker.RequestLog{
+				Method: r.Method, URI: r.URL.RequestURI(), Headers: headers, Body: bodyStr,
+				Matched: true, MockName: mock.Name + " (gateway timeout)", MockConfig: mock,
+				StatusCode: http.StatusGatewayTimeout, Response: gatewayTimeoutBody, RemoteAddr: r.RemoteAddr,
+			})
+		}
+		return
+	}
+
 	if latency > 0 {
-		time.Sleep(time.Duration(latency) * time.Millisecond)
+		if !sleepContext(r.Context(), time.Duration(latency)*time.Millisecond) {
+			log.Printf("Client disconnected during response delay\n")
+			return
+		}
 	}
 
+	statusCode := s.renderStatusCode(&mock.Response, requestData)
+
 	// Render response headers (with templates if enabled)
-	responseHeaders := s.renderHeaderTemplates(mock.Response.Headers, mock.Response.HeaderTemplates, requestData)
+	responseHeaders := s.renderHeaderTemplates(mock.Response.Headers, mock.Response.HeaderTemplates, mock.Response.TemplateDelims, requestData)
 
 	// Set response headers
 	for key, value := range responseHeaders {
 		w.Header().Set(key, value)
 	}
+	for key, values := range mock.Response.HeadersMulti {
+		for _, value := range values {
+			if mock.Response.HeaderTemplates {
+				value = s.renderHeaderValue(key, value, mock.Response.TemplateDelims, requestData)
+			}
+			w.Header().Add(key, value)
+		}
+	}
+	s.setCookies(w, &mock.Response, requestData)
+
+	// Default the Content-Type to the negotiated media type
+	if len(mock.Request.Produces) > 0 && w.Header().Get("Content-Type") == "" {
+		if contentType := matcher.NegotiateContentType(r.Header.Get("Accept"), mock.Request.Produces); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+	}
 
-	// Set status code
-	w.WriteHeader(mock.Response.StatusCode)
+	// File-backed bodies are streamed from disk, with Range support
+	if mock.Response.BodyFile != "" {
+		sent := s.serveBodyFile(w, r, &mock.Response, statusCode)
+		if logLevel != logLevelSilent {
+			log.Printf("Returned %d response from %s\n", sent, mock.Response.BodyFile)
+		}
+		if s.tracker != nil {
+			s.trackRequest(w, r, tracker.RequestLog{
+				Method: r.Method, URI: r.URL.RequestURI(), Headers: headers, Body: bodyStr,
+				Matched: true, MockName: mock.Name, MockConfig: mock, StatusCode: sent,
+				RemoteAddr: r.RemoteAddr,
+			})
+		}
+		return
+	}
 
 	// Render response body (with template if enabled)
 	responseBody := ""
 	if mock.Response.Body != "" {
 		responseBody = mock.Response.Body
 		if mock.Response.Template {
-			rendered, err := s.templateRenderer.Render(mock.Response.Body, requestData)
+			rendered, err := s.templateRenderer.RenderWithDelims(mock.Response.Body, requestData, mock.Response.TemplateDelims)
 			if err != nil {
 				log.Printf("Error rendering response template: %v\n", err)
 				// Fall back to the original body
@@ -429,18 +682,103 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 				responseBody = rendered
 			}
 		}
-		if _, err := w.Write([]byte(responseBody)); err != nil {
-			log.Printf("Error writing response body: %v\n", err)
+		if mock.Response.PrettyJSON || s.prettyJSON {
+			responseBody = prettyPrintJSON(responseBody)
 		}
 	}
 
-	log.Printf("Returned %d response\n", mock.Response.StatusCode)
+	// Conditional requests for an unchanged representation get 304 Not Modified without a body
+	if etag := responseETag(mock.Response.ETag, responseBody); etag != "" {
+		w.Header().Set("ETag", etag)
+		if isNotModified(r, statusCode, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			if logLevel != logLevelSilent {
+				log.Printf("Returned %d response\n", http.StatusNotModified)
+			}
+			if s.tracker != nil {
+				s.trackRequest(w, r, tracker.RequestLog{
+					Method: r.Method, URI: r.URL.RequestURI(), Headers: headers, Body: bodyStr,
+					Matched: true, MockName: mock.Name, MockConfig: mock, StatusCode: http.StatusNotModified,
+					RemoteAddr: r.RemoteAddr,
+				})
+			}
+			return
+		}
+	}
+
+	// A body on a HEAD response and a fake Content-Length can only be sent by writing
+	// the raw response on the hijacked connection
+	isHead := r.Method == http.MethodHead
+	headBody := isHead && mock.Response.AllowHeadBody && responseBody != ""
+	written := false
+	if mock.Response.FakeContentLength > 0 || headBody {
+		contentLength := mock.Response.FakeContentLength
+		if contentLength <= 0 {
+			contentLength = len(responseBody)
+		}
+		body := responseBody
+		if isHead && !mock.Response.AllowHeadBody {
+			body = ""
+		}
+		written = s.writeHijackedResponse(w, statusCode, body, contentLength)
+	}
+
+	if !written {
+		// HEAD responses advertise the length of the body they omit
+		if isHead && responseBody != "" && !mock.Response.OmitContentLength && w.Header().Get("Content-Length") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(responseBody)))
+		}
+
+		bodyBytes := []byte(responseBody)
+		if responseBody != "" && !isHead && mock.Response.Stream == nil {
+			if compressed, ok := s.compressBody(w, r, responseBody); ok {
+				bodyBytes = compressed
+			}
+		}
+
+		// Throttled bodies are flushed slice by slice, which would otherwise drop the Content-Length
+		if mock.Response.ThrottleBytesPerSec > 0 && responseBody != "" && !isHead && !mock.Response.OmitContentLength && w.Header().Get("Content-Length") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(bodyBytes)))
+		}
+
+		// Set status code
+		w.WriteHeader(statusCode)
+
+		if responseBody != "" && !isHead {
+			bodyWriter := w
+			if mock.Response.ThrottleBytesPerSec > 0 {
+				bodyWriter = newThrottledWriter(r.Context(), w, mock.Response.ThrottleBytesPerSec)
+			}
+
+			// Flushing the headers before the body makes net/http use chunked encoding instead of Content-Length
+			if mock.Response.OmitContentLength {
+				if flusher, ok := w.(http.Flusher); ok {
+					flusher.Flush()
+				}
+			}
+			if mock.Response.Stream != nil {
+				s.writeStream(bodyWriter, r, bodyBytes, mock.Response.Stream)
+			} else if _, err := bodyWriter.Write(bodyBytes); err != nil {
+				log.Printf("Error writing response body: %v\n", err)
+			}
+		}
+	}
+
+	if logLevel != logLevelSilent {
+		log.Printf("Returned %d response\n", statusCode)
+	}
+	if logLevel == logLevelDebug {
+		log.Printf("Response headers: %v\n", w.Header())
+		if responseBody != "" {
+			log.Printf("Response body: %s\n", responseBody)
+		}
+	}
 
 	// Track matched request
 	if s.tracker != nil {
-		s.tracker.Log(tracker.RequestLog{
+		s.trackRequest(w, r, tracker.RequestLog{
 			Method: r.Method, URI: r.URL.RequestURI(), Headers: headers, Body: bodyStr,
-			Matched: true, MockName: mock.Name, MockConfig: mock, StatusCode: mock.Response.StatusCode,
+			Matched: true, MockName: mock.Name, MockConfig: mock, StatusCode: statusCode,
 			Response: responseBody, RemoteAddr: r.RemoteAddr,
 		})
 	}
@@ -455,17 +793,133 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		s.recorder.Record(r.Method, r.URL.Path, headers, bodyStr,
-			mock.Response.StatusCode, respHeaders, responseBody)
+			statusCode, respHeaders, responseBody)
 		observability.RecordRecordedRequest()
 	}
 }
 
-// UpdateMocks updates the server's matcher with new mocks
+// writeJWTError writes a 401 response for a missing or invalid Bearer token (RFC 6750)
+func (s *Server) writeJWTError(w http.ResponseWriter, err error) {
+	if errors.Is(err, matcher.ErrMissingToken) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="pmp-mock-http"`)
+	} else {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="pmp-mock-http", error="invalid_token", error_description=%q`, err.Error()))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+
+	response := map[string]string{"error": "invalid_token", "error_description": err.Error()}
+	if errors.Is(err, matcher.ErrMissingToken) {
+		response = map[string]string{"error": "unauthorized", "error_description": err.Error()}
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v\n", err)
+	}
+}
+
+// Per-mock log levels
+const (
+	logLevelSilent = "silent"
+	logLevelInfo   = "info"
+	logLevelDebug  = "debug"
+)
+
+// mockLogLevel returns the log level of the matched mock. Unmatched requests are logged at info level.
+func mockLogLevel(mock *models.Mock) string {
+	if mock == nil || mock.LogLevel == "" {
+		return logLevelInfo
+	}
+	return strings.ToLower(mock.LogLevel)
+}
+
+// SetDecodeRequestBody enables decompressing gzip, deflate and br request bodies before matching
+func (s *Server) SetDecodeRequestBody(enabled bool) {
+	s.decodeBody = enabled
+}
+
+// SetJSTimeout sets how long a JavaScript matcher may run before it's interrupted (0 = no limit)
+func (s *Server) SetJSTimeout(timeout time.Duration) {
+	s.matcher.SetJSTimeout(timeout)
+}
+
+// SetPrettyJSON enables indenting JSON response bodies for all mocks, as if every mock set pretty_json
+func (s *Server) SetPrettyJSON(enabled bool) {
+	s.prettyJSON = enabled
+}
+
+// prettyPrintJSON re-indents the body if it is valid JSON, otherwise it's returned unchanged
+func prettyPrintJSON(body string) string {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(body), "", "  "); err != nil {
+		return body
+	}
+	return indented.String()
+}
+
+// SetMaxHeaderBytes sets the maximum size of request headers. Requests exceeding it are
+// rejected by net/http with 431 Request Header Fields Too Large. Zero keeps the Go default (1MB).
+func (s *Server) SetMaxHeaderBytes(n int) {
+	s.maxHeaderBytes = n
+}
+
+// writeHijackedResponse hijacks the connection and writes the response as-is, bypassing the
+// checks net/http applies (e.g. a deliberately wrong Content-Length or a body on a HEAD response),
+// then closes the connection. Returns false if the connection can't be hijacked (e.g. HTTP/2),
+// in which case nothing has been written.
+func (s *Server) writeHijackedResponse(w http.ResponseWriter, statusCode int, body string, contentLength int) bool {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		log.Printf("Warning: fake_content_length and allow_head_body require HTTP/1.x, sending a regular response\n")
+		return false
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("Error hijacking connection for raw response: %v\n", err)
+		return false
+	}
+	defer conn.Close() //nolint:errcheck // connection is discarded
+
+	header := w.Header().Clone()
+	header.Del("Transfer-Encoding")
+	header.Set("Content-Length", strconv.Itoa(contentLength))
+	header.Set("Connection", "close")
+	if header.Get("Date") == "" {
+		header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+
+	fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\n", statusCode, http.StatusText(statusCode))
+	if err := header.Write(buf); err != nil {
+		log.Printf("Error writing hijacked response headers: %v\n", err)
+		return true
+	}
+	buf.WriteString("\r\n")
+	buf.WriteString(body)
+	if err := buf.Flush(); err != nil {
+		log.Printf("Error writing hijacked response: %v\n", err)
+	}
+	return true
+}
+
+// UpdateMocks updates the server's matcher with new mocks. Managed mocks (see
+// SetManagedMocks) are kept.
 func (s *Server) UpdateMocks(mocks []models.Mock) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.matcher.UpdateMocks(mocks)
+	s.fileMocks = mocks
+	s.matcher.UpdateMocks(s.servedMocks())
+}
+
+// GetCallCounts returns the sequence call counts of the named mock, keyed by client identifier
+func (s *Server) GetCallCounts(mockName string) map[string]int {
+	return s.matcher.GetCallCounts(mockName)
+}
+
+// ResetCallCounts resets the sequence counters of the named mock
+func (s *Server) ResetCallCounts(mockName string) {
+	s.matcher.ResetCallCounts(mockName)
 }
 
 // handleRecordingStart handles starting the recording
@@ -674,20 +1128,53 @@ func (s *Server) handleScenarioSet(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleScenarioState handles getting (GET) or resetting (DELETE) the states of stateful mocks
+func (s *Server) handleScenarioState(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		s.mu.RLock()
+		s.matcher.ResetScenarioStates()
+		s.mu.RUnlock()
+		log.Println("Scenario states reset")
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.RLock()
+	states := s.matcher.ScenarioStates()
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"states": states,
+	}); err != nil {
+		log.Printf("Error encoding response: %v\n", err)
+	}
+}
+
 // applyChaos applies chaos engineering logic to the response
-// Returns (statusCode, shouldFail)
-func (s *Server) applyChaos(chaos *models.ChaosConfig) (int, bool) {
+// Returns (statusCode, shouldFail, reset). A reset connection must not be written to.
+func (s *Server) applyChaos(w http.ResponseWriter, chaos *models.ChaosConfig) (int, bool, bool) {
 	if chaos == nil || !chaos.Enabled {
-		return 0, false
+		return 0, false, false
+	}
+
+	// Check if we should reset the connection
+	if chaos.ConnectionReset > 0 && s.randFloat() < chaos.ConnectionReset && resetConnection(w) {
+		log.Printf("Chaos: Resetting the connection\n")
+		return 0, false, true
 	}
 
 	// Check if we should inject failure
-	if rand.Float64() < chaos.FailureRate {
+	if s.randFloat() < chaos.FailureRate {
 		// Inject failure - pick random error code
 		if len(chaos.ErrorCodes) > 0 {
-			errorCode := chaos.ErrorCodes[rand.Intn(len(chaos.ErrorCodes))]
+			errorCode := chaos.ErrorCodes[s.randIntn(len(chaos.ErrorCodes))]
 			log.Printf("Chaos: Injecting failure with status code %d\n", errorCode)
-			return errorCode, true
+			return errorCode, true, false
 		}
 	}
 
@@ -695,7 +1182,7 @@ func (s *Server) applyChaos(chaos *models.ChaosConfig) (int, bool) {
 	if chaos.LatencyMax > 0 {
 		latency := chaos.LatencyMin
 		if chaos.LatencyMax > chaos.LatencyMin {
-			latency = chaos.LatencyMin + rand.Intn(chaos.LatencyMax-chaos.LatencyMin)
+			latency = chaos.LatencyMin + s.randIntn(chaos.LatencyMax-chaos.LatencyMin)
 		}
 		if latency > 0 {
 			log.Printf("Chaos: Injecting %dms latency\n", latency)
@@ -703,7 +1190,31 @@ func (s *Server) applyChaos(chaos *models.ChaosConfig) (int, bool) {
 		}
 	}
 
-	return 0, false
+	return 0, false, false
+}
+
+// gatewayTimeoutBody is the body of simulated gateway timeouts, as sent by a typical reverse proxy
+const gatewayTimeoutBody = `<html>
+<head><title>504 Gateway Time-out</title></head>
+<body>
+<center><h1>504 Gateway Time-out</h1></center>
+<hr><center>nginx</center>
+</body>
+</html>
+`
+
+// sleepContext waits for the duration or until the context is done.
+// Returns false if the context was cancelled first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 // calculateLatency calculates latency based on the latency configuration
@@ -718,7 +1229,7 @@ func (s *Server) calculateLatency(latency *models.LatencyConfig, baseDelay int)
 			min := latency.Min
 			max := latency.Max
 			if max > min {
-				return min + rand.Intn(max-min)
+				return min + s.randIntn(max-min)
 			}
 			return min
 		}
@@ -726,7 +1237,7 @@ func (s *Server) calculateLatency(latency *models.LatencyConfig, baseDelay int)
 
 	case "percentile":
 		// Use percentile-based latency distribution
-		roll := rand.Float64()
+		roll := s.randFloat()
 		if roll < 0.50 {
 			return latency.P50
 		} else if roll < 0.95 {
@@ -743,33 +1254,74 @@ func (s *Server) calculateLatency(latency *models.LatencyConfig, baseDelay int)
 	}
 }
 
+// renderStatusCode renders the status code template of a response. The configured status
+// code is used if there is no template, or if it fails to render to a valid status code.
+func (s *Server) renderStatusCode(resp *models.Response, requestData *template.RequestData) int {
+	if resp.StatusCodeTemplate == "" {
+		return resp.StatusCode
+	}
+
+	rendered, err := s.templateRenderer.RenderWithDelims(resp.StatusCodeTemplate, requestData, resp.TemplateDelims)
+	if err != nil {
+		log.Printf("Warning: error rendering status code template: %v\n", err)
+		return resp.StatusCode
+	}
+
+	statusCode, err := strconv.Atoi(strings.TrimSpace(rendered))
+	if err != nil || statusCode < 100 || statusCode > 999 {
+		log.Printf("Warning: status code template rendered an invalid status code %q, using %d\n", rendered, resp.StatusCode)
+		return resp.StatusCode
+	}
+	return statusCode
+}
+
 // renderHeaderTemplates renders templates in response headers
-func (s *Server) renderHeaderTemplates(headers map[string]string, useTemplates bool, requestData *template.RequestData) map[string]string {
+func (s *Server) renderHeaderTemplates(headers map[string]string, useTemplates bool, delims [2]string, requestData *template.RequestData) map[string]string {
 	if !useTemplates || len(headers) == 0 {
 		return headers
 	}
 
 	rendered := make(map[string]string)
 	for key, value := range headers {
-		renderedValue, err := s.templateRenderer.Render(value, requestData)
-		if err != nil {
-			log.Printf("Error rendering header template for '%s': %v\n", key, err)
-			rendered[key] = value // Fall back to original value
-		} else {
-			rendered[key] = renderedValue
-		}
+		rendered[key] = s.renderHeaderValue(key, value, delims, requestData)
 	}
 	return rendered
 }
 
+// renderHeaderValue renders the template of a response header value, falling back to the original value on errors
+func (s *Server) renderHeaderValue(key, value string, delims [2]string, requestData *template.RequestData) string {
+	rendered, err := s.templateRenderer.RenderWithDelims(value, requestData, delims)
+	if err != nil {
+		log.Printf("Error rendering header template for '%s': %v\n", key, err)
+		return value
+	}
+	return rendered
+}
+
+// trackRequest adds a request to the request log, along with its absolute URL and the
+// response headers set so far
+func (s *Server) trackRequest(w http.ResponseWriter, r *http.Request, entry tracker.RequestLog) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	entry.URL = scheme + "://" + r.Host + r.URL.RequestURI()
+	if len(w.Header()) > 0 {
+		entry.ResponseHeaders = w.Header().Clone()
+	}
+	s.tracker.Log(entry)
+}
+
 // handleWebSocket handles WebSocket connections
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request, mock *models.Mock) {
 	// Get or create WebSocket handler for this mock
+	s.handlersMu.Lock()
 	handler, exists := s.wsHandlers[mock.Name]
 	if !exists {
 		handler = websocket.NewHandler(mock, s.templateRenderer)
 		s.wsHandlers[mock.Name] = handler
 	}
+	s.handlersMu.Unlock()
 
 	// Track the connection attempt
 	if s.tracker != nil {
@@ -779,7 +1331,7 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request, mock *m
 				headers[key] = values[0]
 			}
 		}
-		s.tracker.Log(tracker.RequestLog{
+		s.trackRequest(w, r, tracker.RequestLog{
 			Method:     r.Method,
 			URI:        r.URL.RequestURI(),
 			Headers:    headers,
@@ -800,11 +1352,13 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request, mock *m
 // handleSSE handles Server-Sent Events streams
 func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request, mock *models.Mock) {
 	// Get or create SSE handler for this mock
+	s.handlersMu.Lock()
 	handler, exists := s.sseHandlers[mock.Name]
 	if !exists {
 		handler = sse.NewHandler(mock, s.templateRenderer)
 		s.sseHandlers[mock.Name] = handler
 	}
+	s.handlersMu.Unlock()
 
 	// Track the SSE stream
 	if s.tracker != nil {
@@ -814,7 +1368,7 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request, mock *models.
 				headers[key] = values[0]
 			}
 		}
-		s.tracker.Log(tracker.RequestLog{
+		s.trackRequest(w, r, tracker.RequestLog{
 			Method:     r.Method,
 			URI:        r.URL.RequestURI(),
 			Headers:    headers,
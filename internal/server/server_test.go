@@ -1,15 +1,37 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/comfortablynumb/pmp-mock-http/internal/management"
 	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+	"github.com/comfortablynumb/pmp-mock-http/internal/oauth"
+	"github.com/comfortablynumb/pmp-mock-http/internal/plugins"
 	"github.com/comfortablynumb/pmp-mock-http/internal/proxy"
+	"github.com/golang-jwt/jwt/v5"
+	"gopkg.in/yaml.v3"
 )
 
 func TestServerBasicRequest(t *testing.T) {
@@ -681,3 +703,2201 @@ func TestServerProxyDisabled(t *testing.T) {
 		t.Errorf("Expected status 404, got %d", resp.StatusCode)
 	}
 }
+
+func TestServerControlEndpointsCORS(t *testing.T) {
+	corsConfig := &CORSConfig{
+		Enabled: true,
+		Origins: "https://dashboard.example.com",
+		Methods: "GET,POST,OPTIONS",
+		Headers: "Content-Type",
+	}
+	srv := NewServer(8080, nil, nil, corsConfig)
+
+	mux := http.NewServeMux()
+	srv.registerControlEndpoints(mux)
+
+	// Preflight request should be answered by the CORS middleware
+	req := httptest.NewRequest("OPTIONS", "/__scenario/set", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status 204 for preflight, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://dashboard.example.com" {
+		t.Errorf("Expected Access-Control-Allow-Origin header, got '%s'", got)
+	}
+
+	// Regular request should carry the CORS headers too
+	req = httptest.NewRequest("GET", "/__scenario/list", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	resp = w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Methods"); got != "GET,POST,OPTIONS" {
+		t.Errorf("Expected Access-Control-Allow-Methods header, got '%s'", got)
+	}
+}
+
+func TestServerScenarioState(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:      "Login",
+			Scenarios: []string{"auth"},
+			NewState:  "LoggedIn",
+			Request:   models.Request{URI: "/login", Method: "POST"},
+			Response:  models.Response{StatusCode: 204},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+	mux := http.NewServeMux()
+	srv.registerControlEndpoints(mux)
+
+	getStates := func(method string) map[string]string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, "/__scenario/state", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var response struct {
+			States map[string]string `json:"states"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return response.States
+	}
+
+	if state := getStates("GET")["auth"]; state != "Started" {
+		t.Errorf("Expected initial state Started, got %q", state)
+	}
+
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("POST", "/login", nil))
+	if w.Code != 204 {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+
+	if state := getStates("GET")["auth"]; state != "LoggedIn" {
+		t.Errorf("Expected state LoggedIn after login, got %q", state)
+	}
+	if state := getStates("DELETE")["auth"]; state != "Started" {
+		t.Errorf("Expected state Started after reset, got %q", state)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/__scenario/state", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
+func TestServerMatchExplain(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name: "Get user",
+			Request: models.Request{
+				URI:     "/api/users/1",
+				Method:  "GET",
+				Headers: map[string]string{"Authorization": "Bearer token"},
+			},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+	mux := http.NewServeMux()
+	srv.registerControlEndpoints(mux)
+
+	body := `{"method": "get", "uri": "/api/users/1?verbose=true", "headers": {"Authorization": "Bearer other"}}`
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/__match/explain", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Match        string `json:"match"`
+		Explanations []struct {
+			Mock       string `json:"mock"`
+			Matched    bool   `json:"matched"`
+			Conditions []struct {
+				Condition string `json:"condition"`
+				Passed    bool   `json:"passed"`
+			} `json:"conditions"`
+		} `json:"explanations"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if response.Match != "" {
+		t.Errorf("Expected no match, got %q", response.Match)
+	}
+	if len(response.Explanations) != 1 || response.Explanations[0].Matched {
+		t.Fatalf("Expected one unmatched explanation, got %+v", response.Explanations)
+	}
+	for _, c := range response.Explanations[0].Conditions {
+		if c.Passed != (c.Condition != "header Authorization") {
+			t.Errorf("Unexpected condition result %+v", c)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/__match/explain", strings.NewReader(`{"uri": "api"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid uri, got %d", w.Code)
+	}
+}
+
+func TestServerVars(t *testing.T) {
+	t.Setenv("PMP_TEST_FEATURE", "from-env")
+
+	mocks := []models.Mock{
+		{
+			Name:     "Feature",
+			Request:  models.Request{URI: "/feature"},
+			Response: models.Response{StatusCode: 200, Body: `{{env "PMP_TEST_FEATURE"}}`, Template: true},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+	mux := http.NewServeMux()
+	srv.registerControlEndpoints(mux)
+
+	feature := func() string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		srv.handleRequest(w, httptest.NewRequest("GET", "/feature", nil))
+		return w.Body.String()
+	}
+	control := func(method, target, body string) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w.Code
+	}
+
+	if got := feature(); got != "from-env" {
+		t.Errorf("Expected the environment variable, got %q", got)
+	}
+
+	if code := control("POST", "/__vars", `{"PMP_TEST_FEATURE": "on"}`); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if got := feature(); got != "on" {
+		t.Errorf("Expected the variable to override the environment, got %q", got)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/__vars", nil))
+	if !strings.Contains(w.Body.String(), `"PMP_TEST_FEATURE":"on"`) {
+		t.Errorf("Expected the variable to be listed, got %s", w.Body.String())
+	}
+
+	if code := control("DELETE", "/__vars?name=PMP_TEST_FEATURE", ""); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if got := feature(); got != "from-env" {
+		t.Errorf("Expected the environment variable after removing the variable, got %q", got)
+	}
+
+	if code := control("POST", "/__vars", `{"": "x"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty name, got %d", code)
+	}
+	if code := control("POST", "/__vars", `["not", "an", "object"]`); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid body, got %d", code)
+	}
+}
+
+func TestServerDefaultResponse(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Known",
+			Request:  models.Request{URI: "/known"},
+			Response: models.Response{StatusCode: 200, Body: "known"},
+		},
+		{
+			Name:     "Fallback body",
+			Request:  models.Request{URI: "/__never"},
+			Response: models.Response{StatusCode: 418, Body: "from mock"},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+	srv.SetDefaultResponse(&models.Response{
+		Headers:  map[string]string{"Content-Type": "application/json"},
+		Body:     `{"error": "no mock for {{.Method}} {{.Path}}"}`,
+		Template: true,
+	})
+
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/known", nil))
+	if w.Code != 200 || w.Body.String() != "known" {
+		t.Errorf("Expected matched mocks to be unaffected, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("DELETE", "/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected default status 404, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %s", got)
+	}
+	if got := w.Body.String(); got != `{"error": "no mock for DELETE /missing"}` {
+		t.Errorf("Expected rendered default body, got %q", got)
+	}
+
+	// A mock's response takes precedence and follows reloads
+	srv.SetDefaultResponseMock("Fallback body")
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/missing", nil))
+	if w.Code != 418 || w.Body.String() != "from mock" {
+		t.Errorf("Expected the mock's response, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestServerAdminAccess(t *testing.T) {
+	srv := NewServer(8080, nil, nil, nil)
+	mux := http.NewServeMux()
+	srv.registerControlEndpoints(mux)
+
+	if err := srv.SetAdminAccess([]string{"10.0.0.0/8", "192.0.2.7"}, []string{"get"}); err != nil {
+		t.Fatalf("SetAdminAccess failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		remoteAddr string
+		expected   int
+	}{
+		{"Allowed network", "POST", "/__recording/start", "10.1.2.3:5000", http.StatusOK},
+		{"Allowed single IP", "POST", "/__recording/start", "192.0.2.7:5000", http.StatusOK},
+		{"Disallowed source", "POST", "/__recording/start", "192.0.2.8:5000", http.StatusForbidden},
+		{"Open method from any source", "GET", "/__recording/status", "192.0.2.8:5000", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", "10.0.0.1")
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+
+	if err := srv.SetAdminAccess([]string{"not-a-cidr"}, nil); err == nil {
+		t.Error("Expected an error for an invalid CIDR")
+	}
+	if err := srv.SetAdminAccess(nil, nil); err != nil {
+		t.Fatalf("SetAdminAccess failed: %v", err)
+	}
+	req := httptest.NewRequest("POST", "/__recording/stop", nil)
+	req.RemoteAddr = "203.0.113.1:5000"
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected any source to be allowed without an allowlist, got %d", w.Code)
+	}
+}
+
+func TestServerSetSeed(t *testing.T) {
+	chaos := &models.ChaosConfig{Enabled: true, FailureRate: 0.5, ErrorCodes: []int{500, 502, 503}}
+	latency := &models.LatencyConfig{Type: "random", Min: 0, Max: 1000}
+
+	collect := func(srv *Server) []int {
+		results := make([]int, 0, 100)
+		for i := 0; i < 50; i++ {
+			code, _, _ := srv.applyChaos(httptest.NewRecorder(), chaos)
+			results = append(results, code, srv.calculateLatency(latency, 0))
+		}
+		return results
+	}
+
+	first := NewServer(8080, nil, nil, nil)
+	first.SetSeed(42)
+	second := NewServer(8080, nil, nil, nil)
+	second.SetSeed(42)
+
+	a, b := collect(first), collect(second)
+	failures := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Expected identical chaos and latencies with the same seed, differ at %d", i)
+		}
+		if i%2 == 0 && a[i] != 0 {
+			failures++
+		}
+	}
+	if failures == 0 || failures == 50 {
+		t.Errorf("Expected some but not all requests to fail, got %d/50", failures)
+	}
+}
+
+func TestServerMockStats(t *testing.T) {
+	mocks := []models.Mock{
+		{Name: "Users", Request: models.Request{URI: "/users"}, Response: models.Response{StatusCode: 200}},
+		{Name: "Orders", Request: models.Request{URI: "/orders"}, Response: models.Response{StatusCode: 200}},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+	mux := http.NewServeMux()
+	srv.registerControlEndpoints(mux)
+
+	getStats := func() map[string]int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/__mocks/stats", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var stats map[string]int
+		if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return stats
+	}
+
+	for i := 0; i < 3; i++ {
+		srv.handleRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+	}
+	srv.handleRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+
+	stats := getStats()
+	if stats["Users"] != 3 {
+		t.Errorf("Expected 3 hits for Users, got %d", stats["Users"])
+	}
+	if count, ok := stats["Orders"]; !ok || count != 0 {
+		t.Errorf("Expected Orders to be listed with 0 hits, got %d (listed: %v)", count, ok)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/__mocks/stats/reset", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET reset, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/__mocks/stats/reset", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if stats := getStats(); stats["Users"] != 0 {
+		t.Errorf("Expected counters to be reset, got %v", stats)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	port := freePort(t)
+
+	mocks := []models.Mock{
+		{
+			Name:     "Fast",
+			Request:  models.Request{URI: "/fast"},
+			Response: models.Response{StatusCode: 200},
+		},
+		{
+			Name:     "Slow",
+			Request:  models.Request{URI: "/slow"},
+			Response: models.Response{StatusCode: 200, Body: "done", Delay: 300},
+		},
+	}
+	srv := NewServer(port, mocks, nil, nil)
+
+	started := make(chan error, 1)
+	go func() { started <- srv.Start() }()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	waitForBody(t, baseURL+"/fast")
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(baseURL + "/slow")
+		results <- result{resp, err}
+	}()
+
+	// Shut down while the slow request is in flight
+	time.Sleep(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	res := <-results
+	if res.err != nil {
+		t.Fatalf("Expected the in-flight request to complete, got %v", res.err)
+	}
+	resp := res.resp
+	defer resp.Body.Close() //nolint:errcheck // test cleanup
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "done" {
+		t.Errorf("Expected the in-flight request to drain, got %d %q", resp.StatusCode, body)
+	}
+
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Errorf("Expected Start to return nil after Shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start didn't return after Shutdown")
+	}
+}
+
+func TestServerMultipleInstances(t *testing.T) {
+	newServer := func(body string) (*Server, string) {
+		port := freePort(t)
+		srv := NewServer(port, []models.Mock{
+			{Name: "Hello", Request: models.Request{URI: "/hello"}, Response: models.Response{StatusCode: 200, Body: body}},
+		}, nil, nil)
+
+		done := make(chan error, 1)
+		go func() { done <- srv.Start() }()
+		t.Cleanup(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := srv.Shutdown(ctx); err != nil {
+				t.Errorf("Shutdown failed: %v", err)
+			}
+			if err := <-done; err != nil {
+				t.Errorf("Start failed: %v", err)
+			}
+		})
+		return srv, fmt.Sprintf("http://127.0.0.1:%d", port)
+	}
+
+	// Starting two servers in one process used to panic on the shared default mux
+	first, firstURL := newServer("first")
+	second, secondURL := newServer("second")
+
+	for _, tt := range []struct{ url, expected string }{{firstURL, "first"}, {secondURL, "second"}} {
+		if got := waitForBody(t, tt.url+"/hello"); got != tt.expected {
+			t.Errorf("Expected %q from %s, got %q", tt.expected, tt.url, got)
+		}
+	}
+
+	// Handlers can also be mounted in another program's mux
+	mux := http.NewServeMux()
+	mux.Handle("/", first.Handler())
+	mux.Handle("/other/", http.StripPrefix("/other", second.Handler()))
+	for path, expected := range map[string]string{"/hello": "first", "/other/hello": "second"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Body.String() != expected {
+			t.Errorf("Expected %q for %s, got %q", expected, path, w.Body.String())
+		}
+	}
+}
+
+// freePort returns a TCP port that is free to listen on
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer listener.Close() //nolint:errcheck // only needed to pick a port
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// waitForBody requests the URL until the server accepts connections and returns the body
+func waitForBody(t *testing.T, url string) string {
+	t.Helper()
+	for i := 0; ; i++ {
+		resp, err := http.Get(url)
+		if err == nil {
+			defer resp.Body.Close() //nolint:errcheck // test cleanup
+			body, _ := io.ReadAll(resp.Body)
+			return string(body)
+		}
+		if i == 100 {
+			t.Fatalf("Server at %s didn't start: %v", url, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerStreamResponse(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:    "Stream",
+			Request: models.Request{URI: "/stream"},
+			Response: models.Response{
+				StatusCode: 200,
+				Body:       "0123456789",
+				Stream:     &models.StreamConfig{ChunkSize: 4, ChunkDelayMs: 20},
+			},
+		},
+	}
+	srv := NewServer(8080, mocks, nil, nil)
+
+	w := &chunkRecorder{ResponseRecorder: httptest.NewRecorder()}
+	start := time.Now()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/stream", nil))
+	elapsed := time.Since(start)
+
+	expected := []string{"0123", "4567", "89"}
+	if strings.Join(w.chunks, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected chunks %v, got %v", expected, w.chunks)
+	}
+	if elapsed < 40*time.Millisecond {
+		t.Errorf("Expected a delay between chunks, took %v", elapsed)
+	}
+
+	// Writers that can't flush get the whole body at once
+	plain := &plainWriter{header: make(http.Header)}
+	srv.handleRequest(plain, httptest.NewRequest("GET", "/stream", nil))
+	if plain.body.String() != "0123456789" || plain.writes != 1 {
+		t.Errorf("Expected the body in a single write, got %q in %d writes", plain.body.String(), plain.writes)
+	}
+}
+
+// chunkRecorder records the data written between flushes
+type chunkRecorder struct {
+	*httptest.ResponseRecorder
+	pending bytes.Buffer
+	chunks  []string
+}
+
+func (c *chunkRecorder) Write(p []byte) (int, error) {
+	c.pending.Write(p)
+	return c.ResponseRecorder.Write(p)
+}
+
+func (c *chunkRecorder) Flush() {
+	if c.pending.Len() > 0 {
+		c.chunks = append(c.chunks, c.pending.String())
+		c.pending.Reset()
+	}
+	c.ResponseRecorder.Flush()
+}
+
+// plainWriter is a ResponseWriter that doesn't implement http.Flusher
+type plainWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	writes int
+}
+
+func (p *plainWriter) Header() http.Header { return p.header }
+func (p *plainWriter) WriteHeader(int)     {}
+func (p *plainWriter) Write(b []byte) (int, error) {
+	p.writes++
+	return p.body.Write(b)
+}
+
+func TestServerMockCORS(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Partner API",
+			Request:  models.Request{URI: "/partner", Method: "POST", Headers: map[string]string{"Authorization": "secret"}},
+			Response: models.Response{StatusCode: 201},
+			CORS:     &models.CORSConfig{Origins: "https://a.example.com, https://b.example.com", Credentials: true, MaxAge: 600},
+		},
+		{
+			Name:     "Public API",
+			Request:  models.Request{URI: "/public", Method: "GET"},
+			Response: models.Response{StatusCode: 200},
+		},
+	}
+	global := &CORSConfig{Enabled: true, Origins: "*", Methods: "GET", Headers: "Content-Type"}
+	srv := NewServer(8080, mocks, nil, global)
+
+	preflight := func(path, method, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", path, nil)
+		req.Header.Set("Access-Control-Request-Method", method)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		srv.handleRequest(w, req)
+		return w
+	}
+
+	// Preflights use the mock's settings, even though they don't carry the mock's headers
+	w := preflight("/partner", "POST", "https://b.example.com")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://b.example.com" {
+		t.Errorf("Expected the allowed origin to be echoed, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Expected credentials to be allowed, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Expected max age 600, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET" {
+		t.Errorf("Expected methods to fall back to the server-wide setting, got %q", got)
+	}
+
+	w = preflight("/partner", "POST", "https://evil.example.com")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no allowed origin for a foreign origin, got %q", got)
+	}
+
+	// Mocks without CORS settings use the server-wide configuration
+	w = preflight("/public", "GET", "https://evil.example.com")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected the server-wide origin, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected no credentials header, got %q", got)
+	}
+
+	// Actual requests get the matched mock's headers
+	req := httptest.NewRequest("POST", "/partner", nil)
+	req.Header.Set("Origin", "https://a.example.com")
+	req.Header.Set("Authorization", "secret")
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, req)
+	if w.Code != 201 {
+		t.Fatalf("Expected status 201, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://a.example.com" {
+		t.Errorf("Expected the mock's origin, got %q", got)
+	}
+
+	// Per-mock CORS also works when it's disabled server-wide
+	srv = NewServer(8080, mocks, nil, nil)
+	w = preflight("/partner", "POST", "https://a.example.com")
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://a.example.com" {
+		t.Errorf("Expected the mock's preflight, got %d %v", w.Code, w.Header())
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != defaultCORSMethods {
+		t.Errorf("Expected the default methods, got %q", got)
+	}
+}
+
+func TestServerAuth(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Basic",
+			Request:  models.Request{URI: "/basic", Method: "POST", Body: `{"ok": true}`, Auth: &models.AuthConfig{Type: "basic", Username: "admin", Password: "s3cret"}},
+			Response: models.Response{StatusCode: 200, Body: "basic ok"},
+		},
+		{
+			Name:     "Bearer",
+			Request:  models.Request{URI: "/bearer", Auth: &models.AuthConfig{Type: "bearer", Token: "abc123", Realm: "api"}},
+			Response: models.Response{StatusCode: 200, Body: "bearer ok"},
+		},
+	}
+	srv := NewServer(8080, mocks, nil, nil)
+
+	basic := func(user, pass string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		auth         string
+		body         string
+		expected     int
+		expectedBody string
+		authenticate string
+	}{
+		{"Basic missing", "POST", "/basic", "", `{"ok": true}`, 401, "", `Basic realm="pmp-mock-http", charset="UTF-8"`},
+		{"Basic wrong password", "POST", "/basic", basic("admin", "nope"), `{"ok": true}`, 401, "", `Basic realm="pmp-mock-http", charset="UTF-8"`},
+		{"Basic wrong body is still unauthorized", "POST", "/basic", "", `{"ok": false}`, 401, "", `Basic realm="pmp-mock-http", charset="UTF-8"`},
+		{"Basic correct", "POST", "/basic", basic("admin", "s3cret"), `{"ok": true}`, 200, "basic ok", ""},
+		{"Basic correct with wrong body", "POST", "/basic", basic("admin", "s3cret"), `{"ok": false}`, 404, "", ""},
+		{"Basic other route", "GET", "/basic", "", "", 404, "", ""},
+		{"Bearer missing", "GET", "/bearer", "", "", 401, "", `Bearer realm="api"`},
+		{"Bearer wrong", "GET", "/bearer", "Bearer xyz", "", 401, "", `Bearer realm="api", error="invalid_token"`},
+		{"Bearer with basic credentials", "GET", "/bearer", basic("admin", "s3cret"), "", 401, "", `Bearer realm="api"`},
+		{"Bearer correct", "GET", "/bearer", "Bearer abc123", "", 200, "bearer ok", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			srv.handleRequest(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.authenticate {
+				t.Errorf("Expected WWW-Authenticate %q, got %q", tt.authenticate, got)
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+
+	// A lower-priority mock without auth answers requests the gate rejected
+	srv.UpdateMocks(append(mocks, models.Mock{
+		Name:     "Custom 401",
+		Priority: -1,
+		Request:  models.Request{URI: "/bearer"},
+		Response: models.Response{StatusCode: 401, Body: "custom"},
+	}))
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/bearer", nil))
+	if w.Code != 401 || w.Body.String() != "custom" {
+		t.Errorf("Expected the custom 401 mock, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestServerStatusCodeTemplate(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Echo Status",
+			Request:  models.Request{URI: "/status"},
+			Response: models.Response{StatusCode: 200, StatusCodeTemplate: `{{index .Headers "X-Status"}}`, Body: "ok"},
+		},
+		{
+			Name:     "Broken Template",
+			Request:  models.Request{URI: "/broken"},
+			Response: models.Response{StatusCode: 202, StatusCodeTemplate: `{{.Missing`, Body: "ok"},
+		},
+	}
+	srv := NewServer(8080, mocks, nil, nil)
+
+	tests := []struct {
+		name     string
+		path     string
+		status   string
+		expected int
+	}{
+		{"Rendered status", "/status", "503", 503},
+		{"Whitespace is trimmed", "/status", " 418 ", 418},
+		{"Not a number", "/status", "teapot", 200},
+		{"Out of range", "/status", "42", 200},
+		{"Empty", "/status", "", 200},
+		{"Render error", "/broken", "503", 202},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.status != "" {
+				req.Header.Set("X-Status", tt.status)
+			}
+			w := httptest.NewRecorder()
+			srv.handleRequest(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestServerChaosConnectionReset(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Reset",
+			Request:  models.Request{URI: "/reset"},
+			Response: models.Response{StatusCode: 200, Body: "ok", Chaos: &models.ChaosConfig{Enabled: true, ConnectionReset: 1}},
+		},
+	}
+	srv := NewServer(8080, mocks, nil, nil)
+
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/reset")
+	if err == nil {
+		resp.Body.Close() //nolint:errcheck // test cleanup
+		t.Fatalf("Expected the connection to be reset, got status %d", resp.StatusCode)
+	}
+
+	// Connections that can't be hijacked get the regular response
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/reset", nil))
+	if w.Code != 200 || w.Body.String() != "ok" {
+		t.Errorf("Expected the regular response, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestServerThrottleResponse(t *testing.T) {
+	body := strings.Repeat("x", 1000)
+	mocks := []models.Mock{
+		{
+			Name:     "Throttled",
+			Request:  models.Request{URI: "/throttled"},
+			Response: models.Response{StatusCode: 200, Body: body, ThrottleBytesPerSec: 2000},
+		},
+		{
+			Name:    "Throttled Template",
+			Request: models.Request{URI: "/throttled-template"},
+			Response: models.Response{StatusCode: 200, Body: `{{.Method}}` + body, Template: true, ThrottleBytesPerSec: 2000,
+				Stream: &models.StreamConfig{ChunkSize: 100}},
+		},
+	}
+	srv := NewServer(8080, mocks, nil, nil)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"Plain body", "/throttled", body},
+		{"Rendered and streamed body", "/throttled-template", "GET" + body},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			start := time.Now()
+			srv.handleRequest(w, httptest.NewRequest("GET", tt.path, nil))
+			elapsed := time.Since(start)
+
+			// 1000 bytes at 2000 bytes per second
+			if elapsed < 450*time.Millisecond {
+				t.Errorf("Expected the transfer to take about 500ms, took %v", elapsed)
+			}
+			if w.Body.String() != tt.expected {
+				t.Errorf("Expected the full body, got %d bytes", w.Body.Len())
+			}
+			if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(tt.expected)) {
+				t.Errorf("Expected Content-Length %d, got %q", len(tt.expected), got)
+			}
+		})
+	}
+}
+
+func TestServerETag(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Static ETag",
+			Request:  models.Request{URI: "/static"},
+			Response: models.Response{StatusCode: 200, Body: "static", ETag: "v1"},
+		},
+		{
+			Name:     "Auto ETag",
+			Request:  models.Request{URI: "/auto"},
+			Response: models.Response{StatusCode: 200, Body: `{"id": 1}`, ETag: "auto"},
+		},
+		{
+			Name:     "Error ETag",
+			Request:  models.Request{URI: "/error"},
+			Response: models.Response{StatusCode: 500, Body: "error", ETag: "v1"},
+		},
+	}
+	srv := NewServer(8080, mocks, nil, nil)
+
+	sum := sha256.Sum256([]byte(`{"id": 1}`))
+	autoETag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		ifNoneMatch  string
+		expected     int
+		expectedETag string
+		expectedBody string
+	}{
+		{"No condition", "GET", "/static", "", 200, `"v1"`, "static"},
+		{"Match", "GET", "/static", `"v1"`, 304, `"v1"`, ""},
+		{"Weak match", "GET", "/static", `W/"v1"`, 304, `"v1"`, ""},
+		{"Match in list", "GET", "/static", `"v0", "v1"`, 304, `"v1"`, ""},
+		{"Wildcard", "GET", "/static", "*", 304, `"v1"`, ""},
+		{"Mismatch", "GET", "/static", `"v2"`, 200, `"v1"`, "static"},
+		{"Unsafe method", "POST", "/static", `"v1"`, 200, `"v1"`, "static"},
+		{"Auto match", "GET", "/auto", autoETag, 304, autoETag, ""},
+		{"Auto mismatch", "GET", "/auto", `"stale"`, 200, autoETag, `{"id": 1}`},
+		{"Error responses are not conditional", "GET", "/error", `"v1"`, 500, `"v1"`, "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			srv.handleRequest(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if got := w.Header().Get("ETag"); got != tt.expectedETag {
+				t.Errorf("Expected ETag %s, got %s", tt.expectedETag, got)
+			}
+			if w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServerBodyFile(t *testing.T) {
+	content := "0123456789abcdefghij"
+	path := filepath.Join(t.TempDir(), "media.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write body file: %v", err)
+	}
+
+	mocks := []models.Mock{
+		{
+			Name:     "File",
+			Request:  models.Request{URI: "/file"},
+			Response: models.Response{StatusCode: 200, BodyFile: path},
+		},
+		{
+			Name:     "File Not Found",
+			Request:  models.Request{URI: "/error"},
+			Response: models.Response{StatusCode: 404, BodyFile: path},
+		},
+		{
+			Name:     "Missing File",
+			Request:  models.Request{URI: "/missing"},
+			Response: models.Response{StatusCode: 200, BodyFile: filepath.Join(t.TempDir(), "missing.txt")},
+		},
+	}
+	srv := NewServer(8080, mocks, nil, nil)
+
+	tests := []struct {
+		name                 string
+		path                 string
+		rangeHeader          string
+		expected             int
+		expectedBody         string
+		expectedContentRange string
+	}{
+		{"Whole file", "/file", "", 200, content, ""},
+		{"Range", "/file", "bytes=5-9", 206, "56789", "bytes 5-9/20"},
+		{"Suffix range", "/file", "bytes=-3", 206, "hij", "bytes 17-19/20"},
+		{"Open range", "/file", "bytes=15-", 206, "fghij", "bytes 15-19/20"},
+		{"Unsatisfiable range", "/file", "bytes=50-60", 416, "", "bytes */20"},
+		{"Other status codes send the whole file", "/error", "bytes=5-9", 404, content, ""},
+		{"Missing file", "/missing", "", 500, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			w := httptest.NewRecorder()
+			srv.handleRequest(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Range"); got != tt.expectedContentRange {
+				t.Errorf("Expected Content-Range %q, got %q", tt.expectedContentRange, got)
+			}
+			if tt.path == "/file" && w.Header().Get("Accept-Ranges") != "bytes" {
+				t.Errorf("Expected Accept-Ranges: bytes, got %q", w.Header().Get("Accept-Ranges"))
+			}
+		})
+	}
+}
+
+func TestServerCookies(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:    "Login",
+			Request: models.Request{URI: "/login"},
+			Response: models.Response{StatusCode: 200, Body: "ok", Template: true, Cookies: []models.ResponseCookie{
+				{Name: "session", Value: "{{.Method}}-123", Path: "/", HTTPOnly: true, Secure: true, SameSite: "Strict", MaxAge: 3600},
+				{Name: "theme", Value: "dark", Domain: "example.com"},
+				{Name: "legacy", MaxAge: -1},
+			}},
+		},
+	}
+	srv := NewServer(8080, mocks, nil, nil)
+
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("POST", "/login", nil))
+
+	expected := []string{
+		"session=POST-123; Path=/; Max-Age=3600; HttpOnly; Secure; SameSite=Strict",
+		"theme=dark; Domain=example.com",
+		"legacy=; Max-Age=0",
+	}
+	got := w.Header().Values("Set-Cookie")
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d Set-Cookie headers, got %v", len(expected), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected Set-Cookie %q, got %q", expected[i], got[i])
+		}
+	}
+}
+
+func TestServerHeadersMulti(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:    "Links",
+			Request: models.Request{URI: "/items"},
+			Response: models.Response{
+				StatusCode:      200,
+				Headers:         map[string]string{"Content-Type": "application/json", "Link": `</items?page=1>; rel="first"`},
+				HeadersMulti:    map[string][]string{"Link": {`</items?page=2>; rel="next"`, `</items?page=9>; rel="last"`}, "X-Method": {"{{.Method}}"}},
+				HeaderTemplates: true,
+				Body:            "[]",
+			},
+		},
+	}
+	srv := NewServer(8080, mocks, nil, nil)
+
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/items")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // test cleanup
+
+	expected := []string{`</items?page=1>; rel="first"`, `</items?page=2>; rel="next"`, `</items?page=9>; rel="last"`}
+	links := resp.Header.Values("Link")
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d Link headers, got %v", len(expected), links)
+	}
+	for i := range expected {
+		if links[i] != expected[i] {
+			t.Errorf("Expected Link %q, got %q", expected[i], links[i])
+		}
+	}
+	if got := resp.Header.Get("X-Method"); got != "GET" {
+		t.Errorf("Expected rendered X-Method header, got %q", got)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected Content-Type from headers, got %q", got)
+	}
+}
+
+func TestServerOAuthProvider(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "oauth.yaml")
+	content := `clients:
+  - client_id: "web"
+    client_secret: "web-secret"
+    redirect_uris: ["http://localhost:3000/callback"]
+    scopes: ["openid", "profile"]
+  - client_id: "service"
+    client_secret: "service-secret"
+users:
+  - username: "alice"
+    password: "wonderland"
+    subject: "user-1"
+    claims:
+      name: "Alice"
+      email: "alice@example.com"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write OAuth2 config: %v", err)
+	}
+
+	provider, err := oauth.NewOAuth2Provider("http://localhost:8083")
+	if err != nil {
+		t.Fatalf("Failed to create OAuth2 provider: %v", err)
+	}
+	config, err := oauth.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load OAuth2 config: %v", err)
+	}
+	if err := provider.ApplyConfig(config); err != nil {
+		t.Fatalf("Failed to apply OAuth2 config: %v", err)
+	}
+
+	srv := NewServer(8080, nil, nil, nil)
+	srv.SetOAuthProvider(provider)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	token := func(form url.Values) (int, map[string]interface{}) {
+		resp, err := http.PostForm(ts.URL+"/oauth/token", form)
+		if err != nil {
+			t.Fatalf("Token request failed: %v", err)
+		}
+		defer resp.Body.Close() //nolint:errcheck // test cleanup
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode token response: %v", err)
+		}
+		return resp.StatusCode, body
+	}
+
+	tests := []struct {
+		name          string
+		form          url.Values
+		expected      int
+		expectedError string
+	}{
+		{"Password grant", url.Values{"grant_type": {"password"}, "client_id": {"web"}, "client_secret": {"web-secret"},
+			"username": {"alice"}, "password": {"wonderland"}, "scope": {"openid"}}, 200, ""},
+		{"Wrong password", url.Values{"grant_type": {"password"}, "client_id": {"web"}, "client_secret": {"web-secret"},
+			"username": {"alice"}, "password": {"guess"}}, 400, "invalid_grant"},
+		{"Unknown user", url.Values{"grant_type": {"password"}, "client_id": {"web"}, "client_secret": {"web-secret"},
+			"username": {"user"}, "password": {"password"}}, 400, "invalid_grant"},
+		{"Scope not allowed", url.Values{"grant_type": {"client_credentials"}, "client_id": {"web"}, "client_secret": {"web-secret"},
+			"scope": {"admin"}}, 400, "invalid_scope"},
+		{"Client without scopes", url.Values{"grant_type": {"client_credentials"}, "client_id": {"service"}, "client_secret": {"service-secret"},
+			"scope": {"admin"}}, 200, ""},
+		{"Default client replaced", url.Values{"grant_type": {"client_credentials"}, "client_id": {"default-client"}, "client_secret": {"default-secret"}},
+			401, "invalid_client"},
+	}
+
+	var accessToken string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := token(tt.form)
+			if status != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %v", tt.expected, status, body)
+			}
+			if tt.expectedError != "" && body["error"] != tt.expectedError {
+				t.Errorf("Expected error %s, got %v", tt.expectedError, body["error"])
+			}
+			if tt.name == "Password grant" {
+				accessToken, _ = body["access_token"].(string)
+				if body["id_token"] == nil {
+					t.Error("Expected an ID token for the openid scope")
+				}
+			}
+		})
+	}
+
+	req, _ := http.NewRequest("GET", ts.URL+"/oauth/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Userinfo request failed: %v", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // test cleanup
+
+	var userInfo map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
+		t.Fatalf("Failed to decode userinfo: %v", err)
+	}
+	if userInfo["sub"] != "user-1" || userInfo["name"] != "Alice" || userInfo["email"] != "alice@example.com" {
+		t.Errorf("Expected the configured user's claims, got %v", userInfo)
+	}
+}
+
+func TestServerDecodeRequestBody(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name: "Compressed Mock",
+			Request: models.Request{
+				URI:    "/api/upload",
+				Method: "POST",
+				JSONPath: []models.JSONPathMatcher{
+					{Path: "name", Value: "John"},
+				},
+			},
+			Response: models.Response{
+				StatusCode: 201,
+			},
+		},
+	}
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write([]byte(`{"name": "John"}`))
+	_ = gz.Close()
+
+	// Without decoding, the compressed body doesn't match
+	srv := NewServer(8080, mocks, nil, nil)
+	req := httptest.NewRequest("POST", "/api/upload", bytes.NewReader(compressed.Bytes()))
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, req)
+	if w.Result().StatusCode != 404 {
+		t.Errorf("Expected status 404 without decoding, got %d", w.Result().StatusCode)
+	}
+
+	// With decoding enabled, the decompressed body is matched
+	srv.SetDecodeRequestBody(true)
+	req = httptest.NewRequest("POST", "/api/upload", bytes.NewReader(compressed.Bytes()))
+	req.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, req)
+	if w.Result().StatusCode != 201 {
+		t.Errorf("Expected status 201 with decoding, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestServerDecodeRequestBodyLimit(t *testing.T) {
+	mocks := []models.Mock{
+		{Name: "Decoded", Priority: 10, Request: models.Request{URI: "/api/upload", Method: "POST", JSONPath: []models.JSONPathMatcher{{Path: "name", Value: "^ +$", Regex: true}}}, Response: models.Response{StatusCode: 201}},
+		{Name: "Raw", Request: models.Request{URI: "/api/upload", Method: "POST"}, Response: models.Response{StatusCode: 202}},
+	}
+
+	// A small gzip body that decompresses to more than the limit
+	var bomb bytes.Buffer
+	gz := gzip.NewWriter(&bomb)
+	_, _ = gz.Write([]byte(`{"name": "`))
+	_, _ = gz.Write(bytes.Repeat([]byte(" "), maxDecodedBodySize))
+	_, _ = gz.Write([]byte(`"}`))
+	_ = gz.Close()
+
+	if _, err := decodeRequestBody("gzip", bomb.Bytes()); err == nil {
+		t.Fatal("Expected an error for a body decompressing past the limit")
+	}
+
+	// The request falls back to matching the raw body
+	srv := NewServer(8080, mocks, nil, nil)
+	srv.SetDecodeRequestBody(true)
+	req := httptest.NewRequest("POST", "/api/upload", bytes.NewReader(bomb.Bytes()))
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, req)
+	if w.Code != 202 {
+		t.Errorf("Expected the raw body to be matched with status 202, got %d", w.Code)
+	}
+}
+
+func TestServerContentLengthOverrides(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:    "Chunked",
+			Request: models.Request{URI: "/chunked", Method: "GET"},
+			Response: models.Response{
+				StatusCode:        200,
+				Body:              "chunked body",
+				OmitContentLength: true,
+			},
+		},
+		{
+			Name:    "Fake Length",
+			Request: models.Request{URI: "/fake", Method: "GET"},
+			Response: models.Response{
+				StatusCode:        200,
+				Body:              "short",
+				FakeContentLength: 100,
+			},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleRequest))
+	defer ts.Close()
+
+	// Omitted Content-Length results in a chunked response
+	resp, err := http.Get(ts.URL + "/chunked")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.ContentLength != -1 {
+		t.Errorf("Expected unknown content length, got %d", resp.ContentLength)
+	}
+	if len(resp.TransferEncoding) == 0 || resp.TransferEncoding[0] != "chunked" {
+		t.Errorf("Expected chunked transfer encoding, got %v", resp.TransferEncoding)
+	}
+	if string(body) != "chunked body" {
+		t.Errorf("Expected body 'chunked body', got '%s'", string(body))
+	}
+
+	// Fake Content-Length is sent as-is and the client sees a truncated body
+	resp, err = http.Get(ts.URL + "/fake")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	_, readErr := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.ContentLength != 100 {
+		t.Errorf("Expected fake content length 100, got %d", resp.ContentLength)
+	}
+	if readErr == nil {
+		t.Error("Expected an error reading a body shorter than its Content-Length")
+	}
+}
+
+func TestServerHeadBody(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Head Default",
+			Request:  models.Request{URI: "/head", Method: "HEAD"},
+			Response: models.Response{StatusCode: 200, Body: "hidden body"},
+		},
+		{
+			Name:     "Head With Body",
+			Request:  models.Request{URI: "/head-body", Method: "HEAD"},
+			Response: models.Response{StatusCode: 200, Body: "visible body", AllowHeadBody: true},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+
+	// By default the body is omitted but its length is advertised
+	req := httptest.NewRequest("HEAD", "/head", nil)
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, req)
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body for HEAD, got '%s'", w.Body.String())
+	}
+	if w.Header().Get("Content-Length") != "11" {
+		t.Errorf("Expected Content-Length 11, got '%s'", w.Header().Get("Content-Length"))
+	}
+
+	// With allow_head_body the body is written on the wire anyway
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleRequest))
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck // test cleanup
+
+	if _, err := fmt.Fprintf(conn, "HEAD /head-body HTTP/1.1\r\nHost: localhost\r\n\r\n"); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	raw, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !strings.HasSuffix(string(raw), "\r\n\r\nvisible body") {
+		t.Errorf("Expected raw response to end with the body, got %q", string(raw))
+	}
+}
+
+func TestServerPluginEndpoints(t *testing.T) {
+	srv := NewServer(8080, nil, nil, nil)
+
+	pluginsDir := t.TempDir()
+	mockGit := plugins.NewMockGitClient()
+	mockGit.SetCloneCallback(func(repoURL, destPath string) error {
+		return os.MkdirAll(filepath.Join(destPath, "pmp-mock-http"), 0755)
+	})
+	manager := plugins.NewManagerWithGitClient(pluginsDir, []string{"https://github.com/user/mocks.git"}, mockGit, nil)
+
+	refreshes := 0
+	srv.SetPluginManager(manager, func() error {
+		refreshes++
+		_, err := manager.SetupPlugins()
+		return err
+	})
+
+	mux := http.NewServeMux()
+	srv.registerControlEndpoints(mux)
+
+	// Nothing is listed before the plugins are set up
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/__plugins", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"count":0`) {
+		t.Fatalf("Expected empty plugin list, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Refresh requires POST
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/__plugins/refresh", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/__plugins/refresh", nil))
+	if w.Code != http.StatusOK || refreshes != 1 {
+		t.Fatalf("Expected refresh to succeed, got %d (%d refreshes): %s", w.Code, refreshes, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/__plugins", nil))
+	var result struct {
+		Plugins []plugins.PluginInfo `json:"plugins"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(result.Plugins) != 1 || result.Plugins[0].Name != "mocks" || result.Plugins[0].Dir != filepath.Join(pluginsDir, "mocks") {
+		t.Errorf("Unexpected plugin list: %+v", result.Plugins)
+	}
+}
+
+func TestServerJWTProtectedMock(t *testing.T) {
+	secret := "test-secret"
+	mocks := []models.Mock{
+		{
+			Name: "Protected",
+			Request: models.Request{
+				URI:    "/api/me",
+				Method: "GET",
+				JWT:    &models.JWTMatcher{Secret: secret, Claims: map[string]string{"scope": "profile"}},
+			},
+			Response: models.Response{
+				StatusCode: 200,
+				Body:       `{"user": "{{.JWT.sub}}"}`,
+				Template:   true,
+			},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+
+	// Missing token
+	req := httptest.NewRequest("GET", "/api/me", nil)
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", w.Code)
+	}
+	if !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+		t.Errorf("Expected Bearer challenge, got '%s'", w.Header().Get("WWW-Authenticate"))
+	}
+
+	// Token without the required scope
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice", "scope": "email"}).SignedString([]byte(secret))
+	req = httptest.NewRequest("GET", "/api/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, req)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Header().Get("WWW-Authenticate"), `error="invalid_token"`) {
+		t.Errorf("Expected 401 invalid_token, got %d (%s)", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+
+	// Valid token, claims available to templates
+	token, _ = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice", "scope": "email profile"}).SignedString([]byte(secret))
+	req = httptest.NewRequest("GET", "/api/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Body.String() != `{"user": "alice"}` {
+		t.Errorf("Expected claims in body, got '%s'", w.Body.String())
+	}
+}
+
+func TestServerSimulateGatewayTimeout(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Slow Upstream",
+			Request:  models.Request{URI: "/slow", Method: "GET"},
+			Response: models.Response{StatusCode: 200, Body: "ok", Delay: 1000, SimulateGatewayTimeout: 50},
+		},
+		{
+			Name:     "Fast Upstream",
+			Request:  models.Request{URI: "/fast", Method: "GET"},
+			Response: models.Response{StatusCode: 200, Body: "ok", Delay: 10, SimulateGatewayTimeout: 50},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+
+	// Upstream slower than the gateway timeout
+	start := time.Now()
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/slow", nil))
+	elapsed := time.Since(start)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected 504, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "504 Gateway Time-out") {
+		t.Errorf("Expected gateway timeout body, got '%s'", w.Body.String())
+	}
+	if elapsed < 50*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("Expected to wait for the gateway timeout only, took %v", elapsed)
+	}
+
+	// Upstream faster than the gateway timeout
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/fast", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("Expected regular response, got %d '%s'", w.Code, w.Body.String())
+	}
+
+	// A cancelled request stops waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/slow", nil).WithContext(ctx))
+	if time.Since(start) > 40*time.Millisecond {
+		t.Errorf("Expected cancelled request to return immediately, took %v", time.Since(start))
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected no response for a cancelled request, got '%s'", w.Body.String())
+	}
+}
+
+func TestServerResponseCompression(t *testing.T) {
+	largeBody := strings.Repeat(`{"id": 1, "name": "item"},`, 100)
+	mocks := []models.Mock{
+		{
+			Name:     "Large",
+			Request:  models.Request{URI: "/large", Method: "GET"},
+			Response: models.Response{StatusCode: 200, Body: largeBody},
+		},
+		{
+			Name:     "Small",
+			Request:  models.Request{URI: "/small", Method: "GET"},
+			Response: models.Response{StatusCode: 200, Body: `{"ok": true}`},
+		},
+		{
+			Name:     "Encoded",
+			Request:  models.Request{URI: "/encoded", Method: "GET"},
+			Response: models.Response{StatusCode: 200, Body: largeBody, Headers: map[string]string{"Content-Encoding": "br"}},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+	srv.SetCompression(true, gzip.BestSpeed, 1024)
+
+	request := func(uri, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", uri, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		srv.handleRequest(w, req)
+		return w
+	}
+
+	w := request("/large", "br, gzip;q=0.8")
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip Content-Encoding, got '%s'", w.Header().Get("Content-Encoding"))
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got '%s'", w.Header().Get("Vary"))
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Failed to read gzip body: %v", err)
+	}
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to decompress body: %v", err)
+	}
+	if string(decompressed) != largeBody {
+		t.Error("Expected the decompressed body to match the mock body")
+	}
+
+	tests := []struct {
+		name           string
+		uri            string
+		acceptEncoding string
+	}{
+		{"below min size", "/small", "gzip"},
+		{"no accept-encoding", "/large", ""},
+		{"gzip refused", "/large", "gzip;q=0, *"},
+		{"other encoding only", "/large", "br"},
+		{"mock sets encoding", "/encoded", "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(tt.uri, tt.acceptEncoding)
+			if w.Header().Get("Content-Encoding") == "gzip" {
+				t.Error("Expected an uncompressed response")
+			}
+		})
+	}
+
+	srv.SetCompression(false, gzip.DefaultCompression, 0)
+	if w := request("/large", "gzip"); w.Header().Get("Content-Encoding") != "" {
+		t.Error("Expected no compression when disabled")
+	}
+}
+
+func TestServerPrettyJSON(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Pretty",
+			Request:  models.Request{URI: "/pretty", Method: "GET"},
+			Response: models.Response{StatusCode: 200, Body: `{"id":1,"tags":["a"]}`, PrettyJSON: true},
+		},
+		{
+			Name:     "Compact",
+			Request:  models.Request{URI: "/compact", Method: "GET"},
+			Response: models.Response{StatusCode: 200, Body: `{"id":1}`},
+		},
+		{
+			Name:     "Text",
+			Request:  models.Request{URI: "/text", Method: "GET"},
+			Response: models.Response{StatusCode: 200, Body: "not {json", PrettyJSON: true},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/pretty", nil))
+	expected := "{\n  \"id\": 1,\n  \"tags\": [\n    \"a\"\n  ]\n}"
+	if w.Body.String() != expected {
+		t.Errorf("Expected indented body, got '%s'", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/text", nil))
+	if w.Body.String() != "not {json" {
+		t.Errorf("Expected non-JSON body unchanged, got '%s'", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/compact", nil))
+	if w.Body.String() != `{"id":1}` {
+		t.Errorf("Expected compact body without the server default, got '%s'", w.Body.String())
+	}
+
+	// Server-wide default
+	srv.SetPrettyJSON(true)
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/compact", nil))
+	if w.Body.String() != "{\n  \"id\": 1\n}" {
+		t.Errorf("Expected indented body with the server default, got '%s'", w.Body.String())
+	}
+}
+
+func TestServerNotAcceptable(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Report",
+			Request:  models.Request{URI: "/report", Method: "GET", Produces: []string{"application/json", "text/csv"}},
+			Response: models.Response{StatusCode: 200, Body: "id,name"},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+
+	req := httptest.NewRequest("GET", "/report", nil)
+	req.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+	if w.Header().Get("Content-Type") != "text/csv" {
+		t.Errorf("Expected negotiated Content-Type text/csv, got '%s'", w.Header().Get("Content-Type"))
+	}
+
+	req = httptest.NewRequest("GET", "/report", nil)
+	req.Header.Set("Accept", "application/xml")
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, req)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected 406, got %d", w.Code)
+	}
+}
+
+func TestServerVirtualClock(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Today",
+			Request:  models.Request{URI: "/today", Method: "GET"},
+			Response: models.Response{StatusCode: 200, Body: "{{date}} {{timestamp}}", Template: true},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+
+	w := httptest.NewRecorder()
+	srv.handleClock(w, httptest.NewRequest("POST", "/__clock", strings.NewReader(`{"time": "2024-01-01T00:00:00Z", "advance": "25h"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/today", nil))
+	if w.Body.String() != "2024-01-02 1704157200" {
+		t.Errorf("Expected the virtual time, got '%s'", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleClock(w, httptest.NewRequest("POST", "/__clock", strings.NewReader(`{"advance": "soon"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid duration, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleClock(w, httptest.NewRequest("DELETE", "/__clock", nil))
+	var state map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if state["fixed"] != false {
+		t.Errorf("Expected the clock to be reset, got %v", state)
+	}
+}
+
+func TestServerMockLogLevel(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Noisy",
+			LogLevel: "silent",
+			Request:  models.Request{URI: "/noisy", Method: "GET"},
+			Response: models.Response{StatusCode: 200, Body: "noisy"},
+		},
+		{
+			Name:     "Debugged",
+			LogLevel: "debug",
+			Request:  models.Request{URI: "/debugged", Method: "GET"},
+			Response: models.Response{StatusCode: 200, Body: "debug body"},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	srv.handleRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/noisy", nil))
+	if logs.Len() != 0 {
+		t.Errorf("Expected no logs for a silent mock, got '%s'", logs.String())
+	}
+
+	srv.handleRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/debugged", nil))
+	for _, expected := range []string{"GET /debugged", "Matched mock: Debugged", "Request headers:", "Response body: debug body"} {
+		if !strings.Contains(logs.String(), expected) {
+			t.Errorf("Expected debug logs to contain '%s', got '%s'", expected, logs.String())
+		}
+	}
+}
+
+func TestServerMaintenanceMode(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Users",
+			Request:  models.Request{URI: "/api/users", Method: "GET"},
+			Response: models.Response{StatusCode: 200, Body: "users"},
+		},
+		{
+			Name:     "Health",
+			Request:  models.Request{URI: "/health", Method: "GET"},
+			Response: models.Response{StatusCode: 200, Body: "OK"},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+	srv.SetMaintenance(true, "", []string{"/health"})
+
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/api/users", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != DefaultMaintenanceBody {
+		t.Errorf("Expected maintenance 503, got %d '%s'", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected allowed path to be served, got %d", w.Code)
+	}
+
+	// Toggle off via the control endpoint
+	w = httptest.NewRecorder()
+	srv.handleMaintenance(w, httptest.NewRequest("POST", "/__maintenance", strings.NewReader(`{"enabled": false}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/api/users", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "users" {
+		t.Errorf("Expected regular response after disabling maintenance, got %d '%s'", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleMaintenance(w, httptest.NewRequest("POST", "/__maintenance", strings.NewReader(`{}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without enabled, got %d", w.Code)
+	}
+}
+
+func TestServerTemplateDelims(t *testing.T) {
+	var spec models.MockSpec
+	err := yaml.Unmarshal([]byte(`
+mocks:
+  - name: "Helm Values"
+    request:
+      uri: "/chart"
+      method: "GET"
+    response:
+      status_code: 200
+      headers:
+        X-Path: "<< .Path >>"
+      header_templates: true
+      body: "image: {{ .Values.image }} path: << .Path >>"
+      template: true
+      template_delims: ["<<", ">>"]
+`), &spec)
+	if err != nil {
+		t.Fatalf("Failed to parse mock: %v", err)
+	}
+
+	srv := NewServer(8080, spec.Mocks, nil, nil)
+
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/chart", nil))
+	if w.Body.String() != "image: {{ .Values.image }} path: /chart" {
+		t.Errorf("Expected only the alternative delimiters to be rendered, got '%s'", w.Body.String())
+	}
+	if w.Header().Get("X-Path") != "/chart" {
+		t.Errorf("Expected rendered header, got '%s'", w.Header().Get("X-Path"))
+	}
+}
+
+func TestAutoTLSListener(t *testing.T) {
+	// Borrow the httptest certificate and a client that trusts it
+	certServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer certServer.Close()
+	tlsClient := certServer.Client()
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	listener := newAutoTLSListener(inner, &tls.Config{Certificates: certServer.TLS.Certificates})
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "tls=%v", r.TLS != nil)
+	})}
+	go server.Serve(listener) //nolint:errcheck // stopped by Close
+	defer server.Close()      //nolint:errcheck // test cleanup
+
+	addr := listener.Addr().String()
+	tests := []struct {
+		name     string
+		client   *http.Client
+		url      string
+		expected string
+	}{
+		{"plain HTTP", http.DefaultClient, "http://" + addr + "/", "tls=false"},
+		{"TLS", tlsClient, "https://" + addr + "/", "tls=true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.client.Get(tt.url)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close() //nolint:errcheck // test cleanup
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, body)
+			}
+		})
+	}
+}
+
+func TestServerTemplateFunctions(t *testing.T) {
+	srv := NewServer(8080, nil, nil, nil)
+	mux := http.NewServeMux()
+	srv.registerControlEndpoints(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/__template/functions", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Functions []struct {
+			Name        string `json:"name"`
+			Signature   string `json:"signature"`
+			Description string `json:"description"`
+		} `json:"functions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	found := false
+	for _, fn := range response.Functions {
+		if fn.Description == "" {
+			t.Errorf("Expected a description for template function %s", fn.Name)
+		}
+		if fn.Name == "randomInt" {
+			found = true
+			if fn.Signature != "randomInt(int, int) int" {
+				t.Errorf("Unexpected randomInt signature '%s'", fn.Signature)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected randomInt in the function list")
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/__template/functions", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for POST, got %d", w.Code)
+	}
+}
+
+func TestServerExpectContinue(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:     "Reject",
+			Request:  models.Request{URI: "/reject", Method: "POST"},
+			Response: models.Response{StatusCode: 201, Expect100: &models.Expect100Config{Action: "reject"}},
+		},
+		{
+			Name:     "Withhold",
+			Request:  models.Request{URI: "/withhold", Method: "POST"},
+			Response: models.Response{StatusCode: 401, Body: "unauthorized", Expect100: &models.Expect100Config{Action: "withhold"}},
+		},
+		{
+			Name:     "Delayed",
+			Request:  models.Request{URI: "/delayed", Method: "POST", Body: "payload"},
+			Response: models.Response{StatusCode: 201, Body: "created", Expect100: &models.Expect100Config{Delay: 200}},
+		},
+	}
+
+	srv := NewServer(8080, mocks, nil, nil)
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleRequest))
+	defer ts.Close()
+
+	// send writes the request headers and returns the first response status line
+	send := func(t *testing.T, path string) (net.Conn, *bufio.Reader, string) {
+		t.Helper()
+		conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		fmt.Fprintf(conn, "POST %s HTTP/1.1\r\nHost: localhost\r\nContent-Length: 7\r\nExpect: 100-continue\r\n\r\n", path)
+		reader := bufio.NewReader(conn)
+		status, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read status line: %v", err)
+		}
+		return conn, reader, strings.TrimSpace(status)
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		conn, _, status := send(t, "/reject")
+		defer conn.Close() //nolint:errcheck // test cleanup
+		if status != "HTTP/1.1 417 Expectation Failed" {
+			t.Errorf("Expected 417, got '%s'", status)
+		}
+	})
+
+	t.Run("withhold", func(t *testing.T) {
+		conn, _, status := send(t, "/withhold")
+		defer conn.Close() //nolint:errcheck // test cleanup
+		if status != "HTTP/1.1 401 Unauthorized" {
+			t.Errorf("Expected the final response without 100 Continue, got '%s'", status)
+		}
+	})
+
+	t.Run("delayed continue", func(t *testing.T) {
+		start := time.Now()
+		conn, reader, status := send(t, "/delayed")
+		defer conn.Close() //nolint:errcheck // test cleanup
+		if status != "HTTP/1.1 100 Continue" {
+			t.Fatalf("Expected 100 Continue, got '%s'", status)
+		}
+		if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+			t.Errorf("Expected 100 Continue after the delay, got it after %v", elapsed)
+		}
+
+		if _, err := reader.ReadString('\n'); err != nil { // Blank line ending the 100 response
+			t.Fatalf("Failed to read 100 Continue: %v", err)
+		}
+		fmt.Fprint(conn, "payload")
+		resp, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		defer resp.Body.Close() //nolint:errcheck // test cleanup
+		if resp.StatusCode != 201 {
+			t.Errorf("Expected 201 after sending the body, got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestServerExpectContinueDuringReload(t *testing.T) {
+	mocks := []models.Mock{
+		{
+			Name:    "Upload",
+			Request: models.Request{URI: "/upload", Method: "POST"},
+			Response: models.Response{
+				StatusCode: 200,
+				Expect100:  &models.Expect100Config{Action: "reject"},
+			},
+		},
+	}
+	srv := NewServer(8080, mocks, nil, nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			srv.UpdateMocks(mocks)
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		req := httptest.NewRequest("POST", "/upload", strings.NewReader("data"))
+		req.Header.Set("Expect", "100-continue")
+		w := httptest.NewRecorder()
+		srv.handleRequest(w, req)
+		if w.Code != http.StatusExpectationFailed {
+			t.Fatalf("Expected status 417, got %d", w.Code)
+		}
+	}
+	<-done
+}
+
+// blockingManagedMocks returns a snapshot per call; the second call blocks until released
+type blockingManagedMocks struct {
+	mu        sync.Mutex
+	calls     int
+	snapshots [][]models.Mock
+	blocked   chan struct{}
+	release   chan struct{}
+}
+
+func (b *blockingManagedMocks) GetAllMocks() []models.Mock {
+	b.mu.Lock()
+	call := b.calls
+	b.calls++
+	b.mu.Unlock()
+
+	if call == 1 {
+		close(b.blocked)
+		<-b.release
+	}
+	return b.snapshots[call]
+}
+
+func TestRefreshManagedMocksAppliesNewestRead(t *testing.T) {
+	stale := models.Mock{Name: "stale", Request: models.Request{URI: "/managed"}, Response: models.Response{StatusCode: 200}}
+	fresh := models.Mock{Name: "fresh", Request: models.Request{URI: "/managed"}, Response: models.Response{StatusCode: 201}}
+	source := &blockingManagedMocks{
+		snapshots: [][]models.Mock{nil, {stale}, {fresh}},
+		blocked:   make(chan struct{}),
+		release:   make(chan struct{}),
+	}
+	srv := NewServer(8080, nil, nil, nil)
+	srv.SetManagedMocks(source)
+
+	// The first refresh reads the stale snapshot and stalls before applying it
+	firstDone := make(chan struct{})
+	go func() {
+		defer close(firstDone)
+		srv.RefreshManagedMocks()
+	}()
+	<-source.blocked
+
+	// A later refresh must wait for it instead of applying its newer read first
+	secondDone := make(chan struct{})
+	go func() {
+		defer close(secondDone)
+		srv.RefreshManagedMocks()
+	}()
+	select {
+	case <-secondDone:
+		t.Fatal("Expected the second refresh to wait for the first one")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(source.release)
+	<-firstDone
+	<-secondDone
+
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/managed", nil))
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected the newest managed mocks to be served, got status %d", w.Code)
+	}
+}
+
+func TestManagedMockChangeKeepsFileMockSequence(t *testing.T) {
+	srv := NewServer(8080, []models.Mock{{
+		Name:    "Steps",
+		Request: models.Request{URI: "/steps"},
+		Response: models.Response{Sequence: []models.ResponseItem{
+			{StatusCode: 200, Body: "first"},
+			{StatusCode: 200, Body: "second"},
+			{StatusCode: 200, Body: "third"},
+		}},
+	}}, nil, nil)
+	manager := management.NewManager()
+	srv.SetManagedMocks(manager)
+	manager.SetChangeHandler(srv.RefreshManagedMocks)
+
+	get := func(uri string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		srv.handleRequest(w, httptest.NewRequest("GET", uri, nil))
+		return w.Body.String()
+	}
+
+	if body := get("/steps"); body != "first" {
+		t.Fatalf("Expected first response, got %q", body)
+	}
+
+	_, err := manager.CreateMock(management.CreateMockRequest{Mock: models.Mock{
+		Name:     "Managed",
+		Request:  models.Request{URI: "/managed"},
+		Response: models.Response{StatusCode: 201},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create managed mock: %v", err)
+	}
+
+	if body := get("/steps"); body != "second" {
+		t.Errorf("Expected the sequence to continue after creating a managed mock, got %q", body)
+	}
+	w := httptest.NewRecorder()
+	srv.handleRequest(w, httptest.NewRequest("GET", "/managed", nil))
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected the managed mock to be served, got status %d", w.Code)
+	}
+}
+
+func TestServerMaxHeaderBytes(t *testing.T) {
+	port := freePort(t)
+	srv := NewServer(port, []models.Mock{
+		{Name: "Hello", Request: models.Request{URI: "/hello"}, Response: models.Response{StatusCode: 200, Body: "hello"}},
+	}, nil, nil)
+	srv.SetMaxHeaderBytes(1024)
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Start() }()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown failed: %v", err)
+		}
+		<-done
+	}()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/hello", port)
+	if body := waitForBody(t, url); body != "hello" {
+		t.Fatalf("Expected headers under the limit to be accepted, got %q", body)
+	}
+
+	// net/http allows 4KB on top of the limit, so go well beyond it
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("X-Large", strings.Repeat("a", 16*1024))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // test cleanup
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("Expected status 431 for oversized headers, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// trackHTTPServer remembers a started server so Shutdown can stop it. Servers started
+// after Shutdown are closed right away.
+func (s *Server) trackHTTPServer(server *http.Server) {
+	s.serversMu.Lock()
+	defer s.serversMu.Unlock()
+	if s.shutdown {
+		server.Close() //nolint:errcheck // server hasn't started serving yet
+	}
+	s.httpServers = append(s.httpServers, server)
+}
+
+// trackHTTP3Server remembers a started HTTP/3 server so Shutdown can stop it
+func (s *Server) trackHTTP3Server(server *http3.Server) {
+	s.serversMu.Lock()
+	defer s.serversMu.Unlock()
+	if s.shutdown {
+		server.Close() //nolint:errcheck // server hasn't started serving yet
+	}
+	s.http3Servers = append(s.http3Servers, server)
+}
+
+// Shutdown gracefully stops the servers started by the Start methods: they stop accepting
+// connections and wait for in-flight requests until the context is done. The Start
+// methods then return nil.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.serversMu.Lock()
+	s.shutdown = true
+	httpServers := s.httpServers
+	http3Servers := s.http3Servers
+	s.serversMu.Unlock()
+
+	var errs []error
+	for _, server := range httpServers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, server := range http3Servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// serveResult hides the error the servers return once they have been shut down
+func serveResult(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// handleMockStats returns how many times each mock matched, as a map of mock name to count
+func (s *Server) handleMockStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.RLock()
+	stats := s.matcher.MockStats()
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		log.Printf("Error encoding response: %v\n", err)
+	}
+}
+
+// handleMockStatsReset zeroes the match counts of all mocks
+func (s *Server) handleMockStatsReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.RLock()
+	s.matcher.ResetMockStats()
+	s.mu.RUnlock()
+	log.Println("Mock stats reset")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "reset",
+		"message": "All mock stats reset",
+	}); err != nil {
+		log.Printf("Error encoding response: %v\n", err)
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+)
+
+// DefaultStreamChunkSize is the size of streamed body chunks if none is configured
+const DefaultStreamChunkSize = 1024
+
+// writeStream writes the body in chunks, flushing each one and waiting between them. The
+// body is written at once if the writer can't flush. Stops early if the client disconnects.
+func (s *Server) writeStream(w http.ResponseWriter, r *http.Request, body []byte, stream *models.StreamConfig) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		if _, err := w.Write(body); err != nil {
+			log.Printf("Error writing response body: %v\n", err)
+		}
+		return
+	}
+
+	chunkSize := stream.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultStreamChunkSize
+	}
+	delay := time.Duration(stream.ChunkDelayMs) * time.Millisecond
+
+	for start := 0; start < len(body); start += chunkSize {
+		if start > 0 && delay > 0 && !sleepContext(r.Context(), delay) {
+			log.Printf("Client disconnected during streamed response\n")
+			return
+		}
+
+		end := min(start+chunkSize, len(body))
+		if _, err := w.Write(body[start:end]); err != nil {
+			log.Printf("Error writing response body: %v\n", err)
+			return
+		}
+		flusher.Flush()
+	}
+}
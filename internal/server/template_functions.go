@@ -0,0 +1,23 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// handleTemplateFunctions lists the helper functions available in response templates
+func (s *Server) handleTemplateFunctions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"functions": s.templateRenderer.Functions(),
+	}); err != nil {
+		log.Printf("Error encoding response: %v\n", err)
+	}
+}
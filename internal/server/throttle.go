@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// throttleSlicesPerSec is how many slices a throttled body is split into per second of transfer
+const throttleSlicesPerSec = 10
+
+// errThrottleCanceled is returned by throttled writes once the client has disconnected
+var errThrottleCanceled = errors.New("client disconnected during throttled response")
+
+// throttledWriter caps the rate at which a response body is written. Bodies are written
+// in slices, each one flushed and paced so the transfer never gets ahead of the rate.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	bytesPerSec int
+	sliceSize   int
+	start       time.Time
+	written     int
+}
+
+// newThrottledWriter wraps the writer to write at most bytesPerSec bytes per second
+func newThrottledWriter(ctx context.Context, w http.ResponseWriter, bytesPerSec int) *throttledWriter {
+	return &throttledWriter{
+		ResponseWriter: w,
+		ctx:            ctx,
+		bytesPerSec:    bytesPerSec,
+		sliceSize:      max(bytesPerSec/throttleSlicesPerSec, 1),
+	}
+}
+
+// Write writes the data slice by slice, waiting until each one is due
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+
+	total := 0
+	for len(p) > 0 {
+		n, err := t.ResponseWriter.Write(p[:min(t.sliceSize, len(p))])
+		total += n
+		t.written += n
+		if err != nil {
+			return total, err
+		}
+		t.Flush()
+		p = p[n:]
+
+		due := t.start.Add(time.Duration(t.written) * time.Second / time.Duration(t.bytesPerSec))
+		if wait := time.Until(due); wait > 0 && !sleepContext(t.ctx, wait) {
+			return total, errThrottleCanceled
+		}
+	}
+	return total, nil
+}
+
+// Flush sends the buffered data to the client, if the wrapped writer supports it
+func (t *throttledWriter) Flush() {
+	if flusher, ok := t.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// handleVars handles listing (GET), setting (POST) and removing (DELETE) the variables read by
+// the env template function. POST takes a JSON object of names to values; DELETE removes the
+// variable given by the name query parameter, or all variables without one.
+func (s *Server) handleVars(w http.ResponseWriter, r *http.Request) {
+	vars := s.templateRenderer.Variables()
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var values map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+			http.Error(w, "Invalid request body, expected a JSON object of string values", http.StatusBadRequest)
+			return
+		}
+		if _, exists := values[""]; exists {
+			http.Error(w, "Variable names can't be empty", http.StatusBadRequest)
+			return
+		}
+		for name, value := range values {
+			vars.Set(name, value)
+		}
+		log.Printf("Template variables set: %d\n", len(values))
+	case http.MethodDelete:
+		if name := r.URL.Query().Get("name"); name != "" {
+			vars.Delete(name)
+			log.Printf("Template variable removed: %s\n", name)
+		} else {
+			vars.Clear()
+			log.Println("Template variables cleared")
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"vars": vars.All(),
+	}); err != nil {
+		log.Printf("Error encoding response: %v\n", err)
+	}
+}
@@ -0,0 +1,68 @@
+package sse
+
+import (
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// errStreamClosed is returned when writing to a compressed stream after it was closed
+var errStreamClosed = errors.New("compressed stream closed")
+
+// gzipStreamWriter gzips an event stream. Every flush emits the compressed bytes of the
+// events written so far, so clients receive them immediately. Writes are serialized, as
+// keep-alive comments are sent from a separate goroutine.
+type gzipStreamWriter struct {
+	http.ResponseWriter
+	flusher http.Flusher
+	gz      *gzip.Writer
+	mu      sync.Mutex
+	closed  bool
+}
+
+func newGzipStreamWriter(w http.ResponseWriter, flusher http.Flusher) *gzipStreamWriter {
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Del("Content-Length")
+	return &gzipStreamWriter{
+		ResponseWriter: w,
+		flusher:        flusher,
+		gz:             gzip.NewWriter(w),
+	}
+}
+
+// Write compresses the data
+func (g *gzipStreamWriter) Write(p []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return 0, errStreamClosed
+	}
+	return g.gz.Write(p)
+}
+
+// Flush sends the compressed data written so far to the client
+func (g *gzipStreamWriter) Flush() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return
+	}
+	if err := g.gz.Flush(); err == nil {
+		g.flusher.Flush()
+	}
+}
+
+// Close ends the gzip stream
+func (g *gzipStreamWriter) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+	err := g.gz.Close()
+	g.flusher.Flush()
+	return err
+}
@@ -0,0 +1,115 @@
+package sse
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+	"github.com/comfortablynumb/pmp-mock-http/internal/template"
+)
+
+// getStream requests the SSE stream without the transport's transparent decompression
+func getStream(t *testing.T, url, acceptEncoding string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}, Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	return resp
+}
+
+func TestHandleStreamGzip(t *testing.T) {
+	mock := &models.Mock{SSE: &models.SSEConfig{
+		Mode:              "once",
+		EnableCompression: true,
+		Events: []models.SSEEvent{
+			{Event: "greeting", Data: "hello", ID: "1"},
+			{Data: "world"},
+		},
+	}}
+	srv := httptest.NewServer(http.HandlerFunc(NewHandler(mock, template.NewRenderer()).HandleStream))
+	defer srv.Close()
+
+	resp := getStream(t, srv.URL, "br, gzip;q=0.8")
+	defer resp.Body.Close() //nolint:errcheck // test response
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a gzipped stream, got Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip stream: %v", err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Failed to read gzip stream: %v", err)
+	}
+	expected := "event: greeting\nid: 1\ndata: hello\n\ndata: world\n\n"
+	if string(body) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(body))
+	}
+}
+
+func TestHandleStreamGzipFlushesEvents(t *testing.T) {
+	// A cycling stream never ends, so the first event can only arrive if flushes reach the client
+	mock := &models.Mock{SSE: &models.SSEConfig{
+		Mode:              "cycle",
+		Interval:          50,
+		EnableCompression: true,
+		Events:            []models.SSEEvent{{Data: "tick"}},
+	}}
+	srv := httptest.NewServer(http.HandlerFunc(NewHandler(mock, template.NewRenderer()).HandleStream))
+	defer srv.Close()
+
+	resp := getStream(t, srv.URL, "gzip")
+	defer resp.Body.Close() //nolint:errcheck // test response
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip stream: %v", err)
+	}
+	line, err := bufio.NewReader(gz).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read the first event: %v", err)
+	}
+	if line != "data: tick\n" {
+		t.Errorf("Expected the first event, got %q", line)
+	}
+}
+
+func TestHandleStreamWithoutGzip(t *testing.T) {
+	mock := &models.Mock{SSE: &models.SSEConfig{
+		Mode:              "once",
+		EnableCompression: true,
+		Events:            []models.SSEEvent{{Data: "plain"}},
+	}}
+	srv := httptest.NewServer(http.HandlerFunc(NewHandler(mock, template.NewRenderer()).HandleStream))
+	defer srv.Close()
+
+	for _, acceptEncoding := range []string{"", "gzip;q=0", "br"} {
+		resp := getStream(t, srv.URL, acceptEncoding)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close() //nolint:errcheck // test response
+
+		if resp.Header.Get("Content-Encoding") != "" {
+			t.Errorf("Accept-Encoding %q: expected no compression, got %q", acceptEncoding, resp.Header.Get("Content-Encoding"))
+		}
+		if !strings.Contains(string(body), "data: plain") {
+			t.Errorf("Accept-Encoding %q: expected the plain event, got %q", acceptEncoding, string(body))
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/comfortablynumb/pmp-mock-http/internal/compress"
 	"github.com/comfortablynumb/pmp-mock-http/internal/models"
 	"github.com/comfortablynumb/pmp-mock-http/internal/template"
 	"github.com/dop251/goja"
@@ -40,6 +41,13 @@ func (h *Handler) HandleStream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Gzip the stream if enabled and the client accepts it; each flush still sends the events at once
+	if h.mock.SSE != nil && h.mock.SSE.EnableCompression && compress.AcceptsGzip(r.Header.Get("Accept-Encoding")) {
+		gw := newGzipStreamWriter(w, flusher)
+		defer gw.Close() //nolint:errcheck // client may be gone
+		w, flusher = gw, gw
+	}
+
 	log.Printf("SSE: Stream started for %s\n", r.RemoteAddr)
 
 	// Create request data for templates
@@ -0,0 +1,62 @@
+package template
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FunctionInfo describes a helper function available in templates
+type FunctionInfo struct {
+	Name        string `json:"name"`
+	Signature   string `json:"signature"`             // e.g. "randomInt(int, int) int"
+	Description string `json:"description,omitempty"` // Empty for functions without a description
+}
+
+// functionDescriptions documents the helper functions of the renderer
+var functionDescriptions = map[string]string{
+	"uuid":         "Random UUID-formatted hex string",
+	"randomString": "Random alphanumeric string of the given length",
+	"randomInt":    "Random integer between min and max (inclusive)",
+	"randomFloat":  "Random float between min and max",
+	"randomBool":   "Random true or false",
+	"firstName":    "Random first name",
+	"lastName":     "Random last name",
+	"fullName":     "Random first and last name",
+	"email":        "Random email address",
+	"username":     "Random username",
+	"city":         "Random city",
+	"country":      "Random country",
+	"zipCode":      "Random zip code",
+	"address":      "Random street address",
+	"company":      "Random company name",
+	"jobTitle":     "Random job title",
+	"ipAddress":    "Random IPv4 address",
+	"domain":       "Random domain name",
+	"url":          "Random URL",
+	"now":          "Current time (follows the virtual clock)",
+	"timestamp":    "Current Unix timestamp in seconds",
+	"date":         "Current date (YYYY-MM-DD)",
+	"datetime":     "Current time in RFC 3339 format",
+	"env":          "Variable set via /__vars, or the environment variable, read on every request",
+	"upper":        "Converts a string to upper case",
+	"lower":        "Converts a string to lower case",
+	"formatInt":    "Formats values like fmt.Sprintf",
+}
+
+// Functions lists the helper functions available in templates, sorted by name
+func (r *Renderer) Functions() []FunctionInfo {
+	functions := make([]FunctionInfo, 0, len(r.funcMap))
+	for name, fn := range r.funcMap {
+		functions = append(functions, FunctionInfo{
+			Name:        name,
+			Signature:   name + strings.TrimPrefix(reflect.TypeOf(fn).String(), "func"),
+			Description: functionDescriptions[name],
+		})
+	}
+
+	sort.Slice(functions, func(i, j int) bool {
+		return functions[i].Name < functions[j].Name
+	})
+	return functions
+}
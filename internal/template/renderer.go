@@ -9,6 +9,8 @@ import (
 	"strings"
 	"text/template"
 	"time"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/clock"
 )
 
 // RequestData holds the incoming request data for template rendering
@@ -20,6 +22,7 @@ type RequestData struct {
 	Headers    map[string]string
 	Body       string
 	RemoteAddr string
+	JWT        map[string]interface{} // Claims of the validated Bearer token, if the mock requires one
 }
 
 // NewRequestData creates RequestData from an http.Request
@@ -45,60 +48,96 @@ func NewRequestData(r *http.Request, body string) *RequestData {
 // Renderer handles template rendering with helper functions
 type Renderer struct {
 	funcMap template.FuncMap
+	clock   clock.Clock // Time source of the time generators
+	vars    *Variables  // Variables read by the env function before the process environment
 }
 
 // NewRenderer creates a new template renderer with helper functions
 func NewRenderer() *Renderer {
-	return &Renderer{
-		funcMap: template.FuncMap{
-			// String generators
-			"uuid":        generateUUID,
-			"randomString": randomString,
-			"randomInt":   randomInt,
-			"randomFloat": randomFloat,
-			"randomBool":  randomBool,
-
-			// Name generators
-			"firstName":  randomFirstName,
-			"lastName":   randomLastName,
-			"fullName":   randomFullName,
-			"email":      randomEmail,
-			"username":   randomUsername,
-
-			// Address generators
-			"city":       randomCity,
-			"country":    randomCountry,
-			"zipCode":    randomZipCode,
-			"address":    randomAddress,
-
-			// Business generators
-			"company":    randomCompany,
-			"jobTitle":   randomJobTitle,
-
-			// Internet generators
-			"ipAddress":  randomIPAddress,
-			"domain":     randomDomain,
-			"url":        randomURL,
-
-			// Time generators
-			"now":        time.Now,
-			"timestamp":  func() int64 { return time.Now().Unix() },
-			"date":       func() string { return time.Now().Format("2006-01-02") },
-			"datetime":   func() string { return time.Now().Format(time.RFC3339) },
-
-			// String utilities
-			"upper":      strings.ToUpper,
-			"lower":      strings.ToLower,
-
-			// Number formatting
-			"formatInt":  fmt.Sprintf,
-		},
+	r := &Renderer{clock: clock.System, vars: NewVariables()}
+	r.funcMap = template.FuncMap{
+		// String generators
+		"uuid":        generateUUID,
+		"randomString": randomString,
+		"randomInt":   randomInt,
+		"randomFloat": randomFloat,
+		"randomBool":  randomBool,
+
+		// Name generators
+		"firstName":  randomFirstName,
+		"lastName":   randomLastName,
+		"fullName":   randomFullName,
+		"email":      randomEmail,
+		"username":   randomUsername,
+
+		// Address generators
+		"city":       randomCity,
+		"country":    randomCountry,
+		"zipCode":    randomZipCode,
+		"address":    randomAddress,
+
+		// Business generators
+		"company":    randomCompany,
+		"jobTitle":   randomJobTitle,
+
+		// Internet generators
+		"ipAddress":  randomIPAddress,
+		"domain":     randomDomain,
+		"url":        randomURL,
+
+		// Time generators
+		"now":        func() time.Time { return r.clock.Now() },
+		"timestamp":  func() int64 { return r.clock.Now().Unix() },
+		"date":       func() string { return r.clock.Now().Format("2006-01-02") },
+		"datetime":   func() string { return r.clock.Now().Format(time.RFC3339) },
+
+		// Environment (evaluated on every render)
+		"env":        func(name string) string { return r.vars.Lookup(name) },
+
+		// String utilities
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+
+		// Number formatting
+		"formatInt":  fmt.Sprintf,
 	}
+	return r
+}
+
+// SetClock sets the time source of the now, timestamp, date and datetime functions
+func (r *Renderer) SetClock(c clock.Clock) {
+	r.clock = c
+}
+
+// Variables returns the variables read by the env function
+func (r *Renderer) Variables() *Variables {
+	return r.vars
+}
+
+// SetVariables sets the variables read by the env function, e.g. to share them between renderers
+func (r *Renderer) SetVariables(v *Variables) {
+	r.vars = v
 }
 
 // Render renders a template string with the given request data
 func (r *Renderer) Render(templateStr string, data *RequestData) (string, error) {
-	tmpl, err := template.New("response").Funcs(r.funcMap).Parse(templateStr)
+	return r.RenderWithDelims(templateStr, data, [2]string{})
+}
+
+// RenderWithDelims renders a template string using alternative left and right delimiters.
+// Empty delimiters default to "{{" and "}}".
+func (r *Renderer) RenderWithDelims(templateStr string, data *RequestData, delims [2]string) (string, error) {
+	return r.render(templateStr, data, delims)
+}
+
+// RenderData renders a template string with data that isn't an HTTP request, such as a gRPC call
+func (r *Renderer) RenderData(templateStr string, data interface{}) (string, error) {
+	return r.render(templateStr, data, [2]string{})
+}
+
+// render parses and executes a template with the helper functions
+func (r *Renderer) render(templateStr string, data interface{}, delims [2]string) (string, error) {
+	tmpl, err := template.New("response").Delims(delims[0], delims[1]).Funcs(r.funcMap).Parse(templateStr)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse template: %w", err)
 	}
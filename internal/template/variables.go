@@ -0,0 +1,66 @@
+package template
+
+import (
+	"os"
+	"sync"
+)
+
+// Variables is an in-memory store of values read by the env template function. Values are
+// looked up at render time, so changing them changes responses without reloading mocks.
+type Variables struct {
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// NewVariables creates an empty variable store
+func NewVariables() *Variables {
+	return &Variables{values: make(map[string]string)}
+}
+
+// Get returns the value of a variable and whether it's set
+func (v *Variables) Get(name string) (string, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	value, exists := v.values[name]
+	return value, exists
+}
+
+// Set sets the value of a variable
+func (v *Variables) Set(name, value string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[name] = value
+}
+
+// Delete removes a variable, so the environment variable of the same name applies again
+func (v *Variables) Delete(name string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.values, name)
+}
+
+// Clear removes all variables
+func (v *Variables) Clear() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values = make(map[string]string)
+}
+
+// All returns a copy of all variables
+func (v *Variables) All() map[string]string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	values := make(map[string]string, len(v.values))
+	for name, value := range v.values {
+		values[name] = value
+	}
+	return values
+}
+
+// Lookup returns the variable if it's set, or the process environment variable otherwise
+func (v *Variables) Lookup(name string) string {
+	if value, exists := v.Get(name); exists {
+		return value
+	}
+	return os.Getenv(name)
+}
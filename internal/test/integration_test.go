@@ -1,16 +1,20 @@
 package test
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/comfortablynumb/pmp-mock-http/internal/loader"
+	"github.com/comfortablynumb/pmp-mock-http/internal/management"
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
 	"github.com/comfortablynumb/pmp-mock-http/internal/server"
 	"github.com/comfortablynumb/pmp-mock-http/internal/watcher"
 )
@@ -319,3 +323,83 @@ func TestIntegrationNestedDirectories(t *testing.T) {
 		}
 	}
 }
+
+func TestIntegrationManagementAPIMocks(t *testing.T) {
+	fileMocks := []models.Mock{
+		{Name: "file-mock", Request: models.Request{URI: "/api/file", Method: "GET"}, Response: models.Response{StatusCode: 200, Body: "from file"}},
+	}
+	srv := server.NewServer(0, fileMocks, nil, nil)
+
+	manager := management.NewManager()
+	srv.SetManagedMocks(manager)
+	manager.SetChangeHandler(srv.RefreshManagedMocks)
+
+	mockServer := httptest.NewServer(srv.Handler())
+	defer mockServer.Close()
+	managementMux := http.NewServeMux()
+	management.NewAPIHandler(manager).RegisterRoutes(managementMux)
+	managementServer := httptest.NewServer(managementMux)
+	defer managementServer.Close()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(mockServer.URL + path)
+		if err != nil {
+			t.Fatalf("Failed to make request: %v", err)
+		}
+		defer resp.Body.Close() //nolint:errcheck // test cleanup
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if status, _ := get("/api/managed"); status != http.StatusNotFound {
+		t.Fatalf("Expected 404 before the mock is created, got %d", status)
+	}
+
+	// Create a mock through the management API
+	create := `{"mock": {"name": "managed-mock", "request": {"uri": "/api/managed", "method": "GET"}, "response": {"StatusCode": 201, "body": "from api"}}}`
+	resp, err := http.Post(managementServer.URL+"/api/v1/mocks", "application/json", strings.NewReader(create))
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	var created management.ManagedMock
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode created mock: %v", err)
+	}
+	resp.Body.Close() //nolint:errcheck // test cleanup
+
+	if status, body := get("/api/managed"); status != http.StatusCreated || body != "from api" {
+		t.Errorf("Expected the created mock to be served, got %d %q", status, body)
+	}
+
+	// Reloading the mock files keeps the managed mocks
+	srv.UpdateMocks(fileMocks)
+	if status, _ := get("/api/managed"); status != http.StatusCreated {
+		t.Errorf("Expected the managed mock to survive a reload, got %d", status)
+	}
+	if status, body := get("/api/file"); status != http.StatusOK || body != "from file" {
+		t.Errorf("Expected the file mock to be served, got %d %q", status, body)
+	}
+
+	// Updates and deletions are applied too
+	update := `{"mock": {"name": "managed-mock", "request": {"uri": "/api/managed", "method": "GET"}, "response": {"StatusCode": 202, "body": "updated"}}}`
+	req, _ := http.NewRequest(http.MethodPut, managementServer.URL+"/api/v1/mocks/"+created.Metadata.ID, strings.NewReader(update))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to update mock: %v", err)
+	}
+	resp.Body.Close() //nolint:errcheck // test cleanup
+	if status, body := get("/api/managed"); status != http.StatusAccepted || body != "updated" {
+		t.Errorf("Expected the updated mock to be served, got %d %q", status, body)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, managementServer.URL+"/api/v1/mocks/"+created.Metadata.ID, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to delete mock: %v", err)
+	}
+	resp.Body.Close() //nolint:errcheck // test cleanup
+	if status, _ := get("/api/managed"); status != http.StatusNotFound {
+		t.Errorf("Expected 404 after the mock is deleted, got %d", status)
+	}
+}
@@ -1,6 +1,7 @@
 package tracker
 
 import (
+	"strings"
 	"sync"
 	"time"
 
@@ -8,35 +9,105 @@ import (
 )
 
 type RequestLog struct {
-	ID          int64             `json:"id"`
-	Timestamp   time.Time         `json:"timestamp"`
-	Method      string            `json:"method"`
-	URI         string            `json:"uri"`
-	Headers     map[string]string `json:"headers"`
-	Body        string            `json:"body"`
-	Matched     bool              `json:"matched"`
-	MockName    string            `json:"mock_name,omitempty"`
-	MockConfig  *models.Mock      `json:"mock_config,omitempty"`
-	StatusCode  int               `json:"status_code"`
-	Response    string            `json:"response"`
-	RemoteAddr  string            `json:"remote_addr"`
+	ID              int64               `json:"id"`
+	Timestamp       time.Time           `json:"timestamp"`
+	Method          string              `json:"method"`
+	URI             string              `json:"uri"`
+	Headers         map[string]string   `json:"headers"`
+	Body            string              `json:"body"`
+	Matched         bool                `json:"matched"`
+	MockName        string              `json:"mock_name,omitempty"`
+	MockConfig      *models.Mock        `json:"mock_config,omitempty"`
+	StatusCode      int                 `json:"status_code"`
+	Response        string              `json:"response"`
+	RemoteAddr      string              `json:"remote_addr"`
+	URL             string              `json:"url,omitempty"` // Absolute request URL, including scheme and host
+	ResponseHeaders map[string][]string `json:"response_headers,omitempty"`
 }
 
+// UnboundedCapacity is how many entries an unbounded tracker (capacity 0) keeps at most,
+// so a long-running server doesn't run out of memory
+const UnboundedCapacity = 100000
+
 type Tracker struct {
-	logs    []RequestLog
-	mu      sync.RWMutex
-	nextID  int64
-	maxLogs int
+	logs        []RequestLog
+	mu          sync.RWMutex
+	nextID      int64
+	total       int64 // Entries logged since the last Clear, including evicted ones
+	capacity    int   // Configured capacity (0 = unbounded)
+	maxLogs     int   // Entries kept before the oldest are evicted
+	subscribers map[chan RequestLog]struct{}
+}
+
+// Meta describes how many entries the tracker holds
+type Meta struct {
+	Count      int   `json:"count"`       // Entries currently kept
+	Total      int64 `json:"total"`       // Entries logged since the last clear, including evicted ones
+	Capacity   int   `json:"capacity"`    // Configured capacity (0 = unbounded)
+	MaxEntries int   `json:"max_entries"` // Entries kept before the oldest are evicted
 }
 
-func NewTracker(maxLogs int) *Tracker {
-	if maxLogs <= 0 {
-		maxLogs = 1000
+// NewTracker creates a tracker that keeps the last capacity entries. A capacity of 0 keeps
+// every entry up to UnboundedCapacity; negative capacities use the default of 1000.
+func NewTracker(capacity int) *Tracker {
+	if capacity < 0 {
+		capacity = 1000
 	}
-	return &Tracker{
-		logs:    make([]RequestLog, 0, maxLogs),
-		maxLogs: maxLogs,
-		nextID:  1,
+	t := &Tracker{
+		nextID:      1,
+		subscribers: make(map[chan RequestLog]struct{}),
+	}
+	t.setCapacity(capacity)
+	t.logs = make([]RequestLog, 0, t.initialSize())
+	return t
+}
+
+// SetCapacity changes the capacity, evicting the oldest entries if there are too many.
+// It's safe to call while requests are being logged.
+func (t *Tracker) SetCapacity(capacity int) {
+	if capacity < 0 {
+		capacity = 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.setCapacity(capacity)
+	t.evict()
+}
+
+// setCapacity sets the configured and effective capacity. The caller must hold t.mu (or own t).
+func (t *Tracker) setCapacity(capacity int) {
+	t.capacity = capacity
+	t.maxLogs = capacity
+	if capacity == 0 {
+		t.maxLogs = UnboundedCapacity
+	}
+}
+
+// initialSize is the number of entries to preallocate. Unbounded trackers grow on demand.
+func (t *Tracker) initialSize() int {
+	if t.capacity == 0 {
+		return 0
+	}
+	return t.maxLogs
+}
+
+// evict drops the oldest entries beyond the capacity. The caller must hold t.mu.
+func (t *Tracker) evict() {
+	if len(t.logs) > t.maxLogs {
+		// Copy, so the evicted entries don't stay reachable through the backing array
+		t.logs = append(make([]RequestLog, 0, t.maxLogs), t.logs[len(t.logs)-t.maxLogs:]...)
+	}
+}
+
+// Meta returns the current number of entries and the capacity
+func (t *Tracker) Meta() Meta {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return Meta{
+		Count:      len(t.logs),
+		Total:      t.total,
+		Capacity:   t.capacity,
+		MaxEntries: t.maxLogs,
 	}
 }
 
@@ -45,11 +116,39 @@ func (t *Tracker) Log(log RequestLog) {
 	defer t.mu.Unlock()
 	log.ID = t.nextID
 	t.nextID++
+	t.total++
 	log.Timestamp = time.Now()
 	t.logs = append(t.logs, log)
 	if len(t.logs) > t.maxLogs {
 		t.logs = t.logs[len(t.logs)-t.maxLogs:]
 	}
+	for ch := range t.subscribers {
+		select {
+		case ch <- log:
+		default:
+			// The subscriber isn't keeping up; drop the entry instead of blocking the request
+		}
+	}
+}
+
+// Subscribe returns a channel that receives every new log entry, and a function that
+// unsubscribes and closes the channel. Entries are dropped while the channel's buffer is full.
+func (t *Tracker) Subscribe(buffer int) (<-chan RequestLog, func()) {
+	ch := make(chan RequestLog, buffer)
+
+	t.mu.Lock()
+	t.subscribers[ch] = struct{}{}
+	t.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			t.mu.Lock()
+			delete(t.subscribers, ch)
+			t.mu.Unlock()
+			close(ch)
+		})
+	}
 }
 
 func (t *Tracker) GetLogs() []RequestLog {
@@ -62,10 +161,67 @@ func (t *Tracker) GetLogs() []RequestLog {
 	return result
 }
 
+// GetLog returns the entry with the given ID, if it's still logged
+func (t *Tracker) GetLog(id int64) (RequestLog, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for i := len(t.logs) - 1; i >= 0; i-- {
+		if t.logs[i].ID == id {
+			return t.logs[i], true
+		}
+	}
+	return RequestLog{}, false
+}
+
+// Filter selects request logs. Zero fields match every entry.
+type Filter struct {
+	Since   time.Time // Only entries logged at or after this time
+	Until   time.Time // Only entries logged at or before this time
+	Matched *bool     // Only matched (true) or unmatched (false) requests
+	Method  string    // Only requests with this method (case-insensitive)
+	Status  int       // Only responses with this status code
+}
+
+// Matches reports whether the entry passes the filter
+func (f *Filter) Matches(log *RequestLog) bool {
+	if !f.Since.IsZero() && log.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && log.Timestamp.After(f.Until) {
+		return false
+	}
+	if f.Matched != nil && log.Matched != *f.Matched {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(log.Method, f.Method) {
+		return false
+	}
+	return f.Status == 0 || log.StatusCode == f.Status
+}
+
+// GetFilteredLogs returns the entries that pass the filter, newest first. Only matching
+// entries are copied, and the scan stops at the first entry older than Since.
+func (t *Tracker) GetFilteredLogs(filter Filter) []RequestLog {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	result := make([]RequestLog, 0)
+	for i := len(t.logs) - 1; i >= 0; i-- {
+		entry := &t.logs[i]
+		if !filter.Since.IsZero() && entry.Timestamp.Before(filter.Since) {
+			break // Entries are in logging order, so all older ones are excluded too
+		}
+		if filter.Matches(entry) {
+			result = append(result, *entry)
+		}
+	}
+	return result
+}
+
 func (t *Tracker) Clear() {
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	t.logs = make([]RequestLog, 0, t.maxLogs)
+	t.logs = make([]RequestLog, 0, t.initialSize())
+	t.total = 0
 }
 
 func (t *Tracker) Count() int {
@@ -0,0 +1,154 @@
+package tracker
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetFilteredLogs(t *testing.T) {
+	tr := NewTracker(10)
+	tr.Log(RequestLog{Method: "GET", URI: "/old", Matched: false, StatusCode: 404})
+	tr.Log(RequestLog{Method: "POST", URI: "/users", Matched: true, StatusCode: 201})
+	tr.Log(RequestLog{Method: "get", URI: "/missing", Matched: false, StatusCode: 404})
+	tr.Log(RequestLog{Method: "GET", URI: "/users", Matched: true, StatusCode: 200})
+
+	now := time.Now()
+	for i := range tr.logs {
+		tr.logs[i].Timestamp = now.Add(time.Duration(i-len(tr.logs)) * time.Minute)
+	}
+
+	unmatched := false
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{"no filter", Filter{}, []string{"/users", "/missing", "/users", "/old"}},
+		{"unmatched", Filter{Matched: &unmatched}, []string{"/missing", "/old"}},
+		{"method", Filter{Method: "GET"}, []string{"/users", "/missing", "/old"}},
+		{"status", Filter{Status: 201}, []string{"/users"}},
+		{"since", Filter{Since: now.Add(-150 * time.Second)}, []string{"/users", "/missing"}},
+		{"until", Filter{Until: now.Add(-150 * time.Second)}, []string{"/users", "/old"}},
+		{"unmatched since", Filter{Since: now.Add(-5 * time.Minute), Matched: &unmatched, Status: 404}, []string{"/missing", "/old"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := tr.GetFilteredLogs(tt.filter)
+			if len(logs) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %+v", tt.expected, logs)
+			}
+			for i, uri := range tt.expected {
+				if logs[i].URI != uri {
+					t.Errorf("Expected entry %d to be %s, got %s", i, uri, logs[i].URI)
+				}
+			}
+		})
+	}
+}
+
+func TestGetLog(t *testing.T) {
+	tr := NewTracker(2)
+	tr.Log(RequestLog{URI: "/first"})
+	tr.Log(RequestLog{URI: "/second"})
+	tr.Log(RequestLog{URI: "/third"})
+
+	entry, ok := tr.GetLog(2)
+	if !ok || entry.URI != "/second" {
+		t.Errorf("Expected entry 2 to be /second, got %+v (found: %v)", entry, ok)
+	}
+	if _, ok := tr.GetLog(1); ok {
+		t.Error("Expected entry 1 to have been evicted")
+	}
+	if _, ok := tr.GetLog(42); ok {
+		t.Error("Expected unknown entry to be missing")
+	}
+}
+
+func TestTrackerCapacity(t *testing.T) {
+	tr := NewTracker(3)
+	for i := 0; i < 5; i++ {
+		tr.Log(RequestLog{URI: "/"})
+	}
+	meta := tr.Meta()
+	if meta.Count != 3 || meta.Total != 5 || meta.Capacity != 3 || meta.MaxEntries != 3 {
+		t.Errorf("Unexpected meta: %+v", meta)
+	}
+
+	tr.SetCapacity(2)
+	if logs := tr.GetLogs(); len(logs) != 2 || logs[0].ID != 5 || logs[1].ID != 4 {
+		t.Errorf("Expected the 2 newest entries to be kept, got %+v", logs)
+	}
+
+	tr.Clear()
+	if meta := tr.Meta(); meta.Count != 0 || meta.Total != 0 {
+		t.Errorf("Expected clear to reset the counts, got %+v", meta)
+	}
+
+	unbounded := NewTracker(0)
+	if meta := unbounded.Meta(); meta.Capacity != 0 || meta.MaxEntries != UnboundedCapacity {
+		t.Errorf("Unexpected unbounded meta: %+v", meta)
+	}
+	if meta := NewTracker(-1).Meta(); meta.Capacity != 1000 {
+		t.Errorf("Expected the default capacity for a negative capacity, got %+v", meta)
+	}
+}
+
+func TestSetCapacityWhileLogging(t *testing.T) {
+	tr := NewTracker(100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				tr.Log(RequestLog{URI: "/"})
+			}
+		}()
+	}
+	for capacity := 1; capacity <= 50; capacity++ {
+		tr.SetCapacity(capacity)
+	}
+	wg.Wait()
+
+	meta := tr.Meta()
+	if meta.Count != 50 || meta.Total != 2000 {
+		t.Errorf("Unexpected meta after concurrent logging: %+v", meta)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	tr := NewTracker(10)
+	ch, unsubscribe := tr.Subscribe(1)
+
+	tr.Log(RequestLog{URI: "/first"})
+	tr.Log(RequestLog{URI: "/dropped"}) // Buffer is full, so this one is dropped
+
+	select {
+	case entry := <-ch:
+		if entry.URI != "/first" || entry.ID != 1 {
+			t.Errorf("Expected /first with ID 1, got %+v", entry)
+		}
+	default:
+		t.Fatal("Expected an entry to be delivered")
+	}
+	select {
+	case entry := <-ch:
+		t.Errorf("Expected entries to be dropped while the buffer is full, got %+v", entry)
+	default:
+	}
+
+	tr.Log(RequestLog{URI: "/third"})
+	if entry := <-ch; entry.URI != "/third" {
+		t.Errorf("Expected /third after the buffer drained, got %+v", entry)
+	}
+
+	unsubscribe()
+	unsubscribe() // Calling it again must not close the channel twice
+	if _, open := <-ch; open {
+		t.Error("Expected the channel to be closed after unsubscribing")
+	}
+	tr.Log(RequestLog{URI: "/after"}) // Must not send on the closed channel
+}
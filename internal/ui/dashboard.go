@@ -35,29 +35,81 @@ const dashboardHTML = `<!DOCTYPE html>
             <div class="mt-4 flex gap-2 items-center">
                 <button id="refresh-btn" class="bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded">Refresh Now</button>
                 <button id="clear-btn" class="bg-red-500 hover:bg-red-700 text-white font-bold py-2 px-4 rounded">Clear All</button>
+                <a id="har-btn" href="/api/requests/har" download class="bg-gray-600 hover:bg-gray-800 text-white font-bold py-2 px-4 rounded">Download HAR</a>
                 <label class="flex items-center ml-4">
                     <input type="checkbox" id="auto-refresh" checked class="mr-2">
-                    <span class="text-gray-700">Auto-refresh (2s)</span>
+                    <span class="text-gray-700">Live updates</span>
                 </label>
+                <span id="live-status" class="text-sm text-gray-500"></span>
                 <input type="text" id="filter-input" placeholder="Filter requests (method, uri, status...)" class="ml-auto border border-gray-300 rounded px-3 py-2 w-96 text-sm">
             </div>
         </div>
         <div class="bg-white rounded-lg shadow-md p-6">
-            <h2 class="text-xl font-bold text-gray-800 mb-4">Recent Requests</h2>
+            <div class="flex justify-between items-baseline mb-4">
+                <h2 class="text-xl font-bold text-gray-800">Recent Requests</h2>
+                <span id="capacity-status" class="text-sm text-gray-500"></span>
+            </div>
             <div id="requests-container"><p class="text-gray-500 text-center py-8">Loading requests...</p></div>
         </div>
     </div>
     <script>
+        const PREFS_KEY = 'pmp-mock-dashboard-prefs';
         let autoRefreshInterval = null;
+        let liveSocket = null;
+        let reconnectTimer = null;
         let allRequests = [];
+        let meta = null; // Count and capacity of the tracker, from /api/requests/meta
+        // Outcome of the last replay per request ID, e.g. {"12": "Replayed: 200"}
+        let replayResults = {};
+        let prefs = loadPrefs();
+        // Expanded details per request ID, e.g. {"12": {"headers": true}}
+        let expandedState = prefs.expanded || {};
+        function loadPrefs() {
+            try {
+                return JSON.parse(localStorage.getItem(PREFS_KEY)) || {};
+            } catch (e) {
+                return {};
+            }
+        }
+        function savePrefs() {
+            prefs.filter = $('#filter-input').val();
+            prefs.autoRefresh = $('#auto-refresh').is(':checked');
+            prefs.expanded = expandedState;
+            try {
+                localStorage.setItem(PREFS_KEY, JSON.stringify(prefs));
+            } catch (e) {
+                // Storage unavailable (e.g. private mode), preferences won't persist
+            }
+        }
+        function pruneExpandedState(requests) {
+            // Forget expanded details of requests that are no longer logged
+            const ids = {};
+            requests.forEach(function(req) { ids[String(req.id)] = true; });
+            Object.keys(expandedState).forEach(function(reqId) {
+                if (!ids[reqId]) delete expandedState[reqId];
+            });
+        }
         function fetchRequests() {
             $.get('/api/requests', function(data) {
                 allRequests = data;
+                pruneExpandedState(data);
                 applyFilter();
                 updateStats(data);
             }).fail(function() {
                 $('#requests-container').html('<p class="text-red-500 text-center py-8">Failed to load requests</p>');
             });
+            fetchMeta();
+        }
+        function fetchMeta() {
+            $.get('/api/requests/meta', function(data) {
+                meta = data;
+                updateCapacityStatus();
+            });
+        }
+        function updateCapacityStatus() {
+            if (!meta) return;
+            const capacity = meta.capacity === 0 ? 'unbounded, up to ' + meta.max_entries : meta.capacity;
+            $('#capacity-status').text('Showing ' + meta.count + ' of ' + meta.total + ' (capacity: ' + capacity + ')');
         }
         function applyFilter() {
             const filterText = $('#filter-input').val().toLowerCase();
@@ -88,17 +140,6 @@ const dashboardHTML = `<!DOCTYPE html>
                 return;
             }
 
-            // Save expanded state before re-rendering
-            const expandedState = {};
-            $('#requests-container details').each(function() {
-                const reqId = $(this).closest('[data-request-id]').attr('data-request-id');
-                const detailType = $(this).attr('data-detail-type');
-                if (reqId && detailType && this.open) {
-                    if (!expandedState[reqId]) expandedState[reqId] = {};
-                    expandedState[reqId][detailType] = true;
-                }
-            });
-
             let html = '';
             requests.forEach(function(req) {
                 const reqIdStr = String(req.id); // Convert to string for consistent lookup
@@ -125,8 +166,13 @@ const dashboardHTML = `<!DOCTYPE html>
                     html += '  <div class="mb-2"><span class="text-sm text-gray-600">Mock: </span>';
                     html += '    <span class="text-sm font-semibold text-blue-600">' + escapeHtml(req.mock_name) + '</span></div>';
                 }
-                html += '  <div class="mb-2"><span class="text-sm text-gray-600">Status: </span>';
-                html += '    <span class="text-sm font-semibold ' + statusClass + '">' + req.status_code + '</span></div>';
+                html += '  <div class="mb-2 flex items-center gap-2"><span class="text-sm text-gray-600">Status: </span>';
+                html += '    <span class="text-sm font-semibold ' + statusClass + '">' + req.status_code + '</span>';
+                html += '    <button class="replay-btn bg-indigo-500 hover:bg-indigo-700 text-white text-xs font-bold py-1 px-2 rounded" data-replay-id="' + reqIdStr + '">Replay</button>';
+                if (replayResults[reqIdStr]) {
+                    html += '    <span class="text-xs text-gray-600">' + escapeHtml(replayResults[reqIdStr]) + '</span>';
+                }
+                html += '  </div>';
                 if (req.headers && Object.keys(req.headers).length > 0) {
                     const headersOpen = expandedState[reqIdStr] && expandedState[reqIdStr]['headers'] ? ' open' : '';
                     html += '  <details class="mt-2" data-detail-type="headers"' + headersOpen + '><summary class="text-sm font-semibold text-gray-700 cursor-pointer">Headers</summary>';
@@ -174,23 +220,123 @@ const dashboardHTML = `<!DOCTYPE html>
                 $.post('/api/clear', function() { fetchRequests(); }).fail(function() { alert('Failed to clear requests'); });
             }
         }
+        function replayRequest(reqId) {
+            $.post('/api/requests/' + reqId + '/replay', function(data) {
+                replayResults[reqId] = 'Replayed: ' + data.status_code;
+                // Live updates show the replayed request as it's logged
+                if (liveSocket && liveSocket.readyState === WebSocket.OPEN) {
+                    applyFilter();
+                } else {
+                    fetchRequests();
+                }
+            }).fail(function(xhr) {
+                alert('Failed to replay request: ' + (xhr.responseText || xhr.statusText));
+            });
+        }
+        function addRequest(req) {
+            // Skip entries the last fetch already returned
+            if (allRequests.some(function(r) { return r.id === req.id; })) return;
+            allRequests.unshift(req);
+            if (meta) {
+                // Mirror the tracker, which evicts the oldest requests beyond its capacity
+                if (allRequests.length > meta.max_entries) allRequests.length = meta.max_entries;
+                meta.total++;
+                meta.count = allRequests.length;
+                updateCapacityStatus();
+            }
+            applyFilter();
+            updateStats(allRequests);
+        }
+        function startPolling() {
+            if (!autoRefreshInterval) {
+                autoRefreshInterval = setInterval(fetchRequests, 2000);
+            }
+        }
+        function stopPolling() {
+            if (autoRefreshInterval) {
+                clearInterval(autoRefreshInterval);
+                autoRefreshInterval = null;
+            }
+        }
+        function connectLive() {
+            if (liveSocket || !window.WebSocket) {
+                if (!window.WebSocket) startPolling();
+                return;
+            }
+            const scheme = location.protocol === 'https:' ? 'wss://' : 'ws://';
+            const socket = new WebSocket(scheme + location.host + '/api/requests/stream');
+            liveSocket = socket;
+            socket.onopen = function() {
+                stopPolling();
+                $('#live-status').text('● live').attr('class', 'text-sm text-green-600');
+                // Catch up on requests logged while disconnected
+                fetchRequests();
+            };
+            socket.onmessage = function(event) {
+                try {
+                    addRequest(JSON.parse(event.data));
+                } catch (e) {
+                    // Ignore malformed messages
+                }
+            };
+            socket.onclose = function() {
+                if (liveSocket !== socket) return;
+                liveSocket = null;
+                if (!$('#auto-refresh').is(':checked')) return;
+                // Fall back to polling until the stream is back
+                $('#live-status').text('polling (2s)').attr('class', 'text-sm text-yellow-600');
+                startPolling();
+                if (!reconnectTimer) {
+                    reconnectTimer = setTimeout(function() { reconnectTimer = null; connectLive(); }, 5000);
+                }
+            };
+        }
+        function disconnectLive() {
+            if (reconnectTimer) {
+                clearTimeout(reconnectTimer);
+                reconnectTimer = null;
+            }
+            if (liveSocket) {
+                const socket = liveSocket;
+                liveSocket = null;
+                socket.close();
+            }
+        }
         function updateAutoRefresh() {
             if ($('#auto-refresh').is(':checked')) {
-                if (!autoRefreshInterval) {
-                    autoRefreshInterval = setInterval(fetchRequests, 2000);
-                }
+                connectLive();
             } else {
-                if (autoRefreshInterval) {
-                    clearInterval(autoRefreshInterval);
-                    autoRefreshInterval = null;
-                }
+                disconnectLive();
+                stopPolling();
+                $('#live-status').text('');
             }
         }
+        function onDetailToggle(event) {
+            const details = event.target;
+            const reqId = $(details).closest('[data-request-id]').attr('data-request-id');
+            const detailType = $(details).attr('data-detail-type');
+            if (!reqId || !detailType) return;
+            if (details.open) {
+                if (!expandedState[reqId]) expandedState[reqId] = {};
+                expandedState[reqId][detailType] = true;
+            } else if (expandedState[reqId]) {
+                delete expandedState[reqId][detailType];
+                if (Object.keys(expandedState[reqId]).length === 0) delete expandedState[reqId];
+            }
+            savePrefs();
+        }
         $(document).ready(function() {
+            // Restore persisted preferences
+            if (typeof prefs.filter === 'string') $('#filter-input').val(prefs.filter);
+            if (typeof prefs.autoRefresh === 'boolean') $('#auto-refresh').prop('checked', prefs.autoRefresh);
+
             $('#refresh-btn').click(fetchRequests);
             $('#clear-btn').click(clearRequests);
-            $('#auto-refresh').change(updateAutoRefresh);
-            $('#filter-input').on('input', applyFilter);
+            $('#requests-container').on('click', '.replay-btn', function() { replayRequest($(this).attr('data-replay-id')); });
+            $('#auto-refresh').change(function() { updateAutoRefresh(); savePrefs(); });
+            $('#filter-input').on('input', function() { applyFilter(); savePrefs(); });
+            // The toggle event doesn't bubble, so listen in the capture phase
+            document.getElementById('requests-container').addEventListener('toggle', onDetailToggle, true);
             fetchRequests();
             updateAutoRefresh();
         });
@@ -0,0 +1,127 @@
+package ui
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplayHeader marks requests sent by the replay endpoint. Replay requests carrying it are
+// refused, so a mock that proxies back to the dashboard can't make replays loop forever.
+const ReplayHeader = "X-PMP-Replay"
+
+// replayTimeout bounds how long a replayed request may take
+const replayTimeout = 30 * time.Second
+
+// hopByHopHeaders are connection-specific and aren't copied to replayed requests
+var hopByHopHeaders = map[string]bool{
+	"Connection":          true,
+	"Content-Length":      true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+// replayResponse is the response of the mock server to a replayed request
+type replayResponse struct {
+	ID         int64               `json:"id"` // ID of the replayed entry
+	StatusCode int                 `json:"status_code"`
+	Headers    map[string][]string `json:"headers"`
+	Body       string              `json:"body"`
+}
+
+// SetReplayTarget sets the base URL of the mock server that recorded requests are replayed
+// against, e.g. http://localhost:8083. Replaying is disabled until a target is set.
+func (s *Server) SetReplayTarget(target string) {
+	s.replayTarget = strings.TrimSuffix(target, "/")
+	s.replayClient = &http.Client{
+		Timeout: replayTimeout,
+		Transport: &http.Transport{
+			// The target is the local mock server, usually with a self-signed certificate
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // local mock server
+		},
+		// Return redirects as they are, like the mock server sent them
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// handleReplay re-sends a recorded request (method, URI, headers and body) to the mock
+// server and returns its response
+func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get(ReplayHeader) != "" {
+		http.Error(w, "Refusing to replay from a replayed request", http.StatusLoopDetected)
+		return
+	}
+	if s.replayTarget == "" {
+		http.Error(w, "Replay is not available", http.StatusServiceUnavailable)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid request ID", http.StatusBadRequest)
+		return
+	}
+	entry, ok := s.tracker.GetLog(id)
+	if !ok {
+		http.Error(w, "Request not found", http.StatusNotFound)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), entry.Method, s.replayTarget+entry.URI, strings.NewReader(entry.Body))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to build request: %v", err), http.StatusBadRequest)
+		return
+	}
+	for key, value := range entry.Headers {
+		if !hopByHopHeaders[http.CanonicalHeaderKey(key)] {
+			req.Header.Set(key, value)
+		}
+	}
+	// Keep the original host, so mocks matching on it still match
+	if original, err := url.Parse(entry.URL); err == nil && original.Host != "" {
+		req.Host = original.Host
+	}
+	req.Header.Set(ReplayHeader, strconv.FormatInt(id, 10))
+
+	resp, err := s.replayClient.Do(req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to replay request: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close() //nolint:errcheck // response is read fully below
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read response: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(replayResponse{
+		ID:         id,
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Header,
+		Body:       string(body),
+	}); err != nil {
+		log.Printf("Error encoding replay response: %v\n", err)
+	}
+}
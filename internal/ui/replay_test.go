@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/tracker"
+)
+
+func TestHandleReplay(t *testing.T) {
+	var received *http.Request
+	var receivedBody string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received, receivedBody = r, string(body)
+		w.Header().Set("X-Mock", "yes")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"ok":true}`)) //nolint:errcheck // test server
+	}))
+	defer target.Close()
+
+	tr := tracker.NewTracker(10)
+	tr.Log(tracker.RequestLog{
+		Method:  "POST",
+		URI:     "/users?active=true",
+		URL:     "http://api.example.com/users?active=true",
+		Headers: map[string]string{"Content-Type": "application/json", "Connection": "close"},
+		Body:    `{"name":"John"}`,
+	})
+	s := NewServer(0, tr)
+	s.SetReplayTarget(target.URL + "/")
+
+	replay := func(id string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/requests/"+id+"/replay", nil)
+		req.SetPathValue("id", id)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		s.handleReplay(rec, req)
+		return rec
+	}
+
+	rec := replay("1", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if received.Method != "POST" || received.URL.RequestURI() != "/users?active=true" || receivedBody != `{"name":"John"}` {
+		t.Errorf("Unexpected replayed request: %s %s %q", received.Method, received.URL.RequestURI(), receivedBody)
+	}
+	if received.Host != "api.example.com" {
+		t.Errorf("Expected original host, got %q", received.Host)
+	}
+	if received.Header.Get("Content-Type") != "application/json" || received.Header.Get(ReplayHeader) != "1" {
+		t.Errorf("Unexpected replayed headers: %v", received.Header)
+	}
+
+	var result replayResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.StatusCode != http.StatusCreated || result.Body != `{"ok":true}` || result.Headers["X-Mock"][0] != "yes" {
+		t.Errorf("Unexpected replay result: %+v", result)
+	}
+
+	if rec := replay("42", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown request, got %d", rec.Code)
+	}
+	if rec := replay("abc", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid ID, got %d", rec.Code)
+	}
+	rec = replay("1", map[string]string{ReplayHeader: "1"})
+	if rec.Code != http.StatusLoopDetected || !strings.Contains(rec.Body.String(), "Refusing") {
+		t.Errorf("Expected status 508 for a replay call from a replayed request, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	disabled := NewServer(0, tr)
+	req := httptest.NewRequest(http.MethodPost, "/api/requests/1/replay", nil)
+	req.SetPathValue("id", "1")
+	rec = httptest.NewRecorder()
+	disabled.handleReplay(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without a target, got %d", rec.Code)
+	}
+}
@@ -5,12 +5,18 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/har"
 	"github.com/comfortablynumb/pmp-mock-http/internal/tracker"
 )
 
 type Server struct {
-	port    int
-	tracker *tracker.Tracker
+	port         int
+	tracker      *tracker.Tracker
+	replayTarget string // Base URL of the mock server (empty = replay disabled)
+	replayClient *http.Client
 }
 
 func NewServer(port int, tracker *tracker.Tracker) *Server {
@@ -21,6 +27,10 @@ func (s *Server) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleDashboard)
 	mux.HandleFunc("/api/requests", s.handleRequests)
+	mux.HandleFunc("/api/requests/stream", s.handleRequestStream)
+	mux.HandleFunc("/api/requests/har", s.handleRequestsHAR)
+	mux.HandleFunc("/api/requests/meta", s.handleRequestsMeta)
+	mux.HandleFunc("/api/requests/{id}/replay", s.handleReplay)
 	mux.HandleFunc("/api/clear", s.handleClear)
 	log.Printf("Starting UI server on port %d\n", s.port)
 	log.Printf("Dashboard available at http://localhost:%d\n", s.port)
@@ -44,7 +54,12 @@ func (s *Server) handleRequests(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	logs := s.tracker.GetLogs()
+	filter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logs := s.tracker.GetFilteredLogs(filter)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(logs); err != nil {
@@ -52,6 +67,55 @@ func (s *Server) handleRequests(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) handleRequestsHAR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	filter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	archive := har.FromRequestLogs(s.tracker.GetFilteredLogs(filter))
+	filename := fmt.Sprintf("pmp-mock-http-%s.har", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(archive); err != nil {
+		log.Printf("Error encoding HAR: %v\n", err)
+	}
+}
+
+// handleRequestsMeta returns the number of logged requests and the tracker capacity. PUT
+// with {"capacity": N} changes the capacity (0 = unbounded), evicting the oldest requests.
+func (s *Server) handleRequestsMeta(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var body struct {
+			Capacity *int `json:"capacity"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		if body.Capacity == nil || *body.Capacity < 0 {
+			http.Error(w, "capacity must be >= 0", http.StatusBadRequest)
+			return
+		}
+		s.tracker.SetCapacity(*body.Capacity)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(s.tracker.Meta()); err != nil {
+		log.Printf("Error encoding meta: %v\n", err)
+	}
+}
+
 func (s *Server) handleClear(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -64,3 +128,43 @@ func (s *Server) handleClear(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Error encoding response: %v\n", err)
 	}
 }
+
+// parseFilter reads the request log filter from the query parameters: since and until (RFC 3339
+// times, or durations like 5m meaning that long ago), matched (true or false), method and status
+func parseFilter(r *http.Request) (tracker.Filter, error) {
+	query := r.URL.Query()
+	var filter tracker.Filter
+
+	var err error
+	if filter.Since, err = parseTime(query.Get("since")); err != nil {
+		return filter, fmt.Errorf("invalid since: %w", err)
+	}
+	if filter.Until, err = parseTime(query.Get("until")); err != nil {
+		return filter, fmt.Errorf("invalid until: %w", err)
+	}
+	if value := query.Get("matched"); value != "" {
+		matched, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid matched %q (must be true or false)", value)
+		}
+		filter.Matched = &matched
+	}
+	filter.Method = query.Get("method")
+	if value := query.Get("status"); value != "" {
+		if filter.Status, err = strconv.Atoi(value); err != nil {
+			return filter, fmt.Errorf("invalid status %q", value)
+		}
+	}
+	return filter, nil
+}
+
+// parseTime parses an RFC 3339 time, or a duration before now. Empty values are the zero time.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ago, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-ago), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
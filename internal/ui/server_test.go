@@ -0,0 +1,48 @@
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/tracker"
+)
+
+func TestHandleRequestsMeta(t *testing.T) {
+	tr := tracker.NewTracker(5)
+	for i := 0; i < 3; i++ {
+		tr.Log(tracker.RequestLog{URI: "/"})
+	}
+	s := NewServer(0, tr)
+
+	call := func(method, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.handleRequestsMeta(rec, httptest.NewRequest(method, "/api/requests/meta", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := call(http.MethodGet, "")
+	var meta tracker.Meta
+	if err := json.Unmarshal(rec.Body.Bytes(), &meta); err != nil {
+		t.Fatalf("Failed to decode meta: %v", err)
+	}
+	if meta.Count != 3 || meta.Total != 3 || meta.Capacity != 5 {
+		t.Errorf("Unexpected meta: %+v", meta)
+	}
+
+	rec = call(http.MethodPut, `{"capacity": 2}`)
+	if rec.Code != http.StatusOK || tr.Meta().Count != 2 || tr.Meta().Capacity != 2 {
+		t.Errorf("Expected the capacity to shrink to 2, got %d: %+v", rec.Code, tr.Meta())
+	}
+
+	for _, body := range []string{`{"capacity": -1}`, `{}`, `nope`} {
+		if rec := call(http.MethodPut, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", body, rec.Code)
+		}
+	}
+	if rec := call(http.MethodDelete, ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// streamBuffer is how many entries a slow dashboard may fall behind before entries are dropped
+	streamBuffer = 256
+	// streamPingInterval keeps idle connections open through proxies
+	streamPingInterval = 30 * time.Second
+	// streamWriteTimeout closes connections of dashboards that stopped reading
+	streamWriteTimeout = 10 * time.Second
+)
+
+// streamUpgrader keeps gorilla's default origin check: the stream carries every captured
+// header and body, so only the dashboard's own pages may connect
+var streamUpgrader = websocket.Upgrader{}
+
+// handleRequestStream pushes new request logs to the dashboard over a WebSocket, one JSON
+// message per entry, until the client disconnects
+func (s *Server) handleRequestStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade already replied with an error
+	}
+	defer conn.Close() //nolint:errcheck // connection is done
+
+	logs, unsubscribe := s.tracker.Subscribe(streamBuffer)
+	defer unsubscribe()
+
+	// Read until the client goes away; the dashboard doesn't send messages
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case entry := <-logs:
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := conn.WriteJSON(entry); err != nil {
+				log.Printf("Error streaming request log: %v\n", err)
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
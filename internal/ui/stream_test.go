@@ -0,0 +1,67 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/tracker"
+	"github.com/gorilla/websocket"
+)
+
+func TestHandleRequestStream(t *testing.T) {
+	tr := tracker.NewTracker(10)
+	s := NewServer(0, tr)
+	srv := httptest.NewServer(http.HandlerFunc(s.handleRequestStream))
+	defer srv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {srv.URL}})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck // test connection
+
+	received := make(chan tracker.RequestLog, 1)
+	go func() {
+		var entry tracker.RequestLog
+		if err := conn.ReadJSON(&entry); err == nil {
+			received <- entry
+		}
+	}()
+
+	// The handler subscribes after the upgrade, so log until an entry comes through
+	deadline := time.After(2 * time.Second)
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case entry := <-received:
+			if entry.URI != "/streamed" || entry.Method != "GET" {
+				t.Errorf("Unexpected streamed entry: %+v", entry)
+			}
+			return
+		case <-tick.C:
+			tr.Log(tracker.RequestLog{Method: "GET", URI: "/streamed"})
+		case <-deadline:
+			t.Fatal("Timed out waiting for a streamed entry")
+		}
+	}
+}
+
+func TestHandleRequestStreamRejectsForeignOrigin(t *testing.T) {
+	s := NewServer(0, tracker.NewTracker(10))
+	srv := httptest.NewServer(http.HandlerFunc(s.handleRequestStream))
+	defer srv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {"http://evil.example.com"}})
+	if err == nil {
+		t.Fatal("Expected a connection from another origin to be rejected")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %v", resp)
+	}
+}
@@ -3,14 +3,24 @@ package validator
 import (
 	"encoding/json"
 	"fmt"
+	"mime"
+	"net/http"
+	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/comfortablynumb/pmp-mock-http/internal/matcher"
 	"github.com/comfortablynumb/pmp-mock-http/internal/models"
 	"github.com/dop251/goja"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/xeipuuv/gojsonschema"
 )
 
+// scriptTimeout is how long a JavaScript matcher may run while being validated
+const scriptTimeout = time.Second
+
 // ValidationResult represents the result of mock validation
 type ValidationResult struct {
 	Valid   bool
@@ -47,6 +57,23 @@ func (v *Validator) ValidateMocks(mocks []models.Mock) *ValidationResult {
 			nameCount[mock.Name]++
 		}
 
+		// Validate log level
+		switch strings.ToLower(mock.LogLevel) {
+		case "", "silent", "info", "debug":
+		default:
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid log_level '%s' (must be: silent, info or debug)", mockPrefix, mock.LogLevel))
+		}
+
+		// Fallback mocks only ever see page navigations
+		if mock.Fallback && !mock.Request.IsRegex.Method {
+			switch strings.ToUpper(mock.Request.Method) {
+			case "", http.MethodGet, http.MethodHead:
+			default:
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s: fallback mock with method '%s' never matches (fallbacks only match GET and HEAD requests accepting text/html)", mockPrefix, mock.Request.Method))
+			}
+		}
+
 		// Validate request patterns
 		v.validateRequest(&mock.Request, mockPrefix, result)
 
@@ -81,6 +108,24 @@ func (v *Validator) validateRequest(req *models.Request, prefix string, result *
 		}
 	}
 
+	if req.IsRegex.Host && req.Host != "" {
+		if _, err := regexp.Compile(req.Host); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid Host regex: %v", prefix, err))
+		}
+	}
+
+	if req.ClientCert != nil && req.ClientCert.Regex {
+		if _, err := regexp.Compile(req.ClientCert.CommonName); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid client_cert common_name regex: %v", prefix, err))
+		}
+		if _, err := regexp.Compile(req.ClientCert.Issuer); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid client_cert issuer regex: %v", prefix, err))
+		}
+	}
+
 	if req.IsRegex.Body && req.Body != "" {
 		if _, err := regexp.Compile(req.Body); err != nil {
 			result.Valid = false
@@ -101,6 +146,59 @@ func (v *Validator) validateRequest(req *models.Request, prefix string, result *
 		}
 	}
 
+	// Validate query parameter matchers
+	for j, qm := range req.QueryParams {
+		if qm.Key == "" {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: query_params[%d] has empty key", prefix, j))
+			continue
+		}
+		if req.IsRegex.QueryParams {
+			if _, err := regexp.Compile(qm.Key); err != nil {
+				result.Valid = false
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid query parameter key regex '%s': %v", prefix, qm.Key, err))
+			}
+			if _, err := regexp.Compile(qm.Value); err != nil {
+				result.Valid = false
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid query parameter value regex for '%s': %v", prefix, qm.Key, err))
+			}
+		}
+	}
+
+	// Validate form field matchers
+	for j, fm := range req.FormParams {
+		if fm.Key == "" {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: form_params[%d] has empty key", prefix, j))
+		}
+		if fm.Regex {
+			if _, err := regexp.Compile(fm.Value); err != nil {
+				result.Valid = false
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: form_params[%d] invalid regex: %v", prefix, j, err))
+			}
+		}
+	}
+
+	// Validate multipart matchers
+	for j, mm := range req.Multipart {
+		if mm.Name == "" {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: multipart[%d] has empty name", prefix, j))
+		}
+		if mm.Regex {
+			if _, err := regexp.Compile(mm.Value); err != nil {
+				result.Valid = false
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: multipart[%d] invalid value regex: %v", prefix, j, err))
+			}
+		}
+		if mm.Filename != "" {
+			if _, err := regexp.Compile(mm.Filename); err != nil {
+				result.Valid = false
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: multipart[%d] invalid filename regex: %v", prefix, j, err))
+			}
+		}
+	}
+
 	// Validate JSON path matchers
 	for j, matcher := range req.JSONPath {
 		if matcher.Path == "" {
@@ -113,6 +211,20 @@ func (v *Validator) validateRequest(req *models.Request, prefix string, result *
 				result.Errors = append(result.Errors, fmt.Sprintf("%s: json_path[%d] invalid regex: %v", prefix, j, err))
 			}
 		}
+		switch strings.ToLower(matcher.Operator) {
+		case "", "eq", "ne":
+		case "gt", "gte", "lt", "lte":
+			if _, err := strconv.ParseFloat(matcher.Value, 64); err != nil {
+				result.Valid = false
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: json_path[%d] operator '%s' requires a numeric value, got '%s'", prefix, j, matcher.Operator, matcher.Value))
+			}
+			if matcher.Regex {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s: json_path[%d] regex is ignored with operator '%s'", prefix, j, matcher.Operator))
+			}
+		default:
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: json_path[%d] invalid operator '%s' (must be: eq, ne, gt, gte, lt or lte)", prefix, j, matcher.Operator))
+		}
 	}
 
 	// Validate JavaScript
@@ -138,13 +250,28 @@ func (v *Validator) validateRequest(req *models.Request, prefix string, result *
 			result.Errors = append(result.Errors, fmt.Sprintf("%s: failed to set request object: %v", prefix, err))
 		}
 
-		// Now validate the JavaScript code
-		if _, err := vm.RunString(req.JavaScript); err != nil {
+		// Now validate the JavaScript code. Scripts that never finish (e.g. an infinite loop)
+		// are interrupted so they can't hang the validation.
+		timer := time.AfterFunc(scriptTimeout, func() { vm.Interrupt("timeout") })
+		_, err := vm.RunString(req.JavaScript)
+		timer.Stop()
+		if err != nil {
 			result.Valid = false
 			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid JavaScript: %v", prefix, err))
 		}
 	}
 
+	// Validate CEL
+	if req.CEL != "" {
+		if _, err := matcher.CompileCEL(req.CEL); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid CEL expression: %v", prefix, err))
+		}
+		if req.JavaScript != "" {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: cel is ignored when javascript is set", prefix))
+		}
+	}
+
 	// Validate JSON schema
 	if len(req.ValidateSchema) > 0 {
 		schemaJSON, err := json.Marshal(req.ValidateSchema)
@@ -159,6 +286,92 @@ func (v *Validator) validateRequest(req *models.Request, prefix string, result *
 			}
 		}
 	}
+	// Validate JWT configuration
+	if req.JWT != nil {
+		sources := 0
+		for _, source := range []string{req.JWT.PublicKey, req.JWT.JWKSURL, req.JWT.Secret} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: jwt requires exactly one of public_key, jwks_url or secret", prefix))
+		}
+
+		if req.JWT.PublicKey != "" {
+			if _, err := jwt.ParseRSAPublicKeyFromPEM([]byte(req.JWT.PublicKey)); err != nil {
+				if _, err := jwt.ParseECPublicKeyFromPEM([]byte(req.JWT.PublicKey)); err != nil {
+					result.Valid = false
+					result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid jwt public_key: must be a PEM-encoded RSA or ECDSA public key", prefix))
+				}
+			}
+		}
+	}
+
+	// Validate the auth gate
+	if req.Auth != nil {
+		switch strings.ToLower(req.Auth.Type) {
+		case matcher.AuthBasic:
+			if req.Auth.Username == "" {
+				result.Valid = false
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: basic auth requires a username", prefix))
+			}
+		case matcher.AuthBearer:
+			if req.Auth.Token == "" {
+				result.Valid = false
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: bearer auth requires a token", prefix))
+			}
+		default:
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid auth type '%s' (must be: basic or bearer)", prefix, req.Auth.Type))
+		}
+		if req.JWT != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: auth and jwt both check the Authorization header", prefix))
+		}
+	}
+
+	// Validate the body match mode
+	switch req.BodyMatchMode {
+	case "", models.BodyMatchExact, models.BodyMatchJSON, models.BodyMatchJSONIgnoreOrder:
+	default:
+		result.Valid = false
+		result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid body_match_mode '%s' (must be: %s, %s or %s)", prefix, req.BodyMatchMode, models.BodyMatchExact, models.BodyMatchJSON, models.BodyMatchJSONIgnoreOrder))
+	}
+	if req.CanonicalJSON && req.BodyMatchMode != "" {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%s: body_match_mode is set, canonical_json is ignored", prefix))
+	}
+
+	// Validate JSON body matching
+	jsonMode := req.BodyMatchMode == models.BodyMatchJSON || req.BodyMatchMode == models.BodyMatchJSONIgnoreOrder ||
+		(req.BodyMatchMode == "" && req.CanonicalJSON)
+	if jsonMode {
+		if req.IsRegex.Body {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: body is compared as JSON, regex.body is ignored", prefix))
+		}
+		if req.Body != "" && !json.Valid([]byte(req.Body)) {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: JSON body matching requires the body to be valid JSON", prefix))
+		}
+	}
+
+	// Validate produced media types
+	for i, produced := range req.Produces {
+		mediaType, _, err := mime.ParseMediaType(produced)
+		if err != nil || !strings.Contains(mediaType, "/") || strings.Contains(mediaType, "*") {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid produces[%d] %q: must be a concrete media type like application/json", prefix, i, produced))
+		}
+	}
+
+	// Validate negated conditions
+	if req.Not != nil {
+		notPrefix := prefix + " not"
+		if req.Not.JavaScript != "" || req.Not.JWT != nil || req.Not.Auth != nil || len(req.Not.Produces) > 0 {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: javascript, jwt, auth and produces are ignored inside not", notPrefix))
+		}
+		v.validateRequest(req.Not, notPrefix, result)
+	}
 }
 
 // validateResponse validates response configuration
@@ -168,13 +381,92 @@ func (v *Validator) validateResponse(resp *models.Response, prefix string, resul
 		result.Warnings = append(result.Warnings, fmt.Sprintf("%s: unusual status code %d", prefix, resp.StatusCode))
 	}
 
+	// Validate Expect: 100-continue handling
+	if resp.Expect100 != nil {
+		switch strings.ToLower(resp.Expect100.Action) {
+		case "", "continue", "withhold", "reject":
+		default:
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid expect_100 action '%s' (must be: continue, withhold or reject)", prefix, resp.Expect100.Action))
+		}
+		if resp.Expect100.Delay < 0 {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: expect_100 delay must be >= 0", prefix))
+		}
+	}
+
+	// Validate streaming
+	if resp.Stream != nil {
+		if resp.Stream.ChunkSize < 0 {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: stream chunk_size must be >= 0", prefix))
+		}
+		if resp.Stream.ChunkDelayMs < 0 {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: stream chunk_delay_ms must be >= 0", prefix))
+		}
+		if resp.FakeContentLength > 0 {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: stream is ignored with fake_content_length", prefix))
+		}
+	}
+
+	// Validate cookies
+	for i, cookie := range resp.Cookies {
+		if cookie.Name == "" {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: cookie %d requires a name", prefix, i))
+		}
+		switch strings.ToLower(cookie.SameSite) {
+		case "", "lax", "strict":
+		case "none":
+			if !cookie.Secure {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s: cookie '%s' with same_site none should be secure, browsers reject it otherwise", prefix, cookie.Name))
+			}
+		default:
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: cookie '%s' has an invalid same_site '%s' (must be: lax, strict or none)", prefix, cookie.Name, cookie.SameSite))
+		}
+	}
+
+	// Validate body file
+	if resp.BodyFile != "" {
+		if info, err := os.Stat(resp.BodyFile); err != nil || info.IsDir() {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: body_file '%s' is not a readable file", prefix, resp.BodyFile))
+		}
+		if resp.Body != "" {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: body is ignored with body_file", prefix))
+		}
+		if resp.Template || resp.Stream != nil || resp.FakeContentLength > 0 {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: template, stream and fake_content_length are ignored with body_file", prefix))
+		}
+	}
+
+	// Validate throttling
+	if resp.ThrottleBytesPerSec < 0 {
+		result.Valid = false
+		result.Errors = append(result.Errors, fmt.Sprintf("%s: throttle_bytes_per_sec must be >= 0", prefix))
+	}
+	if resp.ThrottleBytesPerSec > 0 && resp.FakeContentLength > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%s: throttle_bytes_per_sec is ignored with fake_content_length", prefix))
+	}
+
+	// Validate template delimiters
+	if (resp.TemplateDelims[0] == "") != (resp.TemplateDelims[1] == "") {
+		result.Valid = false
+		result.Errors = append(result.Errors, fmt.Sprintf("%s: template_delims requires both a left and a right delimiter", prefix))
+	}
+
 	// Validate chaos configuration
 	if resp.Chaos != nil && resp.Chaos.Enabled {
 		if resp.Chaos.FailureRate < 0 || resp.Chaos.FailureRate > 1 {
 			result.Valid = false
 			result.Errors = append(result.Errors, fmt.Sprintf("%s: chaos failure_rate must be between 0 and 1", prefix))
 		}
-		if len(resp.Chaos.ErrorCodes) == 0 {
+		if resp.Chaos.ConnectionReset < 0 || resp.Chaos.ConnectionReset > 1 {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: chaos connection_reset must be between 0 and 1", prefix))
+		}
+		if len(resp.Chaos.ErrorCodes) == 0 && resp.Chaos.ConnectionReset == 0 {
 			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: chaos enabled but no error_codes specified", prefix))
 		}
 		for _, code := range resp.Chaos.ErrorCodes {
@@ -241,6 +533,47 @@ func (v *Validator) validateResponse(resp *models.Response, prefix string, resul
 		v.validateResponse(&itemResp, itemPrefix, result)
 	}
 
+	// Validate count thresholds
+	seenThresholds := make(map[int]bool)
+	for j, threshold := range resp.AfterCount {
+		itemPrefix := fmt.Sprintf("%s after_count[%d]", prefix, j)
+		if threshold.After <= 0 {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: after must be > 0, got %d", itemPrefix, threshold.After))
+		}
+		if seenThresholds[threshold.After] {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: duplicate threshold %d, only the first one is used", itemPrefix, threshold.After))
+		}
+		seenThresholds[threshold.After] = true
+		itemResp := threshold.Response
+		if itemResp.StatusCode == 0 {
+			itemResp.StatusCode = 200
+		}
+		v.validateResponse(&itemResp, itemPrefix, result)
+	}
+
+	// Validate probabilistic responses
+	if len(resp.Probabilistic) > 0 {
+		total := 0.0
+		for j, weighted := range resp.Probabilistic {
+			itemPrefix := fmt.Sprintf("%s probabilistic[%d]", prefix, j)
+			if weighted.Probability < 0 {
+				result.Valid = false
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: probability must be >= 0", itemPrefix))
+			}
+			total += weighted.Probability
+			itemResp := weighted.Response
+			if itemResp.StatusCode == 0 {
+				itemResp.StatusCode = 200
+			}
+			v.validateResponse(&itemResp, itemPrefix, result)
+		}
+		if total <= 0 {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: probabilistic responses must have a total probability > 0", prefix))
+		}
+	}
+
 	// Validate sequence mode
 	if len(resp.Sequence) > 0 && resp.SequenceMode != "" {
 		mode := strings.ToLower(resp.SequenceMode)
@@ -249,6 +582,44 @@ func (v *Validator) validateResponse(resp *models.Response, prefix string, resul
 			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid sequence_mode '%s' (must be: cycle or once)", prefix, resp.SequenceMode))
 		}
 	}
+
+	// Validate sequence advance condition
+	if resp.SequenceAdvanceOn != nil {
+		if len(resp.Sequence) == 0 {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: sequence_advance_on has no effect without a sequence", prefix))
+		}
+		if resp.SequenceAdvanceOn.Header == "" && resp.SequenceAdvanceOn.Every == 0 {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: sequence_advance_on requires a header or every", prefix))
+		}
+		if resp.SequenceAdvanceOn.Every < 0 {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: sequence_advance_on.every must be positive, got %d", prefix, resp.SequenceAdvanceOn.Every))
+		}
+		if resp.SequenceAdvanceOn.Value != "" && resp.SequenceAdvanceOn.Header == "" {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: sequence_advance_on.value is ignored without a header", prefix))
+		}
+	}
+
+	// Validate sequence scope
+	if len(resp.Sequence) > 0 && resp.SequenceScope != "" {
+		scope := strings.ToLower(resp.SequenceScope)
+		if scope != "global" && scope != "client" {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid sequence_scope '%s' (must be: global or client)", prefix, resp.SequenceScope))
+		}
+	}
+
+	if resp.SequenceClientKey != "" {
+		key := strings.ToLower(resp.SequenceClientKey)
+		valid := key == "ip" ||
+			(strings.HasPrefix(key, "header:") && len(key) > len("header:")) ||
+			(strings.HasPrefix(key, "cookie:") && len(key) > len("cookie:"))
+		if !valid {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid sequence_client_key '%s' (must be: ip, header:<name> or cookie:<name>)", prefix, resp.SequenceClientKey))
+		}
+	}
 }
 
 // PrintValidationResult prints validation results in a user-friendly format
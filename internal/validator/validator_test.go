@@ -98,6 +98,125 @@ func TestValidateInvalidJavaScript(t *testing.T) {
 	}
 }
 
+func TestValidateSlowJavaScript(t *testing.T) {
+	validator := NewValidator()
+
+	mocks := []models.Mock{
+		{
+			Name:     "Infinite Loop",
+			Request:  models.Request{URI: "/test", JavaScript: "while (true) {}"},
+			Response: models.Response{StatusCode: 200},
+		},
+	}
+
+	result := validator.ValidateMocks(mocks)
+	if result.Valid {
+		t.Error("Expected validation to fail for a script that never finishes")
+	}
+}
+
+func TestValidateCEL(t *testing.T) {
+	validator := NewValidator()
+
+	tests := []struct {
+		name  string
+		expr  string
+		valid bool
+	}{
+		{"Valid expression", `method == "POST" && json.user.age >= 18`, true},
+		{"Syntax error", `method == `, false},
+		{"Unknown variable", `request.method == "GET"`, false},
+		{"Non-boolean result", `size(body)`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mocks := []models.Mock{
+				{
+					Name:     tt.name,
+					Request:  models.Request{URI: "/test", CEL: tt.expr},
+					Response: models.Response{StatusCode: 200},
+				},
+			}
+
+			result := validator.ValidateMocks(mocks)
+			if result.Valid != tt.valid {
+				t.Errorf("Expected valid=%v, got %v (errors: %v)", tt.valid, result.Valid, result.Errors)
+			}
+		})
+	}
+}
+
+func TestValidateAuth(t *testing.T) {
+	validator := NewValidator()
+
+	tests := []struct {
+		name  string
+		auth  models.AuthConfig
+		valid bool
+	}{
+		{"Basic", models.AuthConfig{Type: "basic", Username: "admin", Password: "secret"}, true},
+		{"Basic without username", models.AuthConfig{Type: "basic", Password: "secret"}, false},
+		{"Bearer", models.AuthConfig{Type: "Bearer", Token: "abc"}, true},
+		{"Bearer without token", models.AuthConfig{Type: "bearer"}, false},
+		{"Unknown type", models.AuthConfig{Type: "digest"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := tt.auth
+			mocks := []models.Mock{
+				{
+					Name:     tt.name,
+					Request:  models.Request{URI: "/test", Auth: &auth},
+					Response: models.Response{StatusCode: 200},
+				},
+			}
+
+			result := validator.ValidateMocks(mocks)
+			if result.Valid != tt.valid {
+				t.Errorf("Expected valid=%v, got %v (errors: %v)", tt.valid, result.Valid, result.Errors)
+			}
+		})
+	}
+}
+
+func TestValidateCookies(t *testing.T) {
+	validator := NewValidator()
+
+	tests := []struct {
+		name            string
+		cookie          models.ResponseCookie
+		valid           bool
+		expectedWarning bool
+	}{
+		{"Valid", models.ResponseCookie{Name: "session", Value: "abc", SameSite: "Lax"}, true, false},
+		{"Missing name", models.ResponseCookie{Value: "abc"}, false, false},
+		{"Invalid same_site", models.ResponseCookie{Name: "session", SameSite: "loose"}, false, false},
+		{"Insecure same_site none", models.ResponseCookie{Name: "session", SameSite: "none"}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mocks := []models.Mock{
+				{
+					Name:     tt.name,
+					Request:  models.Request{URI: "/test"},
+					Response: models.Response{StatusCode: 200, Cookies: []models.ResponseCookie{tt.cookie}},
+				},
+			}
+
+			result := validator.ValidateMocks(mocks)
+			if result.Valid != tt.valid {
+				t.Errorf("Expected valid=%v, got %v (errors: %v)", tt.valid, result.Valid, result.Errors)
+			}
+			if hasWarning := len(result.Warnings) > 0; hasWarning != tt.expectedWarning {
+				t.Errorf("Expected warning=%v, got %v", tt.expectedWarning, result.Warnings)
+			}
+		})
+	}
+}
+
 func TestValidateChaosConfig(t *testing.T) {
 	validator := NewValidator()
 
@@ -0,0 +1,70 @@
+package watcher
+
+import (
+	"log"
+	"sync"
+)
+
+// Group keeps a watcher per directory for a set of directories that can change while
+// running, like the mock directories of plugins that appear or go away on refresh
+type Group struct {
+	reloadFn func() error
+	opts     Options
+	watchers map[string]*Watcher
+	mu       sync.Mutex
+}
+
+// NewGroup creates an empty group whose watchers call reloadFn on changes
+func NewGroup(reloadFn func() error, opts Options) *Group {
+	return &Group{
+		reloadFn: reloadFn,
+		opts:     opts,
+		watchers: make(map[string]*Watcher),
+	}
+}
+
+// SetDirectories starts watchers for the directories that aren't watched yet and closes
+// the watchers of the directories that aren't listed anymore. Directories that can't be
+// watched are logged and retried on the next call. Returns the number of watched directories.
+func (g *Group) SetDirectories(dirs ...string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	listed := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		listed[dir] = true
+		if _, exists := g.watchers[dir]; exists {
+			continue
+		}
+
+		w, err := NewWatcherWithOptions(dir, g.reloadFn, g.opts)
+		if err != nil {
+			log.Printf("Warning: failed to create watcher for %s: %v\n", dir, err)
+			continue
+		}
+		if err := w.Start(); err != nil {
+			log.Printf("Warning: failed to start watcher for %s: %v\n", dir, err)
+			w.Close() //nolint:errcheck // cleanup operation
+			continue
+		}
+		g.watchers[dir] = w
+	}
+
+	for dir, w := range g.watchers {
+		if listed[dir] {
+			continue
+		}
+		if err := w.Close(); err != nil {
+			log.Printf("Warning: failed to close watcher for %s: %v\n", dir, err)
+		}
+		delete(g.watchers, dir)
+		log.Printf("Stopped watching %s\n", dir)
+	}
+
+	return len(g.watchers)
+}
+
+// Close stops all watchers of the group
+func (g *Group) Close() {
+	g.SetDirectories()
+}
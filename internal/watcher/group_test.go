@@ -0,0 +1,63 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupSetDirectories(t *testing.T) {
+	mocksDir := t.TempDir()
+	pluginDir := t.TempDir()
+
+	var reloads int32
+	group := NewGroup(func() error {
+		atomic.AddInt32(&reloads, 1)
+		return nil
+	}, testOptions)
+	defer group.Close()
+
+	// writeMock writes a mock file and reports whether it triggered a reload
+	writeMock := func(dir, name string) bool {
+		t.Helper()
+		before := atomic.LoadInt32(&reloads)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("mocks:\n  - name: test\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+		return atomic.LoadInt32(&reloads) > before
+	}
+
+	if watched := group.SetDirectories(mocksDir); watched != 1 {
+		t.Fatalf("Expected 1 watched directory, got %d", watched)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if writeMock(pluginDir, "before.yaml") {
+		t.Error("Expected no reload for a directory that isn't watched yet")
+	}
+
+	// A directory added later is watched without restarting the others
+	if watched := group.SetDirectories(mocksDir, pluginDir); watched != 2 {
+		t.Fatalf("Expected 2 watched directories, got %d", watched)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !writeMock(pluginDir, "added.yaml") {
+		t.Error("Expected a reload for a change in the added directory")
+	}
+	if !writeMock(mocksDir, "kept.yaml") {
+		t.Error("Expected a reload for a change in the kept directory")
+	}
+
+	// A directory that's no longer listed stops being watched
+	if watched := group.SetDirectories(mocksDir); watched != 1 {
+		t.Fatalf("Expected 1 watched directory, got %d", watched)
+	}
+	if writeMock(pluginDir, "removed.yaml") {
+		t.Error("Expected no reload for a directory that isn't watched anymore")
+	}
+	if !writeMock(mocksDir, "still-watched.yaml") {
+		t.Error("Expected a reload for a change in the still watched directory")
+	}
+}
@@ -8,27 +8,69 @@ import (
 	"strings"
 	"time"
 
+	"github.com/comfortablynumb/pmp-mock-http/internal/loader"
 	"github.com/fsnotify/fsnotify"
 )
 
+// DefaultDebounce is how long the watcher waits after the last change before reloading
+const DefaultDebounce = 100 * time.Millisecond
+
+// DefaultExcludes match editor backup, swap and lock files and dotfiles (and dot directories like .git)
+var DefaultExcludes = []string{".*", "*~", "#*#", "*.swp", "*.swx", "*.tmp", "*___jb_tmp___", "*___jb_old___"}
+
 // Watcher monitors the mocks directory for changes
 type Watcher struct {
 	mocksDir string
 	watcher  *fsnotify.Watcher
 	reloadFn func() error
+	debounce time.Duration
+	excludes []string
+	// JSON files that were mock files when last seen, so removing one (or its "mocks" key) reloads
+	jsonMocks map[string]bool
+}
+
+// Options configures a watcher
+type Options struct {
+	Debounce time.Duration // Quiet period after the last change before reloading (0 = DefaultDebounce)
+	// Glob patterns (as in filepath.Match) of file and directory names that never trigger a reload.
+	// nil = DefaultExcludes; an empty list excludes nothing.
+	Exclude []string
 }
 
-// NewWatcher creates a new file watcher
+// NewWatcher creates a new file watcher with the default options
 func NewWatcher(mocksDir string, reloadFn func() error) (*Watcher, error) {
+	return NewWatcherWithOptions(mocksDir, reloadFn, Options{})
+}
+
+// NewWatcherWithOptions creates a new file watcher with custom options
+func NewWatcherWithOptions(mocksDir string, reloadFn func() error, opts Options) (*Watcher, error) {
+	excludes := opts.Exclude
+	if excludes == nil {
+		excludes = DefaultExcludes
+	}
+	for _, pattern := range excludes {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file watcher: %w", err)
 	}
 
+	debounce := opts.Debounce
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+
 	w := &Watcher{
-		mocksDir: mocksDir,
-		watcher:  watcher,
-		reloadFn: reloadFn,
+		mocksDir:  mocksDir,
+		watcher:   watcher,
+		reloadFn:  reloadFn,
+		debounce:  debounce,
+		excludes:  excludes,
+		jsonMocks: make(map[string]bool),
 	}
 
 	return w, nil
@@ -62,11 +104,16 @@ func (w *Watcher) addDirectories() error {
 			return err
 		}
 
-		// Only watch directories
+		// Only watch directories, skipping excluded ones like .git
 		if info.IsDir() {
+			if path != w.mocksDir && w.isExcluded(path) {
+				return filepath.SkipDir
+			}
 			if err := w.watcher.Add(path); err != nil {
 				return fmt.Errorf("failed to watch directory %s: %w", path, err)
 			}
+		} else if isJSONFile(path) && loader.IsMockFile(path) {
+			w.jsonMocks[path] = true
 		}
 
 		return nil
@@ -77,7 +124,7 @@ func (w *Watcher) addDirectories() error {
 func (w *Watcher) watch() {
 	// Debounce timer to avoid multiple reloads for rapid changes
 	var debounceTimer *time.Timer
-	debounceDuration := 100 * time.Millisecond
+	debounceDuration := w.debounce
 
 	for {
 		select {
@@ -86,12 +133,16 @@ func (w *Watcher) watch() {
 				return
 			}
 
-			// Check if it's a YAML file or a directory
-			isYAML := isYAMLFile(event.Name)
+			if w.isExcluded(event.Name) {
+				continue
+			}
+
+			// Check if it's a mock file or a directory
+			isMock := w.isMockFile(event.Name, event.Op&fsnotify.Remove == fsnotify.Remove)
 			isDir := isDirectory(event.Name)
 
 			// Handle different event types
-			if event.Op&fsnotify.Write == fsnotify.Write && isYAML {
+			if event.Op&fsnotify.Write == fsnotify.Write && isMock {
 				log.Printf("Modified file: %s\n", event.Name)
 				w.scheduleReload(&debounceTimer, debounceDuration)
 			} else if event.Op&fsnotify.Create == fsnotify.Create {
@@ -102,12 +153,12 @@ func (w *Watcher) watch() {
 						log.Printf("Failed to watch new directory %s: %v\n", event.Name, err)
 					}
 					w.scheduleReload(&debounceTimer, debounceDuration)
-				} else if isYAML {
+				} else if isMock {
 					log.Printf("New file: %s\n", event.Name)
 					w.scheduleReload(&debounceTimer, debounceDuration)
 				}
 			} else if event.Op&fsnotify.Remove == fsnotify.Remove {
-				if isYAML {
+				if isMock {
 					log.Printf("Deleted file: %s\n", event.Name)
 					w.scheduleReload(&debounceTimer, debounceDuration)
 				} else if isDir {
@@ -149,10 +200,46 @@ func (w *Watcher) Close() error {
 	return w.watcher.Close()
 }
 
-// isYAMLFile checks if a file has a YAML extension
-func isYAMLFile(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	return ext == ".yaml" || ext == ".yml"
+// isExcluded checks if the path, or a directory it's in below the mocks directory, matches an exclude pattern
+func (w *Watcher) isExcluded(path string) bool {
+	rel, err := filepath.Rel(w.mocksDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(path)
+	}
+	if rel == "." {
+		return false
+	}
+
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		for _, pattern := range w.excludes {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isMockFile checks if a changed file is, or was, a mock file. Removed JSON files can't be
+// read anymore, so they count as mock files if they were one when last seen.
+func (w *Watcher) isMockFile(path string, removed bool) bool {
+	if !isJSONFile(path) {
+		return loader.IsMockFile(path)
+	}
+
+	wasMock := w.jsonMocks[path]
+	isMock := !removed && loader.IsMockFile(path)
+	if isMock {
+		w.jsonMocks[path] = true
+	} else {
+		delete(w.jsonMocks, path)
+	}
+	return isMock || wasMock
+}
+
+// isJSONFile checks if a file has a JSON extension
+func isJSONFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
 }
 
 // isDirectory checks if a path is a directory
@@ -1,6 +1,7 @@
 package watcher
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync/atomic"
@@ -8,6 +9,9 @@ import (
 	"time"
 )
 
+// testOptions keep the debounce short so reloads happen well within the tests' waits
+var testOptions = Options{Debounce: 20 * time.Millisecond}
+
 func TestNewWatcher(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "watcher-test-*")
 	if err != nil {
@@ -31,6 +35,9 @@ func TestNewWatcher(t *testing.T) {
 	if w.mocksDir != tempDir {
 		t.Errorf("Expected mocksDir %s, got %s", tempDir, w.mocksDir)
 	}
+	if w.debounce != DefaultDebounce {
+		t.Errorf("Expected the default debounce %v, got %v", DefaultDebounce, w.debounce)
+	}
 }
 
 func TestWatcherStart(t *testing.T) {
@@ -102,6 +109,82 @@ func TestWatcherFileCreate(t *testing.T) {
 	}
 }
 
+func TestWatcherJSONFile(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var reloadCalled int32
+	reloadFn := func() error {
+		atomic.StoreInt32(&reloadCalled, 1)
+		return nil
+	}
+
+	w, err := NewWatcherWithOptions(tempDir, reloadFn, testOptions)
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.Close() //nolint:errcheck // test cleanup
+
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := os.WriteFile(filepath.Join(tempDir, "test.json"), []byte(`{"mocks": []}`), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if atomic.LoadInt32(&reloadCalled) == 0 {
+		t.Error("Expected reload to be called after creating a JSON mock file")
+	}
+}
+
+func TestWatcherJSONFixtures(t *testing.T) {
+	tempDir := t.TempDir()
+	mockFile := filepath.Join(tempDir, "mocks.json")
+	if err := os.WriteFile(mockFile, []byte(`{"mocks": []}`), 0644); err != nil {
+		t.Fatalf("Failed to write mock file: %v", err)
+	}
+
+	var reloadCount int32
+	reloadFn := func() error {
+		atomic.AddInt32(&reloadCount, 1)
+		return nil
+	}
+
+	w, err := NewWatcherWithOptions(tempDir, reloadFn, testOptions)
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.Close() //nolint:errcheck // test cleanup
+
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// JSON files without a top-level "mocks" key, like body_file fixtures, don't reload
+	if err := os.WriteFile(filepath.Join(tempDir, "body.json"), []byte(`{"id": 1}`), 0644); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if count := atomic.LoadInt32(&reloadCount); count != 0 {
+		t.Errorf("Expected no reload after writing a JSON fixture, got %d", count)
+	}
+
+	// Removing a JSON mock file that existed on start does
+	if err := os.Remove(mockFile); err != nil {
+		t.Fatalf("Failed to remove mock file: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&reloadCount) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if atomic.LoadInt32(&reloadCount) == 0 {
+		t.Error("Expected reload to be called after removing a JSON mock file")
+	}
+}
+
 func TestWatcherFileModify(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "watcher-test-*")
 	if err != nil {
@@ -439,3 +522,175 @@ func TestWatcherDebounce(t *testing.T) {
 	// and handles rapid file changes gracefully
 	time.Sleep(300 * time.Millisecond)
 }
+
+func TestWatcherExcludedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var reloadCount int32
+	reloadFn := func() error {
+		atomic.AddInt32(&reloadCount, 1)
+		return nil
+	}
+
+	w, err := NewWatcherWithOptions(tempDir, reloadFn, testOptions)
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.Close() //nolint:errcheck // test cleanup
+
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Editor lock, swap and backup files, including a rename like editors do when saving
+	for _, name := range []string{".#test.yaml", ".test.yaml.swp", "test.yaml~", "#test.yaml#"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("mocks: []\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := os.Rename(filepath.Join(tempDir, "test.yaml~"), filepath.Join(tempDir, "test.yaml.tmp")); err != nil {
+		t.Fatalf("Failed to rename: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if count := atomic.LoadInt32(&reloadCount); count != 0 {
+		t.Errorf("Expected no reload for excluded files, got %d", count)
+	}
+
+	// Regular mock files still trigger a reload
+	if err := os.WriteFile(filepath.Join(tempDir, "test.yaml"), []byte("mocks: []\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if atomic.LoadInt32(&reloadCount) == 0 {
+		t.Error("Expected reload to be called for a regular mock file")
+	}
+}
+
+func TestWatcherCustomExcludes(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Excluded directories aren't watched
+	if err := os.MkdirAll(filepath.Join(tempDir, "drafts"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	var reloadCount int32
+	reloadFn := func() error {
+		atomic.AddInt32(&reloadCount, 1)
+		return nil
+	}
+
+	w, err := NewWatcherWithOptions(tempDir, reloadFn, Options{Debounce: testOptions.Debounce, Exclude: []string{"drafts", "*.draft.yaml"}})
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.Close() //nolint:errcheck // test cleanup
+
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	for _, path := range []string{filepath.Join("drafts", "test.yaml"), "test.draft.yaml"} {
+		if err := os.WriteFile(filepath.Join(tempDir, path), []byte("mocks: []\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if count := atomic.LoadInt32(&reloadCount); count != 0 {
+		t.Errorf("Expected no reload for excluded files, got %d", count)
+	}
+
+	// Custom patterns replace the defaults
+	if err := os.WriteFile(filepath.Join(tempDir, ".hidden.yaml"), []byte("mocks: []\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if atomic.LoadInt32(&reloadCount) == 0 {
+		t.Error("Expected reload to be called for a file the custom patterns don't exclude")
+	}
+}
+
+func TestWatcherInvalidExclude(t *testing.T) {
+	if _, err := NewWatcherWithOptions(t.TempDir(), func() error { return nil }, Options{Exclude: []string{"[invalid"}}); err == nil {
+		t.Error("Expected an error for an invalid exclude pattern")
+	}
+}
+
+func TestNewWatcherWithOptions(t *testing.T) {
+	tempDir := t.TempDir()
+	reloadFn := func() error { return nil }
+
+	w, err := NewWatcherWithOptions(tempDir, reloadFn, Options{Debounce: 250 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.Close() //nolint:errcheck // test cleanup
+	if w.debounce != 250*time.Millisecond {
+		t.Errorf("Expected debounce 250ms, got %v", w.debounce)
+	}
+
+	defaults, err := NewWatcherWithOptions(tempDir, reloadFn, Options{})
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer defaults.Close() //nolint:errcheck // test cleanup
+	if defaults.debounce != DefaultDebounce {
+		t.Errorf("Expected a zero debounce to use the default %v, got %v", DefaultDebounce, defaults.debounce)
+	}
+}
+
+func TestWatcherCustomDebounce(t *testing.T) {
+	tempDir := t.TempDir()
+
+	const debounce = 300 * time.Millisecond
+	var reloadCount int32
+	firstReload := make(chan time.Time, 1)
+	reloadFn := func() error {
+		if atomic.AddInt32(&reloadCount, 1) == 1 {
+			firstReload <- time.Now()
+		}
+		return nil
+	}
+
+	w, err := NewWatcherWithOptions(tempDir, reloadFn, Options{Debounce: debounce})
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.Close() //nolint:errcheck // test cleanup
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// Rapid changes, much closer together than the debounce window
+	const writes = 5
+	testFile := filepath.Join(tempDir, "test.yaml")
+	var lastWrite time.Time
+	for i := 0; i < writes; i++ {
+		if err := os.WriteFile(testFile, []byte(fmt.Sprintf("mocks:\n  - name: test%d\n", i)), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		lastWrite = time.Now()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var reloadedAt time.Time
+	select {
+	case reloadedAt = <-firstReload:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the debounced reload")
+	}
+
+	// The reload waits for a quiet period after the last change, so it coalesces the writes
+	if waited := reloadedAt.Sub(lastWrite); waited < debounce {
+		t.Errorf("Expected the reload to wait at least %v after the last change, waited %v", debounce, waited)
+	}
+	if count := atomic.LoadInt32(&reloadCount); count >= writes {
+		t.Errorf("Expected %d rapid changes to be coalesced, got %d reloads", writes, count)
+	}
+}
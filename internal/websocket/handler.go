@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/comfortablynumb/pmp-mock-http/internal/models"
 	"github.com/comfortablynumb/pmp-mock-http/internal/template"
@@ -14,14 +16,6 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for mock server
-	},
-}
-
 // Handler manages WebSocket connections and message handling
 type Handler struct {
 	mock             *models.Mock
@@ -29,6 +23,7 @@ type Handler struct {
 	connections      map[*websocket.Conn]bool
 	mu               sync.RWMutex
 	broadcast        chan []byte
+	upgrader         websocket.Upgrader
 }
 
 // NewHandler creates a new WebSocket handler
@@ -40,6 +35,13 @@ func NewHandler(mock *models.Mock, templateRenderer *template.Renderer) *Handler
 		broadcast:        make(chan []byte, 256),
 	}
 
+	h.upgrader = websocket.Upgrader{
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		CheckOrigin:       h.checkOrigin,
+		EnableCompression: mock.WebSocket != nil && mock.WebSocket.EnableCompression,
+	}
+
 	// Start broadcast handler if in broadcast mode
 	if mock.WebSocket != nil && mock.WebSocket.Mode == "broadcast" {
 		go h.handleBroadcast()
@@ -63,8 +65,15 @@ func (h *Handler) HandleConnection(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Reject disallowed origins before upgrading
+	if !h.checkOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		log.Printf("WebSocket: Rejected connection from origin %s\n", r.Header.Get("Origin"))
+		return
+	}
+
 	// Upgrade HTTP connection to WebSocket
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v\n", err)
 		return
@@ -128,6 +137,27 @@ func (h *Handler) HandleConnection(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// checkOrigin checks the request Origin against the configured allowed origins.
+// All origins are allowed when none are configured, as are requests without an Origin header.
+func (h *Handler) checkOrigin(r *http.Request) bool {
+	if h.mock.WebSocket == nil || len(h.mock.WebSocket.AllowedOrigins) == 0 {
+		return true // Allow all origins for mock server
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	for _, allowed := range h.mock.WebSocket.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // handleEchoMode echoes received messages back to the client
 func (h *Handler) handleEchoMode(conn *websocket.Conn, requestData *template.RequestData) {
 	for {
@@ -165,30 +195,20 @@ func (h *Handler) handleSequenceMode(conn *websocket.Conn, requestData *template
 			time.Sleep(time.Duration(msg.Delay) * time.Millisecond)
 		}
 
-		// Render template if enabled
-		data := msg.Data
-		if msg.Template || h.mock.WebSocket.Template {
-			rendered, err := h.templateRenderer.Render(data, requestData)
-			if err != nil {
-				log.Printf("WebSocket: Error rendering message template: %v\n", err)
-			} else {
-				data = rendered
-			}
-		}
-
-		// Determine message type
-		msgType := websocket.TextMessage
-		if msg.Type == "binary" {
-			msgType = websocket.BinaryMessage
+		// Build the message payload
+		msgType, payload, err := h.buildSequenceMessage(msg, requestData)
+		if err != nil {
+			log.Printf("WebSocket: Error building message: %v\n", err)
+			continue
 		}
 
 		// Send message
-		if err := conn.WriteMessage(msgType, []byte(data)); err != nil {
+		if err := conn.WriteMessage(msgType, payload); err != nil {
 			log.Printf("WebSocket: Error sending message: %v\n", err)
 			return
 		}
 
-		log.Printf("WebSocket: Sent message (%s): %s\n", msg.Type, data)
+		log.Printf("WebSocket: Sent message (%s): %s\n", msg.Type, string(payload))
 		messagesSent++
 
 		// Check if we should close after this message
@@ -212,6 +232,75 @@ func (h *Handler) handleSequenceMode(conn *websocket.Conn, requestData *template
 	}
 }
 
+// buildSequenceMessage renders a sequence message and determines its frame type
+func (h *Handler) buildSequenceMessage(msg models.WebSocketMessage, requestData *template.RequestData) (int, []byte, error) {
+	useTemplate := msg.Template || h.mock.WebSocket.Template
+
+	// JSON messages with structured data are serialized at send time
+	if msg.Type == "json" && msg.DataJSON != nil {
+		value := msg.DataJSON
+		if useTemplate {
+			value = h.renderJSONValue(value, requestData)
+		}
+		payload, err := json.Marshal(value)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to serialize JSON message: %w", err)
+		}
+		return websocket.TextMessage, payload, nil
+	}
+
+	// Render template if enabled
+	data := msg.Data
+	if useTemplate {
+		rendered, err := h.templateRenderer.Render(data, requestData)
+		if err != nil {
+			log.Printf("WebSocket: Error rendering message template: %v\n", err)
+		} else {
+			data = rendered
+		}
+	}
+
+	// Determine message type
+	msgType := websocket.TextMessage
+	switch msg.Type {
+	case "binary":
+		msgType = websocket.BinaryMessage
+	case "auto":
+		if !utf8.ValidString(data) {
+			msgType = websocket.BinaryMessage
+		}
+	}
+
+	return msgType, []byte(data), nil
+}
+
+// renderJSONValue renders templates in all string values of a JSON structure
+func (h *Handler) renderJSONValue(value interface{}, requestData *template.RequestData) interface{} {
+	switch v := value.(type) {
+	case string:
+		rendered, err := h.templateRenderer.Render(v, requestData)
+		if err != nil {
+			log.Printf("WebSocket: Error rendering message template: %v\n", err)
+			return v
+		}
+		return rendered
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = h.renderJSONValue(item, requestData)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = h.renderJSONValue(item, requestData)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
 // handleBroadcastMode handles broadcast to all connected clients
 func (h *Handler) handleBroadcastMode(conn *websocket.Conn, requestData *template.RequestData) {
 	// Read messages and broadcast to all connections
@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/comfortablynumb/pmp-mock-http/internal/models"
+	"github.com/comfortablynumb/pmp-mock-http/internal/template"
+	"github.com/gorilla/websocket"
+	"gopkg.in/yaml.v3"
+)
+
+func TestHandlerAllowedOrigins(t *testing.T) {
+	mock := &models.Mock{
+		Name: "Origin Test",
+		WebSocket: &models.WebSocketConfig{
+			Mode:           "echo",
+			AllowedOrigins: []string{"https://app.example.com"},
+		},
+	}
+	handler := NewHandler(mock, template.NewRenderer())
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleConnection))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// Disallowed origin is rejected with 403
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": []string{"https://evil.example.com"}})
+	if err == nil {
+		t.Fatal("Expected dial with disallowed origin to fail")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected 403, got %v", resp)
+	}
+
+	// Allowed origin connects
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": []string{"https://app.example.com"}})
+	if err != nil {
+		t.Fatalf("Expected allowed origin to connect: %v", err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Errorf("Failed to close connection: %v", err)
+	}
+}
+
+func TestHandlerCompression(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		mock := &models.Mock{
+			Name: "Compression Test",
+			WebSocket: &models.WebSocketConfig{
+				Mode:              "echo",
+				EnableCompression: enabled,
+			},
+		}
+		handler := NewHandler(mock, template.NewRenderer())
+
+		server := httptest.NewServer(http.HandlerFunc(handler.HandleConnection))
+		url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+		dialer := websocket.Dialer{EnableCompression: true}
+		conn, resp, err := dialer.Dial(url, nil)
+		if err != nil {
+			server.Close()
+			t.Fatalf("Failed to connect: %v", err)
+		}
+
+		negotiated := strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+		if negotiated != enabled {
+			t.Errorf("enable_compression=%v: expected permessage-deflate negotiated=%v, got %v", enabled, enabled, negotiated)
+		}
+
+		// Compressed messages still round-trip
+		message := strings.Repeat("compressible ", 100)
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatalf("Failed to write message: %v", err)
+		}
+		_, echoed, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read message: %v", err)
+		}
+		if string(echoed) != message {
+			t.Errorf("enable_compression=%v: echoed message doesn't match", enabled)
+		}
+
+		conn.Close() //nolint:errcheck // test cleanup
+		server.Close()
+	}
+}
+
+func TestHandlerSequenceFrameTypes(t *testing.T) {
+	var config models.WebSocketConfig
+	err := yaml.Unmarshal([]byte(`
+mode: sequence
+messages:
+  - type: auto
+    data: "plain text"
+  - type: json
+    data:
+      event: "joined"
+      path: "{{.Path}}"
+      ids: [1, 2]
+    template: true
+  - type: json
+    data: '{"raw":true}'
+`), &config)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	// Invalid UTF-8 can't be written in YAML, so append it directly
+	config.Messages = append(config.Messages, models.WebSocketMessage{Type: "auto", Data: "\xff\xfe\x00"})
+
+	mock := &models.Mock{Name: "Sequence Frames Test", WebSocket: &config}
+	handler := NewHandler(mock, template.NewRenderer())
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleConnection))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/feed"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck // test cleanup
+
+	expected := []struct {
+		msgType int
+		payload string
+	}{
+		{websocket.TextMessage, "plain text"},
+		{websocket.TextMessage, `{"event":"joined","ids":[1,2],"path":"/feed"}`},
+		{websocket.TextMessage, `{"raw":true}`},
+		{websocket.BinaryMessage, "\xff\xfe\x00"},
+	}
+
+	for i, want := range expected {
+		msgType, payload, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Message %d: failed to read: %v", i, err)
+		}
+		if msgType != want.msgType {
+			t.Errorf("Message %d: expected frame type %d, got %d", i, want.msgType, msgType)
+		}
+		if string(payload) != want.payload {
+			t.Errorf("Message %d: expected payload %q, got %q", i, want.payload, payload)
+		}
+	}
+}